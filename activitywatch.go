@@ -0,0 +1,253 @@
+//go:build !localonly
+// +build !localonly
+
+package keylogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	Defaults of an ActivityWatch sink: the address of aw-server, how often
+	heartbeats are sent and how long without input counts as away, as in
+	aw-watcher-afk.
+*/
+const (
+	DefaultActivityWatchServer     = "http://localhost:5600"
+	DefaultActivityWatchInterval   = time.Second
+	DefaultActivityWatchAFKTimeout = 3 * time.Minute
+)
+
+/*
+	ActivityWatch is a Sink that reports the foreground window and whether
+	the user is away to an ActivityWatch server, in the buckets of
+	aw-watcher-window and aw-watcher-afk so that its dashboards show them.
+	It needs the FocusEvents of Logger.CaptureFocus, and the key and mouse
+	events to tell activity; injected input does not count. Only a server
+	on this machine is accepted: window titles do not leave it.
+
+	Heartbeats are sent every interval from a goroutine of its own, so
+	Write never waits for the server. Once a heartbeat fails, Writes
+	return its error until one gets through again, so a RetrySink in front
+	waits for the server; Writes repeated with the same events do no harm.
+*/
+type ActivityWatch struct {
+	server     string
+	host       string
+	interval   time.Duration
+	afkTimeout time.Duration
+	client     *http.Client
+
+	mu        sync.Mutex
+	app       string
+	title     string
+	lastInput time.Time
+	afk       bool
+	created   bool
+	err       error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+/*
+	NewActivityWatch returns a sink sending to the aw-server at server,
+	DefaultActivityWatchServer if empty. Zero durations mean the defaults.
+*/
+func NewActivityWatch(server string, interval, afkTimeout time.Duration) (*ActivityWatch, error) {
+	if server == "" {
+		server = DefaultActivityWatchServer
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("activitywatch: %s is not an http URL", server)
+	}
+	if !loopbackHost(u.Hostname()) {
+		return nil, fmt.Errorf("activitywatch: %s is not on this machine", server)
+	}
+	if interval <= 0 {
+		interval = DefaultActivityWatchInterval
+	}
+	if afkTimeout <= 0 {
+		afkTimeout = DefaultActivityWatchAFKTimeout
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	a := &ActivityWatch{
+		server:     strings.TrimSuffix(server, "/"),
+		host:       host,
+		interval:   interval,
+		afkTimeout: afkTimeout,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		lastInput:  time.Now(),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go a.run()
+	return a, nil
+}
+
+func (a *ActivityWatch) Write(events []Event) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, e := range events {
+		switch e := e.(type) {
+		case FocusEvent:
+			a.app, a.title = e.Process, e.Title
+		case KeyEvent:
+			if e.Source() != KeySourceInjected && e.Time.After(a.lastInput) {
+				a.lastInput = e.Time
+			}
+		case MouseEvent:
+			if !e.Injected() && e.Time.After(a.lastInput) {
+				a.lastInput = e.Time
+			}
+		}
+	}
+	return a.err
+}
+
+/*
+	Close stops the heartbeats.
+*/
+func (a *ActivityWatch) Close() error {
+	close(a.stop)
+	<-a.done
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+func (a *ActivityWatch) windowBucket() string {
+	return "aw-watcher-window_" + a.host
+}
+
+func (a *ActivityWatch) afkBucket() string {
+	return "aw-watcher-afk_" + a.host
+}
+
+func (a *ActivityWatch) run() {
+	defer close(a.done)
+	tick := time.NewTicker(a.interval)
+	defer tick.Stop()
+	for {
+		err := a.beat(time.Now())
+		a.mu.Lock()
+		a.err = err
+		a.mu.Unlock()
+		select {
+		case <-tick.C:
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+/*
+	Sends the heartbeats for now, creating the buckets first if that has
+	not worked yet.
+*/
+func (a *ActivityWatch) beat(now time.Time) error {
+	a.mu.Lock()
+	app, title, lastInput, wasAFK, created := a.app, a.title, a.lastInput, a.afk, a.created
+	a.mu.Unlock()
+	if !created {
+		if err := a.createBucket(a.windowBucket(), "currentwindow"); err != nil {
+			return err
+		}
+		if err := a.createBucket(a.afkBucket(), "afkstatus"); err != nil {
+			return err
+		}
+		a.mu.Lock()
+		a.created = true
+		a.mu.Unlock()
+	}
+	if app != "" {
+		data := map[string]string{"app": app, "title": title}
+		if err := a.heartbeat(a.windowBucket(), now, 0, data, a.interval+time.Second); err != nil {
+			return err
+		}
+	}
+	// As in aw-watcher-afk, the status is dated to the last input, an away
+	// one lasting from there, and a change first extends the earlier status
+	// up to the last input.
+	idle := now.Sub(lastInput)
+	afk := idle >= a.afkTimeout
+	pulse := a.afkTimeout + a.interval
+	if afk != wasAFK {
+		if err := a.heartbeat(a.afkBucket(), lastInput, 0, afkStatus(wasAFK), pulse); err != nil {
+			return err
+		}
+		a.mu.Lock()
+		a.afk = afk
+		a.mu.Unlock()
+	}
+	if !afk {
+		idle = 0
+	}
+	return a.heartbeat(a.afkBucket(), lastInput, idle, afkStatus(afk), pulse)
+}
+
+func afkStatus(afk bool) map[string]string {
+	if afk {
+		return map[string]string{"status": "afk"}
+	}
+	return map[string]string{"status": "not-afk"}
+}
+
+func (a *ActivityWatch) createBucket(id, typ string) error {
+	body := map[string]string{"client": "keylogger", "type": typ, "hostname": a.host}
+	// An existing bucket is answered with 304 Not Modified.
+	return a.post("/api/0/buckets/"+url.PathEscape(id), body, http.StatusNotModified)
+}
+
+func (a *ActivityWatch) heartbeat(bucket string, at time.Time, d time.Duration, data map[string]string, pulse time.Duration) error {
+	body := struct {
+		Timestamp time.Time         `json:"timestamp"`
+		Duration  float64           `json:"duration"`
+		Data      map[string]string `json:"data"`
+	}{at.UTC(), d.Seconds(), data}
+	path := fmt.Sprintf("/api/0/buckets/%s/heartbeat?pulsetime=%g", url.PathEscape(bucket), pulse.Seconds())
+	return a.post(path, body, 0)
+}
+
+func (a *ActivityWatch) post(path string, body interface{}, alsoOK int) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Post(a.server+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("activitywatch: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != alsoOK {
+		return fmt.Errorf("activitywatch: %s: %s", path, resp.Status)
+	}
+	return nil
+}
+
+/*
+	Reports whether host names this machine.
+*/
+func loopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
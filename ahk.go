@@ -0,0 +1,178 @@
+package keylogger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+/*
+	AutoHotkey key names that differ from ours.
+*/
+var ahkKeyNames = map[string]string{
+	"lcontrol":    "LCtrl",
+	"rcontrol":    "RCtrl",
+	"numpadenter": "Enter",
+	"numpaddel":   "NumpadDecimal",
+	"numpadins":   "Numpad0",
+}
+
+var (
+	ahkIfWinActive = regexp.MustCompile(`(?i)^#IfWinActive\s*(?:,?\s*ahk_exe\s+(\S+))?\s*$`)
+	ahkHotIf       = regexp.MustCompile(`(?i)^#HotIf(?:\s+WinActive\(\s*"ahk_exe\s+([^"]+)"\s*\))?\s*$`)
+	ahkHotstring   = regexp.MustCompile(`^:([^:]*):(.+?)::(.*)$`)
+	ahkHotkey      = regexp.MustCompile(`^([~*$]*)([#!^+<>]*)([^:\s]+)::\s*(.*)$`)
+)
+
+/*
+	ImportAHK converts the subset of an AutoHotkey script that maps onto
+	this package's configuration:
+
+		::btw::by the way      hotstrings, scoped by #IfWinActive ahk_exe / #HotIf WinActive
+		CapsLock::Ctrl         single-key remaps
+		#n::return             hotkeys that do nothing, imported as block rules
+
+	Everything else is skipped with a warning naming the line, so users can
+	see what did not carry over.
+*/
+func ImportAHK(r io.Reader) (*Config, []string, error) {
+	c := &Config{Remap: make(map[string]string)}
+	var warnings []string
+	warn := func(line int, format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf("line %d: ", line)+fmt.Sprintf(format, args...))
+	}
+
+	var app string
+	inComment := false
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+
+		switch {
+		case inComment:
+			if strings.HasPrefix(line, "*/") {
+				inComment = false
+			}
+			continue
+		case strings.HasPrefix(line, "/*"):
+			inComment = true
+			continue
+		case line == "" || strings.HasPrefix(line, ";"):
+			continue
+		}
+		if i := strings.Index(line, " ;"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+
+		if m := ahkIfWinActive.FindStringSubmatch(line); m != nil {
+			app = m[1]
+			continue
+		}
+		if m := ahkHotIf.FindStringSubmatch(line); m != nil {
+			app = m[1]
+			continue
+		}
+		if strings.HasPrefix(line, "#") && !strings.Contains(line, "::") {
+			warn(n, "directive %q ignored", line)
+			continue
+		}
+
+		if m := ahkHotstring.FindStringSubmatch(line); m != nil {
+			if m[1] != "" {
+				warn(n, "hotstring options %q not supported, imported without them", m[1])
+			}
+			if m[3] == "" {
+				warn(n, "multi-line hotstring %q skipped", m[2])
+				continue
+			}
+			rule := HotstringRule{Abbrev: m[2], Expansion: ahkUnescape(m[3])}
+			if app != "" {
+				rule.Apps = []string{app}
+			}
+			c.Hotstrings = append(c.Hotstrings, rule)
+			continue
+		}
+
+		if m := ahkHotkey.FindStringSubmatch(line); m != nil {
+			prefix, mods, key, action := m[1], m[2], m[3], strings.TrimSpace(m[4])
+			if app != "" {
+				warn(n, "hotkey %q is application specific, which remaps and blocks do not support", line)
+				continue
+			}
+			if strings.Contains(prefix, "~") {
+				warn(n, "pass-through hotkey %q skipped", line)
+				continue
+			}
+			from, err := ahkKey(key)
+			if err != nil {
+				warn(n, "%v", err)
+				continue
+			}
+			if strings.EqualFold(action, "return") {
+				h := Hotkey{VkCode: from, Mods: ahkModifiers(mods)}
+				c.Block = append(c.Block, h.String())
+				continue
+			}
+			to, err := ahkKey(action)
+			if err != nil {
+				warn(n, "hotkey %q skipped: actions other than a remap or return are not supported", line)
+				continue
+			}
+			if mods != "" {
+				warn(n, "remap %q skipped: only single keys can be remapped", line)
+				continue
+			}
+			c.Remap[KeyName(from)] = KeyName(to)
+			continue
+		}
+
+		warn(n, "unsupported line %q", line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(c.Remap) == 0 {
+		c.Remap = nil
+	}
+	if err := c.Validate(); err != nil {
+		return nil, warnings, err
+	}
+	return c, warnings, nil
+}
+
+func ahkKey(name string) (uint16, error) {
+	if n, ok := ahkKeyNames[strings.ToLower(name)]; ok {
+		name = n
+	}
+	if vk, err := ParseKey(name); err == nil {
+		return vk, nil
+	}
+	// Browser_Back, Volume_Up, Media_Play_Pause, ...
+	return ParseKey(strings.ReplaceAll(name, "_", ""))
+}
+
+func ahkModifiers(symbols string) Modifiers {
+	var m Modifiers
+	for _, r := range symbols {
+		switch r {
+		case '^':
+			m |= ModCtrl
+		case '+':
+			m |= ModShift
+		case '!':
+			m |= ModAlt
+		case '#':
+			m |= ModWin
+		}
+	}
+	return m
+}
+
+/*
+	Resolves AutoHotkey escape sequences in hotstring expansions.
+*/
+func ahkUnescape(s string) string {
+	return strings.NewReplacer("`n", "\n", "`t", "\t", "`r", "", "`;", ";", "``", "`").Replace(s)
+}
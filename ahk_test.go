@@ -0,0 +1,80 @@
+package keylogger
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportAHK(t *testing.T) {
+	for _, c := range []struct {
+		script   string
+		want     Config
+		warnings []string
+	}{
+		{
+			script: "::btw::by the way",
+			want:   Config{Hotstrings: []HotstringRule{{Abbrev: "btw", Expansion: "by the way"}}},
+		},
+		{
+			script: "::sig::Regards,`nJane ; signature",
+			want:   Config{Hotstrings: []HotstringRule{{Abbrev: "sig", Expansion: "Regards,\nJane"}}},
+		},
+		{
+			script:   ":*:omw::on my way",
+			want:     Config{Hotstrings: []HotstringRule{{Abbrev: "omw", Expansion: "on my way"}}},
+			warnings: []string{`line 1: hotstring options "*" not supported, imported without them`},
+		},
+		{
+			script:   "::addr::\nline one\nline two",
+			warnings: []string{`line 1: multi-line hotstring "addr" skipped`, `line 2: unsupported line "line one"`, `line 3: unsupported line "line two"`},
+		},
+		{
+			script: "#IfWinActive ahk_exe code.exe\n::fn::function\n#IfWinActive\n::fn::fun",
+			want: Config{Hotstrings: []HotstringRule{
+				{Abbrev: "fn", Expansion: "function", Apps: []string{"code.exe"}},
+				{Abbrev: "fn", Expansion: "fun"},
+			}},
+		},
+		{
+			script: `#HotIf WinActive("ahk_exe slack.exe")` + "\n::ty::thank you",
+			want:   Config{Hotstrings: []HotstringRule{{Abbrev: "ty", Expansion: "thank you", Apps: []string{"slack.exe"}}}},
+		},
+		{
+			script: "CapsLock::LControl\nBrowser_Back::Volume_Mute",
+			want:   Config{Remap: map[string]string{"CapsLock": "LCtrl", "BrowserBack": "VolumeMute"}},
+		},
+		{
+			script: "y::z\nz::y",
+			want:   Config{Remap: map[string]string{"Y": "Z", "Z": "Y"}},
+		},
+		{
+			script: "#n::return\n^+Esc::Return",
+			want:   Config{Block: []string{"Win+N", "Ctrl+Shift+Escape"}},
+		},
+		{
+			script:   "^c::Esc\n~LWin::return\nF1::Run notepad.exe\nF2::Hyper",
+			warnings: []string{`line 1: remap "^c::Esc" skipped: only single keys can be remapped`, `line 2: pass-through hotkey "~LWin::return" skipped`, `line 3: hotkey "F1::Run notepad.exe" skipped: actions other than a remap or return are not supported`, `line 4: hotkey "F2::Hyper" skipped: actions other than a remap or return are not supported`},
+		},
+		{
+			script:   "#IfWinActive ahk_exe game.exe\nCapsLock::Ctrl",
+			warnings: []string{`line 2: hotkey "CapsLock::Ctrl" is application specific, which remaps and blocks do not support`},
+		},
+		{
+			script:   "#NoEnv\nSendMode Input\n; comment\n/*\n::no::not imported\n*/",
+			warnings: []string{`line 1: directive "#NoEnv" ignored`, `line 2: unsupported line "SendMode Input"`},
+		},
+	} {
+		got, warnings, err := ImportAHK(strings.NewReader(c.script))
+		if err != nil {
+			t.Errorf("%q: %v", c.script, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, c.want) {
+			t.Errorf("%q imports as\n%+v\nwant\n%+v", c.script, *got, c.want)
+		}
+		if !reflect.DeepEqual(warnings, c.warnings) {
+			t.Errorf("%q warns\n%q\nwant\n%q", c.script, warnings, c.warnings)
+		}
+	}
+}
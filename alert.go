@@ -0,0 +1,154 @@
+package keylogger
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+/*
+	AlertRule raises an Alert when Phrase is typed as whole words, in any
+	case. Apps restricts the rule to the listed process names; an empty
+	list applies it everywhere.
+*/
+type AlertRule struct {
+	Phrase string   `json:"phrase"`
+	Apps   []string `json:"apps,omitempty"`
+}
+
+/*
+	Alert reports a typed phrase of an AlertRule, with up to
+	maxAlertContext characters typed before the phrase and the application
+	it was typed into.
+*/
+type Alert struct {
+	Phrase  string
+	Context string
+	App     string
+	Time    time.Time
+}
+
+/*
+	maxAlertContext is how much of the text before a phrase an Alert holds.
+*/
+const maxAlertContext = 80
+
+/*
+	Alerts watches the typed character stream for the phrases of its
+	rules. Like Hotstrings, it only sees what was typed since the last
+	caret movement. Alerts are raised where they are fed; they do not leave
+	the machine unless the caller sends them somewhere.
+*/
+type Alerts struct {
+	Rules []AlertRule
+
+	mods ModifierState
+	buf  []rune
+	app  string
+}
+
+/*
+	NewAlerts returns a watcher for the given rules.
+*/
+func NewAlerts(rules []AlertRule) *Alerts {
+	return &Alerts{Rules: rules}
+}
+
+/*
+	Wipe clears the remembered input.
+*/
+func (a *Alerts) Wipe() {
+	for i := range a.buf {
+		a.buf[i] = 0
+	}
+	a.buf = a.buf[:0]
+	a.app = ""
+}
+
+/*
+	Feed processes one captured key event typed into the application app and
+	reports the alert it raises, if the event ended the phrase of a rule.
+*/
+func (a *Alerts) Feed(e KeyEvent, app string) (Alert, bool) {
+	if e.FromInjector() || e.Swallowed {
+		return Alert{}, false
+	}
+	mods := a.mods.Update(e)
+	if !e.Down || modifierOf(e.VkCode) != 0 {
+		return Alert{}, false
+	}
+	if !strings.EqualFold(app, a.app) {
+		a.app = app
+		a.buf = a.buf[:0]
+	}
+
+	switch {
+	case e.VkCode == VK_BACK:
+		if len(a.buf) > 0 {
+			a.buf = a.buf[:len(a.buf)-1]
+		}
+		return Alert{}, false
+	case mods&(ModCtrl|ModWin) != 0 && mods&ModAlt == 0, e.Text == "":
+		a.buf = a.buf[:0]
+		return Alert{}, false
+	}
+
+	var alert Alert
+	found := false
+	for _, r := range e.Text {
+		if isHotstringEnd(r) && !found {
+			if alert, found = a.match(app); found {
+				alert.Time = e.Time
+			}
+		}
+		a.buf = append(a.buf, r)
+	}
+	if max := maxAlertContext + a.longestPhrase() + 1; len(a.buf) > max {
+		a.buf = append(a.buf[:0], a.buf[len(a.buf)-max:]...)
+	}
+	return alert, found
+}
+
+func (a *Alerts) match(app string) (Alert, bool) {
+	for _, rule := range a.Rules {
+		phrase := []rune(rule.Phrase)
+		if len(phrase) == 0 || len(phrase) > len(a.buf) || !appsInclude(rule.Apps, app) {
+			continue
+		}
+		start := len(a.buf) - len(phrase)
+		if !runesEqualFold(a.buf[start:], phrase) {
+			continue
+		}
+		if start > 0 && !isHotstringEnd(a.buf[start-1]) {
+			continue
+		}
+		from := start - maxAlertContext
+		if from < 0 {
+			from = 0
+		}
+		return Alert{Phrase: rule.Phrase, Context: string(a.buf[from:]), App: app}, true
+	}
+	return Alert{}, false
+}
+
+func (a *Alerts) longestPhrase() int {
+	n := 0
+	for _, rule := range a.Rules {
+		if l := len([]rune(rule.Phrase)); l > n {
+			n = l
+		}
+	}
+	return n
+}
+
+func runesEqualFold(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if unicode.ToLower(a[i]) != unicode.ToLower(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package keylogger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAlerts(t *testing.T) {
+	rules := []AlertRule{
+		{Phrase: "top secret"},
+		{Phrase: "invoice", Apps: []string{"outlook.exe"}},
+	}
+	tests := []struct {
+		name  string
+		typed string
+		app   string
+		want  []string // the phrases alerted, in order
+	}{
+		{"phrase", "the top secret plan", "notepad.exe", []string{"top secret"}},
+		{"any case", "Top SECRET.", "notepad.exe", []string{"top secret"}},
+		{"not a whole word", "stop secret ", "notepad.exe", nil},
+		{"no end yet", "top secret", "notepad.exe", nil},
+		{"corrected with backspace", "top secrex\bt ", "notepad.exe", []string{"top secret"}},
+		{"taken back with backspace", "top secret\b\b\b\b\b\bcret ", "notepad.exe", nil},
+		{"twice", "top secret, top secret!", "notepad.exe", []string{"top secret", "top secret"}},
+		{"in its app", "the invoice ", "OUTLOOK.EXE", []string{"invoice"}},
+		{"in another app", "the invoice ", "notepad.exe", nil},
+	}
+	for _, tt := range tests {
+		a := NewAlerts(rules)
+		var got []string
+		for _, e := range typeKeys(tt.typed, true) {
+			if alert, ok := a.Feed(e.(KeyEvent), tt.app); ok {
+				got = append(got, alert.Phrase)
+				if alert.App != tt.app || !strings.HasSuffix(strings.ToLower(alert.Context), alert.Phrase) {
+					t.Errorf("%s: alert %+v, want the phrase at the end of the context typed into %s", tt.name, alert, tt.app)
+				}
+			}
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: alerts %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAlertContext(t *testing.T) {
+	a := NewAlerts([]AlertRule{{Phrase: "top secret"}})
+	typed := strings.Repeat("x", 200) + " top secret "
+	var alert Alert
+	found := false
+	for _, e := range typeKeys(typed, false) {
+		if al, ok := a.Feed(e.(KeyEvent), "notepad.exe"); ok {
+			alert, found = al, true
+		}
+	}
+	if !found {
+		t.Fatal("no alert")
+	}
+	if want := typed[len(typed)-1-len("top secret")-maxAlertContext : len(typed)-1]; alert.Context != want {
+		t.Errorf("context %q, want the %d characters before the phrase and the phrase, %q", alert.Context, maxAlertContext, want)
+	}
+}
+
+/*
+	Another application starts over, and injected or swallowed keys are
+	not typing.
+*/
+func TestAlertsIgnore(t *testing.T) {
+	a := NewAlerts([]AlertRule{{Phrase: "top secret"}})
+	feed := func(typed, app string, change func(*KeyEvent)) bool {
+		alerted := false
+		for _, e := range typeKeys(typed, false) {
+			k := e.(KeyEvent)
+			change(&k)
+			if _, ok := a.Feed(k, app); ok {
+				alerted = true
+			}
+		}
+		return alerted
+	}
+	same := func(*KeyEvent) {}
+	if feed("top ", "notepad.exe", same) || feed("secret ", "code.exe", same) {
+		t.Error("a phrase begun in another application raised an alert")
+	}
+	if feed("top secret ", "notepad.exe", func(k *KeyEvent) { k.Swallowed = true }) {
+		t.Error("swallowed keys raised an alert")
+	}
+	if feed("top secret ", "notepad.exe", func(k *KeyEvent) { k.Flags |= LLKHF_INJECTED; k.ExtraInfo = InjectedSignature }) {
+		t.Error("keys of the Injector raised an alert")
+	}
+}
@@ -0,0 +1,184 @@
+package keylogger
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+	Counts events over the last minute in buckets of a second.
+*/
+type minuteWindow struct {
+	seconds [60]int64 // Unix second each bucket of counts is for
+	counts  [60]int
+}
+
+/*
+	Counts an event at t and returns the events of the minute up to t.
+*/
+func (w *minuteWindow) add(t time.Time) int {
+	now := t.Unix()
+	i := int(uint64(now) % 60)
+	if w.seconds[i] != now {
+		w.seconds[i], w.counts[i] = now, 0
+	}
+	w.counts[i]++
+	return w.count(t)
+}
+
+/*
+	Returns the events of the minute up to t.
+*/
+func (w *minuteWindow) count(t time.Time) int {
+	now := t.Unix()
+	n := 0
+	for i, s := range w.seconds {
+		if now-s < 60 && s <= now {
+			n += w.counts[i]
+		}
+	}
+	return n
+}
+
+/*
+	APMStats are the actions per minute of one application, or of all of
+	them: the actions counted, the seconds in which there were any and the
+	busiest minute.
+*/
+type APMStats struct {
+	App           string    `json:"app,omitempty"`
+	Actions       uint64    `json:"actions"`
+	ActiveSeconds int64     `json:"active_seconds"`
+	Current       int       `json:"current_apm"`
+	Peak          int       `json:"peak_apm"`
+	PeakTime      time.Time `json:"peak_time"`
+}
+
+/*
+	Average returns the actions per active minute.
+*/
+func (s APMStats) Average() float64 {
+	if s.ActiveSeconds == 0 {
+		return 0
+	}
+	return float64(s.Actions) * 60 / float64(s.ActiveSeconds)
+}
+
+/*
+	APMReport is what an APMCounter has counted, overall and by
+	application, the busiest first.
+*/
+type APMReport struct {
+	Total APMStats   `json:"total"`
+	Apps  []APMStats `json:"apps"`
+}
+
+type apmTrack struct {
+	stats      APMStats
+	window     minuteWindow
+	lastSecond int64
+}
+
+func (t *apmTrack) add(at time.Time) {
+	t.stats.Actions++
+	if s := at.Unix(); s != t.lastSecond {
+		t.stats.ActiveSeconds++
+		t.lastSecond = s
+	}
+	if n := t.window.add(at); n > t.stats.Peak {
+		t.stats.Peak, t.stats.PeakTime = n, at
+	}
+}
+
+func (t *apmTrack) report(now time.Time) APMStats {
+	s := t.stats
+	s.Current = t.window.count(now)
+	return s
+}
+
+/*
+	APMCounter counts actions per minute, the measure of how busy a player
+	is: key presses, mouse button presses and wheel turns, not key
+	repeats, releases or movement. Input sent by an Injector of this
+	package is not counted. The current rate covers the last minute, and
+	each application, as named by the caller, gets its own rate and peak.
+	Its methods may be called from any goroutine.
+*/
+type APMCounter struct {
+	mu    sync.Mutex
+	held  map[uint16]bool
+	total apmTrack
+	apps  map[string]*apmTrack
+}
+
+/*
+	NewAPMCounter returns a counter that has not counted anything.
+*/
+func NewAPMCounter() *APMCounter {
+	return &APMCounter{held: make(map[uint16]bool), apps: make(map[string]*apmTrack)}
+}
+
+/*
+	Feed counts e if it is an action, taken in the application app.
+*/
+func (c *APMCounter) Feed(e Event, app string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch e := e.(type) {
+	case KeyEvent:
+		if e.FromInjector() {
+			return
+		}
+		if !e.Down {
+			delete(c.held, e.VkCode)
+			return
+		}
+		if c.held[e.VkCode] {
+			return
+		}
+		c.held[e.VkCode] = true
+	case MouseEvent:
+		if e.FromInjector() || e.Action != MouseDown && e.Action != MouseScroll {
+			return
+		}
+	default:
+		return
+	}
+	t := e.Timestamp()
+	c.total.add(t)
+	track := c.apps[app]
+	if track == nil {
+		track = &apmTrack{stats: APMStats{App: app}}
+		c.apps[app] = track
+	}
+	track.add(t)
+}
+
+/*
+	Current returns the actions of the last minute up to now.
+*/
+func (c *APMCounter) Current(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total.window.count(now)
+}
+
+/*
+	Report returns the counts as of now.
+*/
+func (c *APMCounter) Report(now time.Time) APMReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := APMReport{Total: c.total.report(now), Apps: make([]APMStats, 0, len(c.apps))}
+	for _, t := range c.apps {
+		r.Apps = append(r.Apps, t.report(now))
+	}
+	sort.Slice(r.Apps, func(i, j int) bool {
+		if r.Apps[i].Actions != r.Apps[j].Actions {
+			return r.Apps[i].Actions > r.Apps[j].Actions
+		}
+		return r.Apps[i].App < r.Apps[j].App
+	})
+	return r
+}
@@ -0,0 +1,123 @@
+package keylogger
+
+import (
+	"testing"
+	"time"
+)
+
+func secondsIn(s float64) time.Time {
+	return time.Unix(1700000000, 0).UTC().Add(time.Duration(s * float64(time.Second)))
+}
+
+/*
+	tap is a key pressed and released at second s.
+*/
+func tap(vk uint16, s float64) []Event {
+	return []Event{KeyEvent{VkCode: vk, Down: true, Time: secondsIn(s)}, KeyEvent{VkCode: vk, Time: secondsIn(s)}}
+}
+
+func feedAPM(c *APMCounter, app string, events ...[]Event) {
+	for _, evs := range events {
+		for _, e := range evs {
+			c.Feed(e, app)
+		}
+	}
+}
+
+func TestAPMWindow(t *testing.T) {
+	c := NewAPMCounter()
+	feedAPM(c, "game.exe", tap('A', 0), tap('B', 0.5), tap('C', 10), tap('D', 59))
+	for _, w := range []struct {
+		at   float64
+		want int
+	}{
+		{-1, 0},
+		{0.9, 2},
+		{5, 2}, // later actions are not counted
+		{59, 4},
+		{59.9, 4},
+		{60, 2}, // the first second has left the window
+		{69.5, 2},
+		{70, 1},
+		{118.9, 1},
+		{119, 0},
+	} {
+		if got := c.Current(secondsIn(w.at)); got != w.want {
+			t.Errorf("%d actions at %gs, want %d", got, w.at, w.want)
+		}
+	}
+
+	// A bucket is reused for the same second of a later minute.
+	feedAPM(c, "game.exe", tap('E', 120.5))
+	if got := c.Current(secondsIn(121)); got != 1 {
+		t.Errorf("%d actions after a quiet minute, want 1", got)
+	}
+}
+
+func TestAPMPeak(t *testing.T) {
+	c := NewAPMCounter()
+	feedAPM(c, "game.exe", tap('A', 0), tap('B', 20), tap('C', 20.1), tap('D', 20.2), tap('E', 40))
+	feedAPM(c, "game.exe", tap('F', 100), tap('G', 130))
+	s := c.Report(secondsIn(130)).Total
+	if s.Peak != 5 || !s.PeakTime.Equal(secondsIn(40)) {
+		t.Errorf("peak of %d at %v, want 5 at %v", s.Peak, s.PeakTime, secondsIn(40))
+	}
+	if s.Actions != 7 || s.ActiveSeconds != 5 || s.Current != 2 {
+		t.Errorf("stats %+v", s)
+	}
+	if got, want := s.Average(), 7*60/5.0; got != want {
+		t.Errorf("average %g, want %g", got, want)
+	}
+	if got := (APMStats{}).Average(); got != 0 {
+		t.Errorf("average without actions %g", got)
+	}
+}
+
+/*
+	Presses count, repeats, releases, movement and the input of an
+	Injector do not.
+*/
+func TestAPMActions(t *testing.T) {
+	c := NewAPMCounter()
+	at := secondsIn(1)
+	for _, e := range []Event{
+		KeyEvent{VkCode: 'W', Down: true, Time: at},
+		KeyEvent{VkCode: 'W', Down: true, Time: at}, // repeat
+		KeyEvent{VkCode: 'W', Time: at},
+		KeyEvent{VkCode: 'W', Down: true, Time: at},
+		KeyEvent{VkCode: 'Q', Down: true, Flags: LLKHF_INJECTED, ExtraInfo: InjectedSignature, Time: at},
+		KeyEvent{VkCode: 'E', Down: true, Flags: LLKHF_INJECTED, Time: at}, // another program's
+		MouseEvent{Action: MouseDown, Button: LeftButton, Time: at},
+		MouseEvent{Action: MouseUp, Button: LeftButton, Time: at},
+		MouseEvent{Action: MouseMove, X: 5, Y: 5, Time: at},
+		MouseEvent{Action: MouseScroll, Delta: -120, Time: at},
+		MouseEvent{Action: MouseDown, Button: RightButton, Flags: LLMHF_INJECTED, ExtraInfo: InjectedSignature, Time: at},
+		FocusEvent{Process: "game.exe", Time: at},
+	} {
+		c.Feed(e, "game.exe")
+	}
+	if got := c.Report(at).Total.Actions; got != 5 {
+		t.Errorf("%d actions, want 5", got)
+	}
+}
+
+func TestAPMReportByApp(t *testing.T) {
+	c := NewAPMCounter()
+	feedAPM(c, "b.exe", tap('A', 0))
+	feedAPM(c, "game.exe", tap('A', 1), tap('B', 2), tap('C', 90))
+	feedAPM(c, "a.exe", tap('A', 3))
+	r := c.Report(secondsIn(90))
+	if r.Total.Actions != 5 || r.Total.Current != 1 || r.Total.Peak != 4 {
+		t.Errorf("total %+v", r.Total)
+	}
+	var apps []string
+	for _, s := range r.Apps {
+		apps = append(apps, s.App)
+	}
+	if len(apps) != 3 || apps[0] != "game.exe" || apps[1] != "a.exe" || apps[2] != "b.exe" {
+		t.Fatalf("applications %v", apps)
+	}
+	if g := r.Apps[0]; g.Actions != 3 || g.Current != 1 || g.Peak != 2 || g.ActiveSeconds != 3 {
+		t.Errorf("game.exe %+v", g)
+	}
+}
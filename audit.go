@@ -0,0 +1,162 @@
+package keylogger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+)
+
+/*
+	AuditLog records what was done to the capture, as opposed to what it
+	captured: starts and stops, configuration, filters and sinks, along with
+	who did it and when. Records are JSON lines appended to their own file.
+	Each one holds the SHA-256 of the line before it, so a record that was
+	edited or removed breaks the chain; VerifyAuditLog checks it. Changes
+	to the last record, or cutting records off the end, only show against
+	a copy of the chain's head kept elsewhere.
+*/
+type AuditLog struct {
+	mu   sync.Mutex
+	f    *os.File
+	user string
+	prev string
+}
+
+/*
+	AuditRecord is one line of an AuditLog.
+*/
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	PID    int       `json:"pid"`
+	Action string    `json:"action"`
+	Detail string    `json:"detail,omitempty"`
+	Prev   string    `json:"prev"`
+}
+
+/*
+	Actions of AuditRecords written by a Logger.
+*/
+const (
+	AuditStart       = "start"
+	AuditStartFailed = "start-failed"
+	AuditStop        = "stop"
+	AuditPause       = "pause"
+	AuditResume      = "resume"
+	AuditFilterAdded = "filter-added"
+	AuditSinkAdded   = "sink-added"
+	AuditConfig      = "config-loaded"
+)
+
+/*
+	OpenAuditLog opens or creates the audit log name for appending.
+*/
+func OpenAuditLog(name string) (*AuditLog, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	// Find the last line to continue the chain from.
+	var last []byte
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if len(sc.Bytes()) > 0 {
+			last = append(last[:0], sc.Bytes()...)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	a := &AuditLog{f: f, user: "unknown"}
+	if last != nil {
+		a.prev = auditHash(last)
+	}
+	if u, err := user.Current(); err == nil {
+		a.user = u.Username
+	}
+	return a, nil
+}
+
+/*
+	Record appends a record of action by the current user. A nil AuditLog
+	records nothing.
+*/
+func (a *AuditLog) Record(action, detail string) error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	line, err := json.Marshal(AuditRecord{
+		Time:   time.Now(),
+		User:   a.user,
+		PID:    os.Getpid(),
+		Action: action,
+		Detail: detail,
+		Prev:   a.prev,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := a.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	a.prev = auditHash(line)
+	return a.f.Sync()
+}
+
+func (a *AuditLog) Close() error {
+	return a.f.Close()
+}
+
+/*
+	VerifyAuditLog checks the hash chain of an audit log and returns the
+	number of intact records and the hash of the last one, the head.
+*/
+func VerifyAuditLog(name string) (n int, head string, err error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return 0, "", err
+	}
+	var prev string
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var r AuditRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			return n, prev, &AuditError{Line: i + 1, Reason: err.Error()}
+		}
+		if r.Prev != prev {
+			return n, prev, &AuditError{Line: i + 1, Reason: "does not follow the record before it"}
+		}
+		prev = auditHash(line)
+		n++
+	}
+	return n, prev, nil
+}
+
+/*
+	AuditError reports where an audit log's chain is broken.
+*/
+type AuditError struct {
+	Line   int
+	Reason string
+}
+
+func (e *AuditError) Error() string {
+	return fmt.Sprintf("audit log line %d: %s", e.Line, e.Reason)
+}
+
+func auditHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,106 @@
+package keylogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditPauses(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := OpenAuditLog(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := []struct {
+		reason uint32
+		on     bool
+		action string
+		detail string
+	}{
+		{pauseCalled, true, AuditPause, "capture paused"},
+		{pauseLocked, true, AuditPause, "workstation locked"},
+		{pauseLocked, false, AuditResume, "workstation unlocked"},
+		{pauseCalled, false, AuditResume, "capture resumed"},
+		{pauseSensitive, true, AuditPause, "sensitive application"},
+		{pauseDisconnected, false, AuditResume, "session reconnected"},
+	}
+	for _, c := range changes {
+		if err := recordPause(a, c.reason, c.on); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.Close()
+	if n, _, err := VerifyAuditLog(name); err != nil || n != len(changes) {
+		t.Fatalf("VerifyAuditLog = %d, %v, want %d intact records", n, err, len(changes))
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var r AuditRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatal(err)
+		}
+		if c := changes[i]; r.Action != c.action || r.Detail != c.detail {
+			t.Errorf("record %d = %s %q, want %s %q", i, r.Action, r.Detail, c.action, c.detail)
+		}
+	}
+}
+
+func TestAuditLogChain(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := OpenAuditLog(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range []string{AuditConfig, AuditSinkAdded, AuditStart} {
+		if err := a.Record(action, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.Close()
+	// Reopening continues the chain.
+	if a, err = OpenAuditLog(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(AuditStop, ""); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+	n, head, err := VerifyAuditLog(name)
+	if err != nil || n != 4 || head == "" {
+		t.Fatalf("VerifyAuditLog = %d, %q, %v, want 4 intact records", n, head, err)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	modified := append([][]byte(nil), lines...)
+	modified[1] = bytes.Replace(lines[1], []byte(AuditSinkAdded), []byte(AuditFilterAdded), 1)
+	tests := []struct {
+		name  string
+		lines [][]byte
+		line  int
+	}{
+		{"modified", modified, 3},
+		{"removed", [][]byte{lines[0], lines[2], lines[3]}, 2},
+		{"reordered", [][]byte{lines[0], lines[2], lines[1], lines[3]}, 2},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(name, bytes.Join(tt.lines, nil), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err := VerifyAuditLog(name)
+		var audit *AuditError
+		if !errors.As(err, &audit) || audit.Line != tt.line {
+			t.Errorf("%s: VerifyAuditLog = %v, want a break at line %d", tt.name, err, tt.line)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+//go:build !localonly
+// +build !localonly
+
+package keylogger
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+/*
+	Scope is what an API token allows. ScopeControl includes ScopeRead.
+*/
+type Scope int
+
+const (
+	// ScopeRead allows reading stats and metrics.
+	ScopeRead Scope = iota + 1
+	// ScopeControl allows everything, including diagnostics such as profiles.
+	ScopeControl
+)
+
+var scopeNames = map[Scope]string{ScopeRead: "read", ScopeControl: "control"}
+
+func (s Scope) String() string {
+	if n, ok := scopeNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("Scope(%d)", int(s))
+}
+
+/*
+	ParseScope accepts the names returned by String.
+*/
+func ParseScope(name string) (Scope, error) {
+	for s, n := range scopeNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown scope %q", name)
+}
+
+/*
+	TokenAuth checks bearer tokens on HTTP requests.
+*/
+type TokenAuth struct {
+	tokens []apiToken
+}
+
+type apiToken struct {
+	token []byte
+	scope Scope
+}
+
+/*
+	Add allows token with scope.
+*/
+func (a *TokenAuth) Add(token []byte, scope Scope) {
+	a.tokens = append(a.tokens, apiToken{token: token, scope: scope})
+}
+
+/*
+	LoadTokens reads a token file: one "scope token" pair per line, where
+	the token may be a protected Secret. Empty lines and lines starting
+	with # are skipped.
+*/
+func LoadTokens(name string) (*TokenAuth, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	a := &TokenAuth{}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want a scope and a token", name, n)
+		}
+		scope, err := ParseScope(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, n, err)
+		}
+		token, err := Secret(fields[1]).Reveal()
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, n, err)
+		}
+		if len(token) < 16 {
+			return nil, fmt.Errorf("%s:%d: token shorter than 16 characters", name, n)
+		}
+		a.Add(token, scope)
+	}
+	return a, sc.Err()
+}
+
+/*
+	Require lets requests through to h that carry an
+	"Authorization: Bearer" token with at least scope.
+*/
+func (a *TokenAuth) Require(scope Scope, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := a.scope(r)
+		switch {
+		case !ok:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="keylogger"`)
+			http.Error(w, "missing or unknown token", http.StatusUnauthorized)
+		case got < scope:
+			http.Error(w, "token does not allow "+scope.String(), http.StatusForbidden)
+		default:
+			h.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (a *TokenAuth) scope(r *http.Request) (Scope, bool) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return 0, false
+	}
+	given := []byte(strings.TrimPrefix(auth, "Bearer "))
+	// Every token is compared, so the time taken does not tell which matched.
+	var scope Scope
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(given, t.token) == 1 && t.scope > scope {
+			scope = t.scope
+		}
+	}
+	return scope, scope != 0
+}
+
+/*
+	MutualTLSConfig returns a server TLS configuration that only accepts
+	clients with a certificate signed by one of the CAs in the PEM file
+	clientCAs.
+*/
+func MutualTLSConfig(clientCAs string) (*tls.Config, error) {
+	pem, err := os.ReadFile(clientCAs)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no certificates found", clientCAs)
+	}
+	return &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
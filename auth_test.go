@@ -0,0 +1,72 @@
+//go:build !localonly
+// +build !localonly
+
+package keylogger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTokenAuth(t *testing.T) {
+	a := &TokenAuth{}
+	a.Add([]byte("read-token-0123456789"), ScopeRead)
+	a.Add([]byte("control-token-0123456789"), ScopeControl)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux := http.NewServeMux()
+	mux.Handle("/stats", a.Require(ScopeRead, ok))
+	mux.Handle("/debug/", a.Require(ScopeControl, ok))
+
+	tests := []struct {
+		route string
+		auth  string
+		want  int
+	}{
+		{"/stats", "Bearer read-token-0123456789", http.StatusOK},
+		{"/stats", "Bearer control-token-0123456789", http.StatusOK},
+		{"/debug/pprof/", "Bearer control-token-0123456789", http.StatusOK},
+		{"/debug/pprof/", "Bearer read-token-0123456789", http.StatusForbidden},
+		{"/stats", "", http.StatusUnauthorized},
+		{"/debug/pprof/", "", http.StatusUnauthorized},
+		{"/stats", "Bearer wrong-token-0123456789", http.StatusUnauthorized},
+		{"/stats", "Bearer read-token-012345678", http.StatusUnauthorized},
+		{"/stats", "Basic read-token-0123456789", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.route, nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s with %q: status %d, want %d", tt.route, tt.auth, w.Code, tt.want)
+		}
+		if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s with %q: no WWW-Authenticate header", tt.route, tt.auth)
+		}
+	}
+}
+
+func TestLoadTokens(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "tokens")
+	for _, tt := range []struct {
+		file string
+		ok   bool
+	}{
+		{"# comment\n\nread read-token-0123456789\ncontrol control-token-0123456789\n", true},
+		{"read short\n", false},
+		{"admin admin-token-0123456789\n", false},
+		{"read\n", false},
+	} {
+		if err := os.WriteFile(name, []byte(tt.file), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadTokens(name); (err == nil) != tt.ok {
+			t.Errorf("LoadTokens(%q) = %v, want ok %v", tt.file, err, tt.ok)
+		}
+	}
+}
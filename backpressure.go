@@ -0,0 +1,298 @@
+package keylogger
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	BackpressurePolicy decides what happens to an event when the consumer of
+	the event stream is not keeping up and the channel is full.
+*/
+type BackpressurePolicy int
+
+const (
+	// Block waits for the consumer, for at most Backpressure.Timeout if set.
+	Block BackpressurePolicy = iota
+	// DropNewest discards the event that does not fit.
+	DropNewest
+	// DropOldest discards the oldest undelivered event to make room.
+	DropOldest
+	// SpillToDisk queues events in a temporary file until the consumer catches up.
+	SpillToDisk
+)
+
+var backpressurePolicyNames = []string{"block", "drop-newest", "drop-oldest", "spill"}
+
+func (p BackpressurePolicy) String() string {
+	if p >= 0 && int(p) < len(backpressurePolicyNames) {
+		return backpressurePolicyNames[p]
+	}
+	return fmt.Sprintf("BackpressurePolicy(%d)", int(p))
+}
+
+/*
+	ParseBackpressurePolicy accepts the names returned by String.
+*/
+func ParseBackpressurePolicy(name string) (BackpressurePolicy, error) {
+	for i, n := range backpressurePolicyNames {
+		if n == name {
+			return BackpressurePolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown backpressure policy %q", name)
+}
+
+/*
+	Backpressure configures the event stream's policy. Timeout only applies
+	to Block, after which the event is dropped; SpillDir only to
+	SpillToDisk and defaults to the system temporary directory.
+*/
+type Backpressure struct {
+	Policy   BackpressurePolicy
+	Timeout  time.Duration
+	SpillDir string
+}
+
+/*
+	BackpressureStats counts what each policy did. Every event the stream
+	could not deliver is counted in exactly one of the Dropped or TimedOut
+	fields.
+*/
+type BackpressureStats struct {
+	Delivered     uint64 // events handed to the consumer
+	Blocked       uint64 // events that had to wait for the consumer
+	TimedOut      uint64 // events dropped after waiting Timeout
+	DroppedNewest uint64 // events discarded by DropNewest
+	DroppedOldest uint64 // events discarded by DropOldest
+	Spilled       uint64 // events written to the spill file
+	SpillErrors   uint64 // events lost because the spill file failed
+}
+
+func init() {
+	for _, e := range []Event{KeyEvent{}, MouseEvent{}, FocusEvent{}, ProcessEvent{}, GamepadEvent{}, TextEvent{}, DiagnosticEvent{}} {
+		gob.Register(e)
+	}
+}
+
+/*
+	Delivers events to the stream's channel according to a Backpressure.
+	send may be called from several goroutines.
+*/
+type eventQueue struct {
+	stats BackpressureStats // first for 64-bit alignment on 386
+	out   chan Event
+	bp    Backpressure
+	abort <-chan struct{}
+
+	mu    sync.Mutex
+	spill *spillFile
+}
+
+/*
+	Closing abort gives up on events still waiting in the spill file.
+*/
+func newEventQueue(out chan Event, bp Backpressure, abort <-chan struct{}) *eventQueue {
+	return &eventQueue{out: out, bp: bp, abort: abort}
+}
+
+/*
+	Sends e, giving up when stop is closed while blocked.
+*/
+func (q *eventQueue) send(e Event, stop <-chan struct{}) {
+	if q.bp.Policy == SpillToDisk {
+		q.sendOrSpill(e)
+		return
+	}
+	select {
+	case q.out <- e:
+		atomic.AddUint64(&q.stats.Delivered, 1)
+		return
+	default:
+	}
+	switch q.bp.Policy {
+	case DropNewest:
+		atomic.AddUint64(&q.stats.DroppedNewest, 1)
+	case DropOldest:
+		for {
+			select {
+			case <-q.out:
+				atomic.AddUint64(&q.stats.DroppedOldest, 1)
+			default:
+			}
+			select {
+			case q.out <- e:
+				atomic.AddUint64(&q.stats.Delivered, 1)
+				return
+			default:
+			}
+		}
+	default:
+		atomic.AddUint64(&q.stats.Blocked, 1)
+		var timeout <-chan time.Time
+		if q.bp.Timeout > 0 {
+			t := time.NewTimer(q.bp.Timeout)
+			defer t.Stop()
+			timeout = t.C
+		}
+		select {
+		case q.out <- e:
+			atomic.AddUint64(&q.stats.Delivered, 1)
+		case <-timeout:
+			atomic.AddUint64(&q.stats.TimedOut, 1)
+		case <-stop:
+		}
+	}
+}
+
+/*
+	Once an event has been spilled, later events are spilled as well until
+	the file has been drained, so the consumer still sees them in order.
+*/
+func (q *eventQueue) sendOrSpill(e Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.spill == nil {
+		select {
+		case q.out <- e:
+			atomic.AddUint64(&q.stats.Delivered, 1)
+			return
+		default:
+		}
+		s, err := newSpillFile(q.bp.SpillDir)
+		if err != nil {
+			atomic.AddUint64(&q.stats.SpillErrors, 1)
+			return
+		}
+		q.spill = s
+		go q.drain(s)
+	}
+	if err := q.spill.write(e); err != nil {
+		atomic.AddUint64(&q.stats.SpillErrors, 1)
+		return
+	}
+	atomic.AddUint64(&q.stats.Spilled, 1)
+}
+
+/*
+	Feeds the spilled events to the consumer, then removes the file.
+*/
+func (q *eventQueue) drain(s *spillFile) {
+	for {
+		q.mu.Lock()
+		if s.pending == 0 {
+			q.spill = nil
+			q.mu.Unlock()
+			s.remove()
+			close(s.drained)
+			return
+		}
+		q.mu.Unlock()
+
+		e, err := s.read()
+		q.mu.Lock()
+		s.pending--
+		q.mu.Unlock()
+		if err != nil {
+			atomic.AddUint64(&q.stats.SpillErrors, 1)
+			continue
+		}
+		select {
+		case q.out <- e:
+			atomic.AddUint64(&q.stats.Delivered, 1)
+		case <-q.abort:
+			q.mu.Lock()
+			s.pending = 0
+			q.mu.Unlock()
+		}
+	}
+}
+
+/*
+	Waits until the spill file, if any, has been drained or abandoned. No
+	events may be sent concurrently.
+*/
+func (q *eventQueue) wait() {
+	q.mu.Lock()
+	s := q.spill
+	q.mu.Unlock()
+	if s != nil {
+		<-s.drained
+	}
+}
+
+/*
+	Returns the number of spilled events not yet delivered.
+*/
+func (q *eventQueue) spilled() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.spill == nil {
+		return 0
+	}
+	return q.spill.pending
+}
+
+func (q *eventQueue) statistics() BackpressureStats {
+	return BackpressureStats{
+		Delivered:     atomic.LoadUint64(&q.stats.Delivered),
+		Blocked:       atomic.LoadUint64(&q.stats.Blocked),
+		TimedOut:      atomic.LoadUint64(&q.stats.TimedOut),
+		DroppedNewest: atomic.LoadUint64(&q.stats.DroppedNewest),
+		DroppedOldest: atomic.LoadUint64(&q.stats.DroppedOldest),
+		Spilled:       atomic.LoadUint64(&q.stats.Spilled),
+		SpillErrors:   atomic.LoadUint64(&q.stats.SpillErrors),
+	}
+}
+
+/*
+	A temporary file of gob-encoded events, written by the queue and read
+	back in order by its drain goroutine. pending counts the events written
+	but not yet read and is guarded by the queue's mutex; a record is only
+	read once it has been completely written.
+*/
+type spillFile struct {
+	w, r    *os.File
+	enc     *gob.Encoder
+	dec     *gob.Decoder
+	pending int
+	drained chan struct{}
+}
+
+func newSpillFile(dir string) (*spillFile, error) {
+	w, err := os.CreateTemp(dir, "keylogger-spill-*")
+	if err != nil {
+		return nil, err
+	}
+	r, err := os.Open(w.Name())
+	if err != nil {
+		w.Close()
+		os.Remove(w.Name())
+		return nil, err
+	}
+	return &spillFile{w: w, r: r, enc: gob.NewEncoder(w), dec: gob.NewDecoder(r), drained: make(chan struct{})}, nil
+}
+
+func (s *spillFile) write(e Event) error {
+	if err := s.enc.Encode(&e); err != nil {
+		return err
+	}
+	s.pending++
+	return nil
+}
+
+func (s *spillFile) read() (Event, error) {
+	var e Event
+	err := s.dec.Decode(&e)
+	return e, err
+}
+
+func (s *spillFile) remove() {
+	s.r.Close()
+	s.w.Close()
+	os.Remove(s.w.Name())
+}
@@ -0,0 +1,193 @@
+package keylogger
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBackpressurePolicyNames(t *testing.T) {
+	for p := Block; p <= SpillToDisk; p++ {
+		got, err := ParseBackpressurePolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("%v parses as %v, %v", p, got, err)
+		}
+	}
+	if _, err := ParseBackpressurePolicy("drop-all"); err == nil {
+		t.Error("unknown policy accepted")
+	}
+}
+
+func keyEvents(n int) []Event {
+	events := make([]Event, n)
+	for i := range events {
+		events[i] = KeyEvent{VkCode: uint16('A' + i), Down: true, Time: time.Unix(0, int64(i)).UTC()}
+	}
+	return events
+}
+
+/*
+	Returns what the consumer has been sent so far.
+*/
+func received(out chan Event) []Event {
+	var got []Event
+	for {
+		select {
+		case e := <-out:
+			got = append(got, e)
+		default:
+			return got
+		}
+	}
+}
+
+func TestBackpressureBlock(t *testing.T) {
+	events := keyEvents(3)
+	out := make(chan Event, 1)
+	q := newEventQueue(out, Backpressure{Policy: Block, Timeout: 10 * time.Millisecond}, nil)
+	q.send(events[0], nil)
+	q.send(events[1], nil)
+	if got := received(out); !reflect.DeepEqual(got, events[:1]) {
+		t.Errorf("delivered %v, want the first event", got)
+	}
+	want := BackpressureStats{Delivered: 1, Blocked: 1, TimedOut: 1}
+	if s := q.statistics(); s != want {
+		t.Errorf("stats %+v, want %+v", s, want)
+	}
+
+	// Without a timeout, the event waits for the consumer.
+	q = newEventQueue(out, Backpressure{Policy: Block}, nil)
+	q.send(events[0], nil)
+	sent := make(chan struct{})
+	go func() {
+		q.send(events[1], nil)
+		close(sent)
+	}()
+	for q.statistics().Blocked == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if e := <-out; e != events[0] {
+		t.Errorf("first event %v", e)
+	}
+	<-sent
+	if e := <-out; e != events[1] {
+		t.Errorf("second event %v", e)
+	}
+	if s := q.statistics(); s.Delivered != 2 || s.Blocked != 1 {
+		t.Errorf("stats %+v, want 2 delivered after blocking once", s)
+	}
+
+	// Stop gives up on a blocked event.
+	stop := make(chan struct{})
+	q.send(events[0], stop)
+	close(stop)
+	q.send(events[1], stop)
+	if got := received(out); !reflect.DeepEqual(got, events[:1]) {
+		t.Errorf("delivered %v after stop, want the first event", got)
+	}
+}
+
+func TestBackpressureDrop(t *testing.T) {
+	events := keyEvents(4)
+	for _, c := range []struct {
+		policy BackpressurePolicy
+		want   []Event
+		stats  BackpressureStats
+	}{
+		{DropNewest, events[:2], BackpressureStats{Delivered: 2, DroppedNewest: 2}},
+		{DropOldest, events[2:], BackpressureStats{Delivered: 4, DroppedOldest: 2}},
+	} {
+		out := make(chan Event, 2)
+		q := newEventQueue(out, Backpressure{Policy: c.policy}, nil)
+		for _, e := range events {
+			q.send(e, nil)
+		}
+		if got := received(out); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: delivered %v, want %v", c.policy, got, c.want)
+		}
+		if s := q.statistics(); s != c.stats {
+			t.Errorf("%v: stats %+v, want %+v", c.policy, s, c.stats)
+		}
+	}
+}
+
+/*
+	Events that do not fit go through the spill file, gob-encoded, and reach
+	the consumer in order; the file is removed once it is drained.
+*/
+func TestBackpressureSpill(t *testing.T) {
+	at := time.Unix(0, 1).UTC()
+	events := []Event{
+		KeyEvent{VkCode: 'A', ScanCode: 0x1E, Down: true, Text: "a", Time: at},
+		MouseEvent{Action: MouseScroll, X: -5, Y: 1080, Delta: -120, Horizontal: true, Time: at},
+		FocusEvent{HWND: 0x10246, PID: 4242, Process: "notepad.exe", Title: "Untitled – Notepad", Time: at},
+		ProcessEvent{PID: 7, Name: "code.exe", Started: true, Time: at},
+		TextEvent{Start: at, Process: "notepad.exe", Text: "héllo", Keys: 7, Time: at},
+		DiagnosticEvent{Kind: DiagHookReinstalled, Message: "keyboard hook", Time: at},
+	}
+	dir := t.TempDir()
+	out := make(chan Event, 1)
+	q := newEventQueue(out, Backpressure{Policy: SpillToDisk, SpillDir: dir}, nil)
+	for _, e := range events {
+		q.send(e, nil)
+	}
+	if n := q.spilled(); n == 0 {
+		t.Error("nothing spilled")
+	}
+	var got []Event
+	for range events {
+		select {
+		case e := <-out:
+			got = append(got, e)
+		case <-time.After(5 * time.Second):
+			t.Fatal("spilled events did not arrive")
+		}
+	}
+	q.wait()
+	if !reflect.DeepEqual(got, events) {
+		t.Errorf("delivered\n%v\nwant\n%v", got, events)
+	}
+	want := BackpressureStats{Delivered: uint64(len(events)), Spilled: uint64(len(events) - 1)}
+	if s := q.statistics(); s != want {
+		t.Errorf("stats %+v, want %+v", s, want)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("spill file left behind: %v", files)
+	}
+}
+
+/*
+	Closing abort, as a Stop that runs out of time does, gives up on the
+	spilled events and still removes the file.
+*/
+func TestBackpressureSpillAbort(t *testing.T) {
+	dir := t.TempDir()
+	out := make(chan Event, 1)
+	abort := make(chan struct{})
+	q := newEventQueue(out, Backpressure{Policy: SpillToDisk, SpillDir: dir}, abort)
+	for _, e := range keyEvents(5) {
+		q.send(e, nil)
+	}
+	close(abort)
+	q.wait()
+	if n := q.spilled(); n != 0 {
+		t.Errorf("%d events still spilled", n)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("spill file left behind: %v", files)
+	}
+}
+
+func TestBackpressureSpillError(t *testing.T) {
+	out := make(chan Event, 1)
+	q := newEventQueue(out, Backpressure{Policy: SpillToDisk, SpillDir: filepath.Join(t.TempDir(), "missing")}, nil)
+	for _, e := range keyEvents(3) {
+		q.send(e, nil)
+	}
+	want := BackpressureStats{Delivered: 1, SpillErrors: 2}
+	if s := q.statistics(); s != want {
+		t.Errorf("stats %+v, want %+v", s, want)
+	}
+}
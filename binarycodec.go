@@ -0,0 +1,329 @@
+package keylogger
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+	BinaryCodec writes compact length-prefixed records, several times smaller than
+	JSONCodec's. A record is its body length as a uvarint followed by
+	the body: a zero byte and SchemaVersion as a uvarint, a type byte, the
+	time as varint Unix nanoseconds (0 for the zero time) and the event's
+	fields in declaration order, integers as varints, strings
+	length-prefixed and flags packed into a bit byte. Version 1 bodies
+	start with the type byte. A zero length marks the end of the records,
+	so a preallocated file can be read up to where writing stopped.
+*/
+type BinaryCodec struct{}
+
+const (
+	binaryKey byte = iota + 1
+	binaryMouse
+	binaryFocus
+	binaryProcess
+	binaryGamepad
+	binaryDiagnostic
+	binaryText
+)
+
+func (BinaryCodec) AppendEvent(dst []byte, e Event) ([]byte, error) {
+	start := len(dst)
+	w := binaryWriter{buf: dst}
+	w.byte(0)
+	w.uint(SchemaVersion)
+	switch e := e.(type) {
+	case KeyEvent:
+		w.byte(binaryKey)
+		w.time(e.Time)
+		w.uint(uint64(e.VkCode))
+		w.uint(uint64(e.ScanCode))
+		w.uint(uint64(e.Flags))
+		w.uint(uint64(e.ExtraInfo))
+		w.bits(e.Down, e.Swallowed, e.OnScreen)
+		w.string(e.Text)
+	case MouseEvent:
+		w.byte(binaryMouse)
+		w.time(e.Time)
+		w.byte(byte(e.Action))
+		w.byte(byte(e.Button))
+		w.int(int64(e.X))
+		w.int(int64(e.Y))
+		w.bits(e.DoubleClick, e.Horizontal)
+		w.int(int64(e.Delta))
+		w.uint(uint64(e.Flags))
+		w.uint(uint64(e.ExtraInfo))
+	case FocusEvent:
+		w.byte(binaryFocus)
+		w.time(e.Time)
+		w.uint(uint64(e.HWND))
+		w.uint(uint64(e.PID))
+		w.string(e.Process)
+		w.string(e.Title)
+		w.bits(e.Elevated)
+	case ProcessEvent:
+		w.byte(binaryProcess)
+		w.time(e.Time)
+		w.uint(uint64(e.PID))
+		w.string(e.Name)
+		w.bits(e.Started)
+	case GamepadEvent:
+		w.byte(binaryGamepad)
+		w.time(e.Time)
+		w.byte(byte(e.Pad))
+		w.uint(uint64(e.Button))
+		w.bits(e.Down)
+		w.uint(uint64(e.State.Buttons))
+		w.byte(e.State.LeftTrigger)
+		w.byte(e.State.RightTrigger)
+		w.int(int64(e.State.LeftX))
+		w.int(int64(e.State.LeftY))
+		w.int(int64(e.State.RightX))
+		w.int(int64(e.State.RightY))
+	case DiagnosticEvent:
+		w.byte(binaryDiagnostic)
+		w.time(e.Time)
+		w.string(e.Kind)
+		w.string(e.Message)
+	case TextEvent:
+		w.byte(binaryText)
+		w.time(e.Time)
+		w.time(e.Start)
+		w.string(e.Process)
+		w.string(e.Title)
+		w.string(e.Text)
+		w.int(int64(e.Keys))
+		w.bits(e.Uncertain)
+	default:
+		return dst, fmt.Errorf("cannot encode %s event", EventType(e))
+	}
+	// Prefix the body with its length in place rather than encoding it
+	// separately, so writing a record does not allocate.
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(len(w.buf)-start))
+	end := len(w.buf)
+	w.buf = append(w.buf, size[:n]...)
+	copy(w.buf[start+n:], w.buf[start:end])
+	copy(w.buf[start:], size[:n])
+	return w.buf, nil
+}
+
+/*
+	ErrEndOfRecords is returned by DecodeBinaryEvent for the zero length
+	that ends a preallocated file.
+*/
+var ErrEndOfRecords = errors.New("end of records")
+
+/*
+	DecodeBinaryEvent parses the record at the start of data and returns the
+	event and the record's size, which it also returns with ErrNewerSchema.
+*/
+func DecodeBinaryEvent(data []byte) (Event, int, error) {
+	n, k := binary.Uvarint(data)
+	if k <= 0 {
+		return nil, 0, errors.New("binary record: bad length")
+	}
+	if n == 0 {
+		return nil, k, ErrEndOfRecords
+	}
+	if uint64(len(data)-k) < n {
+		return nil, 0, errors.New("binary record: truncated")
+	}
+	r := binaryReader{buf: data[k : k+int(n)]}
+	version := uint64(1)
+	if len(r.buf) > 0 && r.buf[0] == 0 {
+		r.byte()
+		version = r.uint()
+	}
+	if version > SchemaVersion {
+		return nil, k + int(n), newerSchema(version)
+	}
+	var e Event
+	switch r.byte() {
+	case binaryKey:
+		var ev KeyEvent
+		ev.Time = r.time()
+		ev.VkCode = uint16(r.uint())
+		ev.ScanCode = uint32(r.uint())
+		ev.Flags = uint32(r.uint())
+		ev.ExtraInfo = uintptr(r.uint())
+		b := r.byte()
+		ev.Down, ev.Swallowed, ev.OnScreen = b&1 != 0, b&2 != 0, b&4 != 0
+		ev.Text = r.string()
+		e = ev
+	case binaryMouse:
+		var ev MouseEvent
+		ev.Time = r.time()
+		ev.Action = MouseAction(r.byte())
+		ev.Button = MouseButton(r.byte())
+		ev.X = int32(r.int())
+		ev.Y = int32(r.int())
+		b := r.byte()
+		ev.DoubleClick, ev.Horizontal = b&1 != 0, b&2 != 0
+		ev.Delta = int32(r.int())
+		ev.Flags = uint32(r.uint())
+		ev.ExtraInfo = uintptr(r.uint())
+		e = ev
+	case binaryFocus:
+		var ev FocusEvent
+		ev.Time = r.time()
+		ev.HWND = uintptr(r.uint())
+		ev.PID = uint32(r.uint())
+		ev.Process = r.string()
+		ev.Title = r.string()
+		// Records written before the flags byte end here.
+		if len(r.buf) > 0 {
+			ev.Elevated = r.byte()&1 != 0
+		}
+		e = ev
+	case binaryProcess:
+		var ev ProcessEvent
+		ev.Time = r.time()
+		ev.PID = uint32(r.uint())
+		ev.Name = r.string()
+		ev.Started = r.byte()&1 != 0
+		e = ev
+	case binaryGamepad:
+		var ev GamepadEvent
+		ev.Time = r.time()
+		ev.Pad = int(r.byte())
+		ev.Button = GamepadButton(r.uint())
+		ev.Down = r.byte()&1 != 0
+		ev.State.Buttons = GamepadButton(r.uint())
+		ev.State.LeftTrigger = r.byte()
+		ev.State.RightTrigger = r.byte()
+		ev.State.LeftX = int16(r.int())
+		ev.State.LeftY = int16(r.int())
+		ev.State.RightX = int16(r.int())
+		ev.State.RightY = int16(r.int())
+		e = ev
+	case binaryDiagnostic:
+		var ev DiagnosticEvent
+		ev.Time = r.time()
+		ev.Kind = r.string()
+		ev.Message = r.string()
+		e = ev
+	case binaryText:
+		var ev TextEvent
+		ev.Time = r.time()
+		ev.Start = r.time()
+		ev.Process = r.string()
+		ev.Title = r.string()
+		ev.Text = r.string()
+		ev.Keys = int(r.int())
+		ev.Uncertain = r.byte()&1 != 0
+		e = ev
+	default:
+		return nil, 0, errors.New("binary record: unknown event type")
+	}
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	e, err := migrate(e, version)
+	if err != nil {
+		return nil, 0, err
+	}
+	return e, k + int(n), nil
+}
+
+func appendUvarint(dst []byte, v uint64) []byte {
+	for v >= 0x80 {
+		dst = append(dst, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(dst, byte(v))
+}
+
+type binaryWriter struct {
+	buf []byte
+}
+
+func (w *binaryWriter) byte(b byte)     { w.buf = append(w.buf, b) }
+func (w *binaryWriter) uint(v uint64)   { w.buf = appendUvarint(w.buf, v) }
+func (w *binaryWriter) int(v int64)     { w.buf = appendUvarint(w.buf, uint64(v)<<1^uint64(v>>63)) }
+func (w *binaryWriter) string(s string) { w.uint(uint64(len(s))); w.buf = append(w.buf, s...) }
+
+func (w *binaryWriter) time(t time.Time) {
+	if t.IsZero() {
+		w.int(0)
+		return
+	}
+	w.int(t.UnixNano())
+}
+
+func (w *binaryWriter) bits(flags ...bool) {
+	var b byte
+	for i, f := range flags {
+		if f {
+			b |= 1 << i
+		}
+	}
+	w.byte(b)
+}
+
+/*
+	Reads fields until the first error, after which it returns zero values.
+*/
+type binaryReader struct {
+	buf []byte
+	err error
+}
+
+var errBinaryShort = errors.New("binary record: short field")
+
+func (r *binaryReader) byte() byte {
+	if r.err != nil || len(r.buf) == 0 {
+		r.err = errBinaryShort
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *binaryReader) uint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = errBinaryShort
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *binaryReader) int() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.err = errBinaryShort
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *binaryReader) string() string {
+	n := r.uint()
+	if r.err != nil || uint64(len(r.buf)) < n {
+		r.err = errBinaryShort
+		return ""
+	}
+	s := string(r.buf[:n])
+	r.buf = r.buf[n:]
+	return s
+}
+
+func (r *binaryReader) time() time.Time {
+	ns := r.int()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,110 @@
+package keylogger
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	BlockEvent is the audit record of a key press consumed by a Blocker.
+*/
+type BlockEvent struct {
+	Rule Hotkey
+	Key  KeyEvent
+	Time time.Time
+}
+
+/*
+	Blocker consumes configured keys and chords in the hook so they never
+	reach applications. A chord such as Alt+Tab is blocked whenever its key
+	is pressed with at least the chord's modifiers held; the modifiers
+	themselves still pass. Key combinations handled by the secure attention
+	sequence (Ctrl+Alt+Del, Win+L) are processed by Windows before any hook
+	and cannot be blocked.
+*/
+type Blocker struct {
+	rules   []Hotkey
+	mods    ModifierState
+	blocked map[uint16]bool
+	events  chan BlockEvent
+	dropped uint64
+}
+
+/*
+	NewBlocker returns a Blocker for the given rules.
+*/
+func NewBlocker(rules []Hotkey) *Blocker {
+	return &Blocker{
+		rules:   rules,
+		blocked: make(map[uint16]bool),
+		events:  make(chan BlockEvent, 64),
+	}
+}
+
+/*
+	ParseBlockRules parses the hotkeys of a configuration list.
+*/
+func ParseBlockRules(names []string) ([]Hotkey, error) {
+	rules := make([]Hotkey, 0, len(names))
+	for _, n := range names {
+		h, err := ParseHotkey(n)
+		if err != nil {
+			return nil, fmt.Errorf("block: %v", err)
+		}
+		rules = append(rules, h)
+	}
+	return rules, nil
+}
+
+/*
+	Events returns the audit channel receiving one BlockEvent per blocked key
+	press. Events are dropped rather than stalling the hook when nobody reads.
+*/
+func (b *Blocker) Events() <-chan BlockEvent {
+	return b.events
+}
+
+/*
+	Dropped returns the number of audit events lost because Events was full.
+*/
+func (b *Blocker) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+/*
+	Filter is a KeyFilter consuming blocked keys. Input sent by an Injector of
+	this package is never blocked.
+*/
+func (b *Blocker) Filter(e KeyEvent) bool {
+	if e.FromInjector() {
+		return false
+	}
+	mods := b.mods.Mods()
+	b.mods.Update(e)
+
+	if !e.Down {
+		// Swallow the release of every press we swallowed.
+		if b.blocked[e.VkCode] {
+			delete(b.blocked, e.VkCode)
+			return true
+		}
+		return false
+	}
+	for _, r := range b.rules {
+		if r.VkCode == e.VkCode && mods&r.Mods == r.Mods {
+			b.blocked[e.VkCode] = true
+			b.audit(BlockEvent{Rule: r, Key: e, Time: e.Time})
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Blocker) audit(ev BlockEvent) {
+	select {
+	case b.events <- ev:
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+	}
+}
@@ -0,0 +1,87 @@
+package keylogger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBlocker(t *testing.T) {
+	rules, err := ParseBlockRules([]string{"PrintScreen", "Alt+Tab", "Ctrl+Shift+T"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name string
+		keys []KeyEvent
+		want []bool
+	}{
+		{"key", []KeyEvent{
+			{VkCode: VK_SNAPSHOT, Down: true}, {VkCode: VK_SNAPSHOT},
+		}, []bool{true, true}},
+		{"chord", []KeyEvent{
+			{VkCode: VK_LMENU, Down: true}, {VkCode: VK_TAB, Down: true}, {VkCode: VK_TAB}, {VkCode: VK_LMENU},
+		}, []bool{false, true, true, false}},
+		{"more modifiers than the chord", []KeyEvent{
+			{VkCode: VK_RMENU, Down: true}, {VkCode: VK_LSHIFT, Down: true}, {VkCode: VK_TAB, Down: true}, {VkCode: VK_TAB},
+		}, []bool{false, false, true, true}},
+		{"fewer modifiers than the chord", []KeyEvent{
+			{VkCode: VK_LCONTROL, Down: true}, {VkCode: 'T', Down: true}, {VkCode: 'T'},
+		}, []bool{false, false, false}},
+		{"chord key alone", []KeyEvent{
+			{VkCode: VK_TAB, Down: true}, {VkCode: VK_TAB},
+		}, []bool{false, false}},
+		{"modifier released before the key", []KeyEvent{
+			{VkCode: VK_LMENU, Down: true}, {VkCode: VK_TAB, Down: true}, {VkCode: VK_LMENU}, {VkCode: VK_TAB},
+		}, []bool{false, true, false, true}},
+		{"modifier pressed during the key", []KeyEvent{
+			{VkCode: VK_TAB, Down: true}, {VkCode: VK_LMENU, Down: true}, {VkCode: VK_TAB}, {VkCode: VK_LMENU},
+		}, []bool{false, false, false, false}},
+		{"auto-repeat", []KeyEvent{
+			{VkCode: VK_SNAPSHOT, Down: true}, {VkCode: VK_SNAPSHOT, Down: true}, {VkCode: VK_SNAPSHOT},
+		}, []bool{true, true, true}},
+		{"injected", []KeyEvent{
+			{VkCode: VK_SNAPSHOT, Flags: LLKHF_INJECTED, ExtraInfo: InjectedSignature, Down: true},
+			{VkCode: VK_SNAPSHOT, Flags: LLKHF_INJECTED, ExtraInfo: InjectedSignature},
+		}, []bool{false, false}},
+	} {
+		b := NewBlocker(rules)
+		var got []bool
+		for _, e := range c.keys {
+			got = append(got, b.Filter(e))
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: swallowed %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+/*
+	Each blocked press is audited with the rule that blocked it; releases
+	are not, and audit events nobody reads are counted as dropped.
+*/
+func TestBlockerAudit(t *testing.T) {
+	b := NewBlocker([]Hotkey{{Mods: ModAlt, VkCode: VK_TAB}})
+	b.Filter(KeyEvent{VkCode: VK_LMENU, Down: true})
+	b.Filter(KeyEvent{VkCode: VK_TAB, Down: true})
+	b.Filter(KeyEvent{VkCode: VK_TAB})
+	select {
+	case e := <-b.Events():
+		if e.Rule != (Hotkey{Mods: ModAlt, VkCode: VK_TAB}) || e.Key.VkCode != VK_TAB || !e.Key.Down {
+			t.Errorf("audit event %+v", e)
+		}
+	default:
+		t.Fatal("no audit event")
+	}
+	select {
+	case e := <-b.Events():
+		t.Errorf("second audit event %+v", e)
+	default:
+	}
+
+	for i := 0; i < cap(b.events)+3; i++ {
+		b.Filter(KeyEvent{VkCode: VK_TAB, Down: true})
+	}
+	if n := b.Dropped(); n != 3 {
+		t.Errorf("Dropped = %d, want 3", n)
+	}
+}
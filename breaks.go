@@ -0,0 +1,151 @@
+package keylogger
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	BreakRules configures a BreakReminder. A break is due after
+	MaxTypingMinutes of typing without a pause of PauseMinutes, or when more
+	than MaxKeysPerMinute keys were pressed in the last minute; either limit
+	is off when zero. With LockoutSeconds, the keyboard is locked for that
+	long once a break is due.
+*/
+type BreakRules struct {
+	MaxTypingMinutes float64 `json:"max_typing_minutes,omitempty"`
+	PauseMinutes     float64 `json:"pause_minutes,omitempty"`
+	MaxKeysPerMinute int     `json:"max_keys_per_minute,omitempty"`
+	LockoutSeconds   float64 `json:"lockout_seconds,omitempty"`
+}
+
+/*
+	DefaultBreakPauseMinutes is how long a pause in typing has to be to count
+	as a break when the rules do not say.
+*/
+const DefaultBreakPauseMinutes = 5
+
+/*
+	Validate reports the first invalid rule.
+*/
+func (r BreakRules) Validate() error {
+	switch {
+	case r.MaxTypingMinutes < 0, r.PauseMinutes < 0, r.MaxKeysPerMinute < 0, r.LockoutSeconds < 0:
+		return fmt.Errorf("breaks: limits must not be negative")
+	case r.MaxTypingMinutes == 0 && r.MaxKeysPerMinute == 0:
+		return fmt.Errorf("breaks: neither max_typing_minutes nor max_keys_per_minute is set")
+	}
+	return nil
+}
+
+/*
+	BreakEvent reports that a break is due: how long the typing went on
+	without one, the keys pressed in the last minute and how long the
+	keyboard is locked.
+*/
+type BreakEvent struct {
+	Typing        time.Duration
+	KeysPerMinute int
+	Lockout       time.Duration
+	Time          time.Time
+}
+
+/*
+	BreakReminder tracks how long and how fast physical keys are pressed and
+	reports when a break is due, locking the keyboard for a while if the
+	rules ask for it. Input sent by an Injector of this package does not
+	count and is never locked out.
+*/
+type BreakReminder struct {
+	dropped uint64 // first for 64-bit alignment on 386
+
+	maxTyping time.Duration
+	pause     time.Duration
+	maxKeys   int
+	lockout   time.Duration
+
+	start       time.Time // of the typing without a break
+	last        time.Time // the last key press
+	keys        minuteWindow
+	lockedUntil time.Time
+	locked      map[uint16]bool
+	events      chan BreakEvent
+}
+
+/*
+	NewBreakReminder returns a BreakReminder for the given rules.
+*/
+func NewBreakReminder(rules BreakRules) *BreakReminder {
+	pause := rules.PauseMinutes
+	if pause == 0 {
+		pause = DefaultBreakPauseMinutes
+	}
+	return &BreakReminder{
+		maxTyping: time.Duration(rules.MaxTypingMinutes * float64(time.Minute)),
+		pause:     time.Duration(pause * float64(time.Minute)),
+		maxKeys:   rules.MaxKeysPerMinute,
+		lockout:   time.Duration(rules.LockoutSeconds * float64(time.Second)),
+		locked:    make(map[uint16]bool),
+		events:    make(chan BreakEvent, 8),
+	}
+}
+
+/*
+	Events returns the channel receiving a BreakEvent whenever a break is
+	due. Events are dropped rather than stalling the hook when nobody reads.
+*/
+func (b *BreakReminder) Events() <-chan BreakEvent {
+	return b.events
+}
+
+/*
+	Dropped returns the number of events lost because Events was full.
+*/
+func (b *BreakReminder) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+/*
+	Filter is a KeyFilter counting key presses and consuming them while the
+	keyboard is locked, along with their releases.
+*/
+func (b *BreakReminder) Filter(e KeyEvent) bool {
+	if e.FromInjector() {
+		return false
+	}
+	if !e.Down {
+		if b.locked[e.VkCode] {
+			delete(b.locked, e.VkCode)
+			return true
+		}
+		return false
+	}
+	if e.Time.Before(b.lockedUntil) {
+		b.locked[e.VkCode] = true
+		return true
+	}
+	if b.last.IsZero() || e.Time.Sub(b.last) >= b.pause {
+		b.start = e.Time
+	}
+	b.last = e.Time
+	keys := b.keys.add(e.Time)
+	typing := e.Time.Sub(b.start)
+	if !(b.maxTyping > 0 && typing >= b.maxTyping || b.maxKeys > 0 && keys > b.maxKeys) {
+		return false
+	}
+	// The break starts now; the reminder counts afresh from the next key.
+	b.start, b.last = time.Time{}, time.Time{}
+	b.keys = minuteWindow{}
+	b.lockedUntil = e.Time.Add(b.lockout)
+	select {
+	case b.events <- BreakEvent{Typing: typing, KeysPerMinute: keys, Lockout: b.lockout, Time: e.Time}:
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+	}
+	if b.lockout > 0 {
+		b.locked[e.VkCode] = true
+		return true
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+//go:build !localonly
+// +build !localonly
+
+package main
+
+import (
+	"flag"
+	"time"
+
+	"keylogger"
+)
+
+/*
+	Options of the -activitywatch sink.
+*/
+type activityWatchOptions struct {
+	server     string
+	afkTimeout time.Duration
+}
+
+func activityWatchFlags(flags *flag.FlagSet) *activityWatchOptions {
+	o := &activityWatchOptions{}
+	flags.StringVar(&o.server, "activitywatch", "", "report the foreground window and away time to this ActivityWatch server, e.g. "+keylogger.DefaultActivityWatchServer)
+	flags.DurationVar(&o.afkTimeout, "afk-timeout", keylogger.DefaultActivityWatchAFKTimeout, "with -activitywatch, how long without input counts as away")
+	return o
+}
+
+func (o *activityWatchOptions) enabled() bool {
+	return o.server != ""
+}
+
+func (o *activityWatchOptions) sink() (keylogger.Sink, error) {
+	return keylogger.NewActivityWatch(o.server, 0, o.afkTimeout)
+}
@@ -0,0 +1,27 @@
+//go:build localonly
+// +build localonly
+
+package main
+
+import (
+	"flag"
+
+	"keylogger"
+)
+
+/*
+	Builds with the localonly tag have no -activitywatch sink.
+*/
+type activityWatchOptions struct{}
+
+func activityWatchFlags(flags *flag.FlagSet) *activityWatchOptions {
+	return &activityWatchOptions{}
+}
+
+func (o *activityWatchOptions) enabled() bool {
+	return false
+}
+
+func (o *activityWatchOptions) sink() (keylogger.Sink, error) {
+	return nil, nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"keylogger"
+)
+
+/*
+	audit verify file: checks that no record of an audit log was edited or
+	removed and prints the head, to compare with an earlier copy.
+*/
+func auditCommand(args []string) {
+	if len(args) != 2 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, "usage: keylogger audit verify audit.jsonl")
+		os.Exit(2)
+	}
+	n, head, err := keylogger.VerifyAuditLog(args[1])
+	if err != nil {
+		fatalf("%s: %v after %d intact records", args[1], err, n)
+	}
+	fmt.Printf("%s: %d records, chain intact, head %s\n", args[1], n, head)
+}
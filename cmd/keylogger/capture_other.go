@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+func capture(args []string) {
+	fatalf("capturing input is only supported on Windows")
+}
+
+func replayCommand(args []string) {
+	fatalf("replaying traces is only supported on Windows")
+}
+
+func typingTestCommand(args []string) {
+	fatalf("typing tests are only supported on Windows")
+}
+
+func latencyCommand(args []string) {
+	fatalf("latency tests are only supported on Windows")
+}
+
+func rolloverTestCommand(args []string) {
+	fatalf("rollover tests are only supported on Windows")
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"keylogger"
+)
+
+/*
+	Captures keyboard input until interrupted, running the macro recorder,
+	hotstrings, alerts, break reminders, remapping, blocking and scripts as
+	configured. Typed keys are printed only with -print-keys.
+*/
+func capture(args []string) {
+	flags := flag.NewFlagSet("keylogger", flag.ExitOnError)
+	macros := flags.Bool("macro", false, "record macros with F9, replay them with F10 and abort playback with Pause")
+	macroSpeed := flags.Float64("macro-speed", 1, "macro playback speed multiplier")
+	macroDelay := flags.Duration("macro-delay", 0, "fixed delay between macro steps, replacing the recorded timing")
+	macroLoops := flags.Int("macro-loops", 1, "number of times to replay a macro, -1 to repeat until aborted")
+	macroFile := flags.String("macro-file", "", "load the macro from and save recordings to this file")
+	configFile := flags.String("config", "", "JSON configuration file")
+	auditFile := flags.String("audit", "", "append starts, stops, configuration, filters and sinks to this audit log")
+	scriptFile := flags.String("script", "", "Lua automation script")
+	keyboard := flags.Bool("keyboard", true, "capture keyboard input")
+	mouse := flags.Bool("mouse", false, "capture mouse input")
+	gamepad := flags.Bool("gamepad", false, "capture XInput controller buttons and sticks")
+	focus := flags.Bool("focus", false, "report foreground window changes")
+	printKeys := flags.Bool("print-keys", false, "print the typed keys and -text to the console; not allowed with -redact or -pseudonymize")
+	watch := flags.String("watch", "", "comma-separated executables to report launches and exits of, * for all")
+	target := flags.String("target", "", "comma-separated executables to capture the input into, while one is in the foreground")
+	onScreen := flags.String("on-screen-keyboards", "", "comma-separated executables of on-screen keyboards besides those of Windows, whose injected keys count as typed")
+	logFile := flags.String("log", "", "append captured events to this file as JSON lines")
+	redact := flags.String("redact", "", "comma-separated redactions applied to -log: credit-card, ssn, email or regular expressions")
+	pseudonymKey := flags.String("pseudonymize", "", "log HMACs of typed characters instead of the characters, keyed by this file (created if missing)")
+	mmapLog := flags.Bool("mmap", false, "write -log as binary records through a memory-mapped file")
+	msgpackLog := flags.Bool("msgpack", false, "write -log as MessagePack records")
+	retention := flags.Duration("retention", 0, "remove events older than this from -log every hour, e.g. 168h")
+	traceFile := flags.String("trace", "", "record the raw events to this file for keylogger replay; not allowed with -redact or -pseudonymize")
+	overlayPosition := flags.String("overlay", "", "show the pressed keys on screen at this position: "+strings.Join(keylogger.OverlayPositions, ", "))
+	overlayTheme := flags.String("overlay-theme", "dark", "look of -overlay: dark, light or contrast")
+	overlayKeyNames := flags.String("overlay-key-names", "en", "language -overlay names keys in: system, "+strings.Join(keylogger.KeyNameLanguages(), ", "))
+	apmFile := flags.String("apm", "", "count actions per minute, overall and by application, and write them to this JSON file every minute")
+	hookDump := flags.String("hook-dump", "", "write every call of the keyboard hook to this file as text, to debug missing keys; not allowed with -redact or -pseudonymize")
+	etw := flags.Bool("etw", false, "write key, mouse and focus events to the Keylogger ETW provider, for WPA and PerfView")
+	walFile := flags.String("wal", "", "write-ahead log protecting -log writes against crashes")
+	deadLetter := flags.String("dead-letter", "", "append the events -activitywatch, -wakatime and -obs still fail to take after retrying to this file as JSON lines")
+	batchSize := flags.Int("batch-size", keylogger.DefaultBatchSize, "write the log after this many events")
+	batchDelay := flags.Duration("batch-delay", keylogger.DefaultBatchDelay, "write the log at least this often")
+	stopTimeout := flags.Duration("stop-timeout", keylogger.DefaultStopTimeout, "on Ctrl+C, how long to wait for the last events to be written")
+	loopWatchdog := flags.Duration("loop-watchdog", 5*time.Second, "move the hooks to a new thread if their message loop stops responding for this long, 0 to disable")
+	debounce := flags.Duration("debounce", 0, "drop key presses this soon after the key was released or pressed, the chatter of worn switches, e.g. 10ms")
+	layout := flags.String("layout", "", "log the keys as this layout types them, by their position: "+strings.Join(keylogger.LayoutNames(), ", "))
+	watchdog := flags.Duration("watchdog", 10*time.Second, "check this often that Windows has not removed the hooks, 0 to disable")
+	pauseLocked := flags.Bool("pause-locked", true, "remove the hooks while the workstation is locked")
+	text := flags.Bool("text", false, "log the text each burst of typing left, with Backspace, arrows and selection applied")
+	sessions := flags.Bool("sessions", true, "log the session and user, and remote desktop connects and disconnects")
+	devices := flags.Bool("devices", false, "log keyboards being attached and removed")
+	elevate := flags.Bool("elevate", false, "start again as administrator, after a UAC prompt, to capture input into elevated windows too")
+	backpressure := flags.String("backpressure", "block", "what to do with events while output falls behind: block, drop-newest, drop-oldest or spill")
+	backpressureTimeout := flags.Duration("backpressure-timeout", 0, "with -backpressure block, drop events that waited this long")
+	spillDir := flags.String("spill-dir", "", "directory for -backpressure spill files")
+	listen := listenFlags(flags)
+	activityWatch := activityWatchFlags(flags)
+	wakaTime := wakaTimeFlags(flags)
+	obs := obsFlags(flags)
+	crashReport := crashReportFlags(flags)
+	flags.Parse(args)
+	if *elevate && !keylogger.Elevated() {
+		if err := keylogger.RelaunchElevated(args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	policy, err := keylogger.ParseBackpressurePolicy(*backpressure)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config := &keylogger.Config{}
+	if *configFile != "" {
+		c, err := keylogger.LoadConfig(*configFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		config = c
+	}
+	if config.LocalOnly && listen.enabled() {
+		log.Fatalf("%s is local only: -metrics is not allowed", *configFile)
+	}
+	if config.LocalOnly && activityWatch.enabled() {
+		log.Fatalf("%s is local only: -activitywatch is not allowed", *configFile)
+	}
+	if config.LocalOnly && wakaTime.enabled() {
+		log.Fatalf("%s is local only: -wakatime is not allowed", *configFile)
+	}
+	if config.LocalOnly && obs.enabled() {
+		log.Fatalf("%s is local only: -obs is not allowed", *configFile)
+	}
+	if config.LocalOnly && crashReport.enabled() {
+		log.Fatalf("%s is local only: -crash-report is not allowed", *configFile)
+	}
+
+	logger := keylogger.NewLogger()
+	if crashReport.enabled() {
+		if err := crashReport.start(); err != nil {
+			log.Fatal(err)
+		}
+		defer crashReport.close()
+		defer crashReport.recover()
+		logger.Panicked = crashReport.panicked
+		logger.SinkFailed = crashReport.sinkFailed
+	}
+	if *auditFile != "" {
+		a, err := keylogger.OpenAuditLog(*auditFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer a.Close()
+		logger.Audit = a
+		if *configFile != "" {
+			if err := a.Record(keylogger.AuditConfig, *configFile); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	logger.CaptureKeyboard = *keyboard
+	logger.CaptureMouse = *mouse
+	logger.CaptureGamepad = *gamepad
+	logger.Watchdog = *watchdog
+	logger.LoopWatchdog = *loopWatchdog
+	logger.PauseWhenLocked = *pauseLocked
+	logger.WatchSessions = *sessions
+	logger.WatchDevices = *devices
+	logger.ReconstructText = *text
+	logger.Backpressure = keylogger.Backpressure{Policy: policy, Timeout: *backpressureTimeout, SpillDir: *spillDir}
+	// Hotstrings scoped to applications follow the foreground window;
+	// alerts, actions per minute, ActivityWatch and WakaTime name it, and
+	// sensitive applications pause the capture while they are in front.
+	if config.Sensitive != nil {
+		logger.Sensitive = *config.Sensitive
+	}
+	if config.Target != nil {
+		logger.Target = *config.Target
+	}
+	if *target != "" {
+		logger.Target.Apps = strings.Split(*target, ",")
+	}
+	if *onScreen != "" {
+		logger.OnScreenKeyboards = strings.Split(*onScreen, ",")
+	}
+	logger.CaptureFocus = *focus || *text || len(config.Hotstrings) > 0 || len(config.Alerts) > 0 || *apmFile != "" ||
+		activityWatch.enabled() || wakaTime.enabled() || len(logger.Sensitive.Apps) > 0 && !logger.Sensitive.Running
+	if *watch != "" {
+		logger.WatchApps = strings.Split(*watch, ",")
+	}
+	logger.Debounce.Window = *debounce
+	if *layout != "" {
+		m, err := keylogger.NewLayoutMapper(*layout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logger.Layout = m
+	}
+	logger.MoveSampling.MaxRate = config.MouseSettings().MaxMovesPerSecond
+	logger.MoveSampling.MinDistance = config.MouseSettings().MinMoveDistance
+	if len(config.Remap) > 0 {
+		table, _ := keylogger.ParseRemap(config.Remap)
+		remapper := keylogger.NewRemapper(table, &keylogger.Injector{ExtraInfo: keylogger.RemapSignature})
+		if err := logger.AddFilter(remapper.Filter); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if patterns := config.Redact; len(patterns) > 0 || *redact != "" {
+		if *redact != "" {
+			patterns = append(patterns, strings.Split(*redact, ",")...)
+		}
+		r, err := keylogger.NewRedactor(patterns)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logger.Redact = r
+	}
+	if *pseudonymKey != "" {
+		key, err := keylogger.LoadPseudonymKey(*pseudonymKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if logger.Pseudonymize, err = keylogger.NewPseudonymizer(key); err != nil {
+			log.Fatal(err)
+		}
+		for i := range key {
+			key[i] = 0
+		}
+	}
+	redacted := logger.Redact != nil || logger.Pseudonymize != nil
+	err = checkClearText(redacted,
+		clearText{"-print-keys", *printKeys},
+		clearText{"-trace", *traceFile != ""},
+		clearText{"-hook-dump", *hookDump != ""})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.Breaks != nil {
+		reminder := keylogger.NewBreakReminder(*config.Breaks)
+		if err := logger.AddFilter(reminder.Filter); err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			for ev := range reminder.Events() {
+				msg := fmt.Sprintf("You have been typing for %v (%d keys in the last minute). Time for a break.",
+					ev.Typing.Round(time.Minute), ev.KeysPerMinute)
+				if ev.Lockout > 0 {
+					msg += fmt.Sprintf(" The keyboard is locked for %v.", ev.Lockout)
+				}
+				log.Print("break: ", msg)
+				go notify("Break reminder", msg)
+			}
+		}()
+	}
+	if len(config.Block) > 0 {
+		rules, _ := keylogger.ParseBlockRules(config.Block)
+		blocker := keylogger.NewBlocker(rules)
+		if err := logger.AddFilter(blocker.Filter); err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			for ev := range blocker.Events() {
+				log.Printf("blocked %s (rule %s)", keylogger.KeyName(ev.Key.VkCode), ev.Rule)
+			}
+		}()
+	}
+	var script *keylogger.Script
+	if *scriptFile != "" {
+		foreground := func() string {
+			_, app := keylogger.ForegroundProcess()
+			return app
+		}
+		s, err := keylogger.LoadScript(*scriptFile, keylogger.NewInjector(), foreground, log.Printf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		script = s
+		if err := logger.AddFilter(script.Filter); err != nil {
+			log.Fatal(err)
+		}
+		go script.Run()
+	}
+	if *logFile != "" {
+		f, err := openLog(*logFile, *mmapLog, *msgpackLog, *retention)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s := f
+		if *walFile != "" {
+			w, err := keylogger.OpenWAL(*walFile, f)
+			if err != nil {
+				log.Fatal(err)
+			}
+			s = w
+		}
+		if err := logger.AddSink(keylogger.NewBatcher(s, *batchSize, *batchDelay)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if activityWatch.enabled() {
+		s, err := activityWatch.sink()
+		if err == nil {
+			s, err = retried(s, *deadLetter)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := logger.AddSink(s); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if wakaTime.enabled() {
+		s, err := wakaTime.sink(func() (string, string) {
+			hwnd := keylogger.GetForegroundWindow()
+			_, app := keylogger.ForegroundProcess()
+			return app, keylogger.GetWindowText(hwnd)
+		})
+		if err == nil {
+			s, err = retried(s, *deadLetter)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := logger.AddSink(s); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if obs.enabled() {
+		s, err := obs.sink()
+		if err == nil {
+			s, err = retried(s, *deadLetter)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := logger.AddSink(s); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *etw {
+		p, err := keylogger.NewETWProvider()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := logger.AddSink(p); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		logger.Trace = f
+	}
+	if *hookDump != "" {
+		f, err := os.Create(*hookDump)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		logger.HookDump = f
+	}
+	var overlay *keylogger.Overlay
+	if *overlayPosition != "" {
+		theme, ok := keylogger.OverlayThemes[*overlayTheme]
+		if !ok {
+			log.Fatalf("unknown overlay theme %q", *overlayTheme)
+		}
+		names, err := keylogger.KeyNamer(*overlayKeyNames)
+		if err != nil {
+			log.Fatal(err)
+		}
+		overlay = keylogger.NewOverlay()
+		overlay.Position, overlay.Theme = *overlayPosition, theme
+		overlay.Display.Names = names
+		if err := overlay.Show(); err != nil {
+			log.Fatal(err)
+		}
+		defer overlay.Close()
+	}
+	var notifier *keylogger.Notifier
+	notifyRules := keylogger.NotifyRules{}
+	if config.Notify != nil {
+		notifyRules = *config.Notify
+		notifier = keylogger.NewNotifier("keylogger is capturing input")
+		if err := notifier.Show(); err != nil {
+			log.Fatal(err)
+		}
+		defer notifier.Close()
+		if notifyRules.DiskFreeMB > 0 && *logFile != "" {
+			go watchDiskFree(notifier, *logFile, uint64(notifyRules.DiskFreeMB)<<20)
+		}
+	}
+	if err := logger.Start(); err != nil {
+		log.Fatal(err)
+	}
+	if listen.enabled() {
+		listen.metrics(logger.Stats)
+	}
+	// Ctrl+C stops the capture; the loop below ends once the last events are written.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		ctx, cancel := context.WithTimeout(context.Background(), *stopTimeout)
+		defer cancel()
+		if err := logger.StopContext(ctx); err != nil {
+			log.Print(err)
+		}
+	}()
+
+	injector := keylogger.NewInjector()
+	recorder := keylogger.NewRecorder(
+		keylogger.Hotkey{VkCode: keylogger.VK_F9},
+		keylogger.Hotkey{VkCode: keylogger.VK_F10},
+	)
+	recorder.AbortKey = keylogger.Hotkey{VkCode: keylogger.VK_PAUSE}
+	var abort chan struct{}
+	hotstrings := keylogger.NewHotstrings(config.Hotstrings)
+	alerts := keylogger.NewAlerts(config.Alerts)
+
+	if *macroFile != "" {
+		m, err := keylogger.LoadMacroFile(*macroFile)
+		if err == nil {
+			recorder.SetMacro(m)
+		} else if !os.IsNotExist(err) {
+			log.Fatal(err)
+		}
+	}
+
+	var apm *keylogger.APMCounter
+	if *apmFile != "" {
+		apm = keylogger.NewAPMCounter()
+		defer writeAPM(*apmFile, apm)
+		go func() {
+			for range time.Tick(time.Minute) {
+				writeAPM(*apmFile, apm)
+			}
+		}()
+	}
+
+	var app string
+	var line []byte
+	for ev := range logger.Events() {
+		if apm != nil {
+			apm.Feed(ev, app)
+		}
+		switch e := ev.(type) {
+		case keylogger.KeyEvent:
+			if *macros {
+				switch recorder.Feed(e) {
+				case keylogger.RecorderStarted:
+					log.Print("recording macro")
+				case keylogger.RecorderStopped:
+					log.Printf("recorded %d steps", len(recorder.Macro().Steps))
+					if *macroFile != "" {
+						if err := keylogger.SaveMacroFile(*macroFile, recorder.Macro()); err != nil {
+							log.Print(err)
+						}
+					}
+				case keylogger.RecorderPlay:
+					if abort != nil {
+						close(abort)
+					}
+					abort = make(chan struct{})
+					opts := keylogger.PlayOptions{
+						Speed:      *macroSpeed,
+						FixedDelay: *macroDelay,
+						Loops:      *macroLoops,
+						Abort:      abort,
+					}
+					go func(m *keylogger.Macro) {
+						if err := m.PlayWith(injector, opts); err != nil {
+							log.Print(err)
+						}
+					}(recorder.Macro())
+				case keylogger.RecorderAbort:
+					if abort != nil {
+						close(abort)
+						abort = nil
+					}
+				}
+			}
+			if script != nil {
+				script.Feed(e)
+			}
+			if overlay != nil {
+				overlay.Feed(e)
+			}
+			if len(hotstrings.Rules) > 0 {
+				if x, ok := hotstrings.Feed(e, app); ok {
+					go func() {
+						if err := x.Apply(injector); err != nil {
+							log.Print(err)
+						}
+					}()
+				}
+			}
+			if len(alerts.Rules) > 0 {
+				if a, ok := alerts.Feed(e, app); ok {
+					if redacted {
+						// The context is typed text the log does not get either.
+						log.Printf("alert: %q typed into %s", a.Phrase, a.App)
+					} else {
+						log.Printf("alert: %q typed into %s: %q", a.Phrase, a.App, a.Context)
+					}
+					if notifyRules.Alerts {
+						// The context stays in the log: notifications are kept in the Action Center.
+						toast(notifier, "Alert: "+a.Phrase, "Typed into "+a.App, false)
+					}
+				}
+			}
+			if *printKeys && e.Down && !e.Swallowed {
+				// Formatted by hand into a reused buffer; Printf allocates per key.
+				line = strconv.AppendQuoteRune(line[:0], rune(byte(e.VkCode)))
+				os.Stdout.Write(append(line, '\n'))
+			}
+		case keylogger.MouseEvent:
+			switch {
+			case e.Action == keylogger.MouseScroll:
+				fmt.Printf("mouse scroll %s %.2f (%d, %d)\n", e.Direction(), e.Notches(), e.X, e.Y)
+			case e.DoubleClick:
+				fmt.Printf("mouse %s double-click (%d, %d)\n", e.Button, e.X, e.Y)
+			case e.Action == keylogger.MouseMove:
+				fmt.Printf("mouse move (%d, %d)\n", e.X, e.Y)
+			default:
+				fmt.Printf("%s %s %s (%d, %d)\n", e.Source(), e.Button, e.Action, e.X, e.Y)
+			}
+		case keylogger.FocusEvent:
+			app = e.Process
+			if *focus {
+				fmt.Printf("focus %s %q%s\n", e.Process, e.Title, elevatedMark(e))
+			}
+		case keylogger.GamepadEvent:
+			switch {
+			case e.Button == 0:
+				s := e.State
+				fmt.Printf("pad %d sticks (%d, %d) (%d, %d) triggers %d %d\n", e.Pad, s.LeftX, s.LeftY, s.RightX, s.RightY, s.LeftTrigger, s.RightTrigger)
+			case e.Down:
+				fmt.Printf("pad %d %s down\n", e.Pad, e.Button)
+			default:
+				fmt.Printf("pad %d %s up\n", e.Pad, e.Button)
+			}
+		case keylogger.TextEvent:
+			if *printKeys {
+				fmt.Printf("text %s %q%s\n", e.Process, e.Text, uncertainMark(e))
+			}
+		case keylogger.DiagnosticEvent:
+			log.Printf("%s: %s", e.Kind, e.Message)
+			if e.Kind == keylogger.DiagHookLost && notifyRules.HookLost {
+				toast(notifier, "Capture stopped", e.Message, true)
+			}
+		case keylogger.ProcessEvent:
+			if e.Started {
+				fmt.Printf("started %s (%d)\n", e.Name, e.PID)
+			} else {
+				fmt.Printf("exited %s (%d)\n", e.Name, e.PID)
+			}
+		}
+	}
+	if *debounce > 0 {
+		printChatter(logger.ChatterStats())
+	}
+	// The capture is over; clear what is left of the typed text.
+	hotstrings.Wipe()
+	alerts.Wipe()
+	line = line[:cap(line)]
+	for i := range line {
+		line[i] = 0
+	}
+	if logger.Pseudonymize != nil {
+		logger.Pseudonymize.Wipe()
+	}
+}
+
+/*
+	Reports the keys that chattered, for the health of the keyboard.
+*/
+func printChatter(s keylogger.ChatterStats) {
+	if s.Filtered == 0 {
+		log.Print("debounce: no key chattered")
+		return
+	}
+	keys := make([]string, len(s.Keys))
+	for i, k := range s.Keys {
+		keys[i] = fmt.Sprintf("%s %d", keylogger.KeyName(k.VkCode), k.Count)
+	}
+	log.Printf("debounce: dropped %d presses as chatter since %s: %s", s.Filtered, s.Since.Format("15:04:05"), strings.Join(keys, ", "))
+}
+
+/*
+	Writes the actions per minute counted so far to name, replacing what
+	was written before.
+*/
+func writeAPM(name string, apm *keylogger.APMCounter) {
+	data, err := json.MarshalIndent(apm.Report(time.Now()), "", "  ")
+	if err == nil {
+		err = os.WriteFile(name, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		log.Printf("apm: %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import "fmt"
+
+/*
+	An output of the capture that holds typed keys in clear text, and
+	whether it was asked for.
+*/
+type clearText struct {
+	flag string
+	on   bool
+}
+
+/*
+	Refuses the first clear text output asked for while the log is redacted
+	or pseudonymized, since it would hold what those keep out of the log.
+*/
+func checkClearText(redacted bool, outputs ...clearText) error {
+	if !redacted {
+		return nil
+	}
+	for _, o := range outputs {
+		if o.on {
+			return fmt.Errorf("%s would hold what redaction and -pseudonymize keep out of the log", o.flag)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestCheckClearText(t *testing.T) {
+	tests := []struct {
+		redacted bool
+		outputs  []clearText
+		refused  bool
+	}{
+		{false, []clearText{{"-print-keys", true}, {"-trace", true}}, false},
+		{true, []clearText{{"-print-keys", false}, {"-trace", false}}, false},
+		{true, []clearText{{"-print-keys", true}, {"-trace", false}}, true},
+		{true, []clearText{{"-print-keys", false}, {"-trace", true}}, true},
+	}
+	for _, tt := range tests {
+		if err := checkClearText(tt.redacted, tt.outputs...); (err != nil) != tt.refused {
+			t.Errorf("checkClearText(%v, %v) = %v, want refused %v", tt.redacted, tt.outputs, err, tt.refused)
+		}
+	}
+}
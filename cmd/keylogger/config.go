@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"keylogger"
+)
+
+/*
+	config protect [-machine] < secret: prints the secret read from stdin
+	encrypted with DPAPI, to be pasted into a configuration file in place
+	of the plaintext.
+*/
+func configCommand(args []string) {
+	if len(args) == 0 || args[0] != "protect" {
+		fmt.Fprintln(os.Stderr, "usage: keylogger config protect [-machine] < secret")
+		os.Exit(2)
+	}
+	flags := flag.NewFlagSet("config protect", flag.ExitOnError)
+	machine := flags.Bool("machine", false, "let every user of this machine reveal the secret, not only the current user")
+	flags.Parse(args[1:])
+
+	plain, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	plain = bytes.TrimRight(plain, "\r\n")
+	scope := keylogger.SecretUser
+	if *machine {
+		scope = keylogger.SecretMachine
+	}
+	s, err := keylogger.ProtectSecret(plain, scope)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Println(s)
+}
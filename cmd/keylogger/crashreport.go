@@ -0,0 +1,64 @@
+//go:build !localonly
+// +build !localonly
+
+package main
+
+import (
+	"flag"
+
+	"keylogger"
+)
+
+/*
+	Options of -crash-report.
+*/
+type crashReportOptions struct {
+	dsn      string
+	reporter *keylogger.CrashReporter
+}
+
+func crashReportFlags(flags *flag.FlagSet) *crashReportOptions {
+	o := &crashReportOptions{}
+	flags.StringVar(&o.dsn, "crash-report", "", "send panics and sink failures, never captured input, to this Sentry DSN")
+	return o
+}
+
+func (o *crashReportOptions) enabled() bool {
+	return o.dsn != ""
+}
+
+func (o *crashReportOptions) start() error {
+	r, err := keylogger.NewCrashReporter(o.dsn)
+	o.reporter = r
+	return err
+}
+
+func (o *crashReportOptions) panicked(v interface{}) {
+	o.reporter.Panic(v)
+}
+
+func (o *crashReportOptions) sinkFailed(s keylogger.Sink, err error) {
+	o.reporter.SinkFailed(s, err)
+}
+
+/*
+	Reports a panic of the goroutine it is deferred in and panics on.
+*/
+func (o *crashReportOptions) recover() {
+	if o.reporter == nil {
+		return
+	}
+	if v := recover(); v != nil {
+		o.reporter.Panic(v)
+		panic(v)
+	}
+}
+
+/*
+	Sends the reports still queued.
+*/
+func (o *crashReportOptions) close() {
+	if o.reporter != nil {
+		o.reporter.Close()
+	}
+}
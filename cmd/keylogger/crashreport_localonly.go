@@ -0,0 +1,35 @@
+//go:build localonly
+// +build localonly
+
+package main
+
+import (
+	"flag"
+
+	"keylogger"
+)
+
+/*
+	Builds with the localonly tag send no crash reports.
+*/
+type crashReportOptions struct{}
+
+func crashReportFlags(flags *flag.FlagSet) *crashReportOptions {
+	return &crashReportOptions{}
+}
+
+func (o *crashReportOptions) enabled() bool {
+	return false
+}
+
+func (o *crashReportOptions) start() error {
+	return nil
+}
+
+func (o *crashReportOptions) panicked(v interface{}) {}
+
+func (o *crashReportOptions) sinkFailed(s keylogger.Sink, err error) {}
+
+func (o *crashReportOptions) recover() {}
+
+func (o *crashReportOptions) close() {}
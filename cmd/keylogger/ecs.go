@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"keylogger"
+)
+
+/*
+	ecs [-from time] [-to time] [-mmap | -msgpack] [-key-names lang] [-o events.ecs.jsonl] log...:
+	writes the events of logs as Elastic Common Schema documents, a JSON
+	line each, for Filebeat or Elastic Agent to ship.
+*/
+func ecsCommand(args []string) {
+	flags := flag.NewFlagSet("ecs", flag.ExitOnError)
+	var window windowFlags
+	window.register(flags)
+	out := flags.String("o", "", "file to write; standard output if empty")
+	keyNames := flags.String("key-names", "en", "language of keylogger.key.name: system, "+strings.Join(keylogger.KeyNameLanguages(), ", "))
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: keylogger ecs [-from 2006-01-02] [-to 2006-01-02] [-mmap | -msgpack] [-key-names de] [-o events.ecs.jsonl] log.jsonl...")
+		os.Exit(2)
+	}
+	from, to, codec := window.parse()
+	names, err := keylogger.KeyNamer(*keyNames)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if *out != "" {
+		var err error
+		if f, err = os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600); err != nil {
+			fatalf("%v", err)
+		}
+		w = f
+	}
+	b := bufio.NewWriter(w)
+	ecs := keylogger.NewECSCodec()
+	ecs.Names = names
+	var buf []byte
+	var werr error
+	err = keylogger.ReadLogs(flags.Args(), codec, func(e keylogger.Event) {
+		t := e.Timestamp()
+		if werr != nil || !(!t.Before(from) && (to.IsZero() || t.Before(to))) {
+			return
+		}
+		if buf, werr = ecs.AppendEvent(buf[:0], e); werr == nil {
+			_, werr = b.Write(buf)
+		}
+	})
+	if err == nil {
+		err = werr
+	}
+	if ferr := b.Flush(); err == nil {
+		err = ferr
+	}
+	if f != nil {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(*out)
+		}
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"keylogger"
+)
+
+/*
+	Flags selecting the events of export and erase.
+*/
+type windowFlags struct {
+	from, to      string
+	mmap, msgpack bool
+}
+
+func (w *windowFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&w.from, "from", "", "first time of the window: a date (2006-01-02), time (RFC 3339) or age (24h)")
+	flags.StringVar(&w.to, "to", "", "end of the window, excluded; now if empty")
+	flags.BoolVar(&w.mmap, "mmap", false, "the logs hold binary records written with -mmap")
+	flags.BoolVar(&w.msgpack, "msgpack", false, "the logs hold MessagePack records written with -msgpack")
+}
+
+func (w *windowFlags) parse() (from, to time.Time, codec keylogger.Codec) {
+	now := time.Now()
+	var err error
+	if w.from != "" {
+		if from, err = parseTime(w.from, now); err != nil {
+			fatalf("-from: %v", err)
+		}
+	}
+	if w.to != "" {
+		if to, err = parseTime(w.to, now); err != nil {
+			fatalf("-to: %v", err)
+		}
+	}
+	return from, to, logCodec(w.mmap, w.msgpack)
+}
+
+/*
+	export -from time [-to time] [-mmap | -msgpack] -o archive.zip log...: writes the
+	events of a window to a zip archive.
+*/
+func exportCommand(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	var window windowFlags
+	window.register(flags)
+	out := flags.String("o", "", "archive to write")
+	flags.Parse(args)
+	if window.from == "" || *out == "" || flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: keylogger export -from 2006-01-02 [-to 2006-01-02] [-mmap | -msgpack] -o archive.zip log.jsonl...")
+		os.Exit(2)
+	}
+	from, to, codec := window.parse()
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	m, err := keylogger.ExportEvents(f, flags.Args(), codec, from, to)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*out)
+		fatalf("%v", err)
+	}
+	fmt.Printf("%s: %d events, sha256 %s\n", *out, m.Events, m.SHA256)
+}
+
+/*
+	erase -from time [-to time] [-mmap | -msgpack] [-wal file] [-dead-letter file]
+	[-trace file,...] [-spill-dir dir] -key file log...: removes the events of a window
+	from logs and the other files of the captures that wrote them, and prints a signed
+	receipt.
+	erase verify receipt.json: checks the signature of a receipt.
+*/
+func eraseCommand(args []string) {
+	if len(args) > 0 && args[0] == "verify" {
+		verifyReceipt(args[1:])
+		return
+	}
+	flags := flag.NewFlagSet("erase", flag.ExitOnError)
+	var window windowFlags
+	window.register(flags)
+	keyFile := flags.String("key", "", "Ed25519 key signing the receipt (created if missing)")
+	var stores keylogger.ErasureStores
+	flags.StringVar(&stores.WAL, "wal", "", "the -wal of the captures, erased as well")
+	flags.StringVar(&stores.DeadLetter, "dead-letter", "", "the RetryPolicy dead letter file of the captures, erased as well")
+	traces := flags.String("trace", "", "comma-separated -trace files of the captures, erased as well")
+	flags.StringVar(&stores.SpillDir, "spill-dir", "", "the -spill-dir of the captures; erase refuses while spill files are left in it")
+	flags.Parse(args)
+	if window.from == "" || *keyFile == "" || flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: keylogger erase -from 2006-01-02 [-to 2006-01-02] [-mmap | -msgpack] [-wal events.wal] [-dead-letter dead.jsonl] [-trace trace.jsonl,...] [-spill-dir dir] -key receipt.key log.jsonl... > receipt.json")
+		fmt.Fprintln(os.Stderr, "       keylogger erase verify receipt.json")
+		os.Exit(2)
+	}
+	from, to, codec := window.parse()
+	if *traces != "" {
+		stores.Traces = strings.Split(*traces, ",")
+	}
+	key, err := keylogger.LoadReceiptKey(*keyFile)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	r, err := keylogger.EraseEvents(flags.Args(), codec, from, to, stores, key)
+	if r != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(r)
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+}
+
+func verifyReceipt(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: keylogger erase verify receipt.json")
+		os.Exit(2)
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fatalf("%v", err)
+	}
+	var r keylogger.ErasureReceipt
+	if err := json.Unmarshal(data, &r); err != nil {
+		fatalf("%s: %v", args[0], err)
+	}
+	if err := r.Verify(); err != nil {
+		fatalf("%s: %v", args[0], err)
+	}
+	fmt.Printf("%s: %d events erased %s, signed by %s\n", args[0], r.Events, r.Erased.Format(time.RFC3339), r.Key)
+}
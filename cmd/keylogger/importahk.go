@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"keylogger"
+)
+
+/*
+	import-ahk script.ahk: prints the configuration equivalent to the
+	supported subset of an AutoHotkey script, reporting skipped lines on stderr.
+*/
+func importAHK(args []string) {
+	flags := flag.NewFlagSet("import-ahk", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: keylogger import-ahk script.ahk > config.json")
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	defer f.Close()
+	config, warnings, err := keylogger.ImportAHK(f)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", flags.Arg(0), w)
+	}
+	if err != nil {
+		fatalf("%s: %v", flags.Arg(0), err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(config); err != nil {
+		fatalf("%v", err)
+	}
+}
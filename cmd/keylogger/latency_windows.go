@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"keylogger"
+)
+
+/*
+	latency [-n samples] [-interval d] [-window]: injects key presses and
+	prints how long they took to reach a low-level hook and, with -window,
+	a test window as WM_CHAR.
+*/
+func latencyCommand(args []string) {
+	flags := flag.NewFlagSet("latency", flag.ExitOnError)
+	samples := flags.Int("n", keylogger.DefaultLatencySamples, "key presses to time")
+	interval := flags.Duration("interval", keylogger.DefaultLatencyInterval, "pause between key presses")
+	timeout := flags.Duration("timeout", keylogger.DefaultLatencyTimeout, "count a key press as lost after this long")
+	window := flags.Bool("window", false, "also time the character to a test window that takes the focus")
+	flags.Parse(args)
+
+	test := keylogger.InputLatencyTest{Samples: *samples, Interval: *interval, Timeout: *timeout, Window: *window}
+	r, err := test.Run(func(done int) {
+		fmt.Printf("\r%d of %d", done, *samples)
+	})
+	fmt.Print("\r")
+	if err != nil {
+		fatalf("%v", err)
+	}
+	printLatency("to the hook", keylogger.SummarizeLatencies(r.Hook))
+	if *window {
+		printLatency("to WM_CHAR", keylogger.SummarizeLatencies(r.Char))
+	}
+	if r.Lost > 0 {
+		fmt.Printf("lost         %d of %d\n", r.Lost, *samples)
+	}
+}
+
+func printLatency(name string, s keylogger.LatencySummary) {
+	if s.Count == 0 {
+		fmt.Printf("%-12s nothing arrived\n", name)
+		return
+	}
+	us := func(d time.Duration) string {
+		return fmt.Sprintf("%.0fµs", float64(d)/float64(time.Microsecond))
+	}
+	fmt.Printf("%-12s %d presses: min %s, p50 %s, p90 %s, p99 %s, max %s, mean %s ± %s\n",
+		name, s.Count, us(s.Min), us(s.P50), us(s.P90), us(s.P99), us(s.Max), us(s.Mean), us(s.StdDev))
+}
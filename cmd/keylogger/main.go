@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+/*
+	Subcommands, selected by the first argument. Without one, the keylogger
+	captures input.
+*/
+var commands = map[string]func(args []string){
+	"import-ahk":    importAHK,
+	"config":        configCommand,
+	"audit":         auditCommand,
+	"purge":         purgeCommand,
+	"export":        exportCommand,
+	"erase":         eraseCommand,
+	"replay":        replayCommand,
+	"simulate":      simulateCommand,
+	"typingtest":    typingTestCommand,
+	"typing-stats":  typingStatsCommand,
+	"latency":       latencyCommand,
+	"rollover-test": rolloverTestCommand,
+	"report":        reportCommand,
+	"view":          viewCommand,
+	"index":         indexCommand,
+	"search":        searchCommand,
+	"status":        statusCommand,
+	"ecs":           ecsCommand,
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+	capture(os.Args[1:])
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "keylogger: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,112 @@
+//go:build !localonly
+// +build !localonly
+
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+
+	"keylogger"
+)
+
+/*
+	Options of the -metrics listener. With a token file, every request
+	needs a token; with a client CA, every client a certificate.
+*/
+type listenOptions struct {
+	addr     string
+	tokens   string
+	cert     string
+	key      string
+	clientCA string
+	pprof    bool
+}
+
+/*
+	Registers the -metrics flags.
+*/
+func listenFlags(flags *flag.FlagSet) *listenOptions {
+	o := &listenOptions{}
+	flags.StringVar(&o.addr, "metrics", "", "serve drop and latency metrics on this address, e.g. 127.0.0.1:9273")
+	flags.StringVar(&o.tokens, "api-tokens", "", "require a bearer token from this file (lines of scope and token) on -metrics")
+	flags.StringVar(&o.cert, "tls-cert", "", "serve -metrics over TLS with this certificate")
+	flags.StringVar(&o.key, "tls-key", "", "private key of -tls-cert")
+	flags.StringVar(&o.clientCA, "tls-client-ca", "", "require -metrics clients to present a certificate signed by these CAs")
+	flags.BoolVar(&o.pprof, "pprof", false, "serve pprof profiles and runtime stats under /debug/ on -metrics, to control tokens")
+	return o
+}
+
+func (o *listenOptions) enabled() bool {
+	return o.addr != ""
+}
+
+/*
+	Serves stats on the -metrics address, and with -pprof the profiles
+	and runtime stats, which need a control token or, without
+	-api-tokens, a loopback address.
+*/
+func (o *listenOptions) metrics(stats func() keylogger.Stats) {
+	if o.clientCA != "" && o.cert == "" {
+		log.Fatal("-tls-client-ca needs -tls-cert")
+	}
+	routes := map[string]scoped{"/": {keylogger.ScopeRead, keylogger.MetricsHandler(stats)}}
+	if o.pprof {
+		if host, _, err := net.SplitHostPort(o.addr); o.tokens == "" && (err != nil || !loopback(host)) {
+			log.Fatal("-pprof needs -api-tokens unless -metrics listens on a loopback address")
+		}
+		routes["/debug/"] = scoped{keylogger.ScopeControl, keylogger.DebugHandler(stats)}
+	}
+	serve(*o, routes)
+}
+
+/*
+	A handler and the scope of token it requires.
+*/
+type scoped struct {
+	scope   keylogger.Scope
+	handler http.Handler
+}
+
+func loopback(host string) bool {
+	ip := net.ParseIP(host)
+	return host == "localhost" || ip != nil && ip.IsLoopback()
+}
+
+/*
+	Serves routes, by path prefix, on the -metrics address until the
+	process exits.
+*/
+func serve(o listenOptions, routes map[string]scoped) {
+	var auth *keylogger.TokenAuth
+	if o.tokens != "" {
+		var err error
+		if auth, err = keylogger.LoadTokens(o.tokens); err != nil {
+			log.Fatal(err)
+		}
+	}
+	mux := http.NewServeMux()
+	for prefix, r := range routes {
+		h := r.handler
+		if auth != nil {
+			h = auth.Require(r.scope, h)
+		}
+		mux.Handle(prefix, h)
+	}
+	srv := &http.Server{Addr: o.addr, Handler: mux}
+	if o.clientCA != "" {
+		cfg, err := keylogger.MutualTLSConfig(o.clientCA)
+		if err != nil {
+			log.Fatal(err)
+		}
+		srv.TLSConfig = cfg
+	}
+	go func() {
+		if o.cert != "" {
+			log.Fatal(srv.ListenAndServeTLS(o.cert, o.key))
+		}
+		log.Fatal(srv.ListenAndServe())
+	}()
+}
@@ -0,0 +1,25 @@
+//go:build localonly
+// +build localonly
+
+package main
+
+import (
+	"flag"
+
+	"keylogger"
+)
+
+/*
+	Builds with the localonly tag have no -metrics listener.
+*/
+type listenOptions struct{}
+
+func listenFlags(flags *flag.FlagSet) *listenOptions {
+	return &listenOptions{}
+}
+
+func (o *listenOptions) enabled() bool {
+	return false
+}
+
+func (o *listenOptions) metrics(stats func() keylogger.Stats) {}
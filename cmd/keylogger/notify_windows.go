@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"keylogger"
+)
+
+/*
+	How often the free space of the log's volume is checked.
+*/
+const diskCheckInterval = time.Minute
+
+/*
+	Shows a message box on top of the other windows until it is dismissed.
+	It blocks, so it is called on a goroutine of its own.
+*/
+func notify(title, text string) {
+	t, err := windows.UTF16PtrFromString(text)
+	if err != nil {
+		return
+	}
+	c, err := windows.UTF16PtrFromString(title)
+	if err != nil {
+		return
+	}
+	const flags = windows.MB_OK | windows.MB_ICONINFORMATION | windows.MB_SYSTEMMODAL | windows.MB_SETFOREGROUND
+	if _, err := windows.MessageBox(0, t, c, flags); err != nil {
+		log.Printf("notify: %v", err)
+	}
+}
+
+/*
+	Shows a notification from n without holding up the caller.
+*/
+func toast(n *keylogger.Notifier, title, text string, warning bool) {
+	go func() {
+		if err := n.Notify(title, text, warning); err != nil {
+			log.Printf("notify: %v", err)
+		}
+	}()
+}
+
+/*
+	Warns through n when the volume holding the file name has less than
+	min bytes free, once until it has more again.
+*/
+func watchDiskFree(n *keylogger.Notifier, name string, min uint64) {
+	dir, err := filepath.Abs(filepath.Dir(name))
+	if err != nil {
+		log.Printf("notify: %v", err)
+		return
+	}
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		log.Printf("notify: %v", err)
+		return
+	}
+	low := false
+	for {
+		var free uint64
+		if err := windows.GetDiskFreeSpaceEx(path, &free, nil, nil); err != nil {
+			log.Printf("notify: %s: %v", dir, err)
+		} else if free < min && !low {
+			log.Printf("disk: %d MB free for %s", free>>20, name)
+			toast(n, "Disk almost full", fmt.Sprintf("%d MB left for %s. Capture stops being written when it runs out.", free>>20, filepath.Base(name)), true)
+			low = true
+		} else if free >= min {
+			low = false
+		}
+		time.Sleep(diskCheckInterval)
+	}
+}
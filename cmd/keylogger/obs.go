@@ -0,0 +1,56 @@
+//go:build !localonly
+// +build !localonly
+
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"keylogger"
+)
+
+/*
+	Options of the -obs sink.
+*/
+type obsOptions struct {
+	source       string
+	url          string
+	passwordFile string
+	keyNames     string
+}
+
+func obsFlags(flags *flag.FlagSet) *obsOptions {
+	o := &obsOptions{}
+	flags.StringVar(&o.source, "obs", "", "show the pressed keys in this text source of OBS Studio, through obs-websocket")
+	flags.StringVar(&o.url, "obs-url", keylogger.DefaultOBSURL, "obs-websocket server of -obs")
+	flags.StringVar(&o.passwordFile, "obs-password", "", "file holding the obs-websocket password of -obs")
+	flags.StringVar(&o.keyNames, "obs-key-names", "en", "language -obs names keys in: system, "+strings.Join(keylogger.KeyNameLanguages(), ", "))
+	return o
+}
+
+func (o *obsOptions) enabled() bool {
+	return o.source != ""
+}
+
+func (o *obsOptions) sink() (keylogger.Sink, error) {
+	names, err := keylogger.KeyNamer(o.keyNames)
+	if err != nil {
+		return nil, err
+	}
+	password := ""
+	if o.passwordFile != "" {
+		data, err := os.ReadFile(o.passwordFile)
+		if err != nil {
+			return nil, err
+		}
+		password = strings.TrimSpace(string(data))
+	}
+	d, err := keylogger.NewOBSKeyDisplay(o.url, password, o.source)
+	if err != nil {
+		return nil, err
+	}
+	d.Display.Names = names
+	return d, nil
+}
@@ -0,0 +1,27 @@
+//go:build localonly
+// +build localonly
+
+package main
+
+import (
+	"flag"
+
+	"keylogger"
+)
+
+/*
+	Builds with the localonly tag have no -obs sink.
+*/
+type obsOptions struct{}
+
+func obsFlags(flags *flag.FlagSet) *obsOptions {
+	return &obsOptions{}
+}
+
+func (o *obsOptions) enabled() bool {
+	return false
+}
+
+func (o *obsOptions) sink() (keylogger.Sink, error) {
+	return nil, nil
+}
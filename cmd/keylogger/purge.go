@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"keylogger"
+)
+
+/*
+	purge -before time [-mmap | -msgpack] file...: removes older events from logs that
+	no capture is writing to.
+*/
+func purgeCommand(args []string) {
+	flags := flag.NewFlagSet("purge", flag.ExitOnError)
+	before := flags.String("before", "", "remove events before this date (2006-01-02), time (RFC 3339) or age (168h)")
+	mmap := flags.Bool("mmap", false, "the logs hold binary records written with -mmap")
+	msgpack := flags.Bool("msgpack", false, "the logs hold MessagePack records written with -msgpack")
+	flags.Parse(args)
+	if *before == "" || flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: keylogger purge -before 2006-01-02 [-mmap | -msgpack] log.jsonl...")
+		os.Exit(2)
+	}
+	t, err := parseTime(*before, time.Now())
+	if err != nil {
+		fatalf("-before: %v", err)
+	}
+	codec := logCodec(*mmap, *msgpack)
+	for _, name := range flags.Args() {
+		n, err := keylogger.PurgeLog(name, codec, t)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Printf("%s: %d events removed\n", name, n)
+	}
+}
+
+/*
+	Parses a date, an RFC 3339 time or a duration before now.
+*/
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"keylogger"
+)
+
+/*
+	replay [-config file] [-redact list] [-layout name] [-log file [-mmap | -msgpack]]
+	trace: runs a trace recorded with -trace through blocking, redaction,
+	the layout and the log again, printing the events as JSON lines unless -log is given. Remap
+	rules, hotstrings and scripts send input, so they are not applied.
+*/
+func replayCommand(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	configFile := flags.String("config", "", "JSON configuration file whose block and redact rules apply")
+	redact := flags.String("redact", "", "comma-separated redactions: credit-card, ssn, email or regular expressions")
+	logFile := flags.String("log", "", "append the events to this file instead of printing them")
+	mmapLog := flags.Bool("mmap", false, "write -log as binary records through a memory-mapped file")
+	msgpackLog := flags.Bool("msgpack", false, "write -log as MessagePack records")
+	layout := flags.String("layout", "", "print the keys as this layout types them, by their position: "+strings.Join(keylogger.LayoutNames(), ", "))
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: keylogger replay [-config file] [-redact list] [-log file] trace.jsonl")
+		os.Exit(2)
+	}
+	config := &keylogger.Config{}
+	if *configFile != "" {
+		c, err := keylogger.LoadConfig(*configFile)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		config = c
+	}
+	trace, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	defer trace.Close()
+
+	logger := keylogger.NewLogger()
+	if len(config.Block) > 0 {
+		rules, _ := keylogger.ParseBlockRules(config.Block)
+		logger.AddFilter(keylogger.NewBlocker(rules).Filter)
+	}
+	if patterns := config.Redact; len(patterns) > 0 || *redact != "" {
+		if *redact != "" {
+			patterns = append(patterns, strings.Split(*redact, ",")...)
+		}
+		r, err := keylogger.NewRedactor(patterns)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		logger.Redact = r
+	}
+	if *layout != "" {
+		m, err := keylogger.NewLayoutMapper(*layout)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		logger.Layout = m
+	}
+	var s keylogger.Sink = stdoutSink{}
+	if *logFile != "" {
+		if s, err = openLog(*logFile, *mmapLog, *msgpackLog, 0); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	logger.AddSink(s)
+	if err := logger.Replay(trace); err != nil {
+		fatalf("%s: %v", flags.Arg(0), err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"keylogger"
+)
+
+/*
+	report [-from time] [-to time] [-mmap | -msgpack] [-focus-minutes 25]
+	[-focus-idle 5] [-focus-switches 3] -o report.xlsx log...: writes a
+	workbook of the keys, clicks and active minutes in logs by day and
+	application, of the most pressed keys and of the focus sessions.
+*/
+func reportCommand(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	var window windowFlags
+	window.register(flags)
+	out := flags.String("o", "", "workbook to write")
+	focus := keylogger.DefaultFocusRules
+	flags.Float64Var(&focus.MinMinutes, "focus-minutes", focus.MinMinutes, "minutes of typing into one application that make a focus session")
+	flags.Float64Var(&focus.IdleMinutes, "focus-idle", focus.IdleMinutes, "minutes without typing that end a focus session")
+	flags.IntVar(&focus.MaxSwitches, "focus-switches", focus.MaxSwitches, "switches to other windows a focus session may have")
+	flags.Parse(args)
+	if *out == "" || flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: keylogger report [-from 2006-01-02] [-to 2006-01-02] [-mmap | -msgpack] [-focus-minutes 25] [-focus-idle 5] [-focus-switches 3] -o report.xlsx log.jsonl...")
+		os.Exit(2)
+	}
+	from, to, codec := window.parse()
+	r, err := keylogger.AnalyzeUsageLogs(flags.Args(), codec, from, to, focus)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	err = keylogger.WriteUsageWorkbook(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*out)
+		fatalf("%v", err)
+	}
+	fmt.Printf("%s: %d days, %d applications, %d keys, %d focus sessions\n", *out, len(r.Days), len(r.Apps), len(r.Keys), len(r.Sessions))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"golang.org/x/sys/windows"
+
+	"keylogger"
+)
+
+/*
+	rollover-test [-combos A+S+D,...] [-free]: asks for combinations of
+	keys to hold at once, shows the keys held and reports combinations
+	the keyboard could not report and phantom keys. Esc or Ctrl+C ends
+	the test.
+*/
+func rolloverTestCommand(args []string) {
+	flags := flag.NewFlagSet("rollover-test", flag.ExitOnError)
+	combos := flags.String("combos", strings.Join(keylogger.DefaultRolloverCombos, ","), "combinations of keys to hold, joined by + and separated by commas")
+	free := flags.Bool("free", false, "only show the keys held and the most at once, without combinations")
+	flags.Parse(args)
+
+	var keys [][]uint16
+	if !*free {
+		for _, c := range strings.Split(*combos, ",") {
+			combo, err := keylogger.ParseRolloverCombo(strings.TrimSpace(c))
+			if err != nil {
+				fatalf("%v", err)
+			}
+			keys = append(keys, combo)
+		}
+	}
+	test := keylogger.NewRolloverTest(keys)
+
+	logger := keylogger.NewLogger()
+	events := logger.Events()
+	if err := logger.Start(); err != nil {
+		fatalf("%v", err)
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	fmt.Println("Hold each combination until all of its keys show, then let go. Esc ends the test.")
+	fmt.Println()
+	renderRollover(test)
+	ended := false
+	for !test.Done() && !ended {
+		select {
+		case ev := <-events:
+			e, ok := ev.(keylogger.KeyEvent)
+			if !ok {
+				continue
+			}
+			if e.VkCode == keylogger.VK_ESCAPE && e.Down && !e.Injected() {
+				ended = true
+			} else if test.Feed(e) {
+				renderRollover(test)
+			}
+		case <-interrupt:
+			ended = true
+		}
+	}
+	logger.Stop()
+	// What was typed also went to the console; it is not meant for the shell.
+	flushConsoleInputBuffer.Call(uintptr(windows.Stdin))
+	fmt.Print("\n\n")
+	for _, r := range test.Results {
+		switch {
+		case r.Passed && len(r.Phantom) == 0:
+			fmt.Printf("ok       %s\n", keylogger.KeyNames(r.Keys))
+		case r.Passed:
+			fmt.Printf("ghosting %s: also %s\n", keylogger.KeyNames(r.Keys), keylogger.KeyNames(r.Phantom))
+		default:
+			fmt.Printf("missing  %s: no %s", keylogger.KeyNames(r.Keys), keylogger.KeyNames(r.Missing))
+			if len(r.Phantom) > 0 {
+				fmt.Printf(", also %s", keylogger.KeyNames(r.Phantom))
+			}
+			fmt.Println()
+		}
+	}
+	fmt.Printf("at most %d keys held at once\n", test.MaxHeld)
+}
+
+func renderRollover(test *keylogger.RolloverTest) {
+	line := fmt.Sprintf("held %-40s max %d", keylogger.KeyNames(test.Held()), test.MaxHeld)
+	if combo := test.Current(); combo != nil {
+		line = fmt.Sprintf("%d/%d hold %-24s %s", len(test.Results)+1, len(test.Combos), keylogger.KeyNames(combo), line)
+	}
+	fmt.Printf("\r%-100s", line)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"keylogger"
+)
+
+/*
+	index [-mmap | -msgpack] -o text.idx log...: writes the text typed in logs, cut
+	into segments by window and pause, to an index file for search.
+*/
+func indexCommand(args []string) {
+	flags := flag.NewFlagSet("index", flag.ExitOnError)
+	mmap := flags.Bool("mmap", false, "the logs hold binary records written with -mmap")
+	msgpack := flags.Bool("msgpack", false, "the logs hold MessagePack records written with -msgpack")
+	out := flags.String("o", "", "index file to write")
+	flags.Parse(args)
+	if *out == "" || flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: keylogger index [-mmap | -msgpack] -o text.idx log.jsonl...")
+		os.Exit(2)
+	}
+	codec := logCodec(*mmap, *msgpack)
+	var s keylogger.TextSegmenter
+	if err := keylogger.ReadLogs(flags.Args(), codec, s.Feed); err != nil {
+		fatalf("%v", err)
+	}
+	x := keylogger.NewTextIndex(s.Segments())
+	if err := keylogger.SaveTextIndex(*out, x); err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Printf("%s: %d segments\n", *out, len(x.Segments))
+}
+
+/*
+	search -index text.idx [-app names] [-n max] words...: prints the
+	segments of an index holding all the words, with when and where they
+	were typed.
+*/
+func searchCommand(args []string) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	index := flags.String("index", "", "index file written by keylogger index")
+	apps := flags.String("app", "", "comma-separated executables to search the text of")
+	max := flags.Int("n", 20, "print at most this many matches, 0 for all")
+	flags.Parse(args)
+	if *index == "" || flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: keylogger search -index text.idx [-app chrome.exe] [-n 20] word...")
+		os.Exit(2)
+	}
+	x, err := keylogger.LoadTextIndex(*index)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	var only map[string]bool
+	if *apps != "" {
+		only = make(map[string]bool)
+		for _, a := range strings.Split(*apps, ",") {
+			only[strings.ToLower(strings.TrimSpace(a))] = true
+		}
+	}
+	n := 0
+	for _, m := range x.Search(strings.Join(flags.Args(), " ")) {
+		if only != nil && !only[strings.ToLower(m.App)] {
+			continue
+		}
+		if *max > 0 && n == *max {
+			fmt.Println("…")
+			break
+		}
+		n++
+		fmt.Printf("%s  %s %q\n    %s\n", m.Start.Local().Format("2006-01-02 15:04:05"), m.App, m.Title, m.Excerpt)
+	}
+	if n == 0 {
+		fmt.Println("no matches")
+	}
+}
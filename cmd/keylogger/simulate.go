@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"keylogger"
+)
+
+/*
+	simulate [-corpus file] [-wpm n] [-errors rate] [-duration d] [-log file]:
+	types synthetic text through redaction, pseudonymization and the log,
+	printing the events as JSON lines unless -log is given. Runs on any
+	platform.
+*/
+func simulateCommand(args []string) {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	corpusFile := flags.String("corpus", "", "type the lines of this text file instead of sample sentences")
+	wpm := flags.Float64("wpm", keylogger.DefaultWPM, "typing speed in words per minute, -1 for as fast as the output takes them")
+	errorRate := flags.Float64("errors", 0.02, "fraction of characters mistyped and corrected with Backspace")
+	seed := flags.Int64("seed", 1, "random seed; the same seed types the same keys")
+	duration := flags.Duration("duration", 0, "stop after this long instead of on Ctrl+C")
+	logFile := flags.String("log", "", "append the events to this file instead of printing them")
+	mmapLog := flags.Bool("mmap", false, "write -log as binary records through a memory-mapped file")
+	msgpackLog := flags.Bool("msgpack", false, "write -log as MessagePack records")
+	redact := flags.String("redact", "", "comma-separated redactions: credit-card, ssn, email or regular expressions")
+	pseudonymKey := flags.String("pseudonymize", "", "log HMACs of typed characters, keyed by this file (created if missing)")
+	batchSize := flags.Int("batch-size", keylogger.DefaultBatchSize, "write the log after this many events")
+	batchDelay := flags.Duration("batch-delay", keylogger.DefaultBatchDelay, "write the log at least this often")
+	flags.Parse(args)
+
+	sim := keylogger.NewSimulator()
+	sim.WPM = *wpm
+	sim.ErrorRate = *errorRate
+	sim.Seed = *seed
+	if *corpusFile != "" {
+		data, err := os.ReadFile(*corpusFile)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		sim.Corpus = strings.SplitAfter(string(data), "\n")
+	}
+	if *redact != "" {
+		r, err := keylogger.NewRedactor(strings.Split(*redact, ","))
+		if err != nil {
+			fatalf("%v", err)
+		}
+		sim.Redact = r
+	}
+	if *pseudonymKey != "" {
+		key, err := keylogger.LoadPseudonymKey(*pseudonymKey)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		if sim.Pseudonymize, err = keylogger.NewPseudonymizer(key); err != nil {
+			fatalf("%v", err)
+		}
+		for i := range key {
+			key[i] = 0
+		}
+		defer sim.Pseudonymize.Wipe()
+	}
+	var s keylogger.Sink = stdoutSink{}
+	if *logFile != "" {
+		f, err := openLog(*logFile, *mmapLog, *msgpackLog, 0)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		s = keylogger.NewBatcher(f, *batchSize, *batchDelay)
+	}
+	sim.AddSink(s)
+	if err := sim.Start(); err != nil {
+		fatalf("%v", err)
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	var timeout <-chan time.Time
+	if *duration > 0 {
+		timeout = time.After(*duration)
+	}
+	select {
+	case <-interrupt:
+	case <-timeout:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), keylogger.DefaultStopTimeout)
+	defer cancel()
+	if err := sim.StopContext(ctx); err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"keylogger"
+)
+
+/*
+	Returns the codec of logs written with -mmap or -msgpack, JSON lines
+	for neither.
+*/
+func logCodec(mmap, msgpack bool) keylogger.Codec {
+	switch {
+	case mmap && msgpack:
+		fatalf("-mmap and -msgpack exclude each other")
+	case mmap:
+		return keylogger.BinaryCodec{}
+	case msgpack:
+		return keylogger.MsgpackCodec{}
+	}
+	return keylogger.JSONCodec{}
+}
+
+/*
+	Opens -log: JSON lines, binary records with -mmap or MessagePack
+	records with -msgpack, purged of events older than retention if it is
+	set.
+*/
+func openLog(name string, mmap, msgpack bool, retention time.Duration) (keylogger.Sink, error) {
+	codec := logCodec(mmap, msgpack)
+	open := func(name string) (keylogger.Sink, error) {
+		return keylogger.NewFileSink(name, codec)
+	}
+	if mmap {
+		open = func(name string) (keylogger.Sink, error) {
+			return keylogger.OpenMmapSink(name, 0)
+		}
+	}
+	if retention > 0 {
+		return keylogger.NewRetentionSink(name, codec, retention, 0, open)
+	}
+	return open(name)
+}
+
+/*
+	Wraps a sink that writes over the network in a RetrySink of
+	DefaultRetryPolicy, which appends the events s still fails to take to
+	deadLetter if it is set.
+*/
+func retried(s keylogger.Sink, deadLetter string) (keylogger.Sink, error) {
+	r, err := keylogger.NewRetrySink(s, keylogger.RetryPolicy{DeadLetter: deadLetter})
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+/*
+	Prints events as JSON lines.
+*/
+type stdoutSink struct{}
+
+func (stdoutSink) Write(events []keylogger.Event) error {
+	var buf []byte
+	for _, e := range events {
+		buf, _ = keylogger.JSONCodec{}.AppendEvent(buf, e)
+	}
+	_, err := os.Stdout.Write(buf)
+	return err
+}
+
+func (stdoutSink) Close() error {
+	return nil
+}
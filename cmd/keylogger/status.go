@@ -0,0 +1,118 @@
+//go:build !localonly
+// +build !localonly
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"keylogger"
+)
+
+/*
+	status [-metrics addr] [-token file] [-tls-ca file]: prints the health
+	of a capture running on this machine with -metrics, read from its
+	/stats: how long it has run, what became of its hooks, what it lost
+	and how full its queues are.
+*/
+func statusCommand(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	addr := flags.String("metrics", "127.0.0.1:9273", "-metrics address of the capture")
+	tokenFile := flags.String("token", "", "file holding a bearer token of the capture's -api-tokens, which may be protected")
+	ca := flags.String("tls-ca", "", "connect over TLS, trusting the certificates of this CA file")
+	cert := flags.String("tls-cert", "", "client certificate for a capture with -tls-client-ca")
+	key := flags.String("tls-key", "", "private key of -tls-cert")
+	asJSON := flags.Bool("json", false, "print the stats as JSON")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: keylogger status [-metrics 127.0.0.1:9273] [-token token.txt] [-tls-ca ca.pem [-tls-cert cert.pem -tls-key key.pem]] [-json]")
+		os.Exit(2)
+	}
+	host, _, err := net.SplitHostPort(*addr)
+	if err != nil {
+		fatalf("-metrics: %v", err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		fatalf("-metrics: %s is not this machine; status only reads a local capture", *addr)
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	url := "http://" + *addr + "/stats"
+	if *ca != "" {
+		pem, err := os.ReadFile(*ca)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		cfg := &tls.Config{RootCAs: x509.NewCertPool(), ServerName: host}
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			fatalf("%s: no certificates", *ca)
+		}
+		if *cert != "" {
+			pair, err := tls.LoadX509KeyPair(*cert, *key)
+			if err != nil {
+				fatalf("%v", err)
+			}
+			cfg.Certificates = []tls.Certificate{pair}
+		}
+		client.Transport = &http.Transport{TLSClientConfig: cfg}
+		url = "https://" + *addr + "/stats"
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if *tokenFile != "" {
+		data, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		token, err := keylogger.Secret(bytes.TrimSpace(data)).Reveal()
+		if err != nil {
+			fatalf("%s: %v", *tokenFile, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+string(token))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		fatalf("no capture answering on %s: %v", *addr, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fatalf("%s: %s", url, resp.Status)
+	}
+	var s keylogger.Stats
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		fatalf("%s: %v", url, err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(s)
+		return
+	}
+	printStatus(s)
+}
+
+func printStatus(s keylogger.Stats) {
+	if !s.Started.IsZero() {
+		fmt.Printf("running since  %s (%s)\n", s.Started.Local().Format("2006-01-02 15:04:05"), time.Since(s.Started).Round(time.Second))
+	}
+	fmt.Printf("hooks          %d reinstalled, %d lost\n", s.Hooks.Reinstalled, s.Hooks.Lost)
+	h := s.HookLatency
+	fmt.Printf("hook time      p50 %s, p99 %s, %d calls\n", h.Quantile(0.5), h.Quantile(0.99), h.Count())
+	fmt.Printf("queue          %d of %d, at most %d\n", s.RingQueued, s.RingSize, s.RingHighWater)
+	d := s.Dropped
+	fmt.Printf("dropped        %d: ring %d, backpressure %d, stop %d\n", d.Total(), d.Ring, d.Backpressure, d.Stop)
+	b := s.Backpressure
+	fmt.Printf("delivered      %d, %d blocked, %d spilled\n", b.Delivered, b.Blocked, b.Spilled)
+	fmt.Printf("sink errors    %d\n", s.SinkErrors)
+	fmt.Printf("key chatter    %d\n", s.KeyChatter)
+}
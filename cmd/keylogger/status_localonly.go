@@ -0,0 +1,11 @@
+//go:build localonly
+// +build localonly
+
+package main
+
+/*
+	Builds with the localonly tag have no -metrics listener to ask.
+*/
+func statusCommand(args []string) {
+	fatalf("status reads the -metrics listener, which builds with the localonly tag leave out")
+}
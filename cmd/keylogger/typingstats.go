@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"keylogger"
+)
+
+/*
+	typing-stats [-min n] [-mmap | -msgpack] [-o file] log...: writes the per-key
+	errors, slowest digraphs and dwell and flight times of the typing in
+	logs as JSON, for typing tutors to import.
+*/
+func typingStatsCommand(args []string) {
+	flags := flag.NewFlagSet("typing-stats", flag.ExitOnError)
+	min := flags.Int("min", 5, "leave out digraphs typed fewer times")
+	mmap := flags.Bool("mmap", false, "the logs hold binary records written with -mmap")
+	msgpack := flags.Bool("msgpack", false, "the logs hold MessagePack records written with -msgpack")
+	out := flags.String("o", "", "file to write instead of the standard output")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: keylogger typing-stats [-min n] [-mmap | -msgpack] [-o profile.json] log.jsonl...")
+		os.Exit(2)
+	}
+	codec := logCodec(*mmap, *msgpack)
+	p, err := keylogger.AnalyzeTypingLogs(flags.Args(), codec, *min)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		fatalf("%v", err)
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*out, data, 0600); err != nil {
+		fatalf("%v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"keylogger"
+)
+
+var flushConsoleInputBuffer = windows.NewLazySystemDLL("kernel32.dll").NewProc("FlushConsoleInputBuffer")
+
+/*
+	typingtest [-text file] [-results file] [-history]: shows a prompt to
+	type, times it from the captured key events and adds the speed and
+	accuracy to the results file, comparing them with the earlier results.
+	Esc or Ctrl+C gives up.
+*/
+func typingTestCommand(args []string) {
+	flags := flag.NewFlagSet("typingtest", flag.ExitOnError)
+	textFile := flags.String("text", "", "type the text of this file instead of a sample sentence")
+	resultsFile := flags.String("results", defaultResultsFile(), "file the results are added to")
+	history := flags.Bool("history", false, "print the earlier results instead of running a test")
+	flags.Parse(args)
+
+	results, err := keylogger.LoadTypingResults(*resultsFile)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if *history {
+		for _, r := range results {
+			fmt.Printf("%s  %5.1f wpm  %5.1f%%  %v\n", r.Time.Local().Format("2006-01-02 15:04"), r.WPM, r.Accuracy*100, r.Duration.Round(100*time.Millisecond))
+		}
+		return
+	}
+	prompt := keylogger.DefaultCorpus[rand.New(rand.NewSource(time.Now().UnixNano())).Intn(len(keylogger.DefaultCorpus))]
+	if *textFile != "" {
+		data, err := os.ReadFile(*textFile)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		prompt = string(data)
+	}
+	test := keylogger.NewTypingTest(prompt)
+	if test.Prompt == "" {
+		fatalf("nothing to type")
+	}
+
+	logger := keylogger.NewLogger()
+	events := logger.Events()
+	if err := logger.Start(); err != nil {
+		fatalf("%v", err)
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	colors := enableColors()
+	fmt.Printf("Type this, Esc to give up:\n\n  %s\n\n", test.Prompt)
+	fmt.Print("> ")
+	aborted := false
+	for !test.Done() && !aborted {
+		select {
+		case ev := <-events:
+			e, ok := ev.(keylogger.KeyEvent)
+			if !ok {
+				continue
+			}
+			if e.VkCode == keylogger.VK_ESCAPE && e.Down {
+				aborted = true
+			} else if test.Feed(e) {
+				fmt.Print("\r> " + render(test, colors))
+			}
+		case <-interrupt:
+			aborted = true
+		}
+	}
+	logger.Stop()
+	// What was typed also went to the console; it is not meant for the shell.
+	flushConsoleInputBuffer.Call(uintptr(windows.Stdin))
+	fmt.Print("\n\n")
+	r := test.Result(time.Now())
+	test.Wipe()
+	if aborted {
+		fmt.Println("Given up.")
+		return
+	}
+	fmt.Printf("%.1f wpm (%.1f raw), %.1f%% accuracy, %d mistakes in %v\n",
+		r.WPM, r.RawWPM, r.Accuracy*100, r.Mistakes, r.Duration.Round(100*time.Millisecond))
+	if len(results) > 0 {
+		best, sum := 0.0, 0.0
+		recent := results
+		if len(recent) > 10 {
+			recent = recent[len(recent)-10:]
+		}
+		for _, p := range results {
+			if p.WPM > best {
+				best = p.WPM
+			}
+		}
+		for _, p := range recent {
+			sum += p.WPM
+		}
+		fmt.Printf("best %.1f wpm, %.1f wpm on average over the last %d tests\n", best, sum/float64(len(recent)), len(recent))
+	}
+	if err := os.MkdirAll(filepath.Dir(*resultsFile), 0700); err != nil {
+		fatalf("%v", err)
+	}
+	if err := keylogger.AppendTypingResult(*resultsFile, r); err != nil {
+		fatalf("%v", err)
+	}
+}
+
+func defaultResultsFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "typingtest.jsonl"
+	}
+	return filepath.Join(dir, "keylogger", "typingtest.jsonl")
+}
+
+/*
+	Returns the typed text with the mistakes in red if colors is set, and
+	the rest of the line cleared.
+*/
+func render(test *keylogger.TypingTest, colors bool) string {
+	typed, prompt := []rune(test.Typed()), []rune(test.Prompt)
+	var b strings.Builder
+	for i, r := range typed {
+		switch {
+		case r == prompt[i]:
+			b.WriteRune(r)
+		case !colors:
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteString("\x1b[41m \x1b[0m")
+		default:
+			b.WriteString("\x1b[31m" + string(r) + "\x1b[0m")
+		}
+	}
+	if colors {
+		b.WriteString("\x1b[K")
+	} else {
+		b.WriteString(" ")
+	}
+	return b.String()
+}
+
+/*
+	Turns on escape sequences on the console, reporting whether it worked.
+*/
+func enableColors() bool {
+	var mode uint32
+	if windows.GetConsoleMode(windows.Stdout, &mode) != nil {
+		return false
+	}
+	return windows.SetConsoleMode(windows.Stdout, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"keylogger"
+)
+
+/*
+	Typing stops making one line of -only-text after this long a pause.
+*/
+const viewTextPause = 5 * time.Second
+
+/*
+	view [-from time] [-to time] [-app names] [-only-text] [-mmap | -msgpack] log...:
+	prints the events of logs, one per line with its time and application.
+	With -follow, it goes on printing the events written to the log.
+*/
+func viewCommand(args []string) {
+	flags := flag.NewFlagSet("view", flag.ExitOnError)
+	var window windowFlags
+	window.register(flags)
+	apps := flags.String("app", "", "comma-separated executables to show the events of, e.g. chrome.exe")
+	onlyText := flags.Bool("only-text", false, "show the typed text, a line per application and burst of typing")
+	follow := flags.Bool("follow", false, "go on printing the events written to the log, from its end unless -from is set")
+	flags.Parse(args)
+	if flags.NArg() == 0 || *follow && (flags.NArg() != 1 || window.to != "") {
+		fmt.Fprintln(os.Stderr, "usage: keylogger view [-from 2006-01-02] [-to 2006-01-02] [-app chrome.exe] [-only-text] [-mmap | -msgpack] log.jsonl...")
+		fmt.Fprintln(os.Stderr, "       keylogger view -follow [-from 2006-01-02] [-app chrome.exe] [-only-text] [-mmap | -msgpack] log.jsonl")
+		os.Exit(2)
+	}
+	from, to, codec := window.parse()
+	p := newEventPrinter(os.Stdout, *apps, *onlyText)
+	if *follow {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if window.from == "" {
+			from = time.Now()
+		}
+		err := keylogger.FollowLog(ctx, flags.Arg(0), codec, func(e keylogger.Event) {
+			if !e.Timestamp().Before(from) {
+				p.print(e)
+			} else if f, ok := e.(keylogger.FocusEvent); ok {
+				p.follow(f)
+			}
+		})
+		p.flush()
+		if err != nil {
+			fatalf("%v", err)
+		}
+		return
+	}
+	err := keylogger.ReadLogs(flags.Args(), codec, func(e keylogger.Event) {
+		t := e.Timestamp()
+		if !t.Before(from) && (to.IsZero() || t.Before(to)) {
+			p.print(e)
+		} else if f, ok := e.(keylogger.FocusEvent); ok {
+			// Focus changes before the window still say which application is in front.
+			p.follow(f)
+		}
+	})
+	p.flush()
+	if err != nil {
+		fatalf("%v", err)
+	}
+}
+
+/*
+	Formats events for view, following the foreground application from
+	the FocusEvents among them.
+*/
+type eventPrinter struct {
+	w        io.Writer
+	apps     map[string]bool
+	onlyText bool
+
+	app      string
+	text     []rune
+	textTime time.Time
+	lastKey  time.Time
+}
+
+func newEventPrinter(w io.Writer, apps string, onlyText bool) *eventPrinter {
+	p := &eventPrinter{w: w, onlyText: onlyText}
+	if apps != "" {
+		p.apps = make(map[string]bool)
+		for _, a := range strings.Split(apps, ",") {
+			p.apps[strings.ToLower(strings.TrimSpace(a))] = true
+		}
+	}
+	return p
+}
+
+func (p *eventPrinter) shown() bool {
+	return p.apps == nil || p.apps[strings.ToLower(p.app)]
+}
+
+func (p *eventPrinter) print(ev keylogger.Event) {
+	if e, ok := ev.(keylogger.FocusEvent); ok {
+		p.follow(e)
+		if !p.onlyText && p.shown() {
+			p.line(e.Time, fmt.Sprintf("focus %q%s", e.Title, elevatedMark(e)))
+		}
+		return
+	}
+	if !p.shown() {
+		return
+	}
+	if p.onlyText {
+		if e, ok := ev.(keylogger.KeyEvent); ok {
+			p.typed(e)
+		}
+		return
+	}
+	p.line(ev.Timestamp(), describeEvent(ev))
+}
+
+/*
+	Makes the application of e the one in front, ending the line of text.
+*/
+func (p *eventPrinter) follow(e keylogger.FocusEvent) {
+	p.flush()
+	p.app = e.Process
+}
+
+/*
+	Adds what e typed to the line of text, which ends with Enter, a pause
+	or another application.
+*/
+func (p *eventPrinter) typed(e keylogger.KeyEvent) {
+	if !e.Down {
+		return
+	}
+	if len(p.text) > 0 && e.Time.Sub(p.lastKey) >= viewTextPause {
+		p.flush()
+	}
+	p.lastKey = e.Time
+	if e.VkCode == keylogger.VK_BACK {
+		if len(p.text) > 0 {
+			p.text = p.text[:len(p.text)-1]
+		}
+		return
+	}
+	for _, r := range e.Text {
+		if r == '\n' {
+			p.flush()
+			continue
+		}
+		if len(p.text) == 0 {
+			p.textTime = e.Time
+		}
+		p.text = append(p.text, r)
+	}
+}
+
+/*
+	Prints the text typed so far.
+*/
+func (p *eventPrinter) flush() {
+	if len(p.text) > 0 {
+		p.line(p.textTime, string(p.text))
+	}
+	for i := range p.text {
+		p.text[i] = 0
+	}
+	p.text = p.text[:0]
+}
+
+func (p *eventPrinter) line(t time.Time, s string) {
+	app := p.app
+	if app == "" {
+		app = "-"
+	}
+	fmt.Fprintf(p.w, "%s  %-16s  %s\n", t.Local().Format("2006-01-02 15:04:05.000"), app, s)
+}
+
+func elevatedMark(e keylogger.FocusEvent) string {
+	if e.Elevated {
+		return " elevated"
+	}
+	return ""
+}
+
+func uncertainMark(e keylogger.TextEvent) string {
+	if e.Uncertain {
+		return " uncertain"
+	}
+	return ""
+}
+
+/*
+	Returns a line describing an event other than a FocusEvent.
+*/
+func describeEvent(ev keylogger.Event) string {
+	switch e := ev.(type) {
+	case keylogger.KeyEvent:
+		s := "key " + keylogger.KeyName(e.VkCode)
+		if e.Down {
+			s += " down"
+		} else {
+			s += " up"
+		}
+		if e.Text != "" {
+			s += fmt.Sprintf(" %q", e.Text)
+		}
+		if e.Swallowed {
+			s += " swallowed"
+		}
+		if e.Injected() {
+			s += " " + e.Source().String()
+		}
+		return s
+	case keylogger.MouseEvent:
+		switch {
+		case e.Action == keylogger.MouseScroll:
+			return fmt.Sprintf("mouse scroll %s %.2f (%d, %d)", e.Direction(), e.Notches(), e.X, e.Y)
+		case e.DoubleClick:
+			return fmt.Sprintf("mouse %s double-click (%d, %d)", e.Button, e.X, e.Y)
+		case e.Action == keylogger.MouseMove:
+			return fmt.Sprintf("mouse move (%d, %d)", e.X, e.Y)
+		}
+		return fmt.Sprintf("%s %s %s (%d, %d)", e.Source(), e.Button, e.Action, e.X, e.Y)
+	case keylogger.GamepadEvent:
+		switch {
+		case e.Button == 0:
+			s := e.State
+			return fmt.Sprintf("pad %d sticks (%d, %d) (%d, %d) triggers %d %d", e.Pad, s.LeftX, s.LeftY, s.RightX, s.RightY, s.LeftTrigger, s.RightTrigger)
+		case e.Down:
+			return fmt.Sprintf("pad %d %s down", e.Pad, e.Button)
+		}
+		return fmt.Sprintf("pad %d %s up", e.Pad, e.Button)
+	case keylogger.ProcessEvent:
+		if e.Started {
+			return fmt.Sprintf("started %s (%d)", e.Name, e.PID)
+		}
+		return fmt.Sprintf("exited %s (%d)", e.Name, e.PID)
+	case keylogger.TextEvent:
+		return fmt.Sprintf("text %q%s", e.Text, uncertainMark(e))
+	case keylogger.DiagnosticEvent:
+		return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+	}
+	return fmt.Sprintf("%T", ev)
+}
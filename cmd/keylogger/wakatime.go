@@ -0,0 +1,99 @@
+//go:build !localonly
+// +build !localonly
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"keylogger"
+)
+
+/*
+	Options of the -wakatime sink. The API key and URL default to those of
+	~/.wakatime.cfg, which the WakaTime editor plugins share.
+*/
+type wakaTimeOptions struct {
+	on      bool
+	url     string
+	keyFile string
+}
+
+func wakaTimeFlags(flags *flag.FlagSet) *wakaTimeOptions {
+	o := &wakaTimeOptions{}
+	flags.BoolVar(&o.on, "wakatime", false, "send coding-time heartbeats for the files open in editors and IDEs to WakaTime")
+	flags.StringVar(&o.url, "wakatime-url", "", "WakaTime-compatible API of -wakatime, such as Wakapi's; otherwise api_url of ~/.wakatime.cfg or wakatime.com")
+	flags.StringVar(&o.keyFile, "wakatime-key", "", "file holding the API key of -wakatime; otherwise api_key of ~/.wakatime.cfg")
+	return o
+}
+
+func (o *wakaTimeOptions) enabled() bool {
+	return o.on
+}
+
+/*
+	Returns the sink, reading the foreground window with foreground.
+*/
+func (o *wakaTimeOptions) sink(foreground func() (process, title string)) (keylogger.Sink, error) {
+	cfg, err := readWakaTimeConfig()
+	if err != nil {
+		return nil, err
+	}
+	url, key := cfg["api_url"], cfg["api_key"]
+	if o.url != "" {
+		url = o.url
+	}
+	if o.keyFile != "" {
+		data, err := os.ReadFile(o.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		key = strings.TrimSpace(string(data))
+	}
+	w, err := keylogger.NewWakaTime(url, key)
+	if err != nil {
+		return nil, err
+	}
+	w.Foreground = foreground
+	return w, nil
+}
+
+/*
+	Returns the settings section of ~/.wakatime.cfg, or nothing if there
+	is no such file.
+*/
+func readWakaTimeConfig() (map[string]string, error) {
+	settings := map[string]string{}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return settings, nil
+	}
+	f, err := os.Open(filepath.Join(home, ".wakatime.cfg"))
+	if errors.Is(err, os.ErrNotExist) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	section := ""
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			section = strings.Trim(line, "[]")
+		case section == "settings":
+			if i := strings.IndexAny(line, "=:"); i > 0 {
+				settings[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+			}
+		}
+	}
+	return settings, sc.Err()
+}
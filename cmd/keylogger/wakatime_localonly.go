@@ -0,0 +1,27 @@
+//go:build localonly
+// +build localonly
+
+package main
+
+import (
+	"flag"
+
+	"keylogger"
+)
+
+/*
+	Builds with the localonly tag have no -wakatime sink.
+*/
+type wakaTimeOptions struct{}
+
+func wakaTimeFlags(flags *flag.FlagSet) *wakaTimeOptions {
+	return &wakaTimeOptions{}
+}
+
+func (o *wakaTimeOptions) enabled() bool {
+	return false
+}
+
+func (o *wakaTimeOptions) sink(foreground func() (process, title string)) (keylogger.Sink, error) {
+	return nil, nil
+}
@@ -0,0 +1,67 @@
+/*
+	keylogger.h: the C interface of keylogger.dll, built with
+
+		go build -buildmode=c-shared -o keylogger.dll ./cmd/libkeylogger
+
+	Functions return 0 or a length on success and one of the KL_E* codes
+	on failure; kl_last_error describes the last failure. They may be
+	called from any thread, but only one thread may wait in kl_poll.
+
+	Events are UTF-8 JSON records as JSONCodec writes them, with a "type"
+	and the schema version "v", so the interface stays the same when
+	events gain fields.
+*/
+#ifndef KEYLOGGER_H
+#define KEYLOGGER_H
+
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/* The version of this interface, returned by kl_abi_version. */
+#define KL_ABI_VERSION 1
+
+/* What kl_start captures. */
+#define KL_KEYBOARD 0x1
+#define KL_MOUSE    0x2
+#define KL_FOCUS    0x4
+
+/* Failures. */
+#define KL_ENOTRUNNING -1 /* not started, or stopped while polling */
+#define KL_ERUNNING    -2 /* kl_start while already capturing */
+#define KL_EFAILED     -3 /* see kl_last_error */
+
+int kl_abi_version(void);
+
+/*
+	Starts capturing what flags names. While capturing, an icon in the
+	notification area tells the user the host program is capturing input.
+*/
+int kl_start(uint32_t flags);
+
+/*
+	Stops capturing and removes the icon; a waiting kl_poll returns
+	KL_ENOTRUNNING. Returns KL_EFAILED if events were dropped or the stop
+	could not be recorded, after stopping all the same.
+*/
+int kl_stop(void);
+
+/*
+	Waits up to timeout_ms milliseconds, forever if negative, for the
+	next event and copies its record to buf, NUL-terminated. Returns the
+	length of the record, 0 if none came in time. If the length is not
+	less than size, nothing is copied and the event is kept for the next
+	call, which can pass a buffer large enough, as with snprintf.
+*/
+int kl_poll(char *buf, int size, int timeout_ms);
+
+/* Copies the message of the last failure to buf, as kl_poll copies records. */
+int kl_last_error(char *buf, int size);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
@@ -0,0 +1,168 @@
+package main
+
+/*
+#include "keylogger.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unsafe"
+
+	"keylogger"
+)
+
+/*
+	The C interface of keylogger.h, for programs in other languages to
+	capture input without running keylogger in a process of its own. It
+	wraps one Logger, whose events are handed out one JSON record per
+	kl_poll. The capture cannot be hidden: kl_start shows a notification
+	icon naming the host program for as long as it runs.
+*/
+var lib struct {
+	mu       sync.Mutex // guards the fields below
+	logger   *keylogger.Logger
+	events   <-chan keylogger.Event
+	notifier *keylogger.Notifier
+	err      string
+
+	pollMu  sync.Mutex // held by kl_poll
+	pending []byte
+}
+
+func main() {}
+
+//export kl_abi_version
+func kl_abi_version() C.int {
+	return C.KL_ABI_VERSION
+}
+
+//export kl_start
+func kl_start(flags C.uint32_t) C.int {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+	if lib.logger != nil {
+		return C.KL_ERUNNING
+	}
+	if flags&(C.KL_KEYBOARD|C.KL_MOUSE|C.KL_FOCUS) == 0 {
+		return fail(errors.New("nothing to capture"))
+	}
+	exe, _ := os.Executable()
+	n := keylogger.NewNotifier(fmt.Sprintf("%s is capturing input", filepath.Base(exe)))
+	if err := n.Show(); err != nil {
+		return fail(err)
+	}
+	l := keylogger.NewLogger()
+	l.CaptureKeyboard = flags&C.KL_KEYBOARD != 0
+	l.CaptureMouse = flags&C.KL_MOUSE != 0
+	l.CaptureFocus = flags&C.KL_FOCUS != 0
+	events := l.Events()
+	if err := l.Start(); err != nil {
+		n.Close()
+		return fail(err)
+	}
+	lib.logger, lib.events, lib.notifier = l, events, n
+	return 0
+}
+
+//export kl_stop
+func kl_stop() C.int {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+	if lib.logger == nil {
+		return C.KL_ENOTRUNNING
+	}
+	err := lib.logger.Stop()
+	lib.notifier.Close()
+	lib.logger, lib.events, lib.notifier = nil, nil, nil
+	if err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+//export kl_poll
+func kl_poll(buf *C.char, size C.int, timeoutMs C.int) C.int {
+	lib.pollMu.Lock()
+	defer lib.pollMu.Unlock()
+	if lib.pending == nil {
+		lib.mu.Lock()
+		events := lib.events
+		lib.mu.Unlock()
+		if events == nil {
+			return C.KL_ENOTRUNNING
+		}
+		var e keylogger.Event
+		ok := true
+		switch {
+		case timeoutMs < 0:
+			e, ok = <-events
+		case timeoutMs == 0:
+			select {
+			case e, ok = <-events:
+			default:
+				return 0
+			}
+		default:
+			timer := time.NewTimer(time.Duration(timeoutMs) * time.Millisecond)
+			defer timer.Stop()
+			select {
+			case e, ok = <-events:
+			case <-timer.C:
+				return 0
+			}
+		}
+		if !ok {
+			return C.KL_ENOTRUNNING
+		}
+		record, err := keylogger.JSONCodec{}.AppendEvent(nil, e)
+		if err != nil {
+			lib.mu.Lock()
+			defer lib.mu.Unlock()
+			return fail(err)
+		}
+		lib.pending = bytes.TrimSuffix(record, []byte("\n"))
+	}
+	n := copyOut(buf, size, lib.pending)
+	if n < size {
+		// What was typed does not stay behind in the heap.
+		for i := range lib.pending {
+			lib.pending[i] = 0
+		}
+		lib.pending = nil
+	}
+	return n
+}
+
+//export kl_last_error
+func kl_last_error(buf *C.char, size C.int) C.int {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+	return copyOut(buf, size, []byte(lib.err))
+}
+
+/*
+	Records err for kl_last_error; lib.mu must be held.
+*/
+func fail(err error) C.int {
+	lib.err = err.Error()
+	return C.KL_EFAILED
+}
+
+/*
+	Copies data and a NUL to buf if it has room for both, and returns the
+	length of data either way.
+*/
+func copyOut(buf *C.char, size C.int, data []byte) C.int {
+	if buf != nil && len(data) < int(size) {
+		dst := unsafe.Slice((*byte)(unsafe.Pointer(buf)), int(size))
+		dst[copy(dst, data)] = 0
+	}
+	return C.int(len(data))
+}
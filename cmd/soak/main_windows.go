@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"keylogger"
+)
+
+const (
+	soakSignature = 0x4B4C534B // "KLSK"
+	soakKey       = 0x97       // unassigned
+	tick          = 10 * time.Millisecond
+)
+
+/*
+	Soak test for the capture pipeline: injects synthetic keys and mouse
+	moves at fixed rates for a long time while a Logger captures them, and
+	reports memory, goroutines, drops and hook latency every -report
+	interval. It exits with status 1 if events went missing beyond
+	-max-loss or the heap or goroutine count kept growing after -warmup.
+
+	The keys are an unassigned virtual key and the moves are by zero
+	pixels, so the foreground application sees nothing, but the session
+	never becomes idle while the test runs.
+*/
+func main() {
+	duration := flag.Duration("duration", time.Hour, "how long to inject input")
+	keyRate := flag.Float64("keys", 50, "key presses injected per second")
+	moveRate := flag.Float64("moves", 500, "mouse moves injected per second")
+	report := flag.Duration("report", time.Minute, "how often to print measurements")
+	warmup := flag.Duration("warmup", time.Minute, "time after which memory and goroutines are taken as the baseline")
+	maxLoss := flag.Float64("max-loss", 0, "fraction of injected events that may go missing")
+	maxHeapGrowth := flag.Uint64("max-heap-growth", 16, "MiB the heap may grow beyond the baseline")
+	maxGoroutineGrowth := flag.Int("max-goroutine-growth", 0, "goroutines that may be added beyond the baseline")
+	flag.Parse()
+
+	logger := keylogger.NewLogger()
+	logger.CaptureMouse = *moveRate > 0
+	events := logger.Events()
+	if err := logger.Start(); err != nil {
+		log.Fatal(err)
+	}
+
+	var received uint64
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		for ev := range events {
+			switch e := ev.(type) {
+			case keylogger.KeyEvent:
+				if e.ExtraInfo == soakSignature {
+					atomic.AddUint64(&received, 1)
+				}
+			case keylogger.MouseEvent:
+				if e.ExtraInfo == soakSignature {
+					atomic.AddUint64(&received, 1)
+				}
+			}
+		}
+	}()
+
+	inj := &keylogger.Injector{ExtraInfo: soakSignature}
+	var injected, failed uint64
+	var keys, moves float64
+	var base runtime.MemStats
+	baseGoroutines := 0
+	start := time.Now()
+	ticker := time.NewTicker(tick)
+	reports := time.NewTicker(*report)
+	warm := time.After(*warmup)
+	done := time.After(*duration)
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			keys += *keyRate * tick.Seconds()
+			for ; keys >= 1; keys-- {
+				// A tap is a down and an up event.
+				if inj.Tap(soakKey) == nil {
+					injected += 2
+				} else {
+					failed++
+				}
+			}
+			moves += *moveRate * tick.Seconds()
+			for ; moves >= 1; moves-- {
+				if inj.MoveBy(0, 0) == nil {
+					injected++
+				} else {
+					failed++
+				}
+			}
+		case <-warm:
+			runtime.GC()
+			runtime.ReadMemStats(&base)
+			baseGoroutines = runtime.NumGoroutine()
+		case <-reports.C:
+			printReport(time.Since(start), logger, injected, atomic.LoadUint64(&received), failed)
+		case <-done:
+			break loop
+		}
+	}
+	ticker.Stop()
+	reports.Stop()
+
+	// Measure before Stop, which ends the Logger's goroutines.
+	runtime.GC()
+	var end runtime.MemStats
+	runtime.ReadMemStats(&end)
+	goroutines := runtime.NumGoroutine()
+	logger.Stop()
+	<-consumed
+	got := atomic.LoadUint64(&received)
+	printReport(time.Since(start), logger, injected, got, failed)
+
+	ok := true
+	if lost := float64(injected-got) / float64(injected); injected > 0 && lost > *maxLoss {
+		fmt.Printf("FAIL: %.4f%% of injected events lost\n", lost*100)
+		ok = false
+	}
+	if base.HeapAlloc > 0 && end.HeapAlloc > base.HeapAlloc+*maxHeapGrowth<<20 {
+		fmt.Printf("FAIL: heap grew from %d to %d KiB\n", base.HeapAlloc>>10, end.HeapAlloc>>10)
+		ok = false
+	}
+	if baseGoroutines > 0 && goroutines > baseGoroutines+*maxGoroutineGrowth {
+		fmt.Printf("FAIL: goroutines grew from %d to %d\n", baseGoroutines, goroutines)
+		ok = false
+	}
+	if !ok {
+		os.Exit(1)
+	}
+	fmt.Println("PASS")
+}
+
+func printReport(elapsed time.Duration, logger *keylogger.Logger, injected, received, failed uint64) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	s := logger.Stats()
+	fmt.Printf("%v injected=%d received=%d send-errors=%d dropped=%d (ring %d, backpressure %d, stop %d) ring-high-water=%d/%d hook-p99=%v heap=%dKiB goroutines=%d\n",
+		elapsed.Round(time.Second), injected, received, failed,
+		s.Dropped.Total(), s.Dropped.Ring, s.Dropped.Backpressure, s.Dropped.Stop,
+		s.RingHighWater, s.RingSize, s.HookLatency.Quantile(0.99),
+		m.HeapAlloc>>10, runtime.NumGoroutine())
+}
@@ -0,0 +1,163 @@
+package keylogger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+/*
+	Codec turns events into records of a log format and back.
+	AppendEvent appends one record to dst.
+*/
+type Codec interface {
+	AppendEvent(dst []byte, e Event) ([]byte, error)
+}
+
+/*
+	EventType returns the name of an event's type as used in log records:
+	"key", "mouse", "focus", "process", "gamepad", "text" or "diagnostic".
+*/
+func EventType(e Event) string {
+	switch e.(type) {
+	case KeyEvent:
+		return "key"
+	case MouseEvent:
+		return "mouse"
+	case FocusEvent:
+		return "focus"
+	case ProcessEvent:
+		return "process"
+	case GamepadEvent:
+		return "gamepad"
+	case TextEvent:
+		return "text"
+	case DiagnosticEvent:
+		return "diagnostic"
+	}
+	return fmt.Sprintf("%T", e)
+}
+
+/*
+	JSONCodec writes one JSON object per line, the event's fields plus a
+	"type" field naming it and a "v" field holding SchemaVersion:
+
+		{"type":"key","v":2,"vk":65,"scan_code":30,"down":true,"text":"a","time":"..."}
+*/
+type JSONCodec struct{}
+
+type jsonHead struct {
+	Type string `json:"type"`
+	V    int    `json:"v"`
+}
+
+func (JSONCodec) AppendEvent(dst []byte, e Event) ([]byte, error) {
+	var rec interface{}
+	t := EventType(e)
+	switch e := e.(type) {
+	case KeyEvent:
+		rec = struct {
+			jsonHead
+			KeyEvent
+		}{jsonHead{t, SchemaVersion}, e}
+	case MouseEvent:
+		rec = struct {
+			jsonHead
+			MouseEvent
+		}{jsonHead{t, SchemaVersion}, e}
+	case FocusEvent:
+		rec = struct {
+			jsonHead
+			FocusEvent
+		}{jsonHead{t, SchemaVersion}, e}
+	case ProcessEvent:
+		rec = struct {
+			jsonHead
+			ProcessEvent
+		}{jsonHead{t, SchemaVersion}, e}
+	case GamepadEvent:
+		rec = struct {
+			jsonHead
+			GamepadEvent
+		}{jsonHead{t, SchemaVersion}, e}
+	case TextEvent:
+		rec = struct {
+			jsonHead
+			TextEvent
+		}{jsonHead{t, SchemaVersion}, e}
+	case DiagnosticEvent:
+		rec = struct {
+			jsonHead
+			DiagnosticEvent
+		}{jsonHead{t, SchemaVersion}, e}
+	default:
+		return dst, fmt.Errorf("cannot encode %s event", t)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return dst, err
+	}
+	return append(append(dst, data...), '\n'), nil
+}
+
+/*
+	DecodeJSONEvent parses one record written by JSONCodec, of this or an
+	older SchemaVersion.
+*/
+func DecodeJSONEvent(data []byte) (Event, error) {
+	var head jsonHead
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+	version := uint64(1)
+	if head.V != 0 {
+		if head.V < 0 {
+			return nil, fmt.Errorf("bad schema version %d", head.V)
+		}
+		version = uint64(head.V)
+	}
+	if version > SchemaVersion {
+		return nil, newerSchema(version)
+	}
+	var e Event
+	var err error
+	switch head.Type {
+	case "key":
+		var k KeyEvent
+		err = json.Unmarshal(data, &k)
+		e = k
+	case "mouse":
+		var m MouseEvent
+		err = json.Unmarshal(data, &m)
+		if err == nil && m.Action == 0 {
+			err = errors.New("mouse record without action")
+		}
+		e = m
+	case "focus":
+		var f FocusEvent
+		err = json.Unmarshal(data, &f)
+		e = f
+	case "process":
+		var p ProcessEvent
+		err = json.Unmarshal(data, &p)
+		e = p
+	case "gamepad":
+		var g GamepadEvent
+		err = json.Unmarshal(data, &g)
+		e = g
+	case "text":
+		var x TextEvent
+		err = json.Unmarshal(data, &x)
+		e = x
+	case "diagnostic":
+		var d DiagnosticEvent
+		err = json.Unmarshal(data, &d)
+		e = d
+	default:
+		return nil, fmt.Errorf("unknown event type %q", head.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return migrate(e, version)
+}
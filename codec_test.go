@@ -0,0 +1,166 @@
+package keylogger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+/*
+	Records written before schema versions decode as version 1; records of
+	a newer version are refused and skipped by readers.
+*/
+func TestSchemaVersions(t *testing.T) {
+	key := KeyEvent{VkCode: 'A', ScanCode: 0x1E, Down: true, Text: "a"}
+	e, err := DecodeJSONEvent([]byte(`{"type":"key","vk":65,"scan_code":30,"down":true,"text":"a","time":"0001-01-01T00:00:00Z"}`))
+	if err != nil || !reflect.DeepEqual(e, key) {
+		t.Errorf("version 1 JSON = %#v, %v", e, err)
+	}
+	if _, err := DecodeJSONEvent([]byte(`{"type":"key","v":3,"vk":"A"}`)); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("version 3 JSON: %v", err)
+	}
+
+	record, _ := BinaryCodec{}.AppendEvent(nil, key)
+	if record[1] != 0 || record[2] != SchemaVersion {
+		t.Fatalf("binary record % x has no version", record)
+	}
+	old := append([]byte{record[0] - 2}, record[3:]...)
+	if e, n, err := DecodeBinaryEvent(old); err != nil || n != len(old) || !reflect.DeepEqual(e, key) {
+		t.Errorf("version 1 binary = %#v, %d, %v", e, n, err)
+	}
+	newer := append([]byte(nil), record...)
+	newer[2] = SchemaVersion + 1
+	if _, n, err := DecodeBinaryEvent(newer); !errors.Is(err, ErrNewerSchema) || n != len(newer) {
+		t.Errorf("newer binary record: %d, %v", n, err)
+	}
+	name := filepath.Join(t.TempDir(), "events.bin")
+	if err := os.WriteFile(name, append(append(newer, old...), record...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var got []Event
+	if err := ReadLogs([]string{name}, BinaryCodec{}, func(e Event) { got = append(got, e) }); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []Event{key, key}) {
+		t.Errorf("ReadLogs = %v", got)
+	}
+}
+
+/*
+	MessagePack records follow each other without separators and decode
+	back to the events written, whatever keys they have besides.
+*/
+func TestMsgpackCodec(t *testing.T) {
+	data, err := appendEvents(nil, MsgpackCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Event
+	for off := 0; off < len(data); {
+		e, n, err := DecodeMsgpackEvent(data[off:])
+		if err != nil {
+			t.Fatalf("at %d: %v", off, err)
+		}
+		got = append(got, e)
+		off += n
+	}
+	if !reflect.DeepEqual(got, binaryEvents) {
+		t.Fatalf("read back\n%v\nwant\n%v", got, binaryEvents)
+	}
+
+	// {"type":"key","vk":65,"note":[1.5,null],"down":true}
+	record := []byte("\x84\xa4type\xa3key\xa2vk\x41\xa4note\x92\xcb\x3f\xf8\x00\x00\x00\x00\x00\x00\xc0\xa4down\xc3")
+	e, n, err := DecodeMsgpackEvent(record)
+	if err != nil || n != len(record) {
+		t.Fatalf("DecodeMsgpackEvent = %d, %v", n, err)
+	}
+	if k, ok := e.(KeyEvent); !ok || k.VkCode != 'A' || !k.Down {
+		t.Errorf("decoded %#v", e)
+	}
+}
+
+/*
+	Encoding and decoding the events of binaryEvents, one of each kind, in
+	the formats of the log files. The bytes reported are those of the
+	records.
+*/
+func BenchmarkEncode(b *testing.B) {
+	for _, c := range []struct {
+		name  string
+		codec Codec
+	}{
+		{"JSON", JSONCodec{}},
+		{"Binary", BinaryCodec{}},
+		{"Msgpack", MsgpackCodec{}},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			buf, err := appendEvents(nil, c.codec)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(buf)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if buf, err = appendEvents(buf[:0], c.codec); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	b.Run("JSON", func(b *testing.B) {
+		var records [][]byte
+		size := 0
+		for _, e := range binaryEvents {
+			record, err := JSONCodec{}.AppendEvent(nil, e)
+			if err != nil {
+				b.Fatal(err)
+			}
+			records = append(records, record)
+			size += len(record)
+		}
+		b.SetBytes(int64(size))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, record := range records {
+				if _, err := DecodeJSONEvent(record); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("Binary", func(b *testing.B) {
+		data, err := appendEvents(nil, BinaryCodec{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for off := 0; off < len(data); {
+				_, n, err := DecodeBinaryEvent(data[off:])
+				if err != nil {
+					b.Fatal(err)
+				}
+				off += n
+			}
+		}
+	})
+}
+
+func appendEvents(dst []byte, codec Codec) ([]byte, error) {
+	var err error
+	for _, e := range binaryEvents {
+		if dst, err = codec.AppendEvent(dst, e); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,141 @@
+package keylogger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/*
+	Config is the JSON configuration file of the keylogger command.
+	LocalOnly makes the command refuse options that open the network, as
+	builds with the localonly tag do not have them at all.
+*/
+type Config struct {
+	Hotstrings []HotstringRule   `json:"hotstrings,omitempty"`
+	Alerts     []AlertRule       `json:"alerts,omitempty"`
+	Remap      map[string]string `json:"remap,omitempty"`
+	Block      []string          `json:"block,omitempty"`
+	Mouse      *MouseConfig      `json:"mouse,omitempty"`
+	Breaks     *BreakRules       `json:"breaks,omitempty"`
+	Redact     []string          `json:"redact,omitempty"`
+	Notify     *NotifyRules      `json:"notify,omitempty"`
+	Sensitive  *SensitiveApps    `json:"sensitive,omitempty"`
+	Target     *CaptureTarget    `json:"target,omitempty"`
+	LocalOnly  bool              `json:"local_only,omitempty"`
+}
+
+/*
+	MouseConfig limits how much mouse movement is recorded; see MoveSampler.
+	Without a mouse section, DefaultMouseConfig applies.
+*/
+type MouseConfig struct {
+	MaxMovesPerSecond float64 `json:"max_moves_per_second,omitempty"`
+	MinMoveDistance   float64 `json:"min_move_distance,omitempty"`
+}
+
+/*
+	NotifyRules chooses what the keylogger command shows notifications
+	for: phrases of the alert rules being typed, hooks the watchdog could
+	not install again and the volume of the log having less than
+	DiskFreeMB megabytes free.
+*/
+type NotifyRules struct {
+	Alerts     bool `json:"alerts,omitempty"`
+	HookLost   bool `json:"hook_lost,omitempty"`
+	DiskFreeMB int  `json:"disk_free_mb,omitempty"`
+}
+
+/*
+	DefaultMouseConfig keeps movement logs readable without losing the path.
+*/
+var DefaultMouseConfig = MouseConfig{MaxMovesPerSecond: 20}
+
+/*
+	MouseSettings returns the mouse section or its defaults.
+*/
+func (c *Config) MouseSettings() MouseConfig {
+	if c.Mouse == nil {
+		return DefaultMouseConfig
+	}
+	return *c.Mouse
+}
+
+/*
+	LoadConfig reads and validates a configuration file.
+*/
+func LoadConfig(name string) (*Config, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %v", name, err)
+	}
+	return c, nil
+}
+
+func parseConfig(data []byte) (*Config, error) {
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+/*
+	Validate reports the first invalid setting.
+*/
+func (c *Config) Validate() error {
+	for i, h := range c.Hotstrings {
+		if h.Abbrev == "" {
+			return fmt.Errorf("hotstrings[%d]: empty abbreviation", i)
+		}
+		for _, r := range h.Abbrev {
+			if isHotstringEnd(r) {
+				return fmt.Errorf("hotstrings[%d]: abbreviation %q contains an ending character", i, h.Abbrev)
+			}
+		}
+	}
+	for i, a := range c.Alerts {
+		if strings.TrimSpace(a.Phrase) == "" {
+			return fmt.Errorf("alerts[%d]: empty phrase", i)
+		}
+	}
+	if _, err := ParseRemap(c.Remap); err != nil {
+		return err
+	}
+	if _, err := ParseBlockRules(c.Block); err != nil {
+		return err
+	}
+	if _, err := NewRedactor(c.Redact); err != nil {
+		return err
+	}
+	if c.Breaks != nil {
+		if err := c.Breaks.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Sensitive != nil {
+		if err := c.Sensitive.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Target != nil {
+		if err := c.Target.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Notify != nil && c.Notify.DiskFreeMB < 0 {
+		return fmt.Errorf("notify: disk_free_mb must not be negative")
+	}
+	if m := c.MouseSettings(); m.MaxMovesPerSecond < 0 || m.MinMoveDistance < 0 {
+		return fmt.Errorf("mouse: sampling limits must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+//go:build !localonly
+// +build !localonly
+
+package keylogger
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	A failure of the same sink and error types is reported at most this
+	often; Panic waits this long for its report to be sent.
+*/
+const (
+	crashReportInterval = time.Hour
+	crashReportTimeout  = 5 * time.Second
+)
+
+/*
+	CrashReporter sends reports of panics and failed sink writes to Sentry,
+	or a server with the same API such as GlitchTip, at the DSN it was made
+	with. Reports never hold captured input: a panic is sent with its type,
+	its message only for runtime errors such as an index out of range, and
+	the functions and lines of the stack; a sink failure with the types of
+	the sink and of the errors it wraps, not their messages, which may
+	quote what the sink was writing. Host and user names are not sent.
+
+	Panic reports are sent at once. Sink failures are sent from a goroutine
+	of their own, each kind at most once an hour, retried according to
+	DefaultRetryPolicy, and dropped if the queue is full; Close waits for
+	those queued, at most as long as one report may take.
+*/
+type CrashReporter struct {
+	Release string // the version reports name, from the build if it has one
+
+	endpoint string
+	auth     string
+	client   *http.Client
+
+	mu    sync.Mutex
+	sent  map[string]time.Time
+	queue chan []byte
+	done  chan struct{}
+}
+
+/*
+	NewCrashReporter returns a reporter for the DSN of a Sentry project,
+	https://key@host/project. As reports go to another machine, the DSN
+	has to be https unless the server runs on this one.
+*/
+func NewCrashReporter(dsn string) (*CrashReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && loopbackHost(u.Hostname())) {
+		return nil, fmt.Errorf("crash reports: %s is neither https nor on this machine", u.Redacted())
+	}
+	key := u.User.Username()
+	dir, project := path.Split(strings.TrimSuffix(u.Path, "/"))
+	if key == "" || project == "" {
+		return nil, errors.New("crash reports: the DSN has no key or project")
+	}
+	r := &CrashReporter{
+		endpoint: fmt.Sprintf("%s://%s%sapi/%s/envelope/", u.Scheme, u.Host, dir, project),
+		auth:     "Sentry sentry_version=7, sentry_client=keylogger/1, sentry_key=" + key,
+		client:   &http.Client{Timeout: crashReportTimeout},
+		sent:     make(map[string]time.Time),
+		queue:    make(chan []byte, 16),
+		done:     make(chan struct{}),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "(devel)" {
+		r.Release = info.Main.Version
+	}
+	go r.run()
+	return r, nil
+}
+
+/*
+	Recover reports a panic of the goroutine it is deferred in and panics
+	on with it, so the program still crashes.
+*/
+func (r *CrashReporter) Recover() {
+	if v := recover(); v != nil {
+		r.Panic(v)
+		panic(v)
+	}
+}
+
+/*
+	Panic reports v, a value recovered from a panic, with the stack of the
+	goroutine it is called on, and waits until the report is sent. It may
+	be used as Logger.Panicked.
+*/
+func (r *CrashReporter) Panic(v interface{}) {
+	exc := crashException{Type: fmt.Sprintf("%T", v), Mechanism: &crashMechanism{Type: "panic", Handled: false}}
+	if err, ok := v.(runtime.Error); ok {
+		exc.Value = err.Error()
+	}
+	exc.Stacktrace = &crashStacktrace{Frames: crashFrames(3)}
+	r.send(r.envelope("fatal", exc, nil))
+}
+
+/*
+	SinkFailed queues a report of err, returned by s. It may be used as
+	Logger.SinkFailed.
+*/
+func (r *CrashReporter) SinkFailed(s Sink, err error) {
+	var types []string
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		types = append(types, fmt.Sprintf("%T", e))
+	}
+	kind := fmt.Sprintf("%T", s) + " " + strings.Join(types, " ")
+	now := time.Now()
+	r.mu.Lock()
+	if last, ok := r.sent[kind]; ok && now.Sub(last) < crashReportInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.sent[kind] = now
+	r.mu.Unlock()
+	exc := crashException{Type: fmt.Sprintf("%T", s), Value: "write failed: " + strings.Join(types, " wrapping ")}
+	select {
+	case r.queue <- r.envelope("error", exc, map[string]string{"sink": fmt.Sprintf("%T", s)}):
+	default:
+	}
+}
+
+/*
+	Close sends the queued reports, waiting at most as long as a report
+	may take to send.
+*/
+func (r *CrashReporter) Close() {
+	close(r.queue)
+	select {
+	case <-r.done:
+	case <-time.After(crashReportTimeout):
+	}
+}
+
+func (r *CrashReporter) run() {
+	defer close(r.done)
+	for body := range r.queue {
+		body := body
+		DefaultRetryPolicy.Do(func() error { return r.send(body) })
+	}
+}
+
+func (r *CrashReporter) send(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s answered %s", r.endpoint, resp.Status)
+	}
+	return nil
+}
+
+type crashEvent struct {
+	EventID   string                       `json:"event_id"`
+	Timestamp string                       `json:"timestamp"`
+	Platform  string                       `json:"platform"`
+	Level     string                       `json:"level"`
+	Logger    string                       `json:"logger"`
+	Release   string                       `json:"release,omitempty"`
+	Tags      map[string]string            `json:"tags,omitempty"`
+	Contexts  map[string]map[string]string `json:"contexts"`
+	Exception struct {
+		Values []crashException `json:"values"`
+	} `json:"exception"`
+}
+
+type crashException struct {
+	Type       string           `json:"type"`
+	Value      string           `json:"value,omitempty"`
+	Mechanism  *crashMechanism  `json:"mechanism,omitempty"`
+	Stacktrace *crashStacktrace `json:"stacktrace,omitempty"`
+}
+
+type crashMechanism struct {
+	Type    string `json:"type"`
+	Handled bool   `json:"handled"`
+}
+
+type crashStacktrace struct {
+	Frames []crashFrame `json:"frames"`
+}
+
+type crashFrame struct {
+	Function string `json:"function"`
+	Module   string `json:"module"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+/*
+	Returns the stack of the calling goroutine, skipping skip frames, the
+	outermost first as Sentry wants them. File names are cut to the file
+	and its directory, so build paths do not give the user name of whoever
+	built the program away.
+*/
+func crashFrames(skip int) []crashFrame {
+	pcs := make([]uintptr, 64)
+	pcs = pcs[:runtime.Callers(skip, pcs)]
+	var frames []crashFrame
+	it := runtime.CallersFrames(pcs)
+	for {
+		f, more := it.Next()
+		module, function := "", f.Function
+		if i := strings.LastIndexByte(function, '/'); i >= 0 {
+			module, function = function[:i+1], function[i+1:]
+		}
+		if i := strings.IndexByte(function, '.'); i >= 0 {
+			module, function = module+function[:i], function[i+1:]
+		}
+		file := f.File
+		if dir, base := path.Split(file); dir != "" {
+			file = path.Join(path.Base(dir), base)
+		}
+		frames = append(frames, crashFrame{
+			Function: function,
+			Module:   module,
+			Filename: file,
+			Lineno:   f.Line,
+			InApp:    module == "keylogger" || module == "main",
+		})
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+/*
+	Wraps a report of exc in a Sentry envelope.
+*/
+func (r *CrashReporter) envelope(level string, exc crashException, tags map[string]string) []byte {
+	var id [16]byte
+	rand.Read(id[:])
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	e := crashEvent{
+		EventID:   hex.EncodeToString(id[:]),
+		Timestamp: now,
+		Platform:  "go",
+		Level:     level,
+		Logger:    "keylogger",
+		Release:   r.Release,
+		Tags:      tags,
+		Contexts: map[string]map[string]string{
+			"os":      {"name": runtime.GOOS},
+			"runtime": {"name": "go", "version": runtime.Version()},
+		},
+	}
+	e.Exception.Values = []crashException{exc}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.Encode(map[string]string{"event_id": e.EventID, "sent_at": now})
+	enc.Encode(map[string]string{"type": "event"})
+	enc.Encode(e)
+	return b.Bytes()
+}
@@ -0,0 +1,116 @@
+package keylogger
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+	KeyDebouncer removes the chatter of worn mechanical switches, which
+	make or break contact more than once per press. A key-down coming less
+	than Window after the key went up is taken for a bounce and dropped
+	with the key-up that follows it; so is a second key-down less than
+	Window after the first while the key is held, which auto-repeat never
+	sends that fast. Pressing keys dropped this way were released early by
+	at most Window. Zero disables it; 5 to 15 ms suits most keyboards.
+	Injected keys always pass.
+
+	Applications still receive the chatter: only what the Logger delivers
+	is debounced. Stats counts the dropped presses per key, to tell which
+	switches are failing.
+*/
+type KeyDebouncer struct {
+	Window time.Duration
+
+	mu       sync.Mutex
+	keys     map[uint16]*debounceKey
+	filtered uint64
+	since    time.Time
+}
+
+type debounceKey struct {
+	down     bool
+	bouncing bool // a key-down was dropped; drop the key-up too
+	last     time.Time
+	chatter  uint64
+}
+
+/*
+	ChatterStats summarizes the chatter a KeyDebouncer removed since it
+	first saw a key.
+*/
+type ChatterStats struct {
+	Filtered uint64       // presses dropped as chatter
+	Keys     []KeyChatter // keys that chattered, the most first
+	Since    time.Time
+}
+
+/*
+	KeyChatter is how often one key chattered.
+*/
+type KeyChatter struct {
+	VkCode uint16
+	Count  uint64
+}
+
+/*
+	Keep reports whether e should be delivered and updates the counts.
+*/
+func (d *KeyDebouncer) Keep(e KeyEvent) bool {
+	if d.Window <= 0 || e.Injected() {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.keys == nil {
+		d.keys = make(map[uint16]*debounceKey)
+		d.since = e.Time
+	}
+	k := d.keys[e.VkCode]
+	if k == nil {
+		k = &debounceKey{}
+		d.keys[e.VkCode] = k
+	}
+	if !e.Down {
+		if k.bouncing {
+			k.bouncing = false
+			return false
+		}
+		k.down, k.last = false, e.Time
+		return true
+	}
+	if !k.last.IsZero() && e.Time.Sub(k.last) < d.Window {
+		k.chatter++
+		d.filtered++
+		if !k.down {
+			k.bouncing = true
+		}
+		return false
+	}
+	if k.down {
+		// Auto-repeat: only the first key-down of a press is timed.
+		return true
+	}
+	k.down, k.bouncing, k.last = true, false, e.Time
+	return true
+}
+
+/*
+	Stats returns the chatter removed so far.
+*/
+func (d *KeyDebouncer) Stats() ChatterStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := ChatterStats{Filtered: d.filtered, Since: d.since}
+	for vk, k := range d.keys {
+		if k.chatter > 0 {
+			s.Keys = append(s.Keys, KeyChatter{VkCode: vk, Count: k.chatter})
+		}
+	}
+	sort.Slice(s.Keys, func(i, j int) bool {
+		a, b := s.Keys[i], s.Keys[j]
+		return a.Count > b.Count || a.Count == b.Count && a.VkCode < b.VkCode
+	})
+	return s
+}
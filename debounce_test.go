@@ -0,0 +1,44 @@
+package keylogger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyDebouncer(t *testing.T) {
+	type key struct {
+		ms   int
+		vk   uint16
+		down bool
+		keep bool
+	}
+	tests := []struct {
+		name string
+		keys []key
+	}{
+		{"clean presses", []key{{0, 'A', true, true}, {80, 'A', false, true}, {200, 'A', true, true}, {260, 'A', false, true}}},
+		{"bounce after release", []key{{0, 'A', true, true}, {80, 'A', false, true}, {83, 'A', true, false}, {85, 'A', false, false}, {300, 'A', true, true}}},
+		{"duplicate make", []key{{0, 'A', true, true}, {2, 'A', true, false}, {80, 'A', false, true}}},
+		{"auto-repeat", []key{{0, 'A', true, true}, {500, 'A', true, true}, {533, 'A', true, true}, {540, 'A', false, true}}},
+		{"other keys", []key{{0, 'A', true, true}, {1, 'B', true, true}, {3, 'A', false, true}, {4, 'B', false, true}}},
+		{"held through a bounce", []key{{0, 'A', true, true}, {50, 'A', false, true}, {52, 'A', true, false}, {600, 'A', true, true}, {700, 'A', false, true}}},
+	}
+	for _, tt := range tests {
+		d := &KeyDebouncer{Window: 10 * time.Millisecond}
+		start := time.Unix(0, 0)
+		for i, k := range tt.keys {
+			e := KeyEvent{VkCode: k.vk, Down: k.down, Time: start.Add(time.Duration(k.ms) * time.Millisecond)}
+			if got := d.Keep(e); got != k.keep {
+				t.Errorf("%s: key %d: Keep = %v, want %v", tt.name, i, got, k.keep)
+			}
+		}
+	}
+
+	d := &KeyDebouncer{Window: 10 * time.Millisecond}
+	for _, ms := range []int{0, 1, 2} {
+		d.Keep(KeyEvent{VkCode: 'Q', Down: true, Time: time.Unix(0, 0).Add(time.Duration(ms) * time.Millisecond)})
+	}
+	if s := d.Stats(); s.Filtered != 2 || len(s.Keys) != 1 || s.Keys[0] != (KeyChatter{'Q', 2}) {
+		t.Errorf("Stats = %+v", s)
+	}
+}
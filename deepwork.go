@@ -0,0 +1,143 @@
+package keylogger
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+	FocusRules configures a FocusSessionDetector. A focus session is typing
+	into one application for at least MinMinutes, never pausing for
+	IdleMinutes and leaving it for other windows at most MaxSwitches times.
+*/
+type FocusRules struct {
+	MinMinutes  float64 `json:"min_minutes"`
+	IdleMinutes float64 `json:"idle_minutes"`
+	MaxSwitches int     `json:"max_switches"`
+}
+
+/*
+	DefaultFocusRules are the rules of keylogger report unless its flags
+	say otherwise.
+*/
+var DefaultFocusRules = FocusRules{MinMinutes: 25, IdleMinutes: 5, MaxSwitches: 3}
+
+/*
+	Validate reports the first invalid rule.
+*/
+func (r FocusRules) Validate() error {
+	switch {
+	case r.MinMinutes <= 0, r.IdleMinutes <= 0:
+		return fmt.Errorf("focus sessions: min_minutes and idle_minutes must be positive")
+	case r.MaxSwitches < 0:
+		return fmt.Errorf("focus sessions: max_switches must not be negative")
+	}
+	return nil
+}
+
+/*
+	FocusSession is a stretch of sustained typing into App, from its first
+	key to its last. Switches is how often another window was brought to
+	the front in between.
+*/
+type FocusSession struct {
+	App        string
+	Start, End time.Time
+	Keys       int
+	Switches   int
+}
+
+/*
+	Duration returns how long the session lasted.
+*/
+func (s FocusSession) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+/*
+	FocusSessionDetector finds FocusSessions in events in the order they
+	happened, following the foreground application from FocusEvents.
+	Injected input does not count, except keys typed on an on-screen
+	keyboard.
+*/
+type FocusSessionDetector struct {
+	min      time.Duration
+	idle     time.Duration
+	switches int
+
+	app      string // in the foreground
+	current  *FocusSession
+	other    *FocusSession // typing into another application meanwhile
+	sessions []FocusSession
+}
+
+/*
+	NewFocusSessionDetector returns a detector for the given rules, which
+	must be valid.
+*/
+func NewFocusSessionDetector(rules FocusRules) *FocusSessionDetector {
+	return &FocusSessionDetector{
+		min:      time.Duration(rules.MinMinutes * float64(time.Minute)),
+		idle:     time.Duration(rules.IdleMinutes * float64(time.Minute)),
+		switches: rules.MaxSwitches,
+	}
+}
+
+/*
+	Feed processes one event.
+*/
+func (d *FocusSessionDetector) Feed(ev Event) {
+	switch e := ev.(type) {
+	case FocusEvent:
+		if s := d.current; s != nil && e.Process != d.app && d.app == s.App {
+			s.Switches++
+			if s.Switches > d.switches {
+				s.Switches--
+				d.end()
+				d.other = nil
+			}
+		}
+		d.app = e.Process
+	case KeyEvent:
+		if e.Source() == KeySourceInjected || !e.Down {
+			return
+		}
+		if s := d.current; s != nil && e.Time.Sub(s.End) >= d.idle {
+			// Typing that went on elsewhere may be a session of its own.
+			d.end()
+			if o := d.other; o != nil && e.Time.Sub(o.End) < d.idle {
+				d.current = o
+			}
+			d.other = nil
+		}
+		if d.current == nil {
+			d.current = &FocusSession{App: d.app, Start: e.Time}
+		}
+		if s := d.current; s.App == d.app {
+			s.Keys++
+			s.End = e.Time
+			d.other = nil
+			return
+		}
+		if d.other == nil || d.other.App != d.app {
+			d.other = &FocusSession{App: d.app, Start: e.Time}
+		}
+		d.other.Keys++
+		d.other.End = e.Time
+	}
+}
+
+/*
+	Sessions returns the sessions found so far, ending the one going on.
+*/
+func (d *FocusSessionDetector) Sessions() []FocusSession {
+	d.end()
+	return d.sessions
+}
+
+func (d *FocusSessionDetector) end() {
+	if s := d.current; s != nil && s.Duration() >= d.min {
+		d.sessions = append(d.sessions, *s)
+	}
+	d.current = nil
+}
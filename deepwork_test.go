@@ -0,0 +1,132 @@
+package keylogger
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var deepWorkStart = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+func minutesIn(m float64) time.Time {
+	return deepWorkStart.Add(time.Duration(m * float64(time.Minute)))
+}
+
+func focusAt(app string, m float64) FocusEvent {
+	return FocusEvent{Process: app, Time: minutesIn(m)}
+}
+
+/*
+	typingEvery is a key typed every minute from first to last.
+*/
+func typingEvery(first, last float64) []Event {
+	var events []Event
+	for m := first; m <= last; m++ {
+		events = append(events, KeyEvent{VkCode: 'A', Down: true, Time: minutesIn(m)})
+	}
+	return events
+}
+
+func detectFocusSessions(rules FocusRules, events ...[]Event) []FocusSession {
+	d := NewFocusSessionDetector(rules)
+	for _, evs := range events {
+		for _, e := range evs {
+			d.Feed(e)
+		}
+	}
+	return d.Sessions()
+}
+
+func TestFocusSessionBoundaries(t *testing.T) {
+	rules := FocusRules{MinMinutes: 10, IdleMinutes: 2, MaxSwitches: 1}
+	code := []Event{focusAt("code.exe", 0)}
+	for _, c := range []struct {
+		name   string
+		events [][]Event
+		want   []FocusSession
+	}{
+		{
+			name:   "long enough",
+			events: [][]Event{code, typingEvery(0, 10)},
+			want:   []FocusSession{{App: "code.exe", Start: minutesIn(0), End: minutesIn(10), Keys: 11}},
+		},
+		{
+			name:   "too short",
+			events: [][]Event{code, typingEvery(0, 9)},
+		},
+		{
+			name:   "pause just under the idle threshold",
+			events: [][]Event{code, typingEvery(0, 4), typingEvery(5.99, 10.99)},
+			want:   []FocusSession{{App: "code.exe", Start: minutesIn(0), End: minutesIn(10.99), Keys: 11}},
+		},
+		{
+			name:   "pause of the idle threshold",
+			events: [][]Event{code, typingEvery(0, 4), typingEvery(6, 15)},
+		},
+		{
+			name:   "sessions either side of a pause",
+			events: [][]Event{code, typingEvery(0, 10), typingEvery(20, 30)},
+			want: []FocusSession{
+				{App: "code.exe", Start: minutesIn(0), End: minutesIn(10), Keys: 11},
+				{App: "code.exe", Start: minutesIn(20), End: minutesIn(30), Keys: 11},
+			},
+		},
+		{
+			name: "glance at another window",
+			events: [][]Event{
+				code, typingEvery(0, 5), {focusAt("chrome.exe", 5.5), focusAt("code.exe", 5.9)}, typingEvery(6, 10),
+			},
+			want: []FocusSession{{App: "code.exe", Start: minutesIn(0), End: minutesIn(10), Keys: 11, Switches: 1}},
+		},
+		{
+			name: "too many switches",
+			events: [][]Event{
+				code, typingEvery(0, 10),
+				{focusAt("chrome.exe", 10.2), focusAt("code.exe", 10.4), focusAt("chrome.exe", 10.6), focusAt("code.exe", 10.8)},
+				typingEvery(11, 15),
+			},
+			want: []FocusSession{{App: "code.exe", Start: minutesIn(0), End: minutesIn(10), Keys: 11, Switches: 1}},
+		},
+		{
+			name: "typing moves to another application",
+			events: [][]Event{
+				code, typingEvery(0, 10), {focusAt("slack.exe", 10.5)}, typingEvery(11, 22),
+			},
+			want: []FocusSession{
+				{App: "code.exe", Start: minutesIn(0), End: minutesIn(10), Keys: 11, Switches: 1},
+				{App: "slack.exe", Start: minutesIn(11), End: minutesIn(22), Keys: 12},
+			},
+		},
+		{
+			name: "injected keys and releases",
+			events: [][]Event{
+				code, typingEvery(0, 5),
+				{
+					KeyEvent{VkCode: 'A', Down: true, Flags: LLKHF_INJECTED, Time: minutesIn(7)},
+					KeyEvent{VkCode: 'A', Time: minutesIn(9)},
+					KeyEvent{VkCode: 'A', Down: true, Flags: LLKHF_INJECTED, OnScreen: true, Time: minutesIn(6.5)},
+				},
+			},
+		},
+	} {
+		if got := detectFocusSessions(rules, c.events...); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: sessions\n%+v\nwant\n%+v", c.name, got, c.want)
+		}
+	}
+}
+
+/*
+	Keys typed on an on-screen keyboard count, although they are injected.
+*/
+func TestFocusSessionOnScreenKeyboard(t *testing.T) {
+	var typing []Event
+	for _, e := range typingEvery(0, 10) {
+		k := e.(KeyEvent)
+		k.Flags, k.OnScreen = LLKHF_INJECTED, true
+		typing = append(typing, k)
+	}
+	got := detectFocusSessions(FocusRules{MinMinutes: 10, IdleMinutes: 2}, []Event{focusAt("notepad.exe", 0)}, typing)
+	if len(got) != 1 || got[0].Keys != 11 {
+		t.Errorf("sessions %+v", got)
+	}
+}
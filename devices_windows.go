@@ -0,0 +1,182 @@
+package keylogger
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	registerDeviceNotificationW  = user32.NewProc("RegisterDeviceNotificationW")
+	unregisterDeviceNotification = user32.NewProc("UnregisterDeviceNotification")
+)
+
+/*
+	Device change notifications.
+	https://docs.microsoft.com/en-us/windows/win32/devio/wm-devicechange
+*/
+const (
+	WM_DEVICECHANGE             = 0x0219
+	DBT_DEVICEARRIVAL           = 0x8000
+	DBT_DEVICEREMOVECOMPLETE    = 0x8004
+	DBT_DEVTYP_DEVICEINTERFACE  = 5
+	DEVICE_NOTIFY_WINDOW_HANDLE = 0
+	devicesClass                = "KeyloggerDevices"
+)
+
+/*
+	The interface class every keyboard registers, whatever its bus.
+*/
+var GUID_DEVINTERFACE_KEYBOARD = windows.GUID{Data1: 0x884b96c3, Data2: 0x56ef, Data3: 0x11d1, Data4: [8]byte{0xbc, 0x8c, 0x00, 0xa0, 0xc9, 0x14, 0x05, 0xdd}}
+
+/*
+	DEV_BROADCAST_DEVICEINTERFACE_W, followed in memory by the rest of the
+	NUL-terminated name of the device interface.
+*/
+type devBroadcastDeviceInterface struct {
+	size       uint32
+	deviceType uint32
+	reserved   uint32
+	classGUID  windows.GUID
+	name       [1]uint16
+}
+
+/*
+	A Logger watching keyboards come and go, and the stop channel of its
+	poller.
+*/
+type deviceWatch struct {
+	l    *Logger
+	stop <-chan struct{}
+}
+
+var (
+	devicesClassOnce sync.Once
+	devicesClassErr  error
+	devicesMu        sync.Mutex
+	devices          = make(map[HWND]deviceWatch)
+)
+
+/*
+	Told by device notifications to a message-only window of its own
+	thread, marks every keyboard attached or removed while the Logger runs
+	with a DiagKeyboardAttached or DiagKeyboardRemoved event.
+*/
+func (l *Logger) watchDevices(stop <-chan struct{}) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	hwnd, err := createDevicesWindow()
+	var notify uintptr
+	if err == nil {
+		filter := devBroadcastDeviceInterface{deviceType: DBT_DEVTYP_DEVICEINTERFACE, classGUID: GUID_DEVINTERFACE_KEYBOARD}
+		filter.size = uint32(unsafe.Sizeof(filter))
+		var e error
+		if notify, _, e = registerDeviceNotificationW.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&filter)), DEVICE_NOTIFY_WINDOW_HANDLE); notify == 0 {
+			destroyWindow.Call(uintptr(hwnd))
+			err = fmt.Errorf("RegisterDeviceNotification: %v", e)
+		}
+	}
+	if err != nil {
+		l.emitSide(DiagnosticEvent{Kind: DiagDeviceWatchFailed, Message: err.Error(), Time: time.Now()}, stop)
+		return
+	}
+	defer unregisterDeviceNotification.Call(notify)
+	devicesMu.Lock()
+	devices[hwnd] = deviceWatch{l: l, stop: stop}
+	devicesMu.Unlock()
+	defer func() {
+		devicesMu.Lock()
+		delete(devices, hwnd)
+		devicesMu.Unlock()
+	}()
+	go func() {
+		<-stop
+		postMessageW.Call(uintptr(hwnd), WM_CLOSE, 0, 0)
+	}()
+	var msg MSG
+	for GetMessage(&msg, 0, 0, 0) > 0 {
+		dispatchMessageW.Call(uintptr(unsafe.Pointer(&msg)))
+	}
+}
+
+func createDevicesWindow() (HWND, error) {
+	var instance windows.Handle
+	if err := windows.GetModuleHandleEx(0, nil, &instance); err != nil {
+		return 0, err
+	}
+	class, _ := windows.UTF16PtrFromString(devicesClass)
+	devicesClassOnce.Do(func() {
+		wc := wndClassEx{
+			wndProc:   windows.NewCallback(devicesProc),
+			instance:  instance,
+			className: class,
+		}
+		wc.size = uint32(unsafe.Sizeof(wc))
+		if ret, _, err := registerClassExW.Call(uintptr(unsafe.Pointer(&wc))); ret == 0 {
+			devicesClassErr = fmt.Errorf("RegisterClassEx: %v", err)
+		}
+	})
+	if devicesClassErr != nil {
+		return 0, devicesClassErr
+	}
+	ret, _, err := createWindowExW.Call(0, uintptr(unsafe.Pointer(class)), 0, 0, 0, 0, 0, 0, HWND_MESSAGE, 0, uintptr(instance), 0)
+	if ret == 0 {
+		return 0, fmt.Errorf("CreateWindowEx: %v", err)
+	}
+	return HWND(ret), nil
+}
+
+func devicesProc(hwnd HWND, msg uint32, wparam WPARAM, lparam LPARAM) LRESULT {
+	switch msg {
+	case WM_DEVICECHANGE:
+		if (wparam == DBT_DEVICEARRIVAL || wparam == DBT_DEVICEREMOVECOMPLETE) && lparam != 0 {
+			d := *(**devBroadcastDeviceInterface)(unsafe.Pointer(&lparam))
+			devicesMu.Lock()
+			w, ok := devices[hwnd]
+			devicesMu.Unlock()
+			if ok && d.deviceType == DBT_DEVTYP_DEVICEINTERFACE {
+				w.l.keyboardChanged(windows.UTF16PtrToString(&d.name[0]), wparam == DBT_DEVICEARRIVAL, w.stop)
+			}
+		}
+		return 1
+	case WM_CLOSE:
+		destroyWindow.Call(uintptr(hwnd))
+		return 0
+	case WM_DESTROY:
+		postQuitMessage.Call(0)
+		return 0
+	}
+	ret, _, _ := defWindowProcW.Call(uintptr(hwnd), uintptr(msg), uintptr(wparam), uintptr(lparam))
+	return LRESULT(ret)
+}
+
+func (l *Logger) keyboardChanged(path string, attached bool, stop <-chan struct{}) {
+	d := DiagnosticEvent{Kind: DiagKeyboardRemoved, Message: "keyboard removed: " + describeDevice(path), Time: time.Now()}
+	if attached {
+		d.Kind, d.Message = DiagKeyboardAttached, "keyboard attached: "+describeDevice(path)
+	}
+	l.emitSide(d, stop)
+}
+
+/*
+	Puts the vendor and product ids of a device interface path, such as
+	\\?\HID#VID_046D&PID_C31C&MI_00#..., in front of it.
+*/
+func describeDevice(path string) string {
+	upper := strings.ToUpper(path)
+	var ids []string
+	for _, id := range []string{"VID_", "PID_"} {
+		if i := strings.Index(upper, id); i >= 0 && i+len(id)+4 <= len(upper) {
+			ids = append(ids, upper[i:i+len(id)+4])
+		}
+	}
+	if len(ids) == 0 {
+		return path
+	}
+	return strings.Join(ids, " ") + " (" + path + ")"
+}
@@ -0,0 +1,63 @@
+package keylogger
+
+import "time"
+
+/*
+	DiagnosticEvent reports something that happened to the capture itself
+	rather than to the input, e.g. DiagHookReinstalled. The event stream
+	carries it so logs show gaps in the capture where they occurred.
+*/
+type DiagnosticEvent struct {
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+/*
+	Kinds of DiagnosticEvent.
+*/
+const (
+	// A hook Windows had removed was installed again.
+	DiagHookReinstalled = "hook-reinstalled"
+	// A hook Windows had removed could not be installed again.
+	DiagHookLost = "hook-lost"
+	// The hook thread's message loop stopped responding and was replaced.
+	DiagLoopStalled = "loop-stalled"
+	// Writing the trace failed; it ends before this event.
+	DiagTraceFailed = "trace-failed"
+	// Writing the hook dump failed; it ends before this event.
+	DiagHookDumpFailed = "hook-dump-failed"
+	// The hooks were removed by Pause or because the workstation was locked.
+	DiagPaused = "paused"
+	// The hooks were installed again after a pause.
+	DiagResumed = "resumed"
+	// A pause or resume could not be recorded in the audit log; a resume
+	// that could not be recorded did not happen.
+	DiagAuditFailed = "audit-failed"
+	// Keys are logged without codes or text while a sensitive application is in use.
+	DiagRedactStarted = "redact-started"
+	// Keys are logged in full again.
+	DiagRedactEnded = "redact-ended"
+	// Input went to a desktop the hooks cannot see, such as that of a UAC prompt.
+	DiagCaptureUnavailable = "capture-unavailable"
+	// Input is back on the desktop the hooks see.
+	DiagCaptureAvailable = "capture-available"
+	// The session the capture runs in, or a change to it such as a remote
+	// desktop connecting.
+	DiagSession = "session"
+	// The session could not be watched for PauseWhenLocked or WatchSessions.
+	DiagSessionWatchFailed = "session-watch-failed"
+	// A keyboard was attached, e.g. plugged in over USB or paired.
+	DiagKeyboardAttached = "keyboard-attached"
+	// A keyboard was removed.
+	DiagKeyboardRemoved = "keyboard-removed"
+	// Keyboards could not be watched for WatchDevices.
+	DiagDeviceWatchFailed = "device-watch-failed"
+)
+
+/*
+	Timestamp returns when the condition was detected.
+*/
+func (e DiagnosticEvent) Timestamp() time.Time {
+	return e.Time
+}
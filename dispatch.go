@@ -0,0 +1,110 @@
+package keylogger
+
+import (
+	"sync/atomic"
+)
+
+/*
+	Latency budget of the hook callbacks.
+
+	Windows silently removes a low-level hook whose callback does not
+	return within LowLevelHooksTimeout (HKCU\Control Panel\Desktop, at most
+	1000 ms on Windows 7 and later), and every keystroke on the desktop
+	waits for the callback. The callbacks therefore only copy the hook
+	struct into an eventRing and return; translation, double-click
+	detection, mouse sampling and window lookups run on the Logger's worker
+	goroutine. The one exception are KeyFilters, which must decide
+	synchronously whether to swallow a key and should return within a few
+	microseconds. BenchmarkEventRing measures the copy, well under one
+	microsecond per event.
+
+	Once the caches are warm, neither the hooks nor the worker allocate;
+	TestHotPathAllocs checks this. The one allocation left per event is
+	boxing it into an Event for the Events channel.
+*/
+
+type rawKind uint8
+
+const (
+	rawKey rawKind = iota
+	rawMouse
+	rawFocus
+	rawDiagnostic
+)
+
+/*
+	An event as copied out of the hook, before enrichment.
+*/
+type rawEvent struct {
+	kind  rawKind
+	key   KeyEvent
+	mouse MouseEvent
+	focus FocusEvent
+	diag  DiagnosticEvent
+}
+
+/*
+	Preallocated single-producer, single-consumer queue between the hook
+	thread and the worker. push never blocks: when the worker falls behind
+	by the whole ring, the newest event is dropped and counted.
+*/
+type eventRing struct {
+	dropped uint64 // first for 64-bit alignment on 386
+	head    uint32 // next slot to write, advanced by the producer
+	tail    uint32 // next slot to read, advanced by the consumer
+	most    uint32 // high-water mark, written by the producer
+	buf     []rawEvent
+	mask    uint32
+	wake    chan struct{}
+}
+
+/*
+	size must be a power of two.
+*/
+func newEventRing(size int) *eventRing {
+	return &eventRing{
+		buf:  make([]rawEvent, size),
+		mask: uint32(size - 1),
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (r *eventRing) push(e *rawEvent) bool {
+	head := atomic.LoadUint32(&r.head)
+	if head-atomic.LoadUint32(&r.tail) == uint32(len(r.buf)) {
+		atomic.AddUint64(&r.dropped, 1)
+		return false
+	}
+	r.buf[head&r.mask] = *e
+	atomic.StoreUint32(&r.head, head+1)
+	if n := head + 1 - atomic.LoadUint32(&r.tail); n > r.most {
+		atomic.StoreUint32(&r.most, n)
+	}
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (r *eventRing) pop(e *rawEvent) bool {
+	tail := atomic.LoadUint32(&r.tail)
+	if tail == atomic.LoadUint32(&r.head) {
+		return false
+	}
+	*e = r.buf[tail&r.mask]
+	atomic.StoreUint32(&r.tail, tail+1)
+	return true
+}
+
+func (r *eventRing) len() int {
+	return int(atomic.LoadUint32(&r.head) - atomic.LoadUint32(&r.tail))
+}
+
+func (r *eventRing) highWater() int {
+	return int(atomic.LoadUint32(&r.most))
+}
+
+func (r *eventRing) droppedCount() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
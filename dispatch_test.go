@@ -0,0 +1,91 @@
+package keylogger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventRingDropsWhenFull(t *testing.T) {
+	r := newEventRing(4)
+	for i := 0; i < 6; i++ {
+		r.push(&rawEvent{kind: rawKey, key: KeyEvent{VkCode: uint16('A' + i)}})
+	}
+	if got := r.droppedCount(); got != 2 {
+		t.Fatalf("dropped = %d, want 2", got)
+	}
+	var e rawEvent
+	for i := 0; i < 4; i++ {
+		if !r.pop(&e) || e.key.VkCode != uint16('A'+i) {
+			t.Fatalf("pop %d = %c, want %c", i, rune(e.key.VkCode), 'A'+i)
+		}
+	}
+	if r.pop(&e) {
+		t.Fatal("pop from empty ring succeeded")
+	}
+}
+
+/*
+	The work done per event on the hook thread.
+*/
+func BenchmarkEventRing(b *testing.B) {
+	r := newEventRing(1024)
+	raw := rawEvent{kind: rawKey, key: KeyEvent{VkCode: 'A', Down: true, Time: time.Now()}}
+	var e rawEvent
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.push(&raw)
+		r.pop(&e)
+	}
+}
+
+/*
+	Everything between the hook and the Events channel except the Win32
+	calls: queueing, text conversion, mouse sampling and double-clicks.
+*/
+func hotPath(r *eventRing, text *textCache, s *MoveSampler, clicks *doubleClicks, i int) {
+	now := time.Now()
+	raw := rawEvent{kind: rawKey, key: KeyEvent{VkCode: 'A', Down: true, Time: now}}
+	r.push(&raw)
+	raw = rawEvent{kind: rawMouse, mouse: MouseEvent{Action: MouseDown, X: int32(i), Time: now}}
+	r.push(&raw)
+	var e rawEvent
+	for r.pop(&e) {
+		switch e.kind {
+		case rawKey:
+			e.key.Text = text.text([]uint16{'a' + uint16(i%26)})
+		case rawMouse:
+			if s.Sample(e.mouse) {
+				clicks.check(&e.mouse)
+			}
+		}
+	}
+}
+
+func TestHotPathAllocs(t *testing.T) {
+	r := newEventRing(16)
+	var text textCache
+	var s MoveSampler
+	clicks := doubleClicks{interval: 500 * time.Millisecond, width: 4, height: 4}
+	for i := 0; i < 26; i++ {
+		hotPath(r, &text, &s, &clicks, i) // warm the text cache
+	}
+	i := 0
+	allocs := testing.AllocsPerRun(1000, func() {
+		i++
+		hotPath(r, &text, &s, &clicks, i)
+	})
+	if allocs != 0 {
+		t.Fatalf("%v allocations per event pair, want 0", allocs)
+	}
+}
+
+func BenchmarkHotPath(b *testing.B) {
+	r := newEventRing(16)
+	var text textCache
+	var s MoveSampler
+	clicks := doubleClicks{interval: 500 * time.Millisecond, width: 4, height: 4}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		hotPath(r, &text, &s, &clicks, i)
+	}
+}
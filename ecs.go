@@ -0,0 +1,183 @@
+package keylogger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+)
+
+/*
+	ECSVersion is the version of the Elastic Common Schema ECSCodec writes.
+*/
+const ECSVersion = "8.11.0"
+
+/*
+	ECSCodec writes one JSON document per line in the Elastic Common Schema,
+	for Filebeat or Elastic Agent to ship to Elasticsearch and Kibana to
+	show without a mapping of their own:
+
+		{"@timestamp":"...","ecs":{"version":"8.11.0"},"event":{"kind":"event","category":["host"],"type":["info"],"action":"key-down","dataset":"keylogger.key"},...}
+
+	@timestamp and the event, host and user fields are ECS; the window,
+	process and diagnostic fields map to process.* and message where ECS
+	has a field and go under keylogger.* where it has none, as the key,
+	mouse and text fields do. host.* and user.* are those of Host and
+	User, left out when empty. keylogger.key.name is named by Names,
+	KeyName if nil. Records cannot be read back: logs are kept with
+	JSONCodec or BinaryCodec and converted.
+*/
+type ECSCodec struct {
+	Host  ECSHost
+	User  ECSUser
+	Names func(vk uint16) string
+}
+
+/*
+	ECSHost is the host.* of ECS documents.
+*/
+type ECSHost struct {
+	Name string `json:"name,omitempty"`
+}
+
+/*
+	ECSUser is the user.* of ECS documents.
+*/
+type ECSUser struct {
+	Name   string `json:"name,omitempty"`
+	Domain string `json:"domain,omitempty"`
+}
+
+/*
+	NewECSCodec returns an ECSCodec naming this machine and the current
+	user, split into domain and name as Windows gives it.
+*/
+func NewECSCodec() ECSCodec {
+	var c ECSCodec
+	c.Host.Name, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		c.User.Name = u.Username
+		if i := strings.LastIndexByte(u.Username, '\\'); i >= 0 {
+			c.User.Domain, c.User.Name = u.Username[:i], u.Username[i+1:]
+		}
+	}
+	return c
+}
+
+type ecsEvent struct {
+	Kind     string   `json:"kind"`
+	Category []string `json:"category"`
+	Type     []string `json:"type"`
+	Action   string   `json:"action"`
+	Dataset  string   `json:"dataset"`
+	Start    string   `json:"start,omitempty"`
+	End      string   `json:"end,omitempty"`
+}
+
+type ecsVersion struct {
+	Version string `json:"version"`
+}
+
+type ecsProcess struct {
+	Name string `json:"name,omitempty"`
+	PID  uint32 `json:"pid,omitempty"`
+}
+
+type ecsDocument struct {
+	Timestamp string                 `json:"@timestamp"`
+	ECS       ecsVersion             `json:"ecs"`
+	Event     ecsEvent               `json:"event"`
+	Message   string                 `json:"message,omitempty"`
+	Host      *ECSHost               `json:"host,omitempty"`
+	User      *ECSUser               `json:"user,omitempty"`
+	Process   *ecsProcess            `json:"process,omitempty"`
+	Keylogger map[string]interface{} `json:"keylogger,omitempty"`
+}
+
+func (c ECSCodec) AppendEvent(dst []byte, e Event) ([]byte, error) {
+	t := EventType(e)
+	d := ecsDocument{
+		Timestamp: ecsTime(e.Timestamp()),
+		Event:     ecsEvent{Kind: "event", Category: []string{"host"}, Type: []string{"info"}, Action: t, Dataset: "keylogger." + t},
+	}
+	d.ECS.Version = ECSVersion
+	if c.Host != (ECSHost{}) {
+		d.Host = &c.Host
+	}
+	if c.User != (ECSUser{}) {
+		d.User = &c.User
+	}
+	switch e := e.(type) {
+	case KeyEvent:
+		name := KeyName
+		if c.Names != nil {
+			name = c.Names
+		}
+		d.Event.Action = "key-up"
+		if e.Down {
+			d.Event.Action = "key-down"
+		}
+		d.Keylogger = map[string]interface{}{"key": map[string]interface{}{
+			"vk":        e.VkCode,
+			"name":      name(e.VkCode),
+			"scan_code": e.ScanCode,
+			"text":      e.Text,
+			"injected":  e.Injected(),
+			"source":    e.Source().String(),
+			"swallowed": e.Swallowed,
+		}}
+	case MouseEvent:
+		d.Event.Action = "mouse-" + e.Action.String()
+		d.Keylogger = map[string]interface{}{"mouse": map[string]interface{}{
+			"button":       e.Button.String(),
+			"x":            e.X,
+			"y":            e.Y,
+			"delta":        e.Delta,
+			"horizontal":   e.Horizontal,
+			"double_click": e.DoubleClick,
+			"injected":     e.Injected(),
+		}}
+	case FocusEvent:
+		d.Process = &ecsProcess{Name: e.Process, PID: e.PID}
+		d.Keylogger = map[string]interface{}{"window": map[string]interface{}{
+			"title":    e.Title,
+			"hwnd":     e.HWND,
+			"elevated": e.Elevated,
+		}}
+	case ProcessEvent:
+		d.Event.Category, d.Event.Type, d.Event.Action = []string{"process"}, []string{"end"}, "process-exited"
+		if e.Started {
+			d.Event.Type, d.Event.Action = []string{"start"}, "process-started"
+		}
+		d.Process = &ecsProcess{Name: e.Name, PID: e.PID}
+	case GamepadEvent:
+		d.Keylogger = map[string]interface{}{"gamepad": map[string]interface{}{
+			"pad":    e.Pad,
+			"button": e.Button.String(),
+			"down":   e.Down,
+			"state":  e.State,
+		}}
+	case TextEvent:
+		d.Event.Start, d.Event.End = ecsTime(e.Start), ecsTime(e.Time)
+		d.Process = &ecsProcess{Name: e.Process}
+		d.Keylogger = map[string]interface{}{
+			"window": map[string]interface{}{"title": e.Title},
+			"text":   map[string]interface{}{"value": e.Text, "keys": e.Keys, "uncertain": e.Uncertain},
+		}
+	case DiagnosticEvent:
+		d.Event.Action, d.Message = e.Kind, e.Message
+	default:
+		return dst, fmt.Errorf("cannot encode %s event", t)
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return dst, err
+	}
+	return append(append(dst, data...), '\n'), nil
+}
+
+func ecsTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
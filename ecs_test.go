@@ -0,0 +1,64 @@
+package keylogger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+	The documents of every event type, down to the field names, which
+	Kibana dashboards and Elasticsearch queries depend on.
+*/
+func TestECSGolden(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 30, 0, 123000000, time.UTC)
+	c := ECSCodec{Host: ECSHost{Name: "desk-7"}, User: ECSUser{Name: "ada", Domain: "LAB"}}
+	events := []Event{
+		KeyEvent{VkCode: 'A', ScanCode: 0x1E, Down: true, Text: "a", Time: at},
+		KeyEvent{VkCode: VK_LSHIFT, ScanCode: 0x2A, Flags: LLKHF_INJECTED, Time: at},
+		MouseEvent{Action: MouseDown, Button: LeftButton, X: 10, Y: -20, Time: at},
+		FocusEvent{HWND: 0x10246, PID: 4242, Process: "notepad.exe", Title: "a\"b – Notepad", Time: at},
+		ProcessEvent{PID: 7, Name: "code.exe", Started: true, Time: at},
+		TextEvent{Start: at.Add(-time.Second), Process: "notepad.exe", Title: "notes", Text: "héllo", Keys: 6, Time: at},
+		DiagnosticEvent{Kind: DiagHookReinstalled, Message: "keyboard hook", Time: at},
+		GamepadEvent{Pad: 1, Button: XINPUT_GAMEPAD_A, Down: true, Time: at},
+	}
+	var got []string
+	for _, e := range events {
+		b, err := c.AppendEvent(nil, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.TrimSuffix(string(b), "\n"))
+	}
+	want := strings.Split(strings.TrimSpace(ecsGolden), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("%d documents, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("document %d:\n%s\nwant\n%s", i, got[i], want[i])
+		}
+	}
+
+	// Without a host and user, and with keys named by Names.
+	c = ECSCodec{Names: func(vk uint16) string { return "key-" + KeyName(vk) }}
+	b, err := c.AppendEvent(nil, events[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc := string(b); strings.Contains(doc, `"host":`) || strings.Contains(doc, `"user":`) || !strings.Contains(doc, `"name":"key-A"`) {
+		t.Errorf("document %s", doc)
+	}
+}
+
+const ecsGolden = `
+{"@timestamp":"2024-03-01T09:30:00.123000000Z","ecs":{"version":"8.11.0"},"event":{"kind":"event","category":["host"],"type":["info"],"action":"key-down","dataset":"keylogger.key"},"host":{"name":"desk-7"},"user":{"name":"ada","domain":"LAB"},"keylogger":{"key":{"injected":false,"name":"A","scan_code":30,"source":"keyboard","swallowed":false,"text":"a","vk":65}}}
+{"@timestamp":"2024-03-01T09:30:00.123000000Z","ecs":{"version":"8.11.0"},"event":{"kind":"event","category":["host"],"type":["info"],"action":"key-up","dataset":"keylogger.key"},"host":{"name":"desk-7"},"user":{"name":"ada","domain":"LAB"},"keylogger":{"key":{"injected":true,"name":"LShift","scan_code":42,"source":"injected","swallowed":false,"text":"","vk":160}}}
+{"@timestamp":"2024-03-01T09:30:00.123000000Z","ecs":{"version":"8.11.0"},"event":{"kind":"event","category":["host"],"type":["info"],"action":"mouse-down","dataset":"keylogger.mouse"},"host":{"name":"desk-7"},"user":{"name":"ada","domain":"LAB"},"keylogger":{"mouse":{"button":"Left","delta":0,"double_click":false,"horizontal":false,"injected":false,"x":10,"y":-20}}}
+{"@timestamp":"2024-03-01T09:30:00.123000000Z","ecs":{"version":"8.11.0"},"event":{"kind":"event","category":["host"],"type":["info"],"action":"focus","dataset":"keylogger.focus"},"host":{"name":"desk-7"},"user":{"name":"ada","domain":"LAB"},"process":{"name":"notepad.exe","pid":4242},"keylogger":{"window":{"elevated":false,"hwnd":66118,"title":"a\"b – Notepad"}}}
+{"@timestamp":"2024-03-01T09:30:00.123000000Z","ecs":{"version":"8.11.0"},"event":{"kind":"event","category":["process"],"type":["start"],"action":"process-started","dataset":"keylogger.process"},"host":{"name":"desk-7"},"user":{"name":"ada","domain":"LAB"},"process":{"name":"code.exe","pid":7}}
+{"@timestamp":"2024-03-01T09:30:00.123000000Z","ecs":{"version":"8.11.0"},"event":{"kind":"event","category":["host"],"type":["info"],"action":"text","dataset":"keylogger.text","start":"2024-03-01T09:29:59.123000000Z","end":"2024-03-01T09:30:00.123000000Z"},"host":{"name":"desk-7"},"user":{"name":"ada","domain":"LAB"},"process":{"name":"notepad.exe"},"keylogger":{"text":{"keys":6,"uncertain":false,"value":"héllo"},"window":{"title":"notes"}}}
+{"@timestamp":"2024-03-01T09:30:00.123000000Z","ecs":{"version":"8.11.0"},"event":{"kind":"event","category":["host"],"type":["info"],"action":"hook-reinstalled","dataset":"keylogger.diagnostic"},"message":"keyboard hook","host":{"name":"desk-7"},"user":{"name":"ada","domain":"LAB"}}
+{"@timestamp":"2024-03-01T09:30:00.123000000Z","ecs":{"version":"8.11.0"},"event":{"kind":"event","category":["host"],"type":["info"],"action":"gamepad","dataset":"keylogger.gamepad"},"host":{"name":"desk-7"},"user":{"name":"ada","domain":"LAB"},"keylogger":{"gamepad":{"button":"A","down":true,"pad":1,"state":{"buttons":0,"left_trigger":0,"right_trigger":0,"left_x":0,"left_y":0,"right_x":0,"right_y":0}}}}
+`
@@ -0,0 +1,164 @@
+package keylogger
+
+import (
+	"path"
+	"strings"
+)
+
+/*
+	EditorFile is what the title of an editor or IDE window tells about
+	the file being edited. Project is empty when the title does not name
+	one.
+*/
+type EditorFile struct {
+	Editor  string
+	File    string
+	Project string
+}
+
+/*
+	Editors recognized by ParseEditorTitle, by executable in lower case:
+	their name and how to read their window titles.
+*/
+var editorTitles = map[string]struct {
+	name  string
+	parse func(title string) (file, project string)
+}{
+	"code.exe":            {"vscode", vscodeTitle},
+	"code - insiders.exe": {"vscode", vscodeTitle},
+	"vscodium.exe":        {"vscodium", vscodeTitle},
+	"cursor.exe":          {"cursor", vscodeTitle},
+	"idea64.exe":          {"intellij", jetbrainsTitle},
+	"goland64.exe":        {"goland", jetbrainsTitle},
+	"pycharm64.exe":       {"pycharm", jetbrainsTitle},
+	"webstorm64.exe":      {"webstorm", jetbrainsTitle},
+	"phpstorm64.exe":      {"phpstorm", jetbrainsTitle},
+	"clion64.exe":         {"clion", jetbrainsTitle},
+	"rider64.exe":         {"rider", jetbrainsTitle},
+	"rubymine64.exe":      {"rubymine", jetbrainsTitle},
+	"datagrip64.exe":      {"datagrip", jetbrainsTitle},
+	"studio64.exe":        {"androidstudio", jetbrainsTitle},
+	"sublime_text.exe":    {"sublime", sublimeTitle},
+	"notepad++.exe":       {"notepadpp", notepadPlusPlusTitle},
+	"devenv.exe":          {"visualstudio", visualStudioTitle},
+	"gvim.exe":            {"vim", vimTitle},
+}
+
+/*
+	ParseEditorTitle reports the file and project shown in the title of a
+	window of process, one of the editors and IDEs of Visual Studio Code,
+	JetBrains, Sublime Text, Notepad++, Visual Studio and gVim. It returns
+	false for other processes and for titles that name no file.
+*/
+func ParseEditorTitle(process, title string) (EditorFile, bool) {
+	process = strings.ToLower(process)
+	editor, ok := editorTitles[process]
+	if !ok {
+		return EditorFile{}, false
+	}
+	file, project := editor.parse(strings.TrimSpace(title))
+	file = strings.TrimSpace(strings.TrimLeft(file, "●*• "))
+	if file == "" {
+		return EditorFile{}, false
+	}
+	return EditorFile{Editor: editor.name, File: file, Project: strings.TrimSpace(project)}, true
+}
+
+/*
+	"file.go - project - Visual Studio Code", the project left out when no
+	folder is open.
+*/
+func vscodeTitle(title string) (file, project string) {
+	parts := strings.Split(title, " - ")
+	if len(parts) < 2 {
+		return "", ""
+	}
+	parts = parts[:len(parts)-1]
+	if len(parts) >= 2 {
+		project = parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, " - "), project
+}
+
+/*
+	"project – file.go" or "project – path/file.go", with an en dash; newer
+	versions add " [path]" after the project.
+*/
+func jetbrainsTitle(title string) (file, project string) {
+	i := strings.Index(title, " – ")
+	if i < 0 {
+		return "", ""
+	}
+	project, file = title[:i], title[i+len(" – "):]
+	if j := strings.Index(project, " ["); j >= 0 {
+		project = project[:j]
+	}
+	return file, project
+}
+
+/*
+	"path\file.go (project) - Sublime Text" or "path\file.go - Sublime Text".
+*/
+func sublimeTitle(title string) (file, project string) {
+	title = strings.TrimSuffix(title, " - Sublime Text")
+	if strings.HasSuffix(title, ")") {
+		if i := strings.LastIndex(title, " ("); i >= 0 {
+			project = title[i+2 : len(title)-1]
+			title = title[:i]
+		}
+	}
+	return title, project
+}
+
+/*
+	"path\file.go - Notepad++", the project being the directory.
+*/
+func notepadPlusPlusTitle(title string) (file, project string) {
+	if !strings.HasSuffix(title, " - Notepad++") {
+		return "", ""
+	}
+	file = strings.TrimSuffix(title, " - Notepad++")
+	return file, dirProject(file)
+}
+
+/*
+	"solution - Microsoft Visual Studio", which names no file: the
+	solution is entity and project.
+*/
+func visualStudioTitle(title string) (file, project string) {
+	i := strings.Index(title, " - Microsoft Visual Studio")
+	if i <= 0 {
+		return "", ""
+	}
+	return title[:i], title[:i]
+}
+
+/*
+	"file.go (path) - GVIM", with a + after the file when it is modified.
+*/
+func vimTitle(title string) (file, project string) {
+	if i := strings.LastIndex(title, " - GVIM"); i >= 0 {
+		title = title[:i]
+	}
+	file = title
+	if i := strings.LastIndex(title, " ("); i >= 0 && strings.HasSuffix(title, ")") {
+		file = strings.TrimSuffix(title[:i], " +")
+		dir := title[i+2 : len(title)-1]
+		file = dir + `\` + file
+		return file, dirProject(file)
+	}
+	return file, ""
+}
+
+/*
+	Names a project after the directory of a file path, or nothing for a
+	bare file name.
+*/
+func dirProject(file string) string {
+	dir := path.Dir(strings.ReplaceAll(file, `\`, "/"))
+	if dir == "." || dir == "/" {
+		return ""
+	}
+	return path.Base(dir)
+}
@@ -0,0 +1,94 @@
+package keylogger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+/*
+	Elevated reports whether this process runs elevated, as administrator.
+	Windows does not deliver input meant for elevated windows to the
+	low-level hooks of processes that are not.
+*/
+func Elevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
+/*
+	ProcessElevated reports whether the process pid runs elevated. From a
+	process that is not elevated, the token of an elevated one cannot be
+	opened, which counts as elevated.
+*/
+func ProcessElevated(pid uint32) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(h)
+	var token windows.Token
+	if err := windows.OpenProcessToken(h, windows.TOKEN_QUERY, &token); err != nil {
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer token.Close()
+	return token.IsElevated()
+}
+
+/*
+	RelaunchElevated starts this executable again with args, elevated
+	after the UAC prompt agrees, in a console of its own. The caller exits
+	once it returns nil.
+*/
+func RelaunchElevated(args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = windows.EscapeArg(a)
+	}
+	verb, _ := windows.UTF16PtrFromString("runas")
+	file, err := windows.UTF16PtrFromString(exe)
+	if err != nil {
+		return err
+	}
+	params, err := windows.UTF16PtrFromString(strings.Join(quoted, " "))
+	if err != nil {
+		return err
+	}
+	cwd, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return err
+	}
+	if err := windows.ShellExecute(0, verb, file, params, cwd, windows.SW_SHOWNORMAL); err != nil {
+		return fmt.Errorf("relaunch as administrator: %v", err)
+	}
+	return nil
+}
+
+/*
+	Called by the worker with each FocusEvent: unless the Logger runs
+	elevated, typing into an elevated window is a gap in the capture,
+	marked like input on another desktop.
+*/
+func (l *Logger) elevationFocus(e FocusEvent) (DiagnosticEvent, bool) {
+	if l.elevated || e.Elevated == l.elevatedFocus {
+		return DiagnosticEvent{}, false
+	}
+	l.elevatedFocus = e.Elevated
+	if e.Elevated {
+		name := e.Process
+		if name == "" {
+			name = "an elevated application"
+		}
+		return DiagnosticEvent{Kind: DiagCaptureUnavailable, Message: fmt.Sprintf("%s runs as administrator; its input does not reach the hooks of this unelevated process", name), Time: e.Time}, true
+	}
+	return DiagnosticEvent{Kind: DiagCaptureAvailable, Message: "the foreground window is no longer elevated", Time: e.Time}, true
+}
@@ -0,0 +1,296 @@
+package keylogger
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+	ExportManifest describes an archive written by ExportEvents.
+*/
+type ExportManifest struct {
+	Logs     []string  `json:"logs"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Events   int       `json:"events"`
+	SHA256   string    `json:"sha256"`
+	Exported time.Time `json:"exported"`
+}
+
+/*
+	ExportEvents writes a zip archive of the events in the logs, written
+	with codec, that happened from from until to, or until now if to is
+	zero. The archive holds events.jsonl, one JSONCodec record per event,
+	and manifest.json. Its SHA256 is that of events.jsonl, which is what an
+	ErasureReceipt for the same logs and window gives.
+*/
+func ExportEvents(w io.Writer, logs []string, codec Codec, from, to time.Time) (*ExportManifest, error) {
+	m := &ExportManifest{Logs: logs, From: from, To: to, Exported: time.Now()}
+	z := zip.NewWriter(w)
+	events, err := z.Create("events.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	out := io.MultiWriter(events, h)
+	var buf []byte
+	var werr error
+	for _, name := range logs {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		err = scanLog(data, codec, func(e Event, record []byte) {
+			if e == nil || !inWindow(e.Timestamp(), from, to) {
+				return
+			}
+			buf, _ = JSONCodec{}.AppendEvent(buf[:0], e)
+			if _, err := out.Write(buf); err != nil && werr == nil {
+				werr = err
+			}
+			m.Events++
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	if werr != nil {
+		return nil, werr
+	}
+	m.SHA256 = hex.EncodeToString(h.Sum(nil))
+	manifest, err := z.Create("manifest.json")
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(manifest)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return m, z.Close()
+}
+
+/*
+	ErasureReceipt confirms that the events in a window were erased from
+	logs. SHA256 identifies the erased events without containing them: it
+	is the hash of the events.jsonl ExportEvents would have written for
+	them. Events and SHA256 cover the logs; Stores names the other files of
+	ErasureStores the window was erased from. The receipt is signed with an
+	Ed25519 key whose public half is Key; Verify checks the signature, and
+	whoever relies on the receipt checks that Key is the one they expect.
+*/
+type ErasureReceipt struct {
+	Logs      []string  `json:"logs"`
+	Stores    []string  `json:"stores,omitempty"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Events    int       `json:"events"`
+	SHA256    string    `json:"sha256"`
+	Erased    time.Time `json:"erased"`
+	Key       string    `json:"key"`
+	Signature string    `json:"signature,omitempty"`
+}
+
+/*
+	ErasureStores names the files other than the logs that hold copies of
+	the events of the captures that wrote them: the WAL, the
+	RetryPolicy.DeadLetter file and the Logger.Trace files. SpillDir is
+	where those captures spilled events under the spill Backpressure
+	policy, the default directory for temporary files if empty. A capture
+	removes its spill file when it stops, so any left there belong to one
+	that is running or crashed, and their events cannot be erased; the
+	HookDump text has no dates, so it cannot be erased by window either and
+	should be removed.
+*/
+type ErasureStores struct {
+	WAL        string
+	DeadLetter string
+	Traces     []string
+	SpillDir   string
+}
+
+/*
+	EraseEvents removes the events in the logs, written with codec, that
+	happened from from until to, or until now if to is zero, and overwrites
+	the old contents of each changed log with zeros. It then does the same
+	for the stores. Like PurgeLog, it must not be used on files a capture
+	is writing to. It refuses to erase anything while spill files are left
+	in stores.SpillDir. If a file fails, the receipt covers the files
+	erased before it.
+*/
+func EraseEvents(logs []string, codec Codec, from, to time.Time, stores ErasureStores, key ed25519.PrivateKey) (*ErasureReceipt, error) {
+	if err := stores.checkSpills(); err != nil {
+		return nil, err
+	}
+	r := &ErasureReceipt{From: from, To: to}
+	h := sha256.New()
+	var erased []byte
+	var err error
+	for _, name := range logs {
+		var n int
+		n, err = rewriteLog(name, codec, true, func(e Event) bool {
+			if !inWindow(e.Timestamp(), from, to) {
+				return false
+			}
+			erased, _ = JSONCodec{}.AppendEvent(erased, e)
+			return true
+		})
+		// Only the events of logs that were rewritten count.
+		if err == nil {
+			h.Write(erased)
+		}
+		wipeBytes(erased)
+		erased = erased[:0]
+		if err != nil {
+			break
+		}
+		r.Logs = append(r.Logs, name)
+		r.Events += n
+	}
+	if err == nil {
+		err = stores.erase(r, from, to)
+	}
+	r.SHA256 = hex.EncodeToString(h.Sum(nil))
+	r.Erased = time.Now()
+	if serr := r.sign(key); err == nil {
+		err = serr
+	}
+	return r, err
+}
+
+func (r *ErasureReceipt) sign(key ed25519.PrivateKey) error {
+	r.Key = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	r.Signature = ""
+	msg, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	r.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, msg))
+	return nil
+}
+
+/*
+	Verify checks that the receipt was signed by Key and not changed since.
+*/
+func (r *ErasureReceipt) Verify() error {
+	pub, err := base64.StdEncoding.DecodeString(r.Key)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return errors.New("erasure receipt: bad key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil {
+		return errors.New("erasure receipt: bad signature")
+	}
+	unsigned := *r
+	unsigned.Signature = ""
+	msg, err := json.Marshal(&unsigned)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pub), msg, sig) {
+		return errors.New("erasure receipt: signature does not match")
+	}
+	return nil
+}
+
+/*
+	LoadReceiptKey reads the Ed25519 key that signs erasure receipts from
+	the file name, which may be a protected Secret, creating it like
+	LoadPseudonymKey if it does not exist.
+*/
+func LoadReceiptKey(name string) (ed25519.PrivateKey, error) {
+	seed, err := loadKey(name, ed25519.SeedSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", name)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func (s ErasureStores) checkSpills() error {
+	dir := s.SpillDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	spills, err := filepath.Glob(filepath.Join(dir, "keylogger-spill-*"))
+	if err != nil {
+		return err
+	}
+	if len(spills) > 0 {
+		return fmt.Errorf("erase: %d spill files of a running or crashed capture in %s, such as %s", len(spills), dir, spills[0])
+	}
+	return nil
+}
+
+/*
+	Erases the window from the stores, adding those done to r.Stores.
+*/
+func (s ErasureStores) erase(r *ErasureReceipt, from, to time.Time) error {
+	drop := func(e Event) bool { return inWindow(e.Timestamp(), from, to) }
+	if s.WAL != "" {
+		if err := eraseWAL(s.WAL, drop); err != nil {
+			return fmt.Errorf("%s: %v", s.WAL, err)
+		}
+		r.Stores = append(r.Stores, s.WAL)
+	}
+	// Dead letters and traces are JSON lines; a trace's header is not an
+	// event and is kept.
+	files := s.Traces
+	if s.DeadLetter != "" {
+		files = append([]string{s.DeadLetter}, files...)
+	}
+	for _, name := range files {
+		if _, err := rewriteLog(name, JSONCodec{}, true, drop); err != nil {
+			return err
+		}
+		r.Stores = append(r.Stores, name)
+	}
+	return nil
+}
+
+/*
+	Removes the events drop returns true for from a WAL, which must not be
+	open.
+*/
+func eraseWAL(name string, drop func(Event) bool) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	events, err := readWAL(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	var kept []byte
+	removed := 0
+	for _, e := range events {
+		if drop(e) {
+			removed++
+			continue
+		}
+		if kept, err = appendWALRecord(kept, e); err != nil {
+			return err
+		}
+	}
+	if removed == 0 {
+		return nil
+	}
+	return replaceFile(name, kept, true)
+}
+
+func inWindow(t, from, to time.Time) bool {
+	return !t.Before(from) && (to.IsZero() || t.Before(to))
+}
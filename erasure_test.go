@@ -0,0 +1,204 @@
+package keylogger
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var erasureEnd = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+/*
+	Two logs, with keys ABCDE and FGHIJ a minute apart, each ending at
+	erasureEnd.
+*/
+func erasureLogs(t *testing.T, dir string) []string {
+	t.Helper()
+	logs := []string{filepath.Join(dir, "a.jsonl"), filepath.Join(dir, "b.jsonl")}
+	writeLog(t, logs[0], JSONCodec{}, agedKeys("ABCDE", erasureEnd))
+	writeLog(t, logs[1], JSONCodec{}, agedKeys("FGHIJ", erasureEnd))
+	return logs
+}
+
+func receiptKey() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+}
+
+/*
+	Exports the window of B..D and H..J, the three minutes before and at
+	erasureEnd but the last, and returns the manifest and events.jsonl.
+*/
+func exportWindow(t *testing.T, logs []string) (*ExportManifest, []byte) {
+	t.Helper()
+	var archive bytes.Buffer
+	m, err := ExportEvents(&archive, logs, JSONCodec{}, erasureEnd.Add(-3*time.Minute), erasureEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := z.Open("events.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	events, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := z.Open("manifest.json"); err != nil {
+		t.Error("the archive has no manifest")
+	}
+	return m, events
+}
+
+func TestExportEvents(t *testing.T) {
+	logs := erasureLogs(t, t.TempDir())
+	m, events := exportWindow(t, logs)
+	sum := sha256.Sum256(events)
+	if m.Events != 6 || m.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("manifest has %d events, sha256 %s; want 6 and that of events.jsonl", m.Events, m.SHA256)
+	}
+	if n := bytes.Count(events, []byte("\n")); n != 6 {
+		t.Errorf("events.jsonl has %d lines, want 6", n)
+	}
+	if got := logKeys(t, logs[0], JSONCodec{}); got != "ABCDE" {
+		t.Errorf("export changed the log to %q", got)
+	}
+}
+
+func TestEraseEvents(t *testing.T) {
+	dir := t.TempDir()
+	logs := erasureLogs(t, dir)
+	m, _ := exportWindow(t, logs)
+	r, err := EraseEvents(logs, JSONCodec{}, m.From, m.To, ErasureStores{SpillDir: dir}, receiptKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Events != 6 || r.SHA256 != m.SHA256 || len(r.Logs) != 2 {
+		t.Errorf("receipt has %d events of %v, sha256 %s; want 6 of both logs and the export's %s", r.Events, r.Logs, r.SHA256, m.SHA256)
+	}
+	for i, want := range []string{"AE", "FJ"} {
+		if got := logKeys(t, logs[i], JSONCodec{}); got != want {
+			t.Errorf("%s holds %q, want %q", logs[i], got, want)
+		}
+	}
+	if err := r.Verify(); err != nil {
+		t.Errorf("Verify = %v", err)
+	}
+	tampered := *r
+	tampered.Events--
+	if tampered.Verify() == nil {
+		t.Error("Verify accepted a receipt with a changed event count")
+	}
+	tampered = *r
+	other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+	tampered.Key = base64.StdEncoding.EncodeToString(other)
+	if tampered.Verify() == nil {
+		t.Error("Verify accepted a receipt with another key")
+	}
+}
+
+/*
+	A log that cannot be rewritten keeps its events, and only those of the
+	logs before it are on the receipt.
+*/
+func TestEraseEventsPartialFailure(t *testing.T) {
+	dir := t.TempDir()
+	logs := erasureLogs(t, dir)
+	// No room for the temporary file next to the second log.
+	long := filepath.Join(dir, strings.Repeat("b", 250))
+	if err := os.Rename(logs[1], long); err != nil {
+		t.Fatal(err)
+	}
+	logs[1] = long
+	first, _ := exportWindow(t, logs[:1])
+	r, err := EraseEvents(logs, JSONCodec{}, first.From, first.To, ErasureStores{SpillDir: dir}, receiptKey())
+	if err == nil {
+		t.Fatal("EraseEvents did not fail")
+	}
+	if r.Events != 3 || r.SHA256 != first.SHA256 || len(r.Logs) != 1 || r.Logs[0] != logs[0] {
+		t.Errorf("receipt has %d events of %v, sha256 %s; want 3 of the first log only, sha256 %s", r.Events, r.Logs, r.SHA256, first.SHA256)
+	}
+	if got := logKeys(t, logs[1], JSONCodec{}); got != "FGHIJ" {
+		t.Errorf("the failed log holds %q, want FGHIJ", got)
+	}
+	if err := r.Verify(); err != nil {
+		t.Errorf("Verify = %v", err)
+	}
+}
+
+func TestEraseEventsStores(t *testing.T) {
+	dir := t.TempDir()
+	logs := erasureLogs(t, dir)[:1]
+	stores := ErasureStores{
+		WAL:        filepath.Join(dir, "events.wal"),
+		DeadLetter: filepath.Join(dir, "dead.jsonl"),
+		Traces:     []string{filepath.Join(dir, "trace.jsonl")},
+		SpillDir:   dir,
+	}
+	w, err := OpenWAL(stores.WAL, &memorySink{err: errors.New("offline")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(agedKeys("ABCDE", erasureEnd))
+	w.Close()
+	writeLog(t, stores.DeadLetter, JSONCodec{}, agedKeys("ABCDE", erasureEnd))
+	header := []byte(`{"trace":1,"double_click_ms":500,"double_click_width":4,"double_click_height":4}` + "\n")
+	if err := os.WriteFile(stores.Traces[0], header, 0600); err != nil {
+		t.Fatal(err)
+	}
+	writeLog(t, stores.Traces[0], JSONCodec{}, agedKeys("ABCDE", erasureEnd))
+
+	spill, err := os.CreateTemp(dir, "keylogger-spill-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spill.Close()
+	from := erasureEnd.Add(-3 * time.Minute)
+	if _, err := EraseEvents(logs, JSONCodec{}, from, erasureEnd, stores, receiptKey()); err == nil {
+		t.Fatal("EraseEvents erased with a spill file left")
+	}
+	if got := logKeys(t, logs[0], JSONCodec{}); got != "ABCDE" {
+		t.Errorf("refusing changed the log to %q", got)
+	}
+	os.Remove(spill.Name())
+
+	r, err := EraseEvents(logs, JSONCodec{}, from, erasureEnd, stores, receiptKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Stores) != 3 {
+		t.Errorf("receipt stores = %v, want the WAL, dead letters and trace", r.Stores)
+	}
+	f, err := os.Open(stores.WAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := readWAL(f)
+	f.Close()
+	if err != nil || len(pending) != 2 {
+		t.Errorf("WAL holds %d events (%v), want A and E", len(pending), err)
+	}
+	for _, name := range []string{stores.DeadLetter, stores.Traces[0]} {
+		if got := logKeys(t, name, JSONCodec{}); got != "AE" {
+			t.Errorf("%s holds %q, want AE", name, got)
+		}
+	}
+	trace, err := os.ReadFile(stores.Traces[0])
+	if err != nil || !bytes.HasPrefix(trace, header) {
+		t.Error("the trace lost its header")
+	}
+}
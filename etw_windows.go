@@ -0,0 +1,356 @@
+package keylogger
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32            = windows.NewLazySystemDLL("advapi32.dll")
+	eventRegister       = advapi32.NewProc("EventRegister")
+	eventUnregister     = advapi32.NewProc("EventUnregister")
+	eventSetInformation = advapi32.NewProc("EventSetInformation")
+	eventEnabled        = advapi32.NewProc("EventEnabled")
+	eventWriteTransfer  = advapi32.NewProc("EventWriteTransfer")
+)
+
+/*
+	ETWProviderName is the name of the TraceLogging provider ETWProvider
+	registers. Its GUID is derived from the name as EventSource and
+	TraceLogging do, so tools that know the scheme find it by name, as
+	PerfView /Providers=*Keylogger does.
+*/
+const ETWProviderName = "Keylogger"
+
+/*
+	Keywords of the events of ETWProvider, to enable them one by one.
+*/
+const (
+	ETWKeywordKey   = 0x1
+	ETWKeywordMouse = 0x2
+	ETWKeywordFocus = 0x4
+)
+
+/*
+	Describes an event to ETW.
+	https://docs.microsoft.com/en-us/windows/win32/api/evntprov/ns-evntprov-event_descriptor
+*/
+type EVENT_DESCRIPTOR struct {
+	Id      uint16
+	Version uint8
+	Channel uint8
+	Level   uint8
+	Opcode  uint8
+	Task    uint16
+	Keyword uint64
+}
+
+/*
+	Points to a block of event data, or of TraceLogging metadata.
+	https://docs.microsoft.com/en-us/windows/win32/api/evntprov/ns-evntprov-event_data_descriptor
+*/
+type EVENT_DATA_DESCRIPTOR struct {
+	Ptr  uint64
+	Size uint32
+	Type uint32
+}
+
+/*
+	Values of EVENT_DATA_DESCRIPTOR.Type, the TraceLogging channel and
+	the EventProviderSetTraits information class.
+*/
+const (
+	EVENT_DATA_DESCRIPTOR_TYPE_EVENT_METADATA    = 1
+	EVENT_DATA_DESCRIPTOR_TYPE_PROVIDER_METADATA = 2
+	WINEVENT_CHANNEL_TRACELOGGING                = 11
+	EventProviderSetTraits                       = 2
+)
+
+/*
+	TraceLogging field types.
+	https://github.com/microsoft/tracelogging/blob/main/etw/traceloggingprovider/include/TraceLoggingProvider.h
+*/
+const (
+	tlgInUNICODESTRING = 1
+	tlgInANSISTRING    = 2
+	tlgInUINT16        = 6
+	tlgInINT32         = 7
+	tlgInUINT32        = 8
+	tlgInUINT64        = 10
+	tlgInBOOL32        = 13
+	tlgInFILETIME      = 17
+)
+
+/*
+	The GUID scheme of EventSource names.
+*/
+var etwNamespace = [16]byte{0x48, 0x2C, 0x2D, 0xB2, 0xC3, 0x90, 0x47, 0xC8, 0x87, 0xF8, 0x1A, 0x15, 0xBF, 0xC1, 0x30, 0xFB}
+
+/*
+	ETWProvider is a Sink that writes key, mouse and focus events as
+	TraceLogging events of the ETWProviderName provider, for WPA or
+	PerfView to show next to the other events of a trace: where input
+	waited is then seen against CPU, disk and the window messages of the
+	application it went to. Every event carries Captured, when the hook
+	saw it, and DelayMicroseconds, how long it took to reach the sink, to
+	find the latency the capture itself adds.
+
+	Events are only built while a trace session has the provider on,
+	filtered by level (5, verbose, for keys and mouse, 4 for focus) and
+	by the ETWKeyword constants. Keys are written as their virtual-key
+	code and name, not as the text they typed, but a trace still tells
+	what was typed: keep trace files as safe as -trace files.
+*/
+type ETWProvider struct {
+	handle uint64
+	traits []byte
+	key    etwEvent
+	mouse  etwEvent
+	focus  etwEvent
+
+	data []byte                  // the fields of the event being written
+	ends []int                   // where each field in data ends
+	desc []EVENT_DATA_DESCRIPTOR // traits, metadata and fields
+}
+
+type etwEvent struct {
+	desc     EVENT_DESCRIPTOR
+	metadata []byte
+}
+
+type etwField struct {
+	name string
+	in   byte
+}
+
+/*
+	NewETWProvider registers the provider with ETW.
+*/
+func NewETWProvider() (*ETWProvider, error) {
+	p := &ETWProvider{
+		traits: etwTraits(ETWProviderName),
+		key: newETWEvent("Key", 5, ETWKeywordKey, []etwField{
+			{"VkCode", tlgInUINT16}, {"Key", tlgInANSISTRING}, {"ScanCode", tlgInUINT32}, {"Down", tlgInBOOL32},
+			{"Injected", tlgInBOOL32}, {"Swallowed", tlgInBOOL32}, {"Captured", tlgInFILETIME}, {"DelayMicroseconds", tlgInUINT64},
+		}),
+		mouse: newETWEvent("Mouse", 5, ETWKeywordMouse, []etwField{
+			{"Action", tlgInANSISTRING}, {"Button", tlgInANSISTRING}, {"X", tlgInINT32}, {"Y", tlgInINT32}, {"Delta", tlgInINT32},
+			{"Injected", tlgInBOOL32}, {"Captured", tlgInFILETIME}, {"DelayMicroseconds", tlgInUINT64},
+		}),
+		focus: newETWEvent("Focus", 4, ETWKeywordFocus, []etwField{
+			{"PID", tlgInUINT32}, {"Process", tlgInUNICODESTRING}, {"Title", tlgInUNICODESTRING}, {"Elevated", tlgInBOOL32},
+			{"Captured", tlgInFILETIME}, {"DelayMicroseconds", tlgInUINT64},
+		}),
+	}
+	guid := etwGUID(ETWProviderName)
+	r, _, _ := eventRegister.Call(uintptr(unsafe.Pointer(&guid)), 0, 0, uintptr(unsafe.Pointer(&p.handle)))
+	if r != 0 {
+		return nil, fmt.Errorf("etw: EventRegister: %w", windows.Errno(r))
+	}
+	// Without traits the events carry no provider name; they still decode.
+	eventSetInformation.Call(etwArgs(p.handle, EventProviderSetTraits, uintptr(unsafe.Pointer(&p.traits[0])), uintptr(len(p.traits)))...)
+	return p, nil
+}
+
+func (p *ETWProvider) Write(events []Event) error {
+	var err error
+	for _, e := range events {
+		var ev *etwEvent
+		switch e.(type) {
+		case KeyEvent:
+			ev = &p.key
+		case MouseEvent:
+			ev = &p.mouse
+		case FocusEvent:
+			ev = &p.focus
+		default:
+			continue
+		}
+		if !p.enabled(ev) {
+			continue
+		}
+		p.data, p.ends = p.data[:0], p.ends[:0]
+		switch e := e.(type) {
+		case KeyEvent:
+			p.uint16(e.VkCode)
+			p.ansi(KeyName(e.VkCode))
+			p.uint32(e.ScanCode)
+			p.bool(e.Down)
+			p.bool(e.Injected())
+			p.bool(e.Swallowed)
+		case MouseEvent:
+			p.ansi(e.Action.String())
+			p.ansi(e.Button.String())
+			p.uint32(uint32(e.X))
+			p.uint32(uint32(e.Y))
+			p.uint32(uint32(e.Delta))
+			p.bool(e.Injected())
+		case FocusEvent:
+			p.uint32(e.PID)
+			p.unicode(e.Process)
+			p.unicode(e.Title)
+			p.bool(e.Elevated)
+		}
+		captured := e.Timestamp()
+		ft := windows.NsecToFiletime(captured.UnixNano())
+		p.uint64(uint64(ft.HighDateTime)<<32 | uint64(ft.LowDateTime))
+		p.uint64(uint64(time.Since(captured) / time.Microsecond))
+		if werr := p.write(ev); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+/*
+	Close unregisters the provider.
+*/
+func (p *ETWProvider) Close() error {
+	if r, _, _ := eventUnregister.Call(etwArgs(p.handle)...); r != 0 {
+		return fmt.Errorf("etw: EventUnregister: %w", windows.Errno(r))
+	}
+	return nil
+}
+
+func (p *ETWProvider) enabled(ev *etwEvent) bool {
+	r, _, _ := eventEnabled.Call(etwArgs(p.handle, uintptr(unsafe.Pointer(&ev.desc)))...)
+	return r != 0
+}
+
+/*
+	Writes the fields in data as an event of ev. The descriptors point
+	into buffers of p, held by the heap while ETW copies them.
+*/
+func (p *ETWProvider) write(ev *etwEvent) error {
+	p.desc = append(p.desc[:0],
+		EVENT_DATA_DESCRIPTOR{Ptr: etwPtr(p.traits), Size: uint32(len(p.traits)), Type: EVENT_DATA_DESCRIPTOR_TYPE_PROVIDER_METADATA},
+		EVENT_DATA_DESCRIPTOR{Ptr: etwPtr(ev.metadata), Size: uint32(len(ev.metadata)), Type: EVENT_DATA_DESCRIPTOR_TYPE_EVENT_METADATA},
+	)
+	start := 0
+	for _, end := range p.ends {
+		p.desc = append(p.desc, EVENT_DATA_DESCRIPTOR{Ptr: etwPtr(p.data[start:end]), Size: uint32(end - start)})
+		start = end
+	}
+	r, _, _ := eventWriteTransfer.Call(etwArgs(p.handle,
+		uintptr(unsafe.Pointer(&ev.desc)), 0, 0,
+		uintptr(len(p.desc)), uintptr(unsafe.Pointer(&p.desc[0])))...)
+	if r != 0 {
+		return fmt.Errorf("etw: EventWriteTransfer: %w", windows.Errno(r))
+	}
+	return nil
+}
+
+func (p *ETWProvider) field(b ...byte) {
+	p.data = append(p.data, b...)
+	p.ends = append(p.ends, len(p.data))
+}
+
+func (p *ETWProvider) bool(v bool) {
+	if v {
+		p.uint32(1)
+	} else {
+		p.uint32(0)
+	}
+}
+
+func (p *ETWProvider) uint16(v uint16) {
+	p.field(byte(v), byte(v>>8))
+}
+
+func (p *ETWProvider) uint32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	p.field(b[:]...)
+}
+
+func (p *ETWProvider) uint64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	p.field(b[:]...)
+}
+
+func (p *ETWProvider) ansi(s string) {
+	p.field(append([]byte(strings.ReplaceAll(s, "\x00", "")), 0)...)
+}
+
+func (p *ETWProvider) unicode(s string) {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		if c != 0 {
+			b = append(b, byte(c), byte(c>>8))
+		}
+	}
+	p.field(append(b, 0, 0)...)
+}
+
+/*
+	Returns the TraceLogging metadata of an event: its size, no
+	extensions, its name and the names and types of its fields.
+*/
+func newETWEvent(name string, level uint8, keyword uint64, fields []etwField) etwEvent {
+	m := []byte{0, 0, 0}
+	m = append(append(m, name...), 0)
+	for _, f := range fields {
+		m = append(append(append(m, f.name...), 0), f.in)
+	}
+	binary.LittleEndian.PutUint16(m, uint16(len(m)))
+	return etwEvent{
+		desc:     EVENT_DESCRIPTOR{Channel: WINEVENT_CHANNEL_TRACELOGGING, Level: level, Keyword: keyword},
+		metadata: m,
+	}
+}
+
+/*
+	Returns the TraceLogging provider traits naming the provider.
+*/
+func etwTraits(name string) []byte {
+	t := append([]byte{0, 0}, name...)
+	t = append(t, 0)
+	binary.LittleEndian.PutUint16(t, uint16(len(t)))
+	return t
+}
+
+/*
+	Derives the GUID of a provider from its name, as EventSource does: the
+	SHA-1 of a namespace and the name in upper case UTF-16 big endian,
+	marked as a version 5 GUID.
+*/
+func etwGUID(name string) windows.GUID {
+	h := sha1.New()
+	h.Write(etwNamespace[:])
+	for _, c := range utf16.Encode([]rune(strings.ToUpper(name))) {
+		h.Write([]byte{byte(c >> 8), byte(c)})
+	}
+	sum := h.Sum(nil)
+	sum[7] = sum[7]&0x0F | 0x50
+	g := windows.GUID{
+		Data1: binary.LittleEndian.Uint32(sum[0:]),
+		Data2: binary.LittleEndian.Uint16(sum[4:]),
+		Data3: binary.LittleEndian.Uint16(sum[6:]),
+	}
+	copy(g.Data4[:], sum[8:16])
+	return g
+}
+
+func etwPtr(b []byte) uint64 {
+	return uint64(uintptr(unsafe.Pointer(&b[0])))
+}
+
+/*
+	Returns the arguments of a call taking a REGHANDLE first, which is 64
+	bits wide on 386 too.
+*/
+func etwArgs(handle uint64, rest ...uintptr) []uintptr {
+	args := []uintptr{uintptr(handle)}
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		args = append(args, uintptr(handle>>32))
+	}
+	return append(args, rest...)
+}
@@ -0,0 +1,149 @@
+package keylogger
+
+import "time"
+
+/*
+	Event is anything delivered on the Logger's event stream: KeyEvent,
+	MouseEvent, FocusEvent, ProcessEvent, GamepadEvent, TextEvent or
+	DiagnosticEvent.
+	Consumers tell them apart with a type switch. Input events arrive in the order they
+	happened, since every hook runs on the Logger's single hook thread.
+*/
+type Event interface {
+	Timestamp() time.Time
+}
+
+/*
+	Flags reported in KBDLLHOOKSTRUCT.Flags.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-kbdllhookstruct
+*/
+const (
+	LLKHF_EXTENDED          = 0x01
+	LLKHF_LOWER_IL_INJECTED = 0x02
+	LLKHF_INJECTED          = 0x10
+	LLKHF_ALTDOWN           = 0x20
+	LLKHF_UP                = 0x80
+)
+
+/*
+	InjectedSignature is stored in dwExtraInfo of every event sent by an
+	Injector, so the hook can tell our own input apart from other injected input.
+*/
+const InjectedSignature = 0x4B4C4F47 // "KLOG"
+
+/*
+	KeyEvent is a single key transition observed by the keyboard hook.
+	Text holds the characters a key-down types in the active keyboard layout,
+	and is empty for keys that type nothing. Swallowed events were consumed
+	by a KeyFilter and never reached applications. OnScreen marks injected
+	keys typed on an on-screen keyboard; see Source.
+*/
+type KeyEvent struct {
+	VkCode    uint16    `json:"vk"`
+	ScanCode  uint32    `json:"scan_code"`
+	Flags     uint32    `json:"flags,omitempty"`
+	ExtraInfo uintptr   `json:"extra_info,omitempty"`
+	Down      bool      `json:"down"`
+	Swallowed bool      `json:"swallowed,omitempty"`
+	OnScreen  bool      `json:"on_screen,omitempty"`
+	Text      string    `json:"text,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+/*
+	Timestamp returns when the hook saw the event.
+*/
+func (e KeyEvent) Timestamp() time.Time {
+	return e.Time
+}
+
+/*
+	Injected reports whether the event was synthesized (SendInput, keybd_event)
+	rather than produced by a keyboard.
+*/
+func (e KeyEvent) Injected() bool {
+	return e.Flags&LLKHF_INJECTED != 0
+}
+
+/*
+	FromInjector reports whether the event was sent by an Injector of this package.
+*/
+func (e KeyEvent) FromInjector() bool {
+	return e.Injected() && e.ExtraInfo == InjectedSignature
+}
+
+/*
+	KeySource is what a key event came from.
+*/
+type KeySource int
+
+const (
+	KeySourceKeyboard KeySource = iota
+	KeySourceOnScreen
+	KeySourceInjected
+)
+
+func (s KeySource) String() string {
+	switch s {
+	case KeySourceOnScreen:
+		return "on-screen"
+	case KeySourceInjected:
+		return "injected"
+	}
+	return "keyboard"
+}
+
+/*
+	Source reports whether a key was typed on a keyboard, on an on-screen
+	keyboard or injected by another program. On-screen keyboards inject
+	their keys like any program, so Windows does not tell them apart: the
+	Logger marks injected keys OnScreen while one of OnScreenKeyboards
+	shows a window. Input counted as the user's, such as text and activity,
+	includes on-screen keys.
+*/
+func (e KeyEvent) Source() KeySource {
+	switch {
+	case e.OnScreen:
+		return KeySourceOnScreen
+	case e.Injected():
+		return KeySourceInjected
+	}
+	return KeySourceKeyboard
+}
+
+/*
+	DefaultOnScreenKeyboards are the executables of the on-screen keyboards
+	of Windows: the accessibility keyboard and the touch keyboard of
+	Windows 10 and of Windows 11.
+*/
+var DefaultOnScreenKeyboards = []string{"osk.exe", "TabTip.exe", "TextInputHost.exe"}
+
+/*
+	KeyFilter is called on the hook thread for every key event before it
+	reaches applications; returning true swallows the event. Filters must
+	return quickly: Windows silently removes low-level hooks that exceed
+	the LowLevelHooksTimeout.
+*/
+type KeyFilter func(e KeyEvent) bool
+
+/*
+	FocusEvent reports that another window became the foreground window.
+	Process is the executable base name and is empty if the process cannot
+	be queried, e.g. because it runs elevated. Elevated is set if the
+	window's process runs as administrator.
+*/
+type FocusEvent struct {
+	HWND     uintptr   `json:"hwnd"`
+	PID      uint32    `json:"pid"`
+	Process  string    `json:"process"`
+	Title    string    `json:"title"`
+	Elevated bool      `json:"elevated,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+/*
+	Timestamp returns when the foreground window changed.
+*/
+func (e FocusEvent) Timestamp() time.Time {
+	return e.Time
+}
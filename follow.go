@@ -0,0 +1,147 @@
+package keylogger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+/*
+	How often FollowLog looks for new records.
+*/
+const followPollInterval = 250 * time.Millisecond
+
+/*
+	How much of a binary log FollowLog reads at once; records are smaller.
+*/
+const followChunk = 64 << 10
+
+/*
+	FollowLog calls fn with each event of the log file name, written with
+	codec, and then with the events written to it later, like tail -f,
+	until ctx is done, when it returns nil. A capture writing the log may
+	be running: only complete records are read, and when the file is
+	replaced or shortened, as retention and erasure do, it is read again
+	from the start, leaving out the events not newer than the last one
+	seen.
+*/
+func FollowLog(ctx context.Context, name string, codec Codec, fn func(Event)) error {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	if _, ok := codec.(JSONCodec); !ok && recordDecoder(codec) == nil {
+		return fmt.Errorf("cannot read %T records", codec)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	var last time.Time
+	off, err := readRecords(name, 0, codec, func(e Event) {
+		last = e.Timestamp()
+		fn(e)
+	})
+	if err != nil {
+		return err
+	}
+	tick := time.NewTicker(followPollInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+		}
+		now, err := os.Stat(name)
+		if os.IsNotExist(err) {
+			// Being replaced; the new file shows up on a later tick.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !os.SameFile(info, now) || now.Size() < off {
+			info, off = now, 0
+		}
+		seen := last
+		off, err = readRecords(name, off, codec, func(e Event) {
+			if !e.Timestamp().After(seen) {
+				return
+			}
+			last = e.Timestamp()
+			fn(e)
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+/*
+	Calls fn with the complete records of the file name from off on and
+	returns the offset after them. The file is not kept open, which on
+	Windows would keep retention from replacing it.
+*/
+func readRecords(name string, off int64, codec Codec, fn func(Event)) (int64, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return off, nil
+	}
+	if err != nil {
+		return off, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return off, err
+	}
+	if info.Size() <= off {
+		return off, nil
+	}
+	if _, ok := codec.(JSONCodec); ok {
+		data := make([]byte, info.Size()-off)
+		n, err := f.ReadAt(data, off)
+		if err != nil && err != io.EOF {
+			return off, err
+		}
+		end := bytes.LastIndexByte(data[:n], '\n') + 1
+		for _, line := range bytes.Split(data[:end], []byte("\n")) {
+			if e, err := DecodeJSONEvent(line); err == nil && e != nil {
+				fn(e)
+			}
+		}
+		return off + int64(end), nil
+	}
+	// The file of an MmapSink is mostly zeros: it is read in chunks up to
+	// the first record not written yet.
+	decode := recordDecoder(codec)
+	buf := make([]byte, followChunk)
+	for off < info.Size() {
+		n, err := f.ReadAt(buf, off)
+		if err != nil && err != io.EOF {
+			return off, err
+		}
+		read := 0
+		for read < n {
+			e, size, err := decode(buf[read:n])
+			if errors.Is(err, ErrNewerSchema) {
+				read += size
+				continue
+			}
+			if err != nil {
+				break
+			}
+			fn(e)
+			read += size
+		}
+		if read == 0 {
+			break
+		}
+		off += int64(read)
+	}
+	return off, nil
+}
@@ -0,0 +1,210 @@
+//go:build go1.18
+// +build go1.18
+
+package keylogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+/*
+	A macro file either fails to load or loads into a macro that saves and
+	loads again with the same steps.
+*/
+func FuzzLoadMacro(f *testing.F) {
+	var seed bytes.Buffer
+	SaveMacro(&seed, &Macro{Name: "greeting", Steps: []MacroStep{
+		{VkCode: VK_LSHIFT, Down: true},
+		{VkCode: 'H', Down: true, Delay: 30 * time.Millisecond},
+		{VkCode: 'H', Delay: 12500 * time.Microsecond},
+		{VkCode: VK_LSHIFT},
+	}})
+	f.Add(seed.Bytes())
+	f.Add([]byte(`{"version":1,"steps":[{"vk":65,"down":true,"delay_ms":-1}]}`))
+	f.Add([]byte(`{"version":2,"steps":[]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := LoadMacro(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		var buf bytes.Buffer
+		if err := SaveMacro(&buf, m); err != nil {
+			t.Fatalf("loaded macro does not save: %v", err)
+		}
+		again, err := LoadMacro(&buf)
+		if err != nil {
+			t.Fatalf("saved macro does not load: %v\n%s", err, buf.Bytes())
+		}
+		if len(again.Steps) != len(m.Steps) {
+			t.Fatalf("%d steps after saving, want %d", len(again.Steps), len(m.Steps))
+		}
+	})
+}
+
+/*
+	A valid configuration stays valid when written back as JSON.
+*/
+func FuzzParseConfig(f *testing.F) {
+	f.Add([]byte(`{"hotstrings":[{"abbrev":"btw","text":"by the way"}],"remap":{"CapsLock":"Escape"},"block":["Win+L"]}`))
+	f.Add([]byte(`{"mouse":{"max_moves_per_second":-3},"redact":["credit-card","(a+)+"]}`))
+	f.Add([]byte(`{"remap":{"Nope":"A"}}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c, err := parseConfig(data)
+		if err != nil {
+			return
+		}
+		out, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseConfig(out); err != nil {
+			t.Fatalf("written back, %s is invalid: %v", out, err)
+		}
+	})
+}
+
+/*
+	A JSON record either fails to decode or decodes to an event that
+	encodes to a record decoding to the same event.
+*/
+func FuzzDecodeJSONEvent(f *testing.F) {
+	for _, e := range binaryEvents {
+		record, _ := JSONCodec{}.AppendEvent(nil, e)
+		f.Add(record)
+	}
+	f.Add([]byte(`{"type":"mouse","action":"bogus"}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		e, err := DecodeJSONEvent(data)
+		if err != nil {
+			return
+		}
+		checkRoundTrip(t, JSONCodec{}, e, func(record []byte) (Event, error) {
+			return DecodeJSONEvent(bytes.TrimSuffix(record, []byte("\n")))
+		})
+	})
+}
+
+/*
+	Decoding a binary record never reads past the data, and what it decodes
+	encodes and decodes again to the same event.
+*/
+func FuzzDecodeBinaryEvent(f *testing.F) {
+	var all []byte
+	for _, e := range binaryEvents {
+		record, _ := BinaryCodec{}.AppendEvent(nil, e)
+		f.Add(record)
+		all = append(all, record...)
+	}
+	f.Add(all)
+	f.Add([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0x0F})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		e, n, err := DecodeBinaryEvent(data)
+		if err != nil {
+			return
+		}
+		if n <= 0 || n > len(data) {
+			t.Fatalf("record of %d bytes in %d bytes of data", n, len(data))
+		}
+		checkRoundTrip(t, BinaryCodec{}, e, func(record []byte) (Event, error) {
+			e, _, err := DecodeBinaryEvent(record)
+			return e, err
+		})
+	})
+}
+
+/*
+	Decoding a MessagePack record never reads past the data, and what it
+	decodes encodes and decodes again to the same event.
+*/
+func FuzzDecodeMsgpackEvent(f *testing.F) {
+	for _, e := range binaryEvents {
+		record, _ := MsgpackCodec{}.AppendEvent(nil, e)
+		f.Add(record)
+	}
+	f.Add([]byte{0xDF, 0xFF, 0xFF, 0xFF, 0xFF})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		e, n, err := DecodeMsgpackEvent(data)
+		if err != nil {
+			return
+		}
+		if n <= 0 || n > len(data) {
+			t.Fatalf("record of %d bytes in %d bytes of data", n, len(data))
+		}
+		checkRoundTrip(t, MsgpackCodec{}, e, func(record []byte) (Event, error) {
+			e, _, err := DecodeMsgpackEvent(record)
+			return e, err
+		})
+	})
+}
+
+/*
+	A damaged write-ahead log is read up to the damage; it never fails or
+	yields an event that cannot be written to the next sink.
+*/
+func FuzzReadWAL(f *testing.F) {
+	var log []byte
+	for _, e := range binaryEvents {
+		log, _ = appendWALRecord(log, e)
+	}
+	f.Add(log)
+	f.Add(log[:len(log)-3])
+	f.Add([]byte{0xFF, 0xFF, 0xFF, 0x7F, 0, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		events, err := readWAL(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range events {
+			if _, err := appendWALRecord(nil, e); err != nil {
+				t.Fatalf("recovered %#v does not encode: %v", e, err)
+			}
+		}
+	})
+}
+
+/*
+	A trace either fails to open or yields events until it ends or fails.
+*/
+func FuzzTraceReader(f *testing.F) {
+	var trace bytes.Buffer
+	w := newTraceWriter(&trace, true, doubleClicks{})
+	for _, e := range binaryEvents {
+		w.write(e)
+	}
+	w.flush()
+	f.Add(trace.Bytes())
+	f.Add([]byte(`{"trace":2}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := newTraceReader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for {
+			if _, err := r.next(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+/*
+	Encodes e with codec and checks that decode turns the record back into
+	an event with the same encoding.
+*/
+func checkRoundTrip(t *testing.T, codec Codec, e Event, decode func([]byte) (Event, error)) {
+	t.Helper()
+	record, err := codec.AppendEvent(nil, e)
+	if err != nil {
+		t.Fatalf("decoded %#v does not encode: %v", e, err)
+	}
+	again, err := decode(record)
+	if err != nil {
+		t.Fatalf("%q does not decode: %v", record, err)
+	}
+	if record2, _ := codec.AppendEvent(nil, again); !bytes.Equal(record, record2) {
+		t.Fatalf("%#v encodes to %q, then\n%#v to %q", e, record, again, record2)
+	}
+}
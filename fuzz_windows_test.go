@@ -0,0 +1,48 @@
+//go:build go1.18
+// +build go1.18
+
+package keylogger
+
+import (
+	"testing"
+	"unsafe"
+)
+
+/*
+	The keyboard hook decodes whatever KBDLLHOOKSTRUCT Windows passes: any
+	bytes, with any message, go through the hook procedure and translation
+	without crashing, and a key message arrives as one event for the same
+	key, except the watchdog's probes, which are swallowed.
+*/
+func FuzzKeyboardHook(f *testing.F) {
+	seed := func(s KBDLLHOOKSTRUCT) []byte {
+		return append([]byte(nil), (*[unsafe.Sizeof(s)]byte)(unsafe.Pointer(&s))[:]...)
+	}
+	f.Add(uint32(WM_KEYDOWN), seed(KBDLLHOOKSTRUCT{VkCode: 'A', ScanCode: 0x1E}))
+	f.Add(uint32(WM_SYSKEYUP), seed(KBDLLHOOKSTRUCT{VkCode: VK_LMENU, Flags: LLKHF_UP | LLKHF_ALTDOWN}))
+	f.Add(uint32(WM_KEYDOWN), seed(KBDLLHOOKSTRUCT{VkCode: VK_PACKET, ScanCode: 0x20AC, Flags: LLKHF_INJECTED}))
+	f.Add(uint32(WM_KEYDOWN), seed(KBDLLHOOKSTRUCT{VkCode: 0xFFFFFFFF, ScanCode: 0xFFFFFFFF, DwExtraInfo: ^uintptr(0)}))
+	f.Add(uint32(0x0102), []byte{1, 2, 3}) // WM_CHAR, which the hook never sees
+
+	api := newFakeAPI()
+	l, events := startFakeLogger(f, api, nil)
+	defer l.Stop()
+	f.Fuzz(func(t *testing.T, wparam uint32, data []byte) {
+		var s KBDLLHOOKSTRUCT
+		copy((*[unsafe.Sizeof(s)]byte)(unsafe.Pointer(&s))[:], data)
+		api.rawKey(WPARAM(wparam), s)
+		if r := nextResult(t, api); r != 0 && r != 1 {
+			t.Fatalf("hook returned %d", r)
+		}
+		switch wparam {
+		case WM_KEYDOWN, WM_SYSKEYDOWN, WM_KEYUP, WM_SYSKEYUP:
+			if s.DwExtraInfo == probeSignature {
+				return
+			}
+			e, ok := nextEvent(t, events).(KeyEvent)
+			if !ok || e.VkCode != uint16(s.VkCode) || e.ScanCode != uint32(s.ScanCode) {
+				t.Fatalf("event %+v for %+v", e, s)
+			}
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package keylogger
+
+import (
+	"strings"
+	"time"
+)
+
+/*
+	GamepadButton is a controller button as reported by XInput, one bit each.
+	https://docs.microsoft.com/en-us/windows/win32/api/xinput/ns-xinput-xinput_gamepad
+*/
+type GamepadButton uint16
+
+const (
+	XINPUT_GAMEPAD_DPAD_UP        GamepadButton = 0x0001
+	XINPUT_GAMEPAD_DPAD_DOWN      GamepadButton = 0x0002
+	XINPUT_GAMEPAD_DPAD_LEFT      GamepadButton = 0x0004
+	XINPUT_GAMEPAD_DPAD_RIGHT     GamepadButton = 0x0008
+	XINPUT_GAMEPAD_START          GamepadButton = 0x0010
+	XINPUT_GAMEPAD_BACK           GamepadButton = 0x0020
+	XINPUT_GAMEPAD_LEFT_THUMB     GamepadButton = 0x0040
+	XINPUT_GAMEPAD_RIGHT_THUMB    GamepadButton = 0x0080
+	XINPUT_GAMEPAD_LEFT_SHOULDER  GamepadButton = 0x0100
+	XINPUT_GAMEPAD_RIGHT_SHOULDER GamepadButton = 0x0200
+	XINPUT_GAMEPAD_A              GamepadButton = 0x1000
+	XINPUT_GAMEPAD_B              GamepadButton = 0x2000
+	XINPUT_GAMEPAD_X              GamepadButton = 0x4000
+	XINPUT_GAMEPAD_Y              GamepadButton = 0x8000
+)
+
+var gamepadButtonNames = map[GamepadButton]string{
+	XINPUT_GAMEPAD_DPAD_UP:        "DPadUp",
+	XINPUT_GAMEPAD_DPAD_DOWN:      "DPadDown",
+	XINPUT_GAMEPAD_DPAD_LEFT:      "DPadLeft",
+	XINPUT_GAMEPAD_DPAD_RIGHT:     "DPadRight",
+	XINPUT_GAMEPAD_START:          "Start",
+	XINPUT_GAMEPAD_BACK:           "Back",
+	XINPUT_GAMEPAD_LEFT_THUMB:     "LeftThumb",
+	XINPUT_GAMEPAD_RIGHT_THUMB:    "RightThumb",
+	XINPUT_GAMEPAD_LEFT_SHOULDER:  "LeftShoulder",
+	XINPUT_GAMEPAD_RIGHT_SHOULDER: "RightShoulder",
+	XINPUT_GAMEPAD_A:              "A",
+	XINPUT_GAMEPAD_B:              "B",
+	XINPUT_GAMEPAD_X:              "X",
+	XINPUT_GAMEPAD_Y:              "Y",
+}
+
+/*
+	String returns the button names joined by "+", e.g. "A" or "LeftShoulder+A".
+*/
+func (b GamepadButton) String() string {
+	var names []string
+	for bit := GamepadButton(1); bit != 0; bit <<= 1 {
+		if b&bit != 0 {
+			if n, ok := gamepadButtonNames[bit]; ok {
+				names = append(names, n)
+			}
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+/*
+	GamepadState is a snapshot of a controller's buttons, sticks and triggers.
+	Stick axes range from -32768 to 32767, triggers from 0 to 255.
+*/
+type GamepadState struct {
+	Buttons      GamepadButton `json:"buttons"`
+	LeftTrigger  uint8         `json:"left_trigger"`
+	RightTrigger uint8         `json:"right_trigger"`
+	LeftX        int16         `json:"left_x"`
+	LeftY        int16         `json:"left_y"`
+	RightX       int16         `json:"right_x"`
+	RightY       int16         `json:"right_y"`
+}
+
+/*
+	GamepadEvent reports a button press or release on controller Pad (0-3),
+	or, with Button 0, stick or trigger movement beyond GamepadDeadZone.
+	State is the controller state after the change.
+*/
+type GamepadEvent struct {
+	Pad    int           `json:"pad"`
+	Button GamepadButton `json:"button,omitempty"`
+	Down   bool          `json:"down,omitempty"`
+	State  GamepadState  `json:"state"`
+	Time   time.Time     `json:"time"`
+}
+
+/*
+	Timestamp returns when the poller saw the change.
+*/
+func (e GamepadEvent) Timestamp() time.Time {
+	return e.Time
+}
+
+/*
+	GamepadDeadZone is how far a stick axis or trigger has to move from its
+	last reported position before a movement event is emitted. Triggers use
+	it scaled to their 0-255 range.
+*/
+const GamepadDeadZone = 4096
+
+/*
+	Turns successive controller snapshots into events. A controller's first
+	snapshot after connecting only sets the baseline.
+*/
+type gamepadTracker struct {
+	known [4]bool
+	last  [4]GamepadState
+	moved [4]GamepadState
+}
+
+func (t *gamepadTracker) update(pad int, s GamepadState, connected bool, now time.Time) []GamepadEvent {
+	if !connected {
+		t.known[pad] = false
+		return nil
+	}
+	if !t.known[pad] {
+		t.known[pad] = true
+		t.last[pad], t.moved[pad] = s, s
+		return nil
+	}
+	var events []GamepadEvent
+	if changed := s.Buttons ^ t.last[pad].Buttons; changed != 0 {
+		for bit := GamepadButton(1); bit != 0; bit <<= 1 {
+			if changed&bit != 0 {
+				events = append(events, GamepadEvent{Pad: pad, Button: bit, Down: s.Buttons&bit != 0, State: s, Time: now})
+			}
+		}
+	}
+	m := t.moved[pad]
+	if axisMoved(m.LeftX, s.LeftX) || axisMoved(m.LeftY, s.LeftY) ||
+		axisMoved(m.RightX, s.RightX) || axisMoved(m.RightY, s.RightY) ||
+		triggerMoved(m.LeftTrigger, s.LeftTrigger) || triggerMoved(m.RightTrigger, s.RightTrigger) {
+		events = append(events, GamepadEvent{Pad: pad, State: s, Time: now})
+		t.moved[pad] = s
+	}
+	t.last[pad] = s
+	return events
+}
+
+func axisMoved(from, to int16) bool {
+	d := int32(to) - int32(from)
+	return d >= GamepadDeadZone || d <= -GamepadDeadZone
+}
+
+func triggerMoved(from, to uint8) bool {
+	d := int32(to) - int32(from)
+	return d >= GamepadDeadZone>>7 || d <= -(GamepadDeadZone>>7)
+}
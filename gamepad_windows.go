@@ -0,0 +1,94 @@
+package keylogger
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	xinput         = windows.NewLazySystemDLL("xinput1_4.dll")
+	xinputGetState = xinput.NewProc("XInputGetState")
+)
+
+/*
+	Represents the state of a controller.
+	https://docs.microsoft.com/en-us/windows/win32/api/xinput/ns-xinput-xinput_state
+*/
+type XINPUT_STATE struct {
+	DwPacketNumber DWORD
+	Gamepad        XINPUT_GAMEPAD
+}
+
+/*
+	Describes the current state of the controller.
+	https://docs.microsoft.com/en-us/windows/win32/api/xinput/ns-xinput-xinput_gamepad
+*/
+type XINPUT_GAMEPAD struct {
+	WButtons      uint16
+	BLeftTrigger  uint8
+	BRightTrigger uint8
+	SThumbLX      int16
+	SThumbLY      int16
+	SThumbRX      int16
+	SThumbRY      int16
+}
+
+/*
+	XUSER_MAX_COUNT is the number of controllers XInput supports.
+*/
+const XUSER_MAX_COUNT = 4
+
+/*
+	DefaultGamepadPollInterval is how often controllers are polled when
+	GamepadPollInterval is not set.
+*/
+const DefaultGamepadPollInterval = 16 * time.Millisecond
+
+func (l *Logger) pollGamepads(stop <-chan struct{}) {
+	interval := l.GamepadPollInterval
+	if interval <= 0 {
+		interval = DefaultGamepadPollInterval
+	}
+	var t gamepadTracker
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for pad := 0; pad < XUSER_MAX_COUNT; pad++ {
+			var state XINPUT_STATE
+			connected := XInputGetState(DWORD(pad), &state) == 0
+			g := state.Gamepad
+			s := GamepadState{
+				Buttons:      GamepadButton(g.WButtons),
+				LeftTrigger:  g.BLeftTrigger,
+				RightTrigger: g.BRightTrigger,
+				LeftX:        g.SThumbLX,
+				LeftY:        g.SThumbLY,
+				RightX:       g.SThumbRX,
+				RightY:       g.SThumbRY,
+			}
+			for _, e := range t.update(pad, s, connected, time.Now()) {
+				l.emitSide(e, stop)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+/*
+	Retrieves the current state of the specified controller.
+	Returns ERROR_SUCCESS (0), or ERROR_DEVICE_NOT_CONNECTED if no controller is connected at that index.
+	https://docs.microsoft.com/en-us/windows/win32/api/xinput/nf-xinput-xinputgetstate
+*/
+func XInputGetState(dwUserIndex DWORD, pState *XINPUT_STATE) DWORD {
+	ret, _, _ := xinputGetState.Call(
+		uintptr(dwUserIndex),
+		uintptr(unsafe.Pointer(pState)),
+	)
+	return DWORD(ret)
+}
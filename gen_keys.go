@@ -0,0 +1,186 @@
+//go:build ignore
+// +build ignore
+
+/*
+	Generates keytables.go from keys.txt: the VK_ constants, the key names
+	and the scan code and HID usage of each virtual key. Run by go generate.
+*/
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var docs = map[string]string{
+	"const (": `/*
+	Virtual-Key Codes
+	https://docs.microsoft.com/en-us/windows/win32/inputdev/virtual-key-codes
+	Letters and digits have no named constants; their codes equal the ASCII
+	values of 'A'-'Z' and '0'-'9'.
+*/`,
+	"var keyNameList": `/*
+	Names of virtual keys as used in configuration files and logs.
+	The first name listed for a key is the one KeyName returns.
+	Punctuation keys are named after their US layout characters.
+*/`,
+	"var scanCodes": `/*
+	Scan codes of set 1 by virtual key, with 0xE0 or 0xE1 in the high byte
+	for extended keys, as MapVirtualKey(MAPVK_VK_TO_VSC_EX) returns them for
+	a US keyboard.
+*/`,
+	"var hidUsages": `/*
+	HID usages by virtual key, the usage page in the high 16 bits.
+*/`,
+}
+
+type key struct {
+	vk       uint64
+	constant string
+	scan     uint64
+	hid      uint64
+	names    []string
+}
+
+func main() {
+	keys, err := readKeys("keys.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var b bytes.Buffer
+	b.WriteString(`// Code generated by go run gen_keys.go; DO NOT EDIT.
+
+package keylogger
+
+const (
+`)
+	for _, k := range keys {
+		if k.constant != "" {
+			fmt.Fprintf(&b, "\t%s = 0x%02X\n", k.constant, k.vk)
+		}
+	}
+	b.WriteString(`)
+
+var keyNameList = []struct {
+	vk    uint16
+	names []string
+}{
+`)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\t{%s, %#v},\n", k.goVK(), k.names)
+	}
+	b.WriteString(`}
+
+var scanCodes = [256]uint16{
+`)
+	for _, k := range keys {
+		if k.scan != 0 {
+			fmt.Fprintf(&b, "\t%s: 0x%02X,\n", k.goVK(), k.scan)
+		}
+	}
+	b.WriteString(`}
+
+var hidUsages = [256]uint32{
+`)
+	for _, k := range keys {
+		if k.hid != 0 {
+			fmt.Fprintf(&b, "\t%s: 0x%06X,\n", k.goVK(), k.hid)
+		}
+	}
+	b.WriteString("}\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Added after formatting, which would take the indentation out of them.
+	for decl, doc := range docs {
+		src = bytes.Replace(src, []byte("\n"+decl), []byte("\n"+doc+"\n"+decl), 1)
+	}
+	if err := os.WriteFile("keytables.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (k key) goVK() string {
+	if k.constant != "" {
+		return k.constant
+	}
+	return strconv.QuoteRune(rune(k.vk))
+}
+
+/*
+	Reads and checks the table: every code, constant and name once, codes
+	in order, letters and digits without constants.
+*/
+func readKeys(name string) ([]key, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var keys []key
+	seen := make(map[string]int)
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", name, line, fmt.Sprintf(format, args...))
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 5 {
+			return nil, fail("want code, constant, scan code, HID usage and names")
+		}
+		var k key
+		if k.vk, err = strconv.ParseUint(fields[0], 0, 8); err != nil || k.vk == 0 {
+			return nil, fail("bad virtual key %q", fields[0])
+		}
+		if len(keys) > 0 && k.vk <= keys[len(keys)-1].vk {
+			return nil, fail("0x%02X is out of order", k.vk)
+		}
+		ascii := k.vk >= '0' && k.vk <= '9' || k.vk >= 'A' && k.vk <= 'Z'
+		switch c := fields[1]; {
+		case c == "-" && ascii:
+		case c == "-" || ascii:
+			return nil, fail("letters and digits, and only they, have no constant")
+		case !strings.HasPrefix(c, "VK_"):
+			return nil, fail("constant %s does not start with VK_", c)
+		default:
+			k.constant = c
+		}
+		if k.scan, err = parseOptional(fields[2], 16); err != nil || k.scan > 0xFF && k.scan>>8 != 0xE0 && k.scan>>8 != 0xE1 {
+			return nil, fail("bad scan code %q", fields[2])
+		}
+		if k.hid, err = parseOptional(fields[3], 32); err != nil || k.hid != 0 && k.hid>>16 == 0 {
+			return nil, fail("bad HID usage %q, want page << 16 | usage", fields[3])
+		}
+		k.names = fields[4:]
+		for _, n := range append([]string{k.constant}, k.names...) {
+			lower := strings.ToLower(n)
+			if n == "" {
+				continue
+			}
+			if prev, ok := seen[lower]; ok {
+				return nil, fail("%s is already used on line %d", n, prev)
+			}
+			seen[lower] = line
+		}
+		keys = append(keys, k)
+	}
+	return keys, sc.Err()
+}
+
+func parseOptional(s string, bits int) (uint64, error) {
+	if s == "-" {
+		return 0, nil
+	}
+	return strconv.ParseUint(s, 0, bits)
+}
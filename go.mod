@@ -3,3 +3,5 @@ module keylogger
 go 1.17
 
 require golang.org/x/sys v0.0.0-20211216021012-1d35b9e2eb4e
+
+require github.com/yuin/gopher-lua v1.1.1
@@ -0,0 +1,214 @@
+package keylogger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	A hook dump is a text file with a line for every call of the keyboard
+	hook procedure: when it was called, the message, the whole
+	KBDLLHOOKSTRUCT, what the hook did with it and how long it took. It is
+	meant for keys that go missing on unusual keyboards, so it also holds
+	the calls that never become events: other messages, watchdog probes,
+	keys from an abandoned hook thread and keys the ring had no room for.
+
+		15:04:05.000000 WM_KEYDOWN vk=0x41 A scan=0x1E flags=0x00 time=1234567 extra=0x0 queued result=0 took=1.2µs
+
+	The hook only copies the call into a channel; the worker writes it. Calls
+	that find the channel full are counted and the count is written before
+	the next line. While the hooks are paused or about to be, and while a
+	sensitive application pauses or redacts the capture, the key of a call
+	is left out:
+
+		15:04:05.000000 WM_KEYDOWN key hidden flags=0x00 time=1234567 extra=0x0 queued result=0 took=1.2µs
+*/
+type hookCall struct {
+	at     time.Time
+	took   time.Duration
+	nCode  int
+	wparam WPARAM
+	kbd    KBDLLHOOKSTRUCT
+	fate   hookFate
+	result LRESULT
+	hidden bool // the key is not dumped
+}
+
+/*
+	What the keyboard hook did with a call.
+*/
+type hookFate uint8
+
+const (
+	fateIgnored   hookFate = iota // not a key message, passed on
+	fateProbe                     // the watchdog's probe, swallowed
+	fateStale                     // on an abandoned hook thread, passed on
+	fateDropped                   // the ring was full, passed on
+	fateQueued                    // queued and passed on
+	fateSwallowed                 // queued and swallowed by a filter
+	fateOffTarget                 // into a window not of the Target, passed on
+)
+
+var fateNames = [...]string{"ignored", "probe", "stale", "dropped", "queued", "swallowed", "off-target"}
+
+/*
+	The calls on their way from the hook to the worker.
+*/
+type hookCalls struct {
+	lost  uint64 // first for 64-bit alignment on 386
+	calls chan hookCall
+}
+
+func (d *hookCalls) send(c hookCall) {
+	select {
+	case d.calls <- c:
+	default:
+		atomic.AddUint64(&d.lost, 1)
+	}
+}
+
+/*
+	Writes a hook dump. It is only used from the Logger's worker; the first
+	error stops it and is kept.
+*/
+type hookDumpWriter struct {
+	w    *bufio.Writer
+	lost uint64 // as last written
+	err  error
+}
+
+func newHookDumpWriter(w io.Writer) *hookDumpWriter {
+	return &hookDumpWriter{w: bufio.NewWriter(w)}
+}
+
+/*
+	Writes c and reports whether this write failed, so the failure is
+	reported once.
+*/
+func (d *hookDumpWriter) write(c hookCall, lost uint64) bool {
+	if d.err != nil {
+		return false
+	}
+	if lost != d.lost {
+		fmt.Fprintf(d.w, "%s %d calls not dumped\n", c.at.Format("15:04:05.000000"), lost-d.lost)
+		d.lost = lost
+	}
+	var b strings.Builder
+	b.WriteString(c.at.Format("15:04:05.000000"))
+	if c.nCode != HC_ACTION || !isKeyMessage(c.wparam) {
+		fmt.Fprintf(&b, " nCode=%d wparam=0x%04X", c.nCode, c.wparam)
+	} else {
+		k := c.kbd
+		b.WriteString(" " + keyMessageName(c.wparam))
+		if c.hidden {
+			b.WriteString(" key hidden")
+		} else {
+			fmt.Fprintf(&b, " vk=0x%02X %s scan=0x%02X", k.VkCode, KeyName(uint16(k.VkCode)), k.ScanCode)
+		}
+		fmt.Fprintf(&b, " flags=0x%02X%s time=%d extra=0x%X", k.Flags, flagNames(uint32(k.Flags)), k.Time, k.DwExtraInfo)
+	}
+	fmt.Fprintf(&b, " %s result=%d took=%v\n", fateNames[c.fate], c.result, c.took)
+	_, d.err = d.w.WriteString(b.String())
+	return d.err != nil
+}
+
+/*
+	Flushes the dump when the worker has caught up, so that it holds the
+	last calls even if the process dies.
+*/
+func (d *hookDumpWriter) flush() error {
+	if d.err == nil {
+		d.err = d.w.Flush()
+	}
+	return d.err
+}
+
+func isKeyMessage(wparam WPARAM) bool {
+	switch wparam {
+	case WM_KEYDOWN, WM_SYSKEYDOWN, WM_KEYUP, WM_SYSKEYUP:
+		return true
+	}
+	return false
+}
+
+func keyMessageName(wparam WPARAM) string {
+	switch wparam {
+	case WM_KEYDOWN:
+		return "WM_KEYDOWN"
+	case WM_SYSKEYDOWN:
+		return "WM_SYSKEYDOWN"
+	case WM_KEYUP:
+		return "WM_KEYUP"
+	}
+	return "WM_SYSKEYUP"
+}
+
+/*
+	Returns the names of the LLKHF_ flags set in flags, in parentheses.
+*/
+func flagNames(flags uint32) string {
+	var names []string
+	for _, f := range []struct {
+		flag uint32
+		name string
+	}{
+		{LLKHF_EXTENDED, "extended"},
+		{LLKHF_LOWER_IL_INJECTED, "lower-il-injected"},
+		{LLKHF_INJECTED, "injected"},
+		{LLKHF_ALTDOWN, "alt"},
+		{LLKHF_UP, "up"},
+	} {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(names, ",") + ")"
+}
+
+/*
+	Calls the keyboard hook for a dumped call and queues the call for the
+	worker.
+*/
+func (l *Logger) dumpKeyboard(nCode int, wparam WPARAM, lparam LPARAM) LRESULT {
+	c := hookCall{at: time.Now(), nCode: nCode, wparam: wparam}
+	c.result = l.hookKey(nCode, wparam, lparam, &c)
+	c.took = time.Since(c.at)
+	// A pause may be asked for but not yet handled on the hook thread.
+	if l.sensitivePaused() || l.sensitiveRedacted() || l.Paused() {
+		c.kbd.VkCode, c.kbd.ScanCode, c.hidden = 0, 0, true
+	}
+	l.dumps.send(c)
+	return c.result
+}
+
+func (l *Logger) startHookDump() {
+	l.dump = nil
+	if l.dumps != nil {
+		l.dump = newHookDumpWriter(l.HookDump)
+	}
+}
+
+/*
+	Writes the queued calls to the dump, reporting the first failure.
+*/
+func (l *Logger) writeHookDump(c hookCall) {
+	write := func(c hookCall) {
+		if l.dump.write(c, atomic.LoadUint64(&l.dumps.lost)) {
+			l.out.emit(DiagnosticEvent{Kind: DiagHookDumpFailed, Message: fmt.Sprintf("hook dump: %v", l.dump.err), Time: time.Now()})
+		}
+	}
+	write(c)
+	for len(l.dumps.calls) > 0 {
+		write(<-l.dumps.calls)
+	}
+	if l.dump.err == nil && l.dump.flush() != nil {
+		l.out.emit(DiagnosticEvent{Kind: DiagHookDumpFailed, Message: fmt.Sprintf("hook dump: %v", l.dump.err), Time: time.Now()})
+	}
+}
@@ -0,0 +1,162 @@
+package keylogger
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	Modifiers is a set of held modifier keys, without left/right distinction.
+*/
+type Modifiers uint8
+
+const (
+	ModCtrl Modifiers = 1 << iota
+	ModShift
+	ModAlt
+	ModWin
+)
+
+/*
+	Returns the modifier a virtual key belongs to, or 0 for ordinary keys.
+*/
+func modifierOf(vk uint16) Modifiers {
+	switch vk {
+	case VK_CONTROL, VK_LCONTROL, VK_RCONTROL:
+		return ModCtrl
+	case VK_SHIFT, VK_LSHIFT, VK_RSHIFT:
+		return ModShift
+	case VK_MENU, VK_LMENU, VK_RMENU:
+		return ModAlt
+	case VK_LWIN, VK_RWIN:
+		return ModWin
+	}
+	return 0
+}
+
+/*
+	ModifierState tracks which modifiers are held from a stream of key events.
+	Left and right keys are tracked separately so releasing one Shift while
+	the other is held keeps ModShift set.
+*/
+type ModifierState struct {
+	held map[uint16]bool
+}
+
+/*
+	Update records the transition and returns the modifiers held afterwards.
+*/
+func (s *ModifierState) Update(e KeyEvent) Modifiers {
+	if modifierOf(e.VkCode) != 0 {
+		if s.held == nil {
+			s.held = make(map[uint16]bool)
+		}
+		if e.Down {
+			s.held[e.VkCode] = true
+		} else {
+			delete(s.held, e.VkCode)
+		}
+	}
+	return s.Mods()
+}
+
+/*
+	Mods returns the modifiers currently held.
+*/
+func (s *ModifierState) Mods() Modifiers {
+	var m Modifiers
+	for vk := range s.held {
+		m |= modifierOf(vk)
+	}
+	return m
+}
+
+/*
+	Hotkey is a key pressed together with an exact set of modifiers.
+*/
+type Hotkey struct {
+	Mods   Modifiers
+	VkCode uint16
+}
+
+/*
+	Matches reports whether e is the key-down of the hotkey while exactly
+	mods are held.
+*/
+func (h Hotkey) Matches(e KeyEvent, mods Modifiers) bool {
+	return h.VkCode != 0 && e.Down && e.VkCode == h.VkCode && mods == h.Mods
+}
+
+/*
+	Returns the left-hand keys of the hotkey's modifiers, for injecting it.
+*/
+func (h Hotkey) modifierKeys() []uint16 {
+	var vks []uint16
+	for _, m := range []struct {
+		mod Modifiers
+		vk  uint16
+	}{{ModCtrl, VK_LCONTROL}, {ModShift, VK_LSHIFT}, {ModAlt, VK_LMENU}, {ModWin, VK_LWIN}} {
+		if h.Mods&m.mod != 0 {
+			vks = append(vks, m.vk)
+		}
+	}
+	return vks
+}
+
+/*
+	ParseHotkey parses a combination such as "Ctrl+Shift+T" or "Win+L".
+	The last part names the key, the others must be Ctrl, Shift, Alt or Win.
+*/
+func ParseHotkey(s string) (Hotkey, error) {
+	parts := strings.Split(s, "+")
+	var h Hotkey
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if i < len(parts)-1 {
+			m, ok := modifierNames[strings.ToLower(p)]
+			if !ok {
+				return Hotkey{}, fmt.Errorf("hotkey %q: %q is not a modifier", s, p)
+			}
+			h.Mods |= m
+			continue
+		}
+		vk, err := ParseKey(p)
+		if err != nil {
+			return Hotkey{}, fmt.Errorf("hotkey %q: %v", s, err)
+		}
+		h.VkCode = vk
+	}
+	return h, nil
+}
+
+var modifierNames = map[string]Modifiers{
+	"ctrl":    ModCtrl,
+	"control": ModCtrl,
+	"shift":   ModShift,
+	"alt":     ModAlt,
+	"win":     ModWin,
+}
+
+/*
+	String formats the hotkey the way ParseHotkey reads it.
+*/
+func (h Hotkey) String() string {
+	return h.format(KeyName)
+}
+
+/*
+	Formats the hotkey with the keys named by name; the Windows key is
+	"Win" in every language.
+*/
+func (h Hotkey) format(name func(uint16) string) string {
+	var parts []string
+	for _, m := range []struct {
+		mod  Modifiers
+		name string
+	}{{ModCtrl, name(VK_CONTROL)}, {ModShift, name(VK_SHIFT)}, {ModAlt, name(VK_MENU)}, {ModWin, "Win"}} {
+		if h.Mods&m.mod != 0 {
+			parts = append(parts, m.name)
+		}
+	}
+	return strings.Join(append(parts, name(h.VkCode)), "+")
+}
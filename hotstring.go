@@ -0,0 +1,174 @@
+package keylogger
+
+import (
+	"strings"
+	"unicode"
+)
+
+/*
+	HotstringRule replaces Abbrev with Expansion when the abbreviation is
+	typed as a whole word followed by an ending character (space, tab,
+	newline or punctuation). Apps restricts the rule to the listed process
+	names (e.g. "notepad.exe"); an empty list applies it everywhere.
+*/
+type HotstringRule struct {
+	Abbrev    string   `json:"abbrev"`
+	Expansion string   `json:"expansion"`
+	Apps      []string `json:"apps,omitempty"`
+}
+
+/*
+	Expansion is the edit a hotstring asks for: erase Backspaces characters
+	before the caret, then type Text.
+*/
+type Expansion struct {
+	Backspaces int
+	Text       string
+}
+
+/*
+	TextInjector is the subset of Injector needed to apply an Expansion.
+*/
+type TextInjector interface {
+	Tap(vks ...uint16) error
+	Type(s string) error
+}
+
+/*
+	Apply performs the expansion.
+*/
+func (x Expansion) Apply(inj TextInjector) error {
+	for i := 0; i < x.Backspaces; i++ {
+		if err := inj.Tap(VK_BACK); err != nil {
+			return err
+		}
+	}
+	return inj.Type(x.Text)
+}
+
+/*
+	Hotstrings watches the typed character stream for abbreviations.
+	It only sees what was typed since the last caret movement, so navigation
+	keys, shortcuts and switching applications reset its buffer.
+*/
+type Hotstrings struct {
+	Rules []HotstringRule
+
+	mods ModifierState
+	buf  []rune
+	app  string
+}
+
+/*
+	Wipe clears the remembered input.
+*/
+func (h *Hotstrings) Wipe() {
+	for i := range h.buf {
+		h.buf[i] = 0
+	}
+	h.buf = h.buf[:0]
+	h.app = ""
+}
+
+/*
+	maxHotstringBuffer bounds the remembered input; it only needs to hold the
+	longest abbreviation plus its preceding word boundary.
+*/
+const maxHotstringBuffer = 64
+
+/*
+	NewHotstrings returns an engine for the given rules.
+*/
+func NewHotstrings(rules []HotstringRule) *Hotstrings {
+	return &Hotstrings{Rules: rules}
+}
+
+/*
+	Feed processes one captured key event typed into the application app and
+	reports the expansion to apply, if the event completed an abbreviation.
+*/
+func (h *Hotstrings) Feed(e KeyEvent, app string) (Expansion, bool) {
+	if e.FromInjector() || e.Swallowed {
+		return Expansion{}, false
+	}
+	mods := h.mods.Update(e)
+	if !e.Down || modifierOf(e.VkCode) != 0 {
+		return Expansion{}, false
+	}
+	if !strings.EqualFold(app, h.app) {
+		h.app = app
+		h.buf = h.buf[:0]
+	}
+
+	switch {
+	case e.VkCode == VK_BACK:
+		if len(h.buf) > 0 {
+			h.buf = h.buf[:len(h.buf)-1]
+		}
+		return Expansion{}, false
+	case mods&(ModCtrl|ModWin) != 0 && mods&ModAlt == 0, e.Text == "":
+		// Shortcuts and navigation move the caret or change the text in
+		// ways we cannot follow.
+		h.buf = h.buf[:0]
+		return Expansion{}, false
+	}
+
+	for _, r := range e.Text {
+		if isHotstringEnd(r) {
+			if x, ok := h.match(r, app); ok {
+				h.buf = append(h.buf[:0], r)
+				return x, true
+			}
+		}
+		h.buf = append(h.buf, r)
+	}
+	if len(h.buf) > maxHotstringBuffer {
+		h.buf = append(h.buf[:0], h.buf[len(h.buf)-maxHotstringBuffer:]...)
+	}
+	return Expansion{}, false
+}
+
+func (h *Hotstrings) match(end rune, app string) (Expansion, bool) {
+	for _, rule := range h.Rules {
+		abbrev := []rune(rule.Abbrev)
+		if len(abbrev) == 0 || len(abbrev) > len(h.buf) || !ruleAppliesTo(rule, app) {
+			continue
+		}
+		start := len(h.buf) - len(abbrev)
+		if string(h.buf[start:]) != rule.Abbrev {
+			continue
+		}
+		if start > 0 && !isHotstringEnd(h.buf[start-1]) {
+			continue
+		}
+		return Expansion{
+			Backspaces: len(abbrev) + 1,
+			Text:       rule.Expansion + string(end),
+		}, true
+	}
+	return Expansion{}, false
+}
+
+func ruleAppliesTo(rule HotstringRule, app string) bool {
+	return appsInclude(rule.Apps, app)
+}
+
+/*
+	Reports whether app is in the apps a rule is restricted to, or the rule
+	is not restricted.
+*/
+func appsInclude(apps []string, app string) bool {
+	if len(apps) == 0 {
+		return true
+	}
+	for _, a := range apps {
+		if strings.EqualFold(a, app) {
+			return true
+		}
+	}
+	return false
+}
+
+func isHotstringEnd(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r) && r != '_'
+}
@@ -0,0 +1,109 @@
+package keylogger
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/*
+	Feeds the keys typing s into app and returns the expansions asked for.
+*/
+func feedHotstrings(h *Hotstrings, s, app string) []Expansion {
+	var got []Expansion
+	for _, e := range typeKeys(s, false) {
+		if x, ok := h.Feed(e.(KeyEvent), app); ok {
+			got = append(got, x)
+		}
+	}
+	return got
+}
+
+func TestHotstrings(t *testing.T) {
+	rules := []HotstringRule{
+		{Abbrev: "btw", Expansion: "by the way"},
+		{Abbrev: "omw", Expansion: "on my way", Apps: []string{"slack.exe"}},
+	}
+	btw := func(end string) Expansion {
+		return Expansion{Backspaces: 4, Text: "by the way" + end}
+	}
+	for _, c := range []struct {
+		typed, app string
+		want       []Expansion
+	}{
+		{"btw ", "notepad.exe", []Expansion{btw(" ")}},
+		{"btw.", "notepad.exe", []Expansion{btw(".")}},
+		{"btw\n", "notepad.exe", []Expansion{btw("\n")}},
+		{"so, btw, ", "notepad.exe", []Expansion{btw(",")}},
+		{"btw btw ", "notepad.exe", []Expansion{btw(" "), btw(" ")}},
+		{"btw", "notepad.exe", nil},
+		{"btw_", "notepad.exe", nil},
+		{"abtw ", "notepad.exe", nil},
+		{"BTW ", "notepad.exe", nil},
+		{"Btw ", "notepad.exe", nil},
+		{"btx\bw ", "notepad.exe", []Expansion{btw(" ")}},
+		{"btw\b\bw ", "notepad.exe", nil},
+		{"omw ", "notepad.exe", nil},
+		{"omw ", "Slack.exe", []Expansion{{Backspaces: 4, Text: "on my way "}}},
+	} {
+		got := feedHotstrings(NewHotstrings(rules), c.typed, c.app)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q in %s expands to %+v, want %+v", c.typed, c.app, got, c.want)
+		}
+	}
+}
+
+/*
+	Shortcuts, switching applications and the engine's own injected keys
+	do not complete an abbreviation typed before them.
+*/
+func TestHotstringsReset(t *testing.T) {
+	h := NewHotstrings([]HotstringRule{{Abbrev: "btw", Expansion: "by the way"}})
+	feedHotstrings(h, "bt", "notepad.exe")
+	h.Feed(KeyEvent{VkCode: VK_LCONTROL, Down: true}, "notepad.exe")
+	h.Feed(KeyEvent{VkCode: VK_LEFT, Down: true}, "notepad.exe")
+	h.Feed(KeyEvent{VkCode: VK_LCONTROL}, "notepad.exe")
+	if got := feedHotstrings(h, "w ", "notepad.exe"); got != nil {
+		t.Errorf("expanded across Ctrl+Left: %+v", got)
+	}
+
+	feedHotstrings(h, "bt", "notepad.exe")
+	if got := feedHotstrings(h, "w ", "code.exe"); got != nil {
+		t.Errorf("expanded across applications: %+v", got)
+	}
+
+	feedHotstrings(h, "bt", "notepad.exe")
+	injected := KeyEvent{VkCode: 'W', Flags: LLKHF_INJECTED, ExtraInfo: InjectedSignature, Down: true, Text: "w"}
+	if _, ok := h.Feed(injected, "notepad.exe"); ok {
+		t.Error("an injected key completed the abbreviation")
+	}
+	if got := feedHotstrings(h, "w ", "notepad.exe"); len(got) != 1 {
+		t.Errorf("injected key was remembered: %+v", got)
+	}
+}
+
+type textInjector struct {
+	ops []string
+}
+
+func (i *textInjector) Tap(vks ...uint16) error {
+	for _, vk := range vks {
+		i.ops = append(i.ops, KeyName(vk))
+	}
+	return nil
+}
+
+func (i *textInjector) Type(s string) error {
+	i.ops = append(i.ops, s)
+	return nil
+}
+
+func TestExpansionApply(t *testing.T) {
+	var inj textInjector
+	if err := (Expansion{Backspaces: 4, Text: "by the way "}).Apply(&inj); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(inj.ops, "|"), "Backspace|Backspace|Backspace|Backspace|by the way "; got != want {
+		t.Errorf("Apply did %s, want %s", got, want)
+	}
+}
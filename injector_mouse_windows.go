@@ -0,0 +1,193 @@
+package keylogger
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+var (
+	getSystemMetrics = user32.NewProc("GetSystemMetrics")
+)
+
+/*
+	Contains information about a simulated mouse event.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-mouseinput
+*/
+type MOUSEINPUT struct {
+	Dx          int32
+	Dy          int32
+	MouseData   DWORD
+	DwFlags     DWORD
+	Time        DWORD
+	DwExtraInfo uintptr
+}
+
+/*
+	The mouse variant of the INPUT union. MOUSEINPUT is the largest member,
+	so no padding is needed.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-input
+*/
+type MOUSE_INPUT struct {
+	Type DWORD
+	Mi   MOUSEINPUT
+}
+
+const (
+	INPUT_MOUSE = 0
+
+	MOUSEEVENTF_MOVE        = 0x0001
+	MOUSEEVENTF_LEFTDOWN    = 0x0002
+	MOUSEEVENTF_LEFTUP      = 0x0004
+	MOUSEEVENTF_RIGHTDOWN   = 0x0008
+	MOUSEEVENTF_RIGHTUP     = 0x0010
+	MOUSEEVENTF_MIDDLEDOWN  = 0x0020
+	MOUSEEVENTF_MIDDLEUP    = 0x0040
+	MOUSEEVENTF_XDOWN       = 0x0080
+	MOUSEEVENTF_XUP         = 0x0100
+	MOUSEEVENTF_WHEEL       = 0x0800
+	MOUSEEVENTF_HWHEEL      = 0x1000
+	MOUSEEVENTF_VIRTUALDESK = 0x4000
+	MOUSEEVENTF_ABSOLUTE    = 0x8000
+
+	XBUTTON1 = 0x0001
+	XBUTTON2 = 0x0002
+
+	/*
+		One notch of a standard mouse wheel.
+	*/
+	WHEEL_DELTA = 120
+
+	SM_XVIRTUALSCREEN  = 76
+	SM_YVIRTUALSCREEN  = 77
+	SM_CXVIRTUALSCREEN = 78
+	SM_CYVIRTUALSCREEN = 79
+)
+
+/*
+	MoveTo moves the cursor to the screen coordinates x, y. Coordinates span
+	the whole virtual desktop, so they may be negative on multi-monitor setups.
+*/
+func (in *Injector) MoveTo(x, y int) error {
+	left, top := GetSystemMetrics(SM_XVIRTUALSCREEN), GetSystemMetrics(SM_YVIRTUALSCREEN)
+	width, height := GetSystemMetrics(SM_CXVIRTUALSCREEN), GetSystemMetrics(SM_CYVIRTUALSCREEN)
+	if width <= 1 || height <= 1 {
+		return fmt.Errorf("MoveTo: invalid virtual screen size %dx%d", width, height)
+	}
+	// Absolute coordinates are normalized to 0..65535 across the desktop.
+	return in.sendMouse(in.mouseInput(
+		int32((x-left)*65535/(width-1)),
+		int32((y-top)*65535/(height-1)),
+		0, MOUSEEVENTF_MOVE|MOUSEEVENTF_ABSOLUTE|MOUSEEVENTF_VIRTUALDESK,
+	))
+}
+
+/*
+	MoveBy moves the cursor relative to its current position. The distance is
+	subject to the user's pointer speed and acceleration settings.
+*/
+func (in *Injector) MoveBy(dx, dy int) error {
+	return in.sendMouse(in.mouseInput(int32(dx), int32(dy), 0, MOUSEEVENTF_MOVE))
+}
+
+/*
+	MouseDown presses a mouse button.
+*/
+func (in *Injector) MouseDown(b MouseButton) error {
+	flags, data, err := buttonFlags(b, false)
+	if err != nil {
+		return err
+	}
+	return in.sendMouse(in.mouseInput(0, 0, data, flags))
+}
+
+/*
+	MouseUp releases a mouse button.
+*/
+func (in *Injector) MouseUp(b MouseButton) error {
+	flags, data, err := buttonFlags(b, true)
+	if err != nil {
+		return err
+	}
+	return in.sendMouse(in.mouseInput(0, 0, data, flags))
+}
+
+/*
+	Click presses and releases a mouse button at the current position.
+*/
+func (in *Injector) Click(b MouseButton) error {
+	down, data, err := buttonFlags(b, false)
+	if err != nil {
+		return err
+	}
+	up, _, _ := buttonFlags(b, true)
+	return in.sendMouse(in.mouseInput(0, 0, data, down), in.mouseInput(0, 0, data, up))
+}
+
+/*
+	Wheel rotates the vertical wheel; positive deltas scroll away from the
+	user. One notch is WHEEL_DELTA.
+*/
+func (in *Injector) Wheel(delta int) error {
+	return in.sendMouse(in.mouseInput(0, 0, DWORD(int32(delta)), MOUSEEVENTF_WHEEL))
+}
+
+/*
+	HWheel rotates the horizontal wheel; positive deltas scroll right.
+*/
+func (in *Injector) HWheel(delta int) error {
+	return in.sendMouse(in.mouseInput(0, 0, DWORD(int32(delta)), MOUSEEVENTF_HWHEEL))
+}
+
+func buttonFlags(b MouseButton, up bool) (flags DWORD, data DWORD, err error) {
+	switch b {
+	case LeftButton:
+		flags = MOUSEEVENTF_LEFTDOWN
+	case RightButton:
+		flags = MOUSEEVENTF_RIGHTDOWN
+	case MiddleButton:
+		flags = MOUSEEVENTF_MIDDLEDOWN
+	case XButton1:
+		flags, data = MOUSEEVENTF_XDOWN, XBUTTON1
+	case XButton2:
+		flags, data = MOUSEEVENTF_XDOWN, XBUTTON2
+	default:
+		return 0, 0, fmt.Errorf("unknown mouse button %d", int(b))
+	}
+	if up {
+		// Every *UP flag is the corresponding *DOWN flag shifted by one.
+		flags <<= 1
+	}
+	return flags, data, nil
+}
+
+func (in *Injector) mouseInput(dx, dy int32, data, flags DWORD) MOUSE_INPUT {
+	return MOUSE_INPUT{
+		Type: INPUT_MOUSE,
+		Mi: MOUSEINPUT{
+			Dx:          dx,
+			Dy:          dy,
+			MouseData:   data,
+			DwFlags:     flags,
+			DwExtraInfo: in.extraInfo(),
+		},
+	}
+}
+
+func (in *Injector) sendMouse(inputs ...MOUSE_INPUT) error {
+	n, err := SendInput(uint32(len(inputs)), unsafe.Pointer(&inputs[0]), int32(unsafe.Sizeof(inputs[0])))
+	if int(n) != len(inputs) {
+		return fmt.Errorf("SendInput: inserted %d of %d events: %v", n, len(inputs), err)
+	}
+	return nil
+}
+
+/*
+	Retrieves the specified system metric or system configuration setting.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getsystemmetrics
+*/
+func GetSystemMetrics(nIndex int) int {
+	ret, _, _ := getSystemMetrics.Call(
+		uintptr(nIndex),
+	)
+	return int(int32(ret))
+}
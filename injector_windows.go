@@ -0,0 +1,205 @@
+package keylogger
+
+import (
+	"fmt"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var (
+	sendInput      = user32.NewProc("SendInput")
+	mapVirtualKeyW = user32.NewProc("MapVirtualKeyW")
+)
+
+/*
+	Contains information about a simulated keyboard event.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-keybdinput
+*/
+type KEYBDINPUT struct {
+	WVk         uint16
+	WScan       uint16
+	DwFlags     DWORD
+	Time        DWORD
+	DwExtraInfo uintptr
+}
+
+/*
+	Used by SendInput to store information for synthesizing input events.
+	INPUT is a union in C; this is its keyboard variant, padded to the size
+	of the largest member (MOUSEINPUT) so SendInput accepts it.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-input
+*/
+type KEYBOARD_INPUT struct {
+	Type DWORD
+	Ki   KEYBDINPUT
+	_    [8]byte
+}
+
+const (
+	INPUT_KEYBOARD = 1
+
+	KEYEVENTF_EXTENDEDKEY = 0x0001
+	KEYEVENTF_KEYUP       = 0x0002
+	KEYEVENTF_UNICODE     = 0x0004
+	KEYEVENTF_SCANCODE    = 0x0008
+
+	MAPVK_VK_TO_VSC    = 0
+	MAPVK_VK_TO_VSC_EX = 4
+)
+
+/*
+	Injector synthesizes keyboard input through SendInput.
+	ExtraInfo is attached to every event, InjectedSignature if zero.
+	The zero value is ready to use.
+*/
+type Injector struct {
+	ExtraInfo uintptr
+}
+
+/*
+	NewInjector returns an Injector.
+*/
+func NewInjector() *Injector {
+	return &Injector{}
+}
+
+/*
+	Type sends the string as a sequence of Unicode key presses, independent of
+	the active keyboard layout. Newlines and tabs are sent as Return and Tab.
+*/
+func (in *Injector) Type(s string) error {
+	var inputs []KEYBOARD_INPUT
+	for _, r := range s {
+		switch r {
+		case '\n':
+			inputs = append(inputs, in.vkInput(VK_RETURN, false), in.vkInput(VK_RETURN, true))
+			continue
+		case '\r':
+			continue
+		case '\t':
+			inputs = append(inputs, in.vkInput(VK_TAB, false), in.vkInput(VK_TAB, true))
+			continue
+		}
+		for _, unit := range utf16.Encode([]rune{r}) {
+			inputs = append(inputs, in.unicodeInput(unit, false), in.unicodeInput(unit, true))
+		}
+	}
+	return in.send(inputs)
+}
+
+/*
+	Press sends key-down events for the virtual keys in the given order.
+*/
+func (in *Injector) Press(vks ...uint16) error {
+	inputs := make([]KEYBOARD_INPUT, 0, len(vks))
+	for _, vk := range vks {
+		inputs = append(inputs, in.vkInput(vk, false))
+	}
+	return in.send(inputs)
+}
+
+/*
+	Release sends key-up events for the virtual keys in reverse order, so that
+	Release mirrors a preceding Press with the same arguments.
+*/
+func (in *Injector) Release(vks ...uint16) error {
+	inputs := make([]KEYBOARD_INPUT, 0, len(vks))
+	for i := len(vks) - 1; i >= 0; i-- {
+		inputs = append(inputs, in.vkInput(vks[i], true))
+	}
+	return in.send(inputs)
+}
+
+/*
+	Tap presses and releases a key combination, e.g. Tap(VK_CONTROL, 'C').
+*/
+func (in *Injector) Tap(vks ...uint16) error {
+	inputs := make([]KEYBOARD_INPUT, 0, 2*len(vks))
+	for _, vk := range vks {
+		inputs = append(inputs, in.vkInput(vk, false))
+	}
+	for i := len(vks) - 1; i >= 0; i-- {
+		inputs = append(inputs, in.vkInput(vks[i], true))
+	}
+	return in.send(inputs)
+}
+
+func (in *Injector) send(inputs []KEYBOARD_INPUT) error {
+	if len(inputs) == 0 {
+		return nil
+	}
+	n, err := SendInput(uint32(len(inputs)), unsafe.Pointer(&inputs[0]), int32(unsafe.Sizeof(inputs[0])))
+	if int(n) != len(inputs) {
+		return fmt.Errorf("SendInput: inserted %d of %d events: %v", n, len(inputs), err)
+	}
+	return nil
+}
+
+func (in *Injector) extraInfo() uintptr {
+	if in.ExtraInfo == 0 {
+		return InjectedSignature
+	}
+	return in.ExtraInfo
+}
+
+func (in *Injector) vkInput(vk uint16, up bool) KEYBOARD_INPUT {
+	var flags DWORD
+	if isExtendedKey(vk) {
+		flags |= KEYEVENTF_EXTENDEDKEY
+	}
+	if up {
+		flags |= KEYEVENTF_KEYUP
+	}
+	return KEYBOARD_INPUT{
+		Type: INPUT_KEYBOARD,
+		Ki: KEYBDINPUT{
+			WVk:         vk,
+			WScan:       uint16(MapVirtualKey(uint32(vk), MAPVK_VK_TO_VSC)),
+			DwFlags:     flags,
+			DwExtraInfo: in.extraInfo(),
+		},
+	}
+}
+
+func (in *Injector) unicodeInput(unit uint16, up bool) KEYBOARD_INPUT {
+	var flags DWORD = KEYEVENTF_UNICODE
+	if up {
+		flags |= KEYEVENTF_KEYUP
+	}
+	return KEYBOARD_INPUT{
+		Type: INPUT_KEYBOARD,
+		Ki: KEYBDINPUT{
+			WScan:       unit,
+			DwFlags:     flags,
+			DwExtraInfo: in.extraInfo(),
+		},
+	}
+}
+
+/*
+	Synthesizes keystrokes, mouse motions, and button clicks.
+	Returns the number of events successfully inserted into the input stream;
+	input blocked by UIPI is not reported as an error by Windows.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-sendinput
+*/
+func SendInput(cInputs uint32, pInputs unsafe.Pointer, cbSize int32) (uint32, error) {
+	ret, _, err := sendInput.Call(
+		uintptr(cInputs),
+		uintptr(pInputs),
+		uintptr(cbSize),
+	)
+	return uint32(ret), err
+}
+
+/*
+	Translates a virtual-key code into a scan code or character value, or
+	translates a scan code into a virtual-key code.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-mapvirtualkeyw
+*/
+func MapVirtualKey(uCode uint32, uMapType uint32) uint32 {
+	ret, _, _ := mapVirtualKeyW.Call(
+		uintptr(uCode),
+		uintptr(uMapType),
+	)
+	return uint32(ret)
+}
@@ -0,0 +1,54 @@
+package keylogger
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+/*
+	LatencySummary describes a set of measured latencies.
+*/
+type LatencySummary struct {
+	Count  int
+	Min    time.Duration
+	Max    time.Duration
+	Mean   time.Duration
+	StdDev time.Duration
+	P50    time.Duration
+	P90    time.Duration
+	P99    time.Duration
+}
+
+/*
+	SummarizeLatencies returns the summary of samples, which it sorts.
+	Percentiles are those of the nearest rank.
+*/
+func SummarizeLatencies(samples []time.Duration) LatencySummary {
+	s := LatencySummary{Count: len(samples)}
+	if len(samples) == 0 {
+		return s
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	var sum float64
+	for _, d := range samples {
+		sum += float64(d)
+	}
+	mean := sum / float64(len(samples))
+	var squares float64
+	for _, d := range samples {
+		squares += (float64(d) - mean) * (float64(d) - mean)
+	}
+	rank := func(q float64) time.Duration {
+		i := int(math.Ceil(q*float64(len(samples)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return samples[i]
+	}
+	s.Min, s.Max = samples[0], samples[len(samples)-1]
+	s.Mean = time.Duration(mean)
+	s.StdDev = time.Duration(math.Sqrt(squares / float64(len(samples))))
+	s.P50, s.P90, s.P99 = rank(0.5), rank(0.9), rank(0.99)
+	return s
+}
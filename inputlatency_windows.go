@@ -0,0 +1,263 @@
+package keylogger
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var setForegroundWindow = user32.NewProc("SetForegroundWindow")
+
+/*
+	Window styles and messages of the latency test window.
+*/
+const (
+	WS_OVERLAPPEDWINDOW = 0x00CF0000
+	WM_CHAR             = 0x0102
+	SW_SHOW             = 5
+	CW_USEDEFAULT       = 0x80000000
+)
+
+/*
+	The window class of the latency test, and the defaults of InputLatencyTest.
+*/
+const (
+	latencyClass           = "KeyloggerLatencyTest"
+	DefaultLatencySamples  = 200
+	DefaultLatencyInterval = 20 * time.Millisecond
+	DefaultLatencyTimeout  = time.Second
+)
+
+/*
+	InputLatencyTest measures how long keyboard input takes to reach a
+	low-level hook, and with Window set a window as WM_CHAR: it injects
+	Samples key presses through SendInput, Interval apart, and times each
+	until a Logger of its own sees it and, with Window, until a window it
+	shows in the foreground receives its character. Presses not seen
+	within Timeout count as lost. Without Window, F24 is pressed, which
+	no application acts on; with Window, an x into the test window.
+
+	What the hook sees is also what other hooks, remapping software among
+	them, hold up: running the test with and without such software shows
+	what it costs.
+*/
+type InputLatencyTest struct {
+	Samples  int
+	Interval time.Duration
+	Timeout  time.Duration
+	Window   bool
+}
+
+/*
+	InputLatencyResult holds the latencies measured, from SendInput to
+	the hook and, for a Window test, to WM_CHAR.
+*/
+type InputLatencyResult struct {
+	Hook []time.Duration
+	Char []time.Duration
+	Lost int
+}
+
+/*
+	Run runs the test, calling progress, if not nil, after every sample.
+	The test window is closed again before it returns.
+*/
+func (t InputLatencyTest) Run(progress func(done int)) (InputLatencyResult, error) {
+	var r InputLatencyResult
+	if t.Samples <= 0 {
+		t.Samples = DefaultLatencySamples
+	}
+	if t.Interval <= 0 {
+		t.Interval = DefaultLatencyInterval
+	}
+	if t.Timeout <= 0 {
+		t.Timeout = DefaultLatencyTimeout
+	}
+	var w *latencyWindow
+	var chars chan time.Time
+	if t.Window {
+		chars = make(chan time.Time, 1)
+		var err error
+		if w, err = showLatencyWindow(chars); err != nil {
+			return r, err
+		}
+		defer w.close()
+	}
+
+	logger := NewLogger()
+	events := logger.Events()
+	if err := logger.Start(); err != nil {
+		return r, err
+	}
+	defer logger.Stop()
+	in := NewInjector()
+	vk := uint16(VK_F24)
+	if t.Window {
+		vk = VK_PACKET
+	}
+	for i := 0; i < t.Samples; i++ {
+		if t.Window && GetForegroundWindow() != w.hwnd {
+			return r, errors.New("latency: the test window lost the focus")
+		}
+		// Late arrivals of a lost press are not timed against this one.
+		for len(chars) > 0 {
+			<-chars
+		}
+		for len(events) > 0 {
+			<-events
+		}
+		sent := time.Now()
+		var err error
+		if t.Window {
+			err = in.Type("x")
+		} else {
+			err = in.Tap(vk)
+		}
+		if err != nil {
+			return r, err
+		}
+		deadline := time.NewTimer(t.Timeout)
+		seen, typed := false, !t.Window
+		for !seen || !typed {
+			select {
+			case ev := <-events:
+				if e, ok := ev.(KeyEvent); ok && e.Down && e.VkCode == vk && e.FromInjector() && !seen {
+					r.Hook = append(r.Hook, e.Time.Sub(sent))
+					seen = true
+				}
+			case c := <-chars:
+				r.Char = append(r.Char, c.Sub(sent))
+				typed = true
+			case <-deadline.C:
+				r.Lost++
+				seen, typed = true, true
+			}
+		}
+		deadline.Stop()
+		if progress != nil {
+			progress(i + 1)
+		}
+		time.Sleep(t.Interval)
+	}
+	return r, nil
+}
+
+type latencyWindow struct {
+	hwnd HWND
+	done chan struct{}
+}
+
+var (
+	latencyClassOnce sync.Once
+	latencyClassErr  error
+	latencyMu        sync.Mutex
+	latencyChars     chan<- time.Time // of the window shown
+)
+
+/*
+	Shows the test window in the foreground, on a thread of its own, and
+	has it send the time of every WM_CHAR to chars.
+*/
+func showLatencyWindow(chars chan<- time.Time) (*latencyWindow, error) {
+	w := &latencyWindow{done: make(chan struct{})}
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer close(w.done)
+		hwnd, err := createLatencyWindow()
+		if err != nil {
+			errc <- err
+			return
+		}
+		latencyMu.Lock()
+		latencyChars = chars
+		latencyMu.Unlock()
+		w.hwnd = hwnd
+		showWindow.Call(uintptr(hwnd), SW_SHOW)
+		setForegroundWindow.Call(uintptr(hwnd))
+		errc <- nil
+		var msg MSG
+		for GetMessage(&msg, 0, 0, 0) > 0 {
+			dispatchMessageW.Call(uintptr(unsafe.Pointer(&msg)))
+		}
+		latencyMu.Lock()
+		latencyChars = nil
+		latencyMu.Unlock()
+	}()
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	// Windows may refuse the foreground to a process the user is not
+	// working in; the window only flashes in the taskbar then.
+	for start := time.Now(); GetForegroundWindow() != w.hwnd; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			w.close()
+			return nil, errors.New("latency: the test window could not be brought to the front")
+		}
+	}
+	return w, nil
+}
+
+func createLatencyWindow() (HWND, error) {
+	var instance windows.Handle
+	if err := windows.GetModuleHandleEx(0, nil, &instance); err != nil {
+		return 0, err
+	}
+	class, _ := windows.UTF16PtrFromString(latencyClass)
+	latencyClassOnce.Do(func() {
+		wc := wndClassEx{
+			wndProc:   windows.NewCallback(latencyProc),
+			instance:  instance,
+			className: class,
+		}
+		wc.size = uint32(unsafe.Sizeof(wc))
+		if ret, _, err := registerClassExW.Call(uintptr(unsafe.Pointer(&wc))); ret == 0 {
+			latencyClassErr = fmt.Errorf("RegisterClassEx: %v", err)
+		}
+	})
+	if latencyClassErr != nil {
+		return 0, latencyClassErr
+	}
+	title, _ := windows.UTF16PtrFromString("keylogger latency test")
+	ret, _, err := createWindowExW.Call(0,
+		uintptr(unsafe.Pointer(class)), uintptr(unsafe.Pointer(title)), WS_OVERLAPPEDWINDOW,
+		CW_USEDEFAULT, CW_USEDEFAULT, 400, 200,
+		0, 0, uintptr(instance), 0)
+	if ret == 0 {
+		return 0, fmt.Errorf("CreateWindowEx: %v", err)
+	}
+	return HWND(ret), nil
+}
+
+func latencyProc(hwnd HWND, msg uint32, wparam WPARAM, lparam LPARAM) LRESULT {
+	switch msg {
+	case WM_CHAR:
+		now := time.Now()
+		latencyMu.Lock()
+		select {
+		case latencyChars <- now:
+		default:
+		}
+		latencyMu.Unlock()
+		return 0
+	case WM_CLOSE:
+		destroyWindow.Call(uintptr(hwnd))
+		return 0
+	case WM_DESTROY:
+		postQuitMessage.Call(0)
+		return 0
+	}
+	ret, _, _ := defWindowProcW.Call(uintptr(hwnd), uintptr(msg), uintptr(wparam), uintptr(lparam))
+	return LRESULT(ret)
+}
+
+func (w *latencyWindow) close() {
+	postMessageW.Call(uintptr(w.hwnd), WM_CLOSE, 0, 0)
+	<-w.done
+}
@@ -0,0 +1,161 @@
+//go:build integration
+// +build integration
+
+package keylogger
+
+import (
+	"testing"
+	"time"
+)
+
+/*
+	Integration tests against the real hooks: they inject input with
+	SendInput and check what the Logger reports. They need an interactive
+	desktop session, where low-level hooks are called, and run with
+
+		go test -tags integration -run Integration .
+
+	The injected keys are marked with integrationSignature and swallowed by
+	a filter, so nothing is typed into the foreground window; the user's
+	own input during the test is ignored. Swallowed keys are not
+	translated, so the characters are checked by translating the captured
+	events with the Logger's translator and the real keyboard layout, which
+	must type A and B for those keys.
+*/
+
+const integrationSignature = 0x4B4C4954 // "KLIT"
+
+func startIntegration(t *testing.T, setup func(l *Logger)) (*Logger, <-chan Event) {
+	t.Helper()
+	l := NewLogger()
+	l.AddFilter(func(e KeyEvent) bool { return e.ExtraInfo == integrationSignature })
+	if setup != nil {
+		setup(l)
+	}
+	events := l.Events()
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return l, events
+}
+
+/*
+	Returns the next n events sent by the test, skipping the user's input.
+*/
+func injectedEvents(t *testing.T, events <-chan Event, n int) []Event {
+	t.Helper()
+	var got []Event
+	timeout := time.After(5 * time.Second)
+	for len(got) < n {
+		select {
+		case e := <-events:
+			switch e := e.(type) {
+			case KeyEvent:
+				if e.ExtraInfo == integrationSignature {
+					got = append(got, e)
+				}
+			case MouseEvent:
+				if e.ExtraInfo == integrationSignature {
+					got = append(got, e)
+				}
+			}
+		case <-timeout:
+			t.Fatalf("got %d of %d injected events; is this an interactive session?", len(got), n)
+		}
+	}
+	return got
+}
+
+func TestIntegrationKeys(t *testing.T) {
+	l, events := startIntegration(t, nil)
+	defer l.Stop()
+
+	inj := &Injector{ExtraInfo: integrationSignature}
+	for _, err := range []error{
+		inj.Press(VK_SHIFT),
+		inj.Tap('A'),
+		inj.Release(VK_SHIFT),
+		inj.Tap('B'),
+		inj.Tap(VK_RETURN),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []struct {
+		mod  Modifiers // the key is this modifier
+		vk   uint16
+		down bool
+		mods Modifiers // held before the key
+		text string
+	}{
+		{mod: ModShift, down: true},
+		{vk: 'A', down: true, mods: ModShift, text: "A"},
+		{vk: 'A', mods: ModShift},
+		{mod: ModShift, mods: ModShift},
+		{vk: 'B', down: true, text: "b"},
+		{vk: 'B'},
+		{vk: VK_RETURN, down: true, text: "\n"},
+		{vk: VK_RETURN},
+	}
+	tr := newTranslator(realAPI{})
+	tr.caps = false
+	var mods ModifierState
+	for i, ev := range injectedEvents(t, events, len(want)) {
+		e := ev.(KeyEvent)
+		w := want[i]
+		if w.mod != 0 && modifierOf(e.VkCode) != w.mod || w.mod == 0 && e.VkCode != w.vk {
+			t.Errorf("event %d is %s, want %s", i, KeyName(e.VkCode), KeyName(w.vk))
+		}
+		if e.Down != w.down || !e.Injected() || !e.Swallowed || e.ScanCode == 0 {
+			t.Errorf("event %d = %+v, want down=%v, injected, swallowed and a scan code", i, e, w.down)
+		}
+		if got := mods.Mods(); got != w.mods {
+			t.Errorf("event %d: modifiers %v held, want %v", i, got, w.mods)
+		}
+		mods.Update(e)
+		if text := tr.translate(e); text != w.text {
+			t.Errorf("event %d types %q, want %q", i, text, w.text)
+		}
+	}
+}
+
+func TestIntegrationUnicode(t *testing.T) {
+	l, events := startIntegration(t, nil)
+	defer l.Stop()
+
+	inj := &Injector{ExtraInfo: integrationSignature}
+	if err := inj.Type("é€"); err != nil {
+		t.Fatal(err)
+	}
+	tr := newTranslator(realAPI{})
+	var text string
+	for _, ev := range injectedEvents(t, events, 4) {
+		e := ev.(KeyEvent)
+		if e.VkCode != VK_PACKET {
+			t.Errorf("%s injected, want Packet", KeyName(e.VkCode))
+		}
+		text += tr.translate(e)
+	}
+	if text != "é€" {
+		t.Errorf("typed %q, want %q", text, "é€")
+	}
+}
+
+func TestIntegrationMouse(t *testing.T) {
+	l, events := startIntegration(t, func(l *Logger) {
+		l.CaptureMouse = true
+	})
+	defer l.Stop()
+
+	inj := &Injector{ExtraInfo: integrationSignature}
+	// A move by nothing leaves the cursor where the user put it.
+	if err := inj.MoveBy(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	e := injectedEvents(t, events, 1)[0].(MouseEvent)
+	if e.Action != MouseMove || !e.Injected() {
+		t.Errorf("event = %+v, want an injected move", e)
+	}
+}
@@ -0,0 +1,1130 @@
+package keylogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/sys/windows"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	user32              = windows.NewLazySystemDLL("user32.dll")
+	setWindowsHookExA   = user32.NewProc("SetWindowsHookExA")
+	unhookWindowsHookEx = user32.NewProc("UnhookWindowsHookEx")
+	getMessageW         = user32.NewProc("GetMessageW")
+	callNextHookEx      = user32.NewProc("CallNextHookEx")
+	postThreadMessageW  = user32.NewProc("PostThreadMessageW")
+)
+
+/*
+	Windows Data Types
+	https://docs.microsoft.com/en-us/windows/win32/winprog/windows-data-types
+*/
+type (
+	DWORD     uint32
+	WPARAM    uintptr
+	LPARAM    uintptr
+	LRESULT   uintptr
+	HANDLE    uintptr
+	HINSTANCE HANDLE
+	HHOOK     HANDLE
+	HWND      HANDLE
+)
+
+type HOOKPROC func(int, WPARAM, LPARAM) LRESULT
+
+/*
+	Contains information about a low-level keyboard input event.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-kbdllhookstruct
+*/
+type KBDLLHOOKSTRUCT struct {
+	VkCode      DWORD
+	ScanCode    DWORD
+	Flags       DWORD
+	Time        DWORD
+	DwExtraInfo uintptr
+}
+
+/*
+	Contains message information from a thread's message queue.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-msg
+*/
+type MSG struct {
+	Hwnd    HWND
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      POINT
+}
+
+/*
+	The POINT structure defines the x- and y- coordinates of a point.
+	https://docs.microsoft.com/en-us/previous-versions//dd162805(v=vs.85)?redirectedfrom=MSDN
+*/
+type POINT struct {
+	X, Y int32
+}
+
+const (
+	/*
+		The 'WH_KEYBOARD_LL' hook enables you to monitor keyboard input events about to be posted in a thread input queue.
+		https://docs.microsoft.com/en-us/windows/win32/winmsg/about-hooks#wh_keyboard_ll
+	*/
+	WH_KEYBOARD_LL = 13
+
+	/*
+		The 'WH_MOUSE_LL' hook enables you to monitor mouse input events about to be posted in a thread input queue.
+		https://docs.microsoft.com/en-us/windows/win32/winmsg/about-hooks#wh_mouse_ll
+	*/
+	WH_MOUSE_LL = 14
+
+	/*
+		WM_KEYDOWN : Posted to the window with the keyboard focus when a nonsystem key is pressed.
+		A nonsystem key is a key that is pressed when the ALT key is not pressed.
+	*/
+	WM_KEYDOWN = 256
+
+	/*
+		WM_KEYUP : Posted to the window with the keyboard focus when a nonsystem key is released.
+	*/
+	WM_KEYUP = 257
+
+	/*
+		WM_SYSKEYDOWN / WM_SYSKEYUP : Posted instead of WM_KEYDOWN / WM_KEYUP when the key is
+		pressed while ALT is held down, or for F10.
+	*/
+	WM_SYSKEYDOWN = 260
+	WM_SYSKEYUP   = 261
+
+	/*
+		WM_QUIT : Indicates a request to terminate an application; makes GetMessage return 0.
+	*/
+	WM_QUIT = 18
+
+	/*
+		HC_ACTION : The wParam and lParam parameters contain information about a keyboard message.
+	*/
+	HC_ACTION = 0
+)
+
+/*
+	Logger captures input system-wide through low-level hooks:
+	WH_KEYBOARD_LL if CaptureKeyboard is set, WH_MOUSE_LL if CaptureMouse is
+	set, and a foreground WinEvent hook if CaptureFocus is set. If Thread is
+	set, the keyboard is captured instead with a WH_KEYBOARD hook on that
+	thread of this process only, for a program logging the keys of its own
+	windows; the watchdog and HookDump leave that hook alone. With a
+	Target, only the input into its windows is captured. Injected keys are
+	marked OnScreen while one of DefaultOnScreenKeyboards or
+	OnScreenKeyboards shows a window.
+	Mouse movement is thinned out by MoveSampling and the chatter of keys
+	removed by Debounce before they are delivered; Layout, if set, logs
+	the keys as another layout would have typed them.
+	Launches and exits of the executables named in WatchApps ("*" for all)
+	are polled every AppPollInterval, XInput controllers every
+	GamepadPollInterval if CaptureGamepad is set. Backpressure decides what
+	happens to events the consumer of Events is too slow for. If Watchdog is
+	set, the hooks are checked that often and installed again if Windows
+	has removed them. If LoopWatchdog is set, the message loop running the
+	hooks is pinged that often and the hooks are moved to a new thread if
+	it does not answer in time. Redact, if set, masks sensitive text before
+	the sinks see it, and Pseudonymize replaces typed characters in what the
+	sinks see. Starts, stops, filters and sinks are recorded in Audit if
+	set. If Trace is set, the events are recorded to it as the hooks saw
+	them, for Replay. If HookDump is set, every call of the keyboard hook is
+	written to it as a line of text, to debug keys that go missing. If
+	PauseWhenLocked is set, the hooks are removed while the workstation is
+	locked, as by Pause. If WatchSessions is set, the session is marked at
+	the start and when it connects or disconnects, with the hooks paused
+	while it is disconnected. Sensitive pauses them, or redacts the keys,
+	while the applications it names are in use. If WatchDevices is set,
+	keyboards being attached and removed are marked. If ReconstructText is
+	set, a TextEvent with the text each burst of typing left follows the key
+	events. Stop waits at most StopTimeout for the last events to reach the
+	sinks and the consumer of Events. SinkFailed, if set, is called with
+	every error a sink returns, and Panicked with the value of a panic of
+	the goroutine delivering the events before the panic goes on.
+
+	Events, Stats, Start, Stop and Replay may be called from any goroutine;
+	Start, Stop and Replay take turns. The settings and AddSink and
+	AddFilter must not be used while the Logger runs.
+*/
+type Logger struct {
+	CaptureKeyboard     bool
+	CaptureMouse        bool
+	CaptureFocus        bool
+	Thread              uint32
+	MoveSampling        MoveSampler
+	Debounce            KeyDebouncer
+	Layout              *LayoutMapper
+	WatchApps           []string
+	AppPollInterval     time.Duration
+	CaptureGamepad      bool
+	GamepadPollInterval time.Duration
+	Backpressure        Backpressure
+	Watchdog            time.Duration
+	LoopWatchdog        time.Duration
+	PauseWhenLocked     bool
+	WatchSessions       bool
+	WatchDevices        bool
+	Sensitive           SensitiveApps
+	Target              CaptureTarget
+	OnScreenKeyboards   []string
+	ReconstructText     bool
+	Redact              *Redactor
+	Pseudonymize        *Pseudonymizer
+	Audit               *AuditLog
+	Trace               io.Writer
+	HookDump            io.Writer
+	StopTimeout         time.Duration
+	SinkFailed          func(s Sink, err error)
+	Panicked            func(v interface{})
+
+	api      winapi
+	ring     *eventRing
+	hookTime *latencyRecorder
+	side     chan Event
+	sinks    []Sink
+	pollers  sync.WaitGroup
+
+	runMu   sync.Mutex // held by Start, Stop and Replay
+	running bool
+
+	mu         sync.Mutex // guards the fields below; out is only replaced under runMu
+	events     chan Event
+	out        *output
+	subscribed bool
+
+	filters       []KeyFilter
+	translator    *translator
+	text          *TextReconstructor
+	clicks        doubleClicks
+	onScreen      *onScreenKeyboards
+	trace         *traceWriter
+	dumps         *hookCalls
+	dump          *hookDumpWriter
+	keyboardCB    HOOKPROC
+	threadKeyCB   HOOKPROC
+	mouseCB       HOOKPROC
+	watch         hookWatch
+	threadMu      sync.Mutex
+	thread        *hookThread
+	stopping      bool
+	pauseMu       sync.Mutex // held while pauses change and a thread installs its hooks
+	pauses        uint32
+	sensitive     int32
+	offTarget     int32
+	awayDesktop   int32
+	elevated      bool // of this process, for the worker
+	elevatedFocus bool
+	abandoned     int32
+	done          chan struct{}
+}
+
+/*
+	NewLogger returns a Logger capturing the keyboard, not yet started.
+*/
+func NewLogger() *Logger {
+	return &Logger{
+		CaptureKeyboard: true,
+		api:             realAPI{},
+		events:          make(chan Event, 1024),
+		ring:            newEventRing(4096),
+		hookTime:        new(latencyRecorder),
+		side:            make(chan Event, 64),
+	}
+}
+
+/*
+	Events returns the channel all captured events are delivered on, in the
+	order they happened. While the channel is full, Backpressure applies;
+	the hooks themselves never wait, but drop events once their own queue
+	is full as well. Dropped counts those.
+
+	A Logger with sinks only delivers on the channel once Events has been
+	called. Stop closes the channel; from then on, Events returns the
+	channel of the next run.
+*/
+func (l *Logger) Events() <-chan Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.subscribed = true
+	if l.out != nil {
+		l.out.subscribe()
+	}
+	return l.events
+}
+
+/*
+	AddSink adds a sink every event is written to, before it is delivered
+	on Events. Sinks are written from a single goroutine and closed by
+	Stop. It must be called before Start, and adds nothing if the sink
+	cannot be recorded in Audit.
+*/
+func (l *Logger) AddSink(s Sink) error {
+	if err := l.Audit.Record(AuditSinkAdded, fmt.Sprintf("%T", s)); err != nil {
+		return err
+	}
+	l.sinks = append(l.sinks, s)
+	return nil
+}
+
+/*
+	Dropped returns the number of events lost because the hooks or the
+	consumer of Events fell behind, or because Stop ran out of time.
+	Stats.Dropped breaks it down.
+*/
+func (l *Logger) Dropped() uint64 {
+	return l.Stats().Dropped.Total()
+}
+
+/*
+	Stats returns counters for sizing the Logger's queues; see Stats.
+*/
+func (l *Logger) Stats() Stats {
+	s := Stats{
+		Dropped:       DropStats{Ring: l.ring.droppedCount()},
+		RingSize:      len(l.ring.buf),
+		RingQueued:    l.ring.len(),
+		RingHighWater: l.ring.highWater(),
+		HookLatency:   l.hookTime.snapshot(),
+		Hooks: HookStats{
+			Reinstalled: uint64(atomic.LoadUint32(&l.watch.reinstalled)),
+			Lost:        uint64(atomic.LoadUint32(&l.watch.lost)),
+		},
+		KeyChatter: l.Debounce.Stats().Filtered,
+	}
+	if out := l.output(); out != nil {
+		out.stats(&s)
+	}
+	return s
+}
+
+/*
+	SinkErrors returns the number of failed sink writes.
+*/
+func (l *Logger) SinkErrors() uint64 {
+	return l.Stats().SinkErrors
+}
+
+/*
+	BackpressureStats reports what the Backpressure policy did since Start.
+*/
+func (l *Logger) BackpressureStats() BackpressureStats {
+	return l.Stats().Backpressure
+}
+
+/*
+	Returns the delivery side of the current or last run, nil before the
+	first.
+*/
+func (l *Logger) output() *output {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.out
+}
+
+/*
+	MovementStats returns the mouse distance travelled and move counts.
+*/
+func (l *Logger) MovementStats() MovementStats {
+	return l.MoveSampling.Stats()
+}
+
+/*
+	ChatterStats returns the key chatter Debounce removed.
+*/
+func (l *Logger) ChatterStats() ChatterStats {
+	return l.Debounce.Stats()
+}
+
+/*
+	AddFilter adds a filter run on every key event. It must be called before Start.
+	Filters run on the hook thread and hold up all keyboard input on the
+	desktop while they do, so they must not block. It adds nothing if the
+	filter cannot be recorded in Audit.
+*/
+func (l *Logger) AddFilter(f KeyFilter) error {
+	if err := l.Audit.Record(AuditFilterAdded, runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()); err != nil {
+		return err
+	}
+	l.filters = append(l.filters, f)
+	return nil
+}
+
+/*
+	Start installs the hook on a dedicated OS thread running the message loop
+	and returns once the hook is in place. It fails without capturing
+	anything if the start cannot be recorded in Audit, or if the Logger is
+	already running. A start that fails after it was recorded is recorded
+	as failed.
+*/
+func (l *Logger) Start() error {
+	l.runMu.Lock()
+	defer l.runMu.Unlock()
+	if l.running {
+		return errLoggerRunning
+	}
+	if err := l.Audit.Record(AuditStart, l.describe()); err != nil {
+		return err
+	}
+	if l.CaptureGamepad {
+		if err := xinput.Load(); err != nil {
+			return l.startFailed(err)
+		}
+	}
+	if l.Thread != 0 {
+		if err := checkThread(l.Thread); err != nil {
+			return l.startFailed(err)
+		}
+	}
+	l.awaitLastRun()
+	l.dumps = nil
+	if l.HookDump != nil {
+		l.dumps = &hookCalls{calls: make(chan hookCall, 1024)}
+	}
+	l.done = make(chan struct{})
+	l.threadMu.Lock()
+	l.stopping = false
+	l.threadMu.Unlock()
+	l.pauseMu.Lock()
+	l.pauses &^= pauseLocked | pauseSensitive | pauseDisconnected
+	l.pauseMu.Unlock()
+	atomic.StoreInt32(&l.sensitive, 0)
+	atomic.StoreInt32(&l.awayDesktop, 0)
+	atomic.StoreInt32(&l.offTarget, 0)
+	l.elevated, l.elevatedFocus = Elevated(), false
+	l.onScreen = newOnScreenKeyboards(l.OnScreenKeyboards)
+	if err := l.startThread(); err != nil {
+		return l.startFailed(err)
+	}
+	// The hooks queue their events until the worker starts.
+	l.begin()
+	polls := []func(stop <-chan struct{}){}
+	if l.Watchdog > 0 {
+		polls = append(polls, l.watchdog)
+	}
+	if l.LoopWatchdog > 0 {
+		polls = append(polls, l.superviseLoop)
+	}
+	if l.PauseWhenLocked || l.WatchSessions {
+		polls = append(polls, l.watchSession)
+	}
+	if l.WatchDevices {
+		polls = append(polls, l.watchDevices)
+	}
+	if len(l.Sensitive.Apps) > 0 && l.Sensitive.Running {
+		polls = append(polls, l.watchSensitive)
+	}
+	if len(l.WatchApps) > 0 {
+		polls = append(polls, l.watchApps)
+	}
+	if l.CaptureGamepad {
+		polls = append(polls, l.pollGamepads)
+	}
+	for _, poll := range polls {
+		l.pollers.Add(1)
+		go func(poll func(<-chan struct{})) {
+			defer l.pollers.Done()
+			poll(l.done)
+		}(poll)
+	}
+	l.running = true
+	go l.work(l.done)
+	return nil
+}
+
+var errLoggerRunning = errors.New("logger: already running")
+
+/*
+	Records in Audit that the start recorded before failed with err, and
+	returns err.
+*/
+func (l *Logger) startFailed(err error) error {
+	if rerr := l.Audit.Record(AuditStartFailed, err.Error()); rerr != nil {
+		return fmt.Errorf("%w (recording the failed start: %v)", err, rerr)
+	}
+	return err
+}
+
+/*
+	Waits for the worker of the last run, which may still be closing the
+	sinks after a Stop that ran out of time. Called under runMu, like begin
+	and end.
+*/
+func (l *Logger) awaitLastRun() {
+	if l.out != nil {
+		<-l.out.finished
+	}
+}
+
+/*
+	Sets up the delivery of a run.
+*/
+func (l *Logger) begin() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out = newOutput(l.events, l.Backpressure, l.sinks, l.Redact, l.Pseudonymize, l.subscribed || len(l.sinks) == 0)
+	l.out.sinkFailed = l.SinkFailed
+}
+
+/*
+	Ends a run whose output has been finished or abandoned: the next run
+	delivers on a new channel, which Events returns from now on.
+*/
+func (l *Logger) end() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = make(chan Event, cap(l.events))
+}
+
+/*
+	Stop removes the hooks and waits until the pending events have been
+	written to the sinks and delivered on Events, for at most StopTimeout
+	(DefaultStopTimeout if zero). It returns the error of StopContext.
+*/
+func (l *Logger) Stop() error {
+	timeout := l.StopTimeout
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return l.StopContext(ctx)
+}
+
+/*
+	StopContext removes the hooks and waits until the pending events have
+	been written to the sinks, the sinks closed and the events delivered on
+	Events, which is then closed. If ctx is done first, the remaining events
+	are dropped and counted by Dropped, and the error says how many. The
+	stop is recorded in Audit, and an error recording it is returned if
+	there is no other. It does nothing if the Logger is not running.
+*/
+func (l *Logger) StopContext(ctx context.Context) error {
+	l.runMu.Lock()
+	defer l.runMu.Unlock()
+	if !l.running {
+		return nil
+	}
+	l.running = false
+	l.threadMu.Lock()
+	l.stopping = true
+	t := l.thread
+	l.threadMu.Unlock()
+	l.api.PostMessage(t.id, WM_QUIT, 0, 0)
+	<-l.done
+	l.pollers.Wait()
+	err := l.out.wait(ctx, func() int {
+		return l.ring.len() + len(l.side) + l.out.queue.spilled()
+	})
+	l.end()
+	detail := ""
+	if err != nil {
+		detail = err.Error()
+	}
+	if rerr := l.Audit.Record(AuditStop, detail); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+/*
+	Lists what the Logger captures, for the audit log.
+*/
+func (l *Logger) describe() string {
+	var what []string
+	for _, c := range []struct {
+		on   bool
+		name string
+	}{
+		{l.CaptureKeyboard, "keyboard"},
+		{l.CaptureMouse, "mouse"},
+		{l.CaptureFocus, "focus"},
+		{l.Thread != 0, fmt.Sprintf("thread %d", l.Thread)},
+		{l.Target.set(), "target"},
+		{l.CaptureGamepad, "gamepad"},
+		{len(l.WatchApps) > 0, "apps " + strings.Join(l.WatchApps, ",")},
+		{l.Redact != nil, "redacted"},
+		{l.Pseudonymize != nil, "pseudonymized"},
+		{l.Trace != nil, "trace"},
+		{l.HookDump != nil, "hook dump"},
+	} {
+		if c.on {
+			what = append(what, c.name)
+		}
+	}
+	return strings.Join(what, " ")
+}
+
+/*
+	The OS thread that owns the hooks and pumps their messages. A thread
+	whose message loop stalls is abandoned for a new one; see LoopWatchdog.
+*/
+type hookThread struct {
+	id        uint32
+	keyboard  HHOOK
+	mouse     HHOOK
+	focus     HANDLE
+	desktop   HANDLE
+	pongs     uint32
+	abandoned uint32
+}
+
+/*
+	Starts a hook thread, which becomes the current one once its hooks are
+	in place.
+*/
+func (l *Logger) startThread() error {
+	errc := make(chan error, 1)
+	go l.run(&hookThread{}, errc)
+	return <-errc
+}
+
+/*
+	Returns the hook thread that receives the Logger's messages.
+*/
+func (l *Logger) current() *hookThread {
+	l.threadMu.Lock()
+	defer l.threadMu.Unlock()
+	return l.thread
+}
+
+/*
+	Reports whether a hook callback runs on the current hook thread rather
+	than on an abandoned one that has come back to life. Only asked while a
+	thread is abandoned, so the hooks do not pay for it otherwise.
+*/
+func (l *Logger) onCurrentThread() bool {
+	return atomic.LoadInt32(&l.abandoned) == 0 || l.current().id == windows.GetCurrentThreadId()
+}
+
+/*
+	Pushes e from the hook thread, unless it is an abandoned one, and
+	reports whether e was queued and whether the thread is current. The
+	ring has one producer: while a thread is abandoned, the check and the
+	push are made together under threadMu, so a thread that comes back to
+	life cannot push alongside its replacement. Nothing between the check
+	of abandoned and the push can stall, so no thread is abandoned there.
+*/
+func (l *Logger) pushCurrent(e *rawEvent) (queued, current bool) {
+	if atomic.LoadInt32(&l.abandoned) == 0 {
+		return l.ring.push(e), true
+	}
+	l.threadMu.Lock()
+	defer l.threadMu.Unlock()
+	if l.thread.id != windows.GetCurrentThreadId() {
+		return false, false
+	}
+	return l.ring.push(e), true
+}
+
+func (l *Logger) run(t *hookThread, errc chan<- error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer func() {
+		l.threadMu.Lock()
+		if l.thread == t {
+			close(l.done)
+		}
+		l.threadMu.Unlock()
+		if atomic.LoadUint32(&t.abandoned) != 0 {
+			atomic.AddInt32(&l.abandoned, -1)
+		}
+	}()
+
+	t.id = windows.GetCurrentThreadId()
+	defer l.unhook(t)
+	// The Target follows the foreground as well.
+	if l.CaptureFocus || l.Target.set() {
+		t.focus = SetWinEventHook(EVENT_SYSTEM_FOREGROUND, EVENT_SYSTEM_FOREGROUND, 0, l.focusProc, 0, 0, WINEVENT_OUTOFCONTEXT)
+		if t.focus == 0 {
+			errc <- errors.New("SetWinEventHook(EVENT_SYSTEM_FOREGROUND) failed")
+			return
+		}
+		defer UnhookWinEvent(t.focus)
+		l.targetFocus(GetForegroundWindow())
+	}
+	// Not fatal: without it, only the gaps in the capture go unmarked.
+	t.desktop = SetWinEventHook(EVENT_SYSTEM_DESKTOPSWITCH, EVENT_SYSTEM_DESKTOPSWITCH, 0, l.desktopProc, 0, 0, WINEVENT_OUTOFCONTEXT)
+	if t.desktop != 0 {
+		defer UnhookWinEvent(t.desktop)
+	}
+	l.pauseMu.Lock()
+	if l.pauses == 0 {
+		if err := l.installHooks(t); err != nil {
+			l.pauseMu.Unlock()
+			errc <- err
+			return
+		}
+	}
+	l.threadMu.Lock()
+	l.thread = t
+	stopping := l.stopping
+	l.threadMu.Unlock()
+	l.pauseMu.Unlock()
+	if stopping {
+		// Replaced a stalled thread while Stop was waiting for it.
+		errc <- nil
+		return
+	}
+	// Only once t is current, as the thread it replaces may still push.
+	if l.CaptureFocus {
+		l.pushCurrent(&rawEvent{kind: rawFocus, focus: FocusEvent{HWND: uintptr(GetForegroundWindow()), Time: time.Now()}})
+	}
+	errc <- nil
+
+	var msg MSG
+	for l.api.GetMessage(&msg) > 0 {
+		switch msg.Message {
+		case wmReinstallHook:
+			if atomic.LoadUint32(&t.abandoned) == 0 {
+				l.reinstall(t, int(msg.WParam))
+			}
+		case wmPing:
+			atomic.AddUint32(&t.pongs, 1)
+		case wmThreadKey:
+			l.pushCurrent(&rawEvent{kind: rawKey, key: threadKeyEvent(WPARAM(msg.WParam), LPARAM(msg.LParam))})
+		case wmPauseHooks, wmResumeHooks:
+			if atomic.LoadUint32(&t.abandoned) == 0 {
+				l.pauseHooks(t, uint32(msg.WParam), msg.Message == wmPauseHooks)
+			}
+		}
+	}
+}
+
+/*
+	Removes the low-level hooks of t. It may be called from any thread, so
+	that the hooks of a stalled thread stop holding up input; the handles
+	are cleared so the thread does not remove them again, once their
+	values may belong to another hook.
+*/
+func (l *Logger) unhook(t *hookThread) {
+	l.threadMu.Lock()
+	defer l.threadMu.Unlock()
+	if t.keyboard != 0 {
+		l.api.Unhook(t.keyboard)
+		t.keyboard = 0
+	}
+	if t.mouse != 0 {
+		l.api.Unhook(t.mouse)
+		t.mouse = 0
+	}
+}
+
+/*
+	Installs the low-level hooks the Logger captures with.
+*/
+func (l *Logger) installHooks(t *hookThread) error {
+	if l.CaptureKeyboard {
+		if err := l.installKeyboard(t); err != nil {
+			return err
+		}
+	}
+	if l.CaptureMouse {
+		if err := l.installMouse(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+	The hook procedures are created once, so reinstalling a hook does not
+	allocate another callback; Windows limits how many a process can have.
+*/
+func (l *Logger) installKeyboard(t *hookThread) error {
+	if l.Thread != 0 {
+		return l.installThreadKeyboard(t)
+	}
+	if l.keyboardCB == nil {
+		l.keyboardCB = l.keyboardProc
+	}
+	t.keyboard = l.api.SetHook(WH_KEYBOARD_LL, l.keyboardCB, 0)
+	if t.keyboard == 0 {
+		return errors.New("SetWindowsHookEx(WH_KEYBOARD_LL) failed")
+	}
+	return nil
+}
+
+func (l *Logger) installMouse(t *hookThread) error {
+	if l.mouseCB == nil {
+		l.mouseCB = l.mouseProc
+	}
+	t.mouse = l.api.SetHook(WH_MOUSE_LL, l.mouseCB, 0)
+	if t.mouse == 0 {
+		return errors.New("SetWindowsHookEx(WH_MOUSE_LL) failed")
+	}
+	return nil
+}
+
+func (l *Logger) keyboardProc(nCode int, wparam WPARAM, lparam LPARAM) LRESULT {
+	if l.dumps != nil {
+		return l.dumpKeyboard(nCode, wparam, lparam)
+	}
+	return l.hookKey(nCode, wparam, lparam, nil)
+}
+
+/*
+	Handles a call of the keyboard hook; if c is not nil, it is filled in
+	for the hook dump.
+*/
+func (l *Logger) hookKey(nCode int, wparam WPARAM, lparam LPARAM, c *hookCall) LRESULT {
+	if nCode == HC_ACTION {
+		switch wparam {
+		case WM_KEYDOWN, WM_SYSKEYDOWN, WM_KEYUP, WM_SYSKEYUP:
+			kbdstruct := *(**KBDLLHOOKSTRUCT)(unsafe.Pointer(&lparam))
+			if c != nil {
+				c.kbd = *kbdstruct
+			}
+			if l.watch.sawKey(kbdstruct) {
+				if c != nil {
+					c.fate = fateProbe
+				}
+				return 1
+			}
+			if !l.onTarget() {
+				if c != nil {
+					c.fate = fateOffTarget
+				}
+				break
+			}
+			e := KeyEvent{
+				VkCode:    uint16(kbdstruct.VkCode),
+				ScanCode:  uint32(kbdstruct.ScanCode),
+				Flags:     uint32(kbdstruct.Flags),
+				ExtraInfo: kbdstruct.DwExtraInfo,
+				Down:      wparam == WM_KEYDOWN || wparam == WM_SYSKEYDOWN,
+				Time:      time.Now(),
+			}
+			e.Swallowed = l.filter(e)
+			queued, current := l.pushCurrent(&rawEvent{kind: rawKey, key: e})
+			if !current {
+				if c != nil {
+					c.fate = fateStale
+				}
+				break
+			}
+			l.hookTime.observe(time.Since(e.Time))
+			if c != nil {
+				switch {
+				case !queued:
+					c.fate = fateDropped
+				case e.Swallowed:
+					c.fate = fateSwallowed
+				default:
+					c.fate = fateQueued
+				}
+			}
+			if e.Swallowed {
+				return 1
+			}
+		}
+	}
+	return l.api.CallNext(nCode, wparam, lparam)
+}
+
+/*
+	Reports whether a filter swallows e.
+*/
+func (l *Logger) filter(e KeyEvent) bool {
+	for _, f := range l.filters {
+		if f(e) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Enriches the events queued by the hooks and delivers them until stop is
+	closed and the queue is drained.
+*/
+func (l *Logger) work(stop <-chan struct{}) {
+	defer l.panicked()
+	l.translator = newTranslator(l.api)
+	if l.Layout != nil {
+		l.Layout.CapsLock = l.translator.caps
+	}
+	l.clicks = newDoubleClicks()
+	l.startTrace()
+	l.startHookDump()
+	var dumps <-chan hookCall
+	if l.dumps != nil {
+		dumps = l.dumps.calls
+	}
+	var expire <-chan time.Time
+	if l.Redact != nil && l.Redact.MaxHold > 0 {
+		t := time.NewTicker(l.Redact.MaxHold / 4)
+		defer t.Stop()
+		expire = t.C
+	}
+	l.startText()
+	var bursts <-chan time.Time
+	if l.text != nil {
+		t := time.NewTicker(textBurstPause / 4)
+		defer t.Stop()
+		bursts = t.C
+	}
+	var raw rawEvent
+	for {
+		for l.ring.pop(&raw) {
+			l.deliver(&raw)
+		}
+		select {
+		case <-l.ring.wake:
+		case now := <-expire:
+			l.out.expire(now)
+		case now := <-bursts:
+			l.emitText(l.text.Expire(now))
+		case e := <-l.side:
+			l.record(e)
+			l.out.emit(e)
+		case c := <-dumps:
+			l.writeHookDump(c)
+		case <-stop:
+			// The hooks are gone; the pollers may still be sending.
+			l.pollers.Wait()
+			for l.ring.pop(&raw) {
+				l.deliver(&raw)
+			}
+			for len(l.side) > 0 {
+				e := <-l.side
+				l.record(e)
+				l.out.emit(e)
+			}
+			l.endText()
+			l.endTrace()
+			if len(dumps) > 0 {
+				l.writeHookDump(<-dumps)
+			}
+			// Wiped before finishing, after which the next run may start.
+			l.ring.wipe()
+			raw = rawEvent{}
+			*l.translator = translator{}
+			l.out.finish()
+			return
+		}
+	}
+}
+
+/*
+	Hands a panic of the worker to Panicked and panics on.
+*/
+func (l *Logger) panicked() {
+	if l.Panicked == nil {
+		return
+	}
+	if v := recover(); v != nil {
+		l.Panicked(v)
+		panic(v)
+	}
+}
+
+func (l *Logger) startText() {
+	l.text = nil
+	if l.ReconstructText {
+		l.text = &TextReconstructor{}
+	}
+}
+
+func (l *Logger) endText() {
+	if l.text != nil {
+		l.emitText(l.text.Flush())
+	}
+}
+
+/*
+	Delivers the TextEvent of an ended burst. It is not traced: Replay
+	makes it again from the keys.
+*/
+func (l *Logger) emitText(t TextEvent, ended bool) {
+	if ended {
+		l.out.emit(t)
+	}
+}
+
+/*
+	Feeds a delivered event to the TextReconstructor, if there is one.
+*/
+func (l *Logger) reconstruct(e Event) {
+	if l.text != nil {
+		l.emitText(l.text.Feed(e))
+	}
+}
+
+/*
+	Hands an event from a poller to the worker, giving up when stop is closed.
+*/
+func (l *Logger) emitSide(e Event, stop <-chan struct{}) {
+	select {
+	case l.side <- e:
+	case <-stop:
+	}
+}
+
+func (l *Logger) deliver(raw *rawEvent) {
+	if (raw.kind == rawKey || raw.kind == rawMouse) && l.sensitivePaused() {
+		return
+	}
+	switch raw.kind {
+	case rawKey:
+		e := raw.key
+		if l.onScreen != nil && e.Injected() && !e.FromInjector() {
+			e.OnScreen = l.onScreen.visible()
+		}
+		if !l.Debounce.Keep(e) {
+			l.record(e)
+			return
+		}
+		if !e.Swallowed {
+			e.Text = l.translator.translate(e)
+		}
+		if l.Layout != nil {
+			e = l.Layout.Map(e)
+		}
+		if l.sensitiveRedacted() {
+			e = hideKey(e)
+		}
+		l.record(e)
+		l.out.emit(e)
+		l.reconstruct(e)
+	case rawMouse:
+		e := raw.mouse
+		l.record(e)
+		if l.MoveSampling.Sample(e) {
+			l.clicks.check(&e)
+			l.out.emit(e)
+		}
+		l.reconstruct(e)
+	case rawFocus:
+		e := newFocusEvent(HWND(raw.focus.HWND), raw.focus.Time)
+		l.sensitiveFocus(&e)
+		l.record(e)
+		l.out.emit(e)
+		l.reconstruct(e)
+		if d, ok := l.elevationFocus(e); ok {
+			l.record(d)
+			l.out.emit(d)
+		}
+	case rawDiagnostic:
+		l.record(raw.diag)
+		l.out.emit(raw.diag)
+	}
+}
+
+func (l *Logger) startTrace() {
+	l.trace = nil
+	if l.Trace != nil {
+		l.trace = newTraceWriter(l.Trace, l.translator.caps, l.clicks)
+	}
+}
+
+/*
+	Adds e to the trace, if one is written, and reports when that fails.
+*/
+func (l *Logger) record(e Event) {
+	if l.trace.write(e) {
+		l.out.emit(DiagnosticEvent{Kind: DiagTraceFailed, Message: fmt.Sprintf("trace: %v", l.trace.err), Time: time.Now()})
+	}
+}
+
+func (l *Logger) endTrace() {
+	if l.trace == nil || l.trace.err != nil {
+		return
+	}
+	if err := l.trace.flush(); err != nil {
+		l.out.emit(DiagnosticEvent{Kind: DiagTraceFailed, Message: fmt.Sprintf("trace: %v", err), Time: time.Now()})
+	}
+}
+
+/*
+	MessageLoop is necessary for WH_KEYBOARD_LL
+*/
+func MessageLoop() {
+	var msg MSG
+	for GetMessage(&msg, 0, 0, 0) != 0 {
+	}
+}
+
+/*
+	Installs an application-defined hook procedure into a hook chain.
+	You would install a hook procedure to monitor the system for certain types of events.
+	These events are associated either with a specific thread or with all threads in the same desktop as the calling thread.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-setwindowshookexa
+*/
+func SetWindowsHookExA(idHook int, lpfn HOOKPROC, hMod HINSTANCE, dwThreadId DWORD) HHOOK {
+	ret, _, _ := setWindowsHookExA.Call(
+		uintptr(idHook),
+		syscall.NewCallback(lpfn),
+		uintptr(hMod),
+		uintptr(dwThreadId),
+	)
+	return HHOOK(ret)
+}
+
+/*
+	Passes the hook information to the next hook procedure in the current hook chain.
+	A hook procedure can call this function either before or after processing the hook information.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-callnexthookex
+*/
+func CallNextHookEx(hhk HHOOK, nCode int, wParam WPARAM, lParam LPARAM) LRESULT {
+	// Called for every event: unlike Proc.Call, Syscall6 does not allocate.
+	ret, _, _ := syscall.Syscall6(callNextHookEx.Addr(), 4,
+		uintptr(hhk),
+		uintptr(nCode),
+		uintptr(wParam),
+		uintptr(lParam),
+		0, 0,
+	)
+	return LRESULT(ret)
+}
+
+/*
+	Retrieves a message from the calling thread's message queue.
+	The function dispatches incoming sent messages until a posted message is available for retrieval.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getmessage
+*/
+func GetMessage(msg *MSG, hwnd HWND, msgFilterMin uint32, msgFilterMax uint32) int {
+	ret, _, _ := getMessageW.Call(
+		uintptr(unsafe.Pointer(msg)),
+		uintptr(hwnd),
+		uintptr(msgFilterMin),
+		uintptr(msgFilterMax))
+	return int(ret)
+}
+
+/*
+	Removes a hook procedure installed in a hook chain by the SetWindowsHookEx function.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-unhookwindowshookex
+*/
+func UnhookWindowsHookEx(hhk HHOOK) bool {
+	ret, _, _ := unhookWindowsHookEx.Call(
+		uintptr(hhk),
+	)
+	return ret != 0
+}
+
+/*
+	Places a message in the message queue of the specified thread and returns without waiting.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-postthreadmessagew
+*/
+func PostThreadMessage(idThread uint32, msg uint32, wParam WPARAM, lParam LPARAM) bool {
+	ret, _, _ := postThreadMessageW.Call(
+		uintptr(idThread),
+		uintptr(msg),
+		uintptr(wParam),
+		uintptr(lParam),
+	)
+	return ret != 0
+}
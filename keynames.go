@@ -0,0 +1,115 @@
+package keylogger
+
+//go:generate go run gen_keys.go
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	keyNames  = make(map[uint16]string)
+	keyByName = make(map[string]uint16)
+)
+
+func init() {
+	for _, k := range keyNameList {
+		keyNames[k.vk] = k.names[0]
+		for _, n := range k.names {
+			keyByName[strings.ToLower(n)] = k.vk
+		}
+	}
+}
+
+/*
+	KeyName returns the name of a virtual key, or its hex code if it has none.
+*/
+func KeyName(vk uint16) string {
+	if n, ok := keyNames[vk]; ok {
+		return n
+	}
+	return fmt.Sprintf("0x%02X", vk)
+}
+
+/*
+	ParseKey returns the virtual key for a name accepted by KeyName, case
+	insensitively. Hex codes such as "0x41" are accepted as well.
+*/
+func ParseKey(name string) (uint16, error) {
+	if vk, ok := keyByName[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return vk, nil
+	}
+	var vk uint16
+	if _, err := fmt.Sscanf(name, "0x%x", &vk); err == nil && vk > 0 && vk < 0xFF {
+		return vk, nil
+	}
+	return 0, fmt.Errorf("unknown key %q", name)
+}
+
+/*
+	VirtualKey is a virtual-key code that reads and writes as its name, for
+	configuration files and reports to name keys by: in JSON, "Ctrl" rather
+	than 17. Codes without a name are written in hex, which parses back to
+	the same code for every key from 0x01 to 0xFE. KeyEvent keeps VkCode a number, as logs are written.
+*/
+type VirtualKey uint16
+
+/*
+	ParseVirtualKey returns the key named name, as ParseKey does.
+*/
+func ParseVirtualKey(name string) (VirtualKey, error) {
+	vk, err := ParseKey(name)
+	return VirtualKey(vk), err
+}
+
+/*
+	String returns the name of the key, as KeyName does.
+*/
+func (k VirtualKey) String() string {
+	return KeyName(uint16(k))
+}
+
+func (k VirtualKey) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *VirtualKey) UnmarshalText(text []byte) error {
+	vk, err := ParseVirtualKey(string(text))
+	if err != nil {
+		return err
+	}
+	*k = vk
+	return nil
+}
+
+/*
+	Key returns the virtual key of the event.
+*/
+func (e KeyEvent) Key() VirtualKey {
+	return VirtualKey(e.VkCode)
+}
+
+/*
+	KeyScanCode returns the scan code of set 1 that a US keyboard sends for
+	a virtual key, with 0xE0 or 0xE1 in the high byte for extended keys, or
+	0 if the key has none.
+*/
+func KeyScanCode(vk uint16) uint16 {
+	if vk > 0xFF {
+		return 0
+	}
+	return scanCodes[vk]
+}
+
+/*
+	KeyHIDUsage returns the USB HID usage of a virtual key as usage page in
+	the high 16 bits and usage ID in the low 16, such as 0x070004 for A on
+	the keyboard page or 0x0C00E9 for VolumeUp on the consumer page, or 0
+	if it has none.
+*/
+func KeyHIDUsage(vk uint16) uint32 {
+	if vk > 0xFF {
+		return 0
+	}
+	return hidUsages[vk]
+}
@@ -0,0 +1,49 @@
+package keylogger
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestVirtualKeyRoundTrip(t *testing.T) {
+	for vk := VirtualKey(1); vk < 0xFF; vk++ {
+		text, err := vk.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got VirtualKey
+		if err := got.UnmarshalText(text); err != nil || got != vk {
+			t.Errorf("%#x: %q parses as %#x, %v", uint16(vk), text, uint16(got), err)
+		}
+	}
+
+	var keys struct {
+		Keys []VirtualKey `json:"keys"`
+	}
+	if err := json.Unmarshal([]byte(`{"keys": ["Ctrl", "a", "0x0E"]}`), &keys); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(keys)
+	if string(data) != `{"keys":["Ctrl","A","0x0E"]}` {
+		t.Errorf("Marshal = %s", data)
+	}
+	if _, err := ParseVirtualKey("Hyper"); err == nil {
+		t.Error("ParseVirtualKey accepted an unknown key")
+	}
+}
+
+func TestKeyNamer(t *testing.T) {
+	names, err := KeyNamer("de_DE.UTF-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := (Hotkey{Mods: ModCtrl | ModShift, VkCode: VK_DELETE}).format(names); got != "Strg+Umschalt+Entf" {
+		t.Errorf("format = %q", got)
+	}
+	if got := names('A'); got != "A" {
+		t.Errorf("names('A') = %q", got)
+	}
+	if _, err := KeyNamer("tlh"); err == nil {
+		t.Error("KeyNamer accepted a language without names")
+	}
+}
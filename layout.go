@@ -0,0 +1,148 @@
+package keylogger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+/*
+	The scan codes of the keys of the main block, in the order of the rows
+	of a layout: digits, top, home and bottom row, left to right.
+*/
+var layoutScanCodes = [4][]uint32{
+	{0x29, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D},
+	{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1A, 0x1B, 0x2B},
+	{0x1E, 0x1F, 0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28},
+	{0x2C, 0x2D, 0x2E, 0x2F, 0x30, 0x31, 0x32, 0x33, 0x34, 0x35},
+}
+
+/*
+	The virtual keys of the US layout typing the punctuation of layouts.
+*/
+var punctuationKeys = map[rune]uint16{
+	'`': VK_OEM_3, '-': VK_OEM_MINUS, '=': VK_OEM_PLUS, '[': VK_OEM_4, ']': VK_OEM_6, '\\': VK_OEM_5,
+	';': VK_OEM_1, '\'': VK_OEM_7, ',': VK_OEM_COMMA, '.': VK_OEM_PERIOD, '/': VK_OEM_2,
+}
+
+/*
+	LogicalLayouts are the layouts a LayoutMapper can reinterpret keys
+	through, each as its rows unshifted and shifted. qwerty is the US
+	layout, which names every key by its position.
+*/
+var LogicalLayouts = map[string][4][2]string{
+	"qwerty": {
+		{"`1234567890-=", "~!@#$%^&*()_+"},
+		{`qwertyuiop[]\`, "QWERTYUIOP{}|"},
+		{"asdfghjkl;'", `ASDFGHJKL:"`},
+		{"zxcvbnm,./", "ZXCVBNM<>?"},
+	},
+	"dvorak": {
+		{"`1234567890[]", "~!@#$%^&*(){}"},
+		{`',.pyfgcrl/=\`, `"<>PYFGCRL?+|`},
+		{"aoeuidhtns-", "AOEUIDHTNS_"},
+		{";qjkxbmwvz", ":QJKXBMWVZ"},
+	},
+	"colemak": {
+		{"`1234567890-=", "~!@#$%^&*()_+"},
+		{`qwfpgjluy;[]\`, "QWFPGJLUY:{}|"},
+		{"arstdhneio'", `ARSTDHNEIO"`},
+		{"zxcvbkm,./", "ZXCVBKM<>?"},
+	},
+	"workman": {
+		{"`1234567890-=", "~!@#$%^&*()_+"},
+		{`qdrwbjfup;[]\`, "QDRWBJFUP:{}|"},
+		{"ashtgyneoi'", `ASHTGYNEOI"`},
+		{"zxmcvkl,./", "ZXMCVKL<>?"},
+	},
+}
+
+/*
+	LayoutNames returns the names of LogicalLayouts, sorted.
+*/
+func LayoutNames() []string {
+	var names []string
+	for name := range LogicalLayouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type layoutKey struct {
+	vk           uint16
+	lower, upper string
+}
+
+/*
+	LayoutMapper reinterprets keys by where they are on the keyboard, as
+	told by their scan code, whatever the layout Windows used: with
+	dvorak, the key left of the right Shift is logged as Z and types z,
+	the key where QWERTY has Q as an apostrophe. Key events of the main
+	block get the virtual key and the text the key has in the layout;
+	text is only replaced where the key typed some, so shortcuts and
+	AltGr characters keep theirs, and shifted and Caps Lock follow the
+	modifiers held. The qwerty layout normalizes every layout to
+	positions, for layout research: its keys are the US ones, whose
+	KeyHIDUsage is the USB position.
+
+	Injected keys, Unicode input and keys outside the main block pass
+	unchanged. Map is not safe for concurrent use.
+*/
+type LayoutMapper struct {
+	Name     string
+	CapsLock bool // whether Caps Lock is on, toggled by the keys mapped
+
+	keys map[uint32]layoutKey
+	mods ModifierState
+}
+
+/*
+	NewLayoutMapper returns a mapper for one of LogicalLayouts.
+*/
+func NewLayoutMapper(name string) (*LayoutMapper, error) {
+	layout, ok := LogicalLayouts[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown layout %q, not one of %s", name, strings.Join(LayoutNames(), ", "))
+	}
+	m := &LayoutMapper{Name: strings.ToLower(name), keys: make(map[uint32]layoutKey)}
+	for row, codes := range layoutScanCodes {
+		lower, upper := []rune(layout[row][0]), []rune(layout[row][1])
+		for i, sc := range codes {
+			c := lower[i]
+			vk, ok := punctuationKeys[c]
+			if !ok {
+				vk = uint16(unicode.ToUpper(c))
+			}
+			m.keys[sc] = layoutKey{vk: vk, lower: string(c), upper: string(upper[i])}
+		}
+	}
+	return m, nil
+}
+
+/*
+	Map returns e as typed in the layout.
+*/
+func (m *LayoutMapper) Map(e KeyEvent) KeyEvent {
+	mods := m.mods.Update(e)
+	if e.VkCode == VK_CAPITAL && e.Down {
+		m.CapsLock = !m.CapsLock
+	}
+	k, ok := m.keys[e.ScanCode]
+	if !ok || e.Injected() || e.VkCode == VK_PACKET || e.Flags&LLKHF_EXTENDED != 0 {
+		return e
+	}
+	e.VkCode = k.vk
+	if e.Text != "" && mods&(ModCtrl|ModAlt) == 0 {
+		shifted := mods&ModShift != 0
+		if m.CapsLock && unicode.IsLetter([]rune(k.lower)[0]) {
+			shifted = !shifted
+		}
+		e.Text = k.lower
+		if shifted {
+			e.Text = k.upper
+		}
+	}
+	return e
+}
@@ -0,0 +1,43 @@
+package keylogger
+
+import "testing"
+
+func TestLayoutMapper(t *testing.T) {
+	for _, name := range LayoutNames() {
+		for r, row := range LogicalLayouts[name] {
+			for _, keys := range row {
+				if n := len([]rune(keys)); n != len(layoutScanCodes[r]) {
+					t.Errorf("%s: row %d has %d keys, want %d", name, r, n, len(layoutScanCodes[r]))
+				}
+			}
+		}
+	}
+
+	m, err := NewLayoutMapper("dvorak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in       KeyEvent
+		vk       uint16
+		text     string
+		capsLock bool
+	}{
+		{KeyEvent{VkCode: 'Q', ScanCode: 0x10, Down: true, Text: "q"}, VK_OEM_7, "'", false},
+		{KeyEvent{VkCode: 'S', ScanCode: 0x1F, Down: true, Text: "s"}, 'O', "o", false},
+		{KeyEvent{VkCode: VK_LSHIFT, ScanCode: 0x2A, Down: true}, VK_LSHIFT, "", false},
+		{KeyEvent{VkCode: 'Z', ScanCode: 0x2C, Down: true, Text: "Z"}, VK_OEM_1, ":", false},
+		{KeyEvent{VkCode: VK_LSHIFT, ScanCode: 0x2A}, VK_LSHIFT, "", false},
+		{KeyEvent{VkCode: VK_CAPITAL, ScanCode: 0x3A, Down: true}, VK_CAPITAL, "", true},
+		{KeyEvent{VkCode: 'J', ScanCode: 0x24, Down: true, Text: "J"}, 'H', "H", true},
+		{KeyEvent{VkCode: VK_LCONTROL, ScanCode: 0x1D, Down: true}, VK_LCONTROL, "", true},
+		{KeyEvent{VkCode: 'C', ScanCode: 0x2E, Down: true}, 'J', "", true}, // Ctrl+C types nothing
+		{KeyEvent{VkCode: VK_PACKET, ScanCode: 0x10, Down: true, Text: "x", Flags: LLKHF_INJECTED}, VK_PACKET, "x", true},
+	}
+	for i, tt := range tests {
+		e := m.Map(tt.in)
+		if e.VkCode != tt.vk || e.Text != tt.text || m.CapsLock != tt.capsLock {
+			t.Errorf("%d: Map = %s %q, Caps Lock %v; want %s %q, %v", i, KeyName(e.VkCode), e.Text, m.CapsLock, KeyName(tt.vk), tt.text, tt.capsLock)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package keylogger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+	KeyNameTables name keys in other languages as their keyboards label
+	them, by language code. Keys a table leaves out, among them letters and
+	digits, keep their KeyName.
+*/
+var KeyNameTables = map[string]map[uint16]string{
+	"de": {
+		VK_BACK: "Rücktaste", VK_RETURN: "Eingabe", VK_SHIFT: "Umschalt", VK_CONTROL: "Strg",
+		VK_PAUSE: "Pause", VK_CAPITAL: "Feststell", VK_ESCAPE: "Esc", VK_SPACE: "Leertaste",
+		VK_PRIOR: "Bild auf", VK_NEXT: "Bild ab", VK_END: "Ende", VK_HOME: "Pos1",
+		VK_LEFT: "Links", VK_UP: "Oben", VK_RIGHT: "Rechts", VK_DOWN: "Unten",
+		VK_SNAPSHOT: "Druck", VK_INSERT: "Einfg", VK_DELETE: "Entf", VK_APPS: "Menü",
+		VK_NUMLOCK: "Num", VK_SCROLL: "Rollen", VK_LSHIFT: "Umschalt links", VK_RSHIFT: "Umschalt rechts",
+		VK_LCONTROL: "Strg links", VK_RCONTROL: "Strg rechts", VK_LMENU: "Alt", VK_RMENU: "Alt Gr",
+	},
+	"fr": {
+		VK_BACK: "Retour arrière", VK_RETURN: "Entrée", VK_SHIFT: "Maj", VK_CONTROL: "Ctrl",
+		VK_PAUSE: "Pause", VK_CAPITAL: "Verr Maj", VK_ESCAPE: "Échap", VK_SPACE: "Espace",
+		VK_PRIOR: "Pg préc", VK_NEXT: "Pg suiv", VK_END: "Fin", VK_HOME: "Origine",
+		VK_LEFT: "Gauche", VK_UP: "Haut", VK_RIGHT: "Droite", VK_DOWN: "Bas",
+		VK_SNAPSHOT: "Impr écran", VK_INSERT: "Inser", VK_DELETE: "Suppr", VK_APPS: "Menu",
+		VK_NUMLOCK: "Verr num", VK_SCROLL: "Arrêt défil", VK_LSHIFT: "Maj gauche", VK_RSHIFT: "Maj droite",
+		VK_LCONTROL: "Ctrl gauche", VK_RCONTROL: "Ctrl droite", VK_LMENU: "Alt", VK_RMENU: "Alt Gr",
+	},
+	"es": {
+		VK_BACK: "Retroceso", VK_RETURN: "Entrar", VK_SHIFT: "Mayús", VK_CONTROL: "Ctrl",
+		VK_PAUSE: "Pausa", VK_CAPITAL: "Bloq Mayús", VK_ESCAPE: "Esc", VK_SPACE: "Espacio",
+		VK_PRIOR: "Re Pág", VK_NEXT: "Av Pág", VK_END: "Fin", VK_HOME: "Inicio",
+		VK_LEFT: "Izquierda", VK_UP: "Arriba", VK_RIGHT: "Derecha", VK_DOWN: "Abajo",
+		VK_SNAPSHOT: "Impr Pant", VK_INSERT: "Insert", VK_DELETE: "Supr", VK_APPS: "Menú",
+		VK_NUMLOCK: "Bloq Num", VK_SCROLL: "Bloq Despl", VK_LSHIFT: "Mayús izquierda", VK_RSHIFT: "Mayús derecha",
+		VK_LCONTROL: "Ctrl izquierda", VK_RCONTROL: "Ctrl derecha", VK_LMENU: "Alt", VK_RMENU: "Alt Gr",
+	},
+	"it": {
+		VK_BACK: "Backspace", VK_RETURN: "Invio", VK_SHIFT: "Maiusc", VK_CONTROL: "Ctrl",
+		VK_PAUSE: "Pausa", VK_CAPITAL: "Bloc Maiusc", VK_ESCAPE: "Esc", VK_SPACE: "Spazio",
+		VK_PRIOR: "Pag su", VK_NEXT: "Pag giù", VK_END: "Fine", VK_HOME: "Home",
+		VK_LEFT: "Sinistra", VK_UP: "Su", VK_RIGHT: "Destra", VK_DOWN: "Giù",
+		VK_SNAPSHOT: "Stamp", VK_INSERT: "Ins", VK_DELETE: "Canc", VK_APPS: "Menu",
+		VK_NUMLOCK: "Bloc Num", VK_SCROLL: "Bloc Scorr", VK_LSHIFT: "Maiusc sinistro", VK_RSHIFT: "Maiusc destro",
+		VK_LCONTROL: "Ctrl sinistro", VK_RCONTROL: "Ctrl destro", VK_LMENU: "Alt", VK_RMENU: "Alt Gr",
+	},
+}
+
+/*
+	KeyNameLanguages returns the languages KeyNameNamer accepts, sorted:
+	"en", the names of KeyName, and those of KeyNameTables.
+*/
+func KeyNameLanguages() []string {
+	langs := []string{"en"}
+	for lang := range KeyNameTables {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+/*
+	KeyNamer returns a function naming keys in lang, a language code such
+	as "de" or a locale such as "de-DE" or "de_AT.UTF-8", for the Names of
+	a KeyDisplay or an ECSCodec. "system" names them in the language of the
+	user interface, as SystemKeyNames does.
+*/
+func KeyNamer(lang string) (func(vk uint16) string, error) {
+	if strings.EqualFold(lang, "system") {
+		return SystemKeyNames(), nil
+	}
+	code := languageCode(lang)
+	if code == "en" {
+		return KeyName, nil
+	}
+	if _, ok := KeyNameTables[code]; !ok {
+		return nil, fmt.Errorf("no key names in %q, not one of system, %s", lang, strings.Join(KeyNameLanguages(), ", "))
+	}
+	return tableKeyNames(code, KeyName), nil
+}
+
+/*
+	Returns the language of a locale, "de" for "de-DE" or "de_AT.UTF-8".
+*/
+func languageCode(locale string) string {
+	if i := strings.IndexAny(locale, "-_.@"); i >= 0 {
+		locale = locale[:i]
+	}
+	return strings.ToLower(locale)
+}
+
+/*
+	Names keys from the KeyNameTables of lang, and others with fallback.
+*/
+func tableKeyNames(lang string, fallback func(uint16) string) func(uint16) string {
+	table := KeyNameTables[lang]
+	return func(vk uint16) string {
+		if n, ok := table[vk]; ok {
+			return n
+		}
+		return fallback(vk)
+	}
+}
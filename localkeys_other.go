@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package keylogger
+
+import "os"
+
+/*
+	SystemKeyNames returns a function naming keys in the language of the
+	locale, as LC_ALL, LC_MESSAGES or LANG set it, from KeyNameTables;
+	other languages get KeyName.
+*/
+func SystemKeyNames() func(vk uint16) string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale := os.Getenv(env); locale != "" {
+			if lang := languageCode(locale); KeyNameTables[lang] != nil {
+				return tableKeyNames(lang, KeyName)
+			}
+			break
+		}
+	}
+	return KeyName
+}
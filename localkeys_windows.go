@@ -0,0 +1,101 @@
+package keylogger
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var getKeyNameTextW = user32.NewProc("GetKeyNameTextW")
+
+/*
+	SystemKeyNames returns a function naming keys in the language of the
+	user interface: from KeyNameTables if it has the language, and as the
+	keyboard layout names them, through GetKeyNameText, where it does not
+	or the table leaves a key out. Letters, digits and punctuation keep
+	their KeyName, as do all keys for English. Names are looked up once per
+	key; the function is safe for concurrent use.
+*/
+func SystemKeyNames() func(vk uint16) string {
+	lang := "en"
+	if langs, err := windows.GetUserPreferredUILanguages(windows.MUI_LANGUAGE_NAME); err == nil && len(langs) > 0 {
+		lang = languageCode(langs[0])
+	}
+	if lang == "en" {
+		return KeyName
+	}
+	var (
+		mu    sync.Mutex
+		names = make(map[uint16]string)
+	)
+	return tableKeyNames(lang, func(vk uint16) string {
+		mu.Lock()
+		defer mu.Unlock()
+		if n, ok := names[vk]; ok {
+			return n
+		}
+		n := KeyName(vk)
+		if layoutNamed(vk) {
+			if text := GetKeyNameText(vk); text != "" {
+				n = titleCase(text)
+			}
+		}
+		names[vk] = n
+		return n
+	})
+}
+
+/*
+	Reports whether the keyboard layout, rather than KeyName, should name
+	vk: KeyName already is the label of letter and digit keys, and those
+	of punctuation change with the layout anyway.
+*/
+func layoutNamed(vk uint16) bool {
+	switch {
+	case vk >= '0' && vk <= '9', vk >= 'A' && vk <= 'Z':
+		return false
+	case vk >= VK_OEM_1 && vk <= VK_OEM_3, vk >= VK_OEM_4 && vk <= VK_OEM_8, vk == VK_OEM_102:
+		return false
+	}
+	return true
+}
+
+/*
+	Returns "Entf" for "ENTF", as layouts name many keys in capitals;
+	names with lower case letters are kept.
+*/
+func titleCase(name string) string {
+	if strings.ToUpper(name) != name {
+		return name
+	}
+	words := strings.Fields(name)
+	for i, w := range words {
+		if r, n := utf8.DecodeRuneInString(w); utf8.RuneCountInString(w) > 1 && unicode.IsLetter(r) {
+			words[i] = string(r) + strings.ToLower(w[n:])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+/*
+	GetKeyNameText returns the name the keyboard layout of the calling
+	thread gives the key vk is on, or "" if it has none.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getkeynametextw
+*/
+func GetKeyNameText(vk uint16) string {
+	sc := MapVirtualKey(uint32(vk), MAPVK_VK_TO_VSC_EX)
+	if sc == 0 {
+		return ""
+	}
+	lparam := (sc & 0xFF) << 16
+	if sc>>8 == 0xE0 || sc>>8 == 0xE1 {
+		lparam |= 1 << 24
+	}
+	var buf [64]uint16
+	n, _, _ := getKeyNameTextW.Call(uintptr(lparam), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	return windows.UTF16ToString(buf[:n])
+}
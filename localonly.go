@@ -0,0 +1,13 @@
+//go:build localonly
+// +build localonly
+
+package keylogger
+
+/*
+	LocalOnly reports whether the package was built with the localonly tag,
+	which leaves out everything that listens on or talks to the network:
+	MetricsHandler, the token authentication and TLS configuration of the
+	API, the ActivityWatch, WakaTime and OBS sinks, the CrashReporter, and
+	the Lua functions that start processes.
+*/
+const LocalOnly = true
@@ -0,0 +1,72 @@
+package keylogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+/*
+	Packages that reach the network or other processes.
+*/
+var networkPackages = map[string]bool{
+	"net":        true,
+	"net/http":   true,
+	"net/rpc":    true,
+	"net/smtp":   true,
+	"crypto/tls": true,
+	"os/exec":    true,
+}
+
+/*
+	The imports of network packages accepted in localonly builds, by
+	importer: golang.org/x/sys/windows imports net only for socket types,
+	and gopher-lua's os.execute and io.popen are removed from scripts in
+	LocalOnly builds.
+*/
+var acceptedNetworkImports = map[string]string{
+	"golang.org/x/sys/windows":   "net",
+	"github.com/yuin/gopher-lua": "os/exec",
+}
+
+/*
+	Walks everything the module's packages, the commands and the DLL among
+	them, build on with the localonly tag, and fails on every import of a
+	network package but the accepted ones.
+*/
+func TestLocalOnlyImports(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	cmd := exec.Command("go", "list", "-tags", "localonly", "-deps", "-json", "./...")
+	cmd.Env = append(os.Environ(), "GOOS=windows", "CGO_ENABLED=1")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var p struct {
+			ImportPath string
+			Imports    []string
+		}
+		if err := dec.Decode(&p); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if networkPackages[p.ImportPath] {
+			// Reported where it is imported.
+			continue
+		}
+		for _, imp := range p.Imports {
+			if networkPackages[imp] && acceptedNetworkImports[p.ImportPath] != imp {
+				t.Errorf("%s imports %s with the localonly tag", p.ImportPath, imp)
+			}
+		}
+	}
+}
@@ -0,0 +1,685 @@
+package keylogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func startFakeLogger(t testing.TB, f *fakeAPI, setup func(l *Logger)) (*Logger, <-chan Event) {
+	t.Helper()
+	l := NewLogger()
+	l.api = f
+	if setup != nil {
+		setup(l)
+	}
+	events := l.Events()
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return l, events
+}
+
+func nextEvent(t testing.TB, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+		return nil
+	}
+}
+
+func nextResult(t testing.TB, f *fakeAPI) LRESULT {
+	t.Helper()
+	select {
+	case r := <-f.results:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("input was not handled")
+		return 0
+	}
+}
+
+func TestKeyboardHookTranslates(t *testing.T) {
+	f := newFakeAPI()
+	l, events := startFakeLogger(t, f, nil)
+	defer l.Stop()
+
+	keys := []struct {
+		vk   uint16
+		down bool
+		text string
+	}{
+		{VK_LSHIFT, true, ""},
+		{'A', true, "A"},
+		{'A', false, ""},
+		{VK_LSHIFT, false, ""},
+		{'B', true, "b"},
+		{'B', false, ""},
+		{'7', true, "7"},
+		{'7', false, ""},
+	}
+	for _, k := range keys {
+		f.key(k.vk, k.down)
+		if r := nextResult(t, f); r != 0 {
+			t.Fatalf("hook returned %d for %s, want the key passed on", r, KeyName(k.vk))
+		}
+	}
+	for i, k := range keys {
+		e, ok := nextEvent(t, events).(KeyEvent)
+		if !ok || e.VkCode != k.vk || e.Down != k.down || e.Text != k.text || e.Swallowed {
+			t.Errorf("event %d = %+v, want %s down=%v text %q", i, e, KeyName(k.vk), k.down, k.text)
+		}
+	}
+	if n := len(f.passedOn()); n != len(keys) {
+		t.Errorf("%d keys passed on, want %d", n, len(keys))
+	}
+}
+
+func TestKeyboardHookCapsLock(t *testing.T) {
+	f := newFakeAPI()
+	f.capsLock = true
+	l, events := startFakeLogger(t, f, nil)
+	defer l.Stop()
+
+	for _, k := range []struct {
+		vk   uint16
+		text string
+	}{
+		{'Q', "Q"},
+		{VK_CAPITAL, ""},
+		{'Q', "q"},
+	} {
+		f.key(k.vk, true)
+		f.key(k.vk, false)
+		if e := nextEvent(t, events).(KeyEvent); e.Text != k.text {
+			t.Errorf("%s typed %q, want %q", KeyName(k.vk), e.Text, k.text)
+		}
+		nextEvent(t, events)
+	}
+}
+
+func TestKeyboardHookSwallowsFiltered(t *testing.T) {
+	f := newFakeAPI()
+	l, events := startFakeLogger(t, f, func(l *Logger) {
+		l.AddFilter(func(e KeyEvent) bool { return e.VkCode == 'X' })
+	})
+	defer l.Stop()
+
+	f.key('X', true)
+	if r := nextResult(t, f); r != 1 {
+		t.Errorf("hook returned %d for a filtered key, want 1", r)
+	}
+	f.key('Y', true)
+	if r := nextResult(t, f); r != 0 {
+		t.Errorf("hook returned %d for another key, want 0", r)
+	}
+	if e := nextEvent(t, events).(KeyEvent); e.VkCode != 'X' || !e.Swallowed || e.Text != "" {
+		t.Errorf("filtered key = %+v, want X swallowed without text", e)
+	}
+	if e := nextEvent(t, events).(KeyEvent); e.VkCode != 'Y' || e.Swallowed || e.Text != "y" {
+		t.Errorf("other key = %+v, want y", e)
+	}
+	if passed := f.passedOn(); len(passed) != 1 || passed[0].wparam != WM_KEYDOWN {
+		t.Errorf("passed on %+v, want only the Y key-down", passed)
+	}
+}
+
+func TestKeyboardHookPassesOtherCodes(t *testing.T) {
+	f := newFakeAPI()
+	l, events := startFakeLogger(t, f, nil)
+	defer l.Stop()
+
+	// Hook codes below zero must go to the next hook untouched.
+	f.input(func() LRESULT { return f.send(WH_KEYBOARD_LL, -1, WM_KEYDOWN, 0) })
+	nextResult(t, f)
+	f.key('C', true)
+	nextResult(t, f)
+	if e := nextEvent(t, events).(KeyEvent); e.VkCode != 'C' {
+		t.Errorf("first event = %+v, want the C key", e)
+	}
+	if passed := f.passedOn(); len(passed) != 2 || passed[0].nCode != -1 {
+		t.Errorf("passed on %+v, want the negative code and the key", passed)
+	}
+}
+
+func TestThreadKeyboardHook(t *testing.T) {
+	f := newFakeAPI()
+	thread := windows.GetCurrentThreadId()
+	l, events := startFakeLogger(t, f, func(l *Logger) {
+		l.Thread = thread
+		l.AddFilter(func(e KeyEvent) bool { return e.VkCode == 'X' })
+	})
+	defer l.Stop()
+
+	f.mu.Lock()
+	for _, h := range f.hooks {
+		if h.id != WH_KEYBOARD || h.thread != thread {
+			t.Errorf("hook %d on thread %d installed, want WH_KEYBOARD on %d", h.id, h.thread, thread)
+		}
+	}
+	f.mu.Unlock()
+	for _, k := range []struct {
+		vk     uint16
+		lparam LPARAM
+		want   LRESULT
+	}{
+		{'A', 0x1e<<16 | 1, 0},
+		{'A', 1<<31 | 1<<30 | 0x1e<<16 | 1, 0},
+		{'X', 0x2d<<16 | 1, 1},
+	} {
+		vk, lparam := k.vk, k.lparam
+		f.input(func() LRESULT { return f.send(WH_KEYBOARD, HC_ACTION, WPARAM(vk), lparam) })
+		if r := nextResult(t, f); r != k.want {
+			t.Errorf("hook returned %d for %s, want %d", r, KeyName(vk), k.want)
+		}
+	}
+	if e := nextEvent(t, events).(KeyEvent); e.VkCode != 'A' || !e.Down || e.ScanCode != 0x1e || e.Text != "a" {
+		t.Errorf("first event = %+v, want a typed", e)
+	}
+	if e := nextEvent(t, events).(KeyEvent); e.VkCode != 'A' || e.Down || e.Flags&LLKHF_UP == 0 {
+		t.Errorf("second event = %+v, want A released", e)
+	}
+	if e := nextEvent(t, events).(KeyEvent); e.VkCode != 'X' || !e.Swallowed {
+		t.Errorf("third event = %+v, want X swallowed", e)
+	}
+}
+
+func TestHooksPassOnOffTarget(t *testing.T) {
+	f := newFakeAPI()
+	filtered := 0
+	l, events := startFakeLogger(t, f, func(l *Logger) {
+		l.CaptureMouse = true
+		l.AddFilter(func(e KeyEvent) bool { filtered++; return true })
+	})
+	defer l.Stop()
+
+	// As targetFocus records a window not of the Target.
+	atomic.StoreInt32(&l.offTarget, 1)
+	f.key('A', true)
+	if r := nextResult(t, f); r != 0 {
+		t.Errorf("hook returned %d off target, want the key passed on", r)
+	}
+	f.mouse(WM_LBUTTONDOWN, 1, 1, 0)
+	nextResult(t, f)
+	atomic.StoreInt32(&l.offTarget, 0)
+	f.key('B', true)
+	if r := nextResult(t, f); r != 1 {
+		t.Errorf("hook returned %d on target, want the filter to swallow the key", r)
+	}
+	if e, ok := nextEvent(t, events).(KeyEvent); !ok || e.VkCode != 'B' {
+		t.Errorf("first event = %+v, want the B key", e)
+	}
+	if filtered != 1 {
+		t.Errorf("filter ran %d times, want once", filtered)
+	}
+	if n := len(f.passedOn()); n != 2 {
+		t.Errorf("%d inputs passed on, want the key and click off target", n)
+	}
+}
+
+func TestThreadOfAnotherProcess(t *testing.T) {
+	l := NewLogger()
+	l.api = newFakeAPI()
+	// A thread of the System process, if there is one: never of this one.
+	l.Thread = 4
+	if err := l.Start(); err == nil {
+		l.Stop()
+		t.Error("Start succeeded for a thread of another process")
+	}
+}
+
+/*
+	Keys are left out of the dump while a pause is asked for but not yet in
+	effect, and while a sensitive application has them redacted.
+*/
+func TestHookDumpHidesKeys(t *testing.T) {
+	f := newFakeAPI()
+	var dump bytes.Buffer
+	l, events := startFakeLogger(t, f, func(l *Logger) {
+		l.HookDump = &dump
+		l.Sensitive.Redact = true
+	})
+	l.pauseMu.Lock()
+	l.pauses |= pauseCalled
+	l.pauseMu.Unlock()
+	f.rawKey(WM_KEYDOWN, KBDLLHOOKSTRUCT{VkCode: 'A', ScanCode: 0x1E, Time: 100})
+	nextResult(t, f)
+	l.pauseMu.Lock()
+	l.pauses &^= pauseCalled
+	l.pauseMu.Unlock()
+	atomic.StoreInt32(&l.sensitive, 1)
+	f.rawKey(WM_KEYUP, KBDLLHOOKSTRUCT{VkCode: 'A', ScanCode: 0x1E, Flags: LLKHF_UP, Time: 180})
+	nextResult(t, f)
+	for i := 0; i < 2; i++ {
+		nextEvent(t, events)
+	}
+	l.Stop()
+
+	lines := strings.Split(strings.TrimSuffix(dump.String(), "\n"), "\n")
+	want := []string{
+		" WM_KEYDOWN key hidden flags=0x00 time=100 ",
+		" WM_KEYUP key hidden flags=0x80 (up) time=180 ",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("dump:\n%s\nwant %d lines", dump.String(), len(want))
+	}
+	for i, w := range want {
+		if !strings.Contains(lines[i], w) || strings.Contains(lines[i], "vk=") {
+			t.Errorf("line %d = %q, want %q", i, lines[i], w)
+		}
+	}
+}
+
+func TestHookDump(t *testing.T) {
+	f := newFakeAPI()
+	var dump bytes.Buffer
+	l, events := startFakeLogger(t, f, func(l *Logger) {
+		l.HookDump = &dump
+		l.AddFilter(func(e KeyEvent) bool { return e.VkCode == 'X' })
+	})
+	f.rawKey(WM_KEYDOWN, KBDLLHOOKSTRUCT{VkCode: 'A', ScanCode: 0x1E, Time: 100})
+	f.rawKey(WM_KEYUP, KBDLLHOOKSTRUCT{VkCode: 'A', ScanCode: 0x1E, Flags: LLKHF_UP, Time: 180})
+	f.rawKey(WM_KEYDOWN, KBDLLHOOKSTRUCT{VkCode: 'X', Time: 200})
+	f.input(func() LRESULT { return f.send(WH_KEYBOARD_LL, 3, 0, 0) })
+	for i := 0; i < 4; i++ {
+		nextResult(t, f)
+	}
+	for i := 0; i < 3; i++ {
+		nextEvent(t, events)
+	}
+	l.Stop()
+
+	lines := strings.Split(strings.TrimSuffix(dump.String(), "\n"), "\n")
+	want := []string{
+		" WM_KEYDOWN vk=0x41 A scan=0x1E flags=0x00 time=100 extra=0x0 queued result=0 took=",
+		" WM_KEYUP vk=0x41 A scan=0x1E flags=0x80 (up) time=180 extra=0x0 queued result=0 took=",
+		" WM_KEYDOWN vk=0x58 X scan=0x00 flags=0x00 time=200 extra=0x0 swallowed result=1 took=",
+		" nCode=3 wparam=0x0000 ignored result=0 took=",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("dump:\n%s\nwant %d lines", dump.String(), len(want))
+	}
+	for i, w := range want {
+		if !strings.Contains(lines[i], w) {
+			t.Errorf("line %d = %q, want %q", i, lines[i], w)
+		}
+	}
+}
+
+func TestMouseHookDispatch(t *testing.T) {
+	f := newFakeAPI()
+	l, events := startFakeLogger(t, f, func(l *Logger) {
+		l.CaptureKeyboard = false
+		l.CaptureMouse = true
+	})
+	defer l.Stop()
+
+	f.mouse(WM_LBUTTONDOWN, 10, 20, 0)
+	f.mouse(WM_LBUTTONUP, 10, 20, 0)
+	f.mouse(WM_MOUSEWHEEL, 10, 20, DWORD(uint16(0xFF88))<<16) // -120
+	for i := 0; i < 3; i++ {
+		nextResult(t, f)
+	}
+	if e := nextEvent(t, events).(MouseEvent); e.Action != MouseDown || e.Button != LeftButton || e.X != 10 || e.Y != 20 {
+		t.Errorf("button down = %+v", e)
+	}
+	if e := nextEvent(t, events).(MouseEvent); e.Action != MouseUp || e.Button != LeftButton {
+		t.Errorf("button up = %+v", e)
+	}
+	if e := nextEvent(t, events).(MouseEvent); e.Action != MouseScroll || e.Delta != -120 || e.Horizontal {
+		t.Errorf("wheel = %+v, want a scroll of -120", e)
+	}
+}
+
+func TestStopRemovesHooks(t *testing.T) {
+	f := newFakeAPI()
+	l, events := startFakeLogger(t, f, func(l *Logger) {
+		l.CaptureMouse = true
+	})
+	if n := f.installed(); n != 2 {
+		t.Fatalf("%d hooks installed, want 2", n)
+	}
+	f.key('Z', true)
+	nextResult(t, f)
+	l.Stop()
+	if n := f.installed(); n != 0 {
+		t.Errorf("%d hooks left after Stop", n)
+	}
+	var got []Event
+	for e := range events {
+		got = append(got, e)
+	}
+	if len(got) != 1 {
+		t.Errorf("%d events delivered before the channel closed, want 1", len(got))
+	}
+}
+
+func TestPauseRemovesHooks(t *testing.T) {
+	f := newFakeAPI()
+	l, events := startFakeLogger(t, f, func(l *Logger) {
+		l.CaptureMouse = true
+	})
+	defer l.Stop()
+	l.Pause()
+	if e := nextEvent(t, events).(DiagnosticEvent); e.Kind != DiagPaused {
+		t.Fatalf("got %+v, want a %s marker", e, DiagPaused)
+	}
+	if n := f.installed(); n != 0 {
+		t.Errorf("%d hooks installed while paused", n)
+	}
+	l.Resume()
+	if e := nextEvent(t, events).(DiagnosticEvent); e.Kind != DiagResumed {
+		t.Fatalf("got %+v, want a %s marker", e, DiagResumed)
+	}
+	if n := f.installed(); n != 2 {
+		t.Errorf("%d hooks installed after Resume, want 2", n)
+	}
+}
+
+func TestPauseAudited(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := OpenAuditLog(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := newFakeAPI()
+	l, events := startFakeLogger(t, f, func(l *Logger) { l.Audit = a })
+	if err := l.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events)
+	if err := l.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events)
+	l.Stop()
+	a.Close()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var r AuditRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, r.Action)
+	}
+	if got, want := strings.Join(actions, " "), "start pause resume stop"; got != want {
+		t.Errorf("audit log holds %s, want %s", got, want)
+	}
+}
+
+/*
+	The watchdog asks for a hook to be installed again after a probe went
+	missing, but the hooks were paused meanwhile; they must stay removed.
+*/
+func TestReinstallWhilePaused(t *testing.T) {
+	f := newFakeAPI()
+	l, events := startFakeLogger(t, f, func(l *Logger) {
+		l.CaptureMouse = true
+	})
+	defer l.Stop()
+	l.Pause()
+	if e := nextEvent(t, events).(DiagnosticEvent); e.Kind != DiagPaused {
+		t.Fatalf("got %+v, want a %s marker", e, DiagPaused)
+	}
+	f.PostMessage(l.current().id, wmReinstallHook, WH_KEYBOARD_LL, 0)
+	f.PostMessage(l.current().id, wmReinstallHook, WH_MOUSE_LL, 0)
+	// Runs once the hook thread has handled both.
+	f.input(func() LRESULT { return 0 })
+	nextResult(t, f)
+	if n := f.installed(); n != 0 {
+		t.Errorf("%d hooks installed again while paused", n)
+	}
+	l.Resume()
+	if e := nextEvent(t, events).(DiagnosticEvent); e.Kind != DiagResumed {
+		t.Fatalf("got %+v, want a %s marker", e, DiagResumed)
+	}
+	if n := f.installed(); n != 2 {
+		t.Errorf("%d hooks installed after Resume, want 2", n)
+	}
+}
+
+/*
+	A stalled hook thread comes back to life while its replacement starts,
+	under a stream of keys; run with -race. Until it quits, the old thread
+	keeps running the hooks for some of the keys, and must not push to the
+	ring alongside the replacement, which pushes the foreground window as
+	soon as it is current.
+*/
+func TestReplaceThreadUnderLoad(t *testing.T) {
+	f := newFakeAPI()
+	l := NewLogger()
+	l.api = f
+	l.CaptureFocus = true
+	events := l.Events()
+	if err := l.Start(); err != nil {
+		t.Skip("capturing focus needs a desktop:", err)
+	}
+	defer l.Stop()
+	var focus, keys int
+	delivered := make(chan struct{})
+	go func() {
+		defer close(delivered)
+		for e := range events {
+			switch e.(type) {
+			case FocusEvent:
+				focus++
+			case KeyEvent:
+				keys++
+			}
+		}
+	}()
+
+	const n = 200
+	old := l.current()
+	stall := make(chan struct{})
+	f.input(func() LRESULT { <-stall; return 0 })
+	go func() {
+		for i := 0; i < n; i++ {
+			f.key('K', i%2 == 0)
+		}
+	}()
+	replaced := make(chan error, 1)
+	go func() { replaced <- l.replaceThread(old) }()
+	for atomic.LoadUint32(&old.abandoned) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(stall)
+	if err := <-replaced; err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n+1; i++ {
+		nextResult(t, f)
+	}
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(&l.abandoned) != 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("the abandoned thread did not quit")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if l.current() == old {
+		t.Fatal("the stalled thread is still current")
+	}
+	if err := l.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	<-delivered
+	if focus != 2 {
+		t.Errorf("%d focus events, want one from each thread", focus)
+	}
+	if keys > n {
+		t.Errorf("%d keys delivered of %d", keys, n)
+	}
+}
+
+/*
+	Start, Stop, Events and Stats called from several goroutines at once
+	while keys arrive; run with -race. Only one of concurrent Starts wins,
+	and Stop on a stopped Logger does nothing.
+*/
+func TestConcurrentLifecycle(t *testing.T) {
+	f := newFakeAPI()
+	l := NewLogger()
+	l.api = f
+	l.StopTimeout = time.Second
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-f.results:
+			case <-done:
+				return
+			}
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				events := l.Events()
+				for open := true; open; {
+					select {
+					case _, open = <-events:
+					case <-done:
+						return
+					}
+				}
+				l.Stats()
+			}
+		}()
+	}
+	for i := 0; i < 30; i++ {
+		var mu sync.Mutex
+		started := 0
+		var starts sync.WaitGroup
+		for j := 0; j < 3; j++ {
+			starts.Add(1)
+			go func() {
+				defer starts.Done()
+				if l.Start() == nil {
+					mu.Lock()
+					started++
+					mu.Unlock()
+				}
+			}()
+		}
+		starts.Wait()
+		if started != 1 {
+			t.Fatalf("run %d: %d concurrent starts succeeded, want 1", i, started)
+		}
+		f.key('K', true)
+		f.key('K', false)
+		var stops sync.WaitGroup
+		for j := 0; j < 3; j++ {
+			stops.Add(1)
+			go func() {
+				defer stops.Done()
+				l.Stop()
+			}()
+		}
+		stops.Wait()
+		if n := f.installed(); n != 0 {
+			t.Fatalf("run %d: %d hooks left after Stop", i, n)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+/*
+	A fakeAPI that does not record what the hooks pass on, for benchmarks.
+*/
+type benchAPI struct {
+	*fakeAPI
+}
+
+func (benchAPI) CallNext(nCode int, wparam WPARAM, lparam LPARAM) LRESULT {
+	return 0
+}
+
+/*
+	The keyboard hook procedure alone, as Windows calls it for every key.
+*/
+func BenchmarkKeyboardHook(b *testing.B) {
+	l := NewLogger()
+	l.api = benchAPI{newFakeAPI()}
+	s := &KBDLLHOOKSTRUCT{VkCode: 'A'}
+	var raw rawEvent
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		wparam := WPARAM(WM_KEYDOWN)
+		if i%2 == 1 {
+			wparam = WM_KEYUP
+		}
+		l.keyboardProc(HC_ACTION, wparam, LPARAM(unsafe.Pointer(s)))
+		l.ring.pop(&raw)
+	}
+}
+
+/*
+	Key events to the text they type, each letter pressed and released.
+*/
+func BenchmarkTranslate(b *testing.B) {
+	t := newTranslator(newFakeAPI())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		vk := uint16('A' + i/2%26)
+		t.translate(KeyEvent{VkCode: vk, Down: i%2 == 0})
+	}
+}
+
+/*
+	Keys from the hook thread through the worker to Events. The fake's
+	queueing of the input is included.
+*/
+func BenchmarkLoggerPipeline(b *testing.B) {
+	f := newFakeAPI()
+	l, events := startFakeLogger(b, f, func(l *Logger) { l.api = benchAPI{f} })
+	go func() {
+		for range f.results {
+		}
+	}()
+	b.ReportAllocs()
+	start := time.Now()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			f.key('A', i%2 == 0)
+		}
+	}()
+	// Keys the worker did not keep up with are dropped from the ring.
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	received, dropped := 0, 0
+	for received+dropped < b.N {
+		select {
+		case <-events:
+			received++
+		case <-tick.C:
+			dropped = int(l.Stats().Dropped.Ring)
+		}
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+	l.Stop()
+	b.ReportMetric(float64(received)/elapsed.Seconds(), "events/s")
+	b.ReportMetric(float64(b.N-received), "dropped")
+}
@@ -0,0 +1,264 @@
+package keylogger
+
+import (
+	"errors"
+	"time"
+)
+
+/*
+	MacroStep is one recorded key transition, played back after Delay has
+	elapsed since the previous step.
+*/
+type MacroStep struct {
+	VkCode uint16
+	Down   bool
+	Delay  time.Duration
+}
+
+/*
+	Macro is a recorded sequence of key transitions with their timing.
+*/
+type Macro struct {
+	Name        string
+	Description string
+	Created     time.Time
+	Steps       []MacroStep
+}
+
+/*
+	KeyInjector is the subset of Injector needed to replay key transitions.
+*/
+type KeyInjector interface {
+	Press(vks ...uint16) error
+	Release(vks ...uint16) error
+}
+
+/*
+	PlayOptions control macro playback. The zero value replays once with
+	the recorded timing.
+*/
+type PlayOptions struct {
+	// Speed multiplies playback speed: 2 plays twice as fast. Zero means 1.
+	Speed float64
+	// FixedDelay, if set, replaces every recorded delay.
+	FixedDelay time.Duration
+	// Loops is the number of times to play; zero means once and a
+	// negative value repeats until aborted.
+	Loops int
+	// Abort stops playback when closed.
+	Abort <-chan struct{}
+}
+
+/*
+	ErrPlaybackAborted is returned by PlayWith when PlayOptions.Abort is closed.
+*/
+var ErrPlaybackAborted = errors.New("macro playback aborted")
+
+/*
+	Play replays the macro once with its recorded timing.
+*/
+func (m *Macro) Play(inj KeyInjector) error {
+	return m.PlayWith(inj, PlayOptions{})
+}
+
+/*
+	PlayWith replays the macro according to opts. Keys still held when
+	playback is aborted or fails are released.
+*/
+func (m *Macro) PlayWith(inj KeyInjector, opts PlayOptions) error {
+	held := make(map[uint16]bool)
+	defer func() {
+		for vk := range held {
+			inj.Release(vk)
+		}
+	}()
+
+	loops := opts.Loops
+	if loops == 0 {
+		loops = 1
+	}
+	for loop := 0; loops < 0 || loop < loops; loop++ {
+		for _, step := range m.Steps {
+			if err := opts.wait(step.Delay); err != nil {
+				return err
+			}
+			var err error
+			if step.Down {
+				err = inj.Press(step.VkCode)
+				held[step.VkCode] = true
+			} else {
+				err = inj.Release(step.VkCode)
+				delete(held, step.VkCode)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (o PlayOptions) wait(recorded time.Duration) error {
+	d := recorded
+	if o.FixedDelay > 0 {
+		d = o.FixedDelay
+	}
+	if o.Speed > 0 {
+		d = time.Duration(float64(d) / o.Speed)
+	}
+	if d <= 0 {
+		select {
+		case <-o.Abort:
+			return ErrPlaybackAborted
+		default:
+			return nil
+		}
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-o.Abort:
+		return ErrPlaybackAborted
+	case <-t.C:
+		return nil
+	}
+}
+
+/*
+	RecorderAction tells the caller what a key event did to a Recorder.
+*/
+type RecorderAction int
+
+const (
+	RecorderNone RecorderAction = iota
+	RecorderStarted
+	RecorderStopped
+	RecorderPlay
+	RecorderAbort
+)
+
+/*
+	Recorder turns the captured key stream into macros.
+	Pressing RecordKey starts and stops a recording; pressing PlayKey asks
+	the caller to replay the last finished one, AbortKey to stop playback. Input sent by an Injector
+	is never recorded, so a macro being replayed does not record itself.
+*/
+type Recorder struct {
+	RecordKey Hotkey
+	PlayKey   Hotkey
+	AbortKey  Hotkey
+
+	mods      ModifierState
+	recording bool
+	held      map[uint16]bool
+	last      time.Time
+	current   *Macro
+	macro     *Macro
+}
+
+/*
+	NewRecorder returns a Recorder toggled by record and replaying on play.
+*/
+func NewRecorder(record, play Hotkey) *Recorder {
+	return &Recorder{RecordKey: record, PlayKey: play}
+}
+
+/*
+	Feed processes one captured key event.
+*/
+func (r *Recorder) Feed(e KeyEvent) RecorderAction {
+	if e.FromInjector() || e.Swallowed {
+		return RecorderNone
+	}
+	before := r.mods.Mods()
+	r.mods.Update(e)
+
+	switch {
+	case r.RecordKey.Matches(e, before):
+		if r.recording {
+			r.stop()
+			return RecorderStopped
+		}
+		r.start(e.Time)
+		return RecorderStarted
+	case r.AbortKey.Matches(e, before):
+		return RecorderAbort
+	case !r.recording && r.PlayKey.Matches(e, before):
+		if r.macro == nil {
+			return RecorderNone
+		}
+		return RecorderPlay
+	case r.recording:
+		r.record(e)
+	}
+	return RecorderNone
+}
+
+/*
+	Recording reports whether a recording is in progress.
+*/
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+/*
+	Macro returns the last finished recording, or nil.
+*/
+func (r *Recorder) Macro() *Macro {
+	return r.macro
+}
+
+/*
+	SetMacro replaces the macro replayed by PlayKey, e.g. with one loaded from a file.
+*/
+func (r *Recorder) SetMacro(m *Macro) {
+	r.macro = m
+}
+
+func (r *Recorder) start(t time.Time) {
+	r.recording = true
+	r.held = make(map[uint16]bool)
+	r.current = &Macro{Created: t}
+	r.last = t
+}
+
+func (r *Recorder) record(e KeyEvent) {
+	if e.Down {
+		r.held[e.VkCode] = true
+	} else if !r.held[e.VkCode] {
+		// Release of a key pressed before the recording started,
+		// typically the record hotkey itself.
+		return
+	}
+	if !e.Down {
+		delete(r.held, e.VkCode)
+	}
+	r.current.Steps = append(r.current.Steps, MacroStep{
+		VkCode: e.VkCode,
+		Down:   e.Down,
+		Delay:  e.Time.Sub(r.last),
+	})
+	r.last = e.Time
+}
+
+func (r *Recorder) stop() {
+	steps := r.current.Steps
+	// Drop the modifiers of the stop hotkey, which were pressed but not
+	// released inside the recording.
+	for len(steps) > 0 {
+		s := steps[len(steps)-1]
+		if !s.Down || !r.held[s.VkCode] || modifierOf(s.VkCode) == 0 {
+			break
+		}
+		delete(r.held, s.VkCode)
+		steps = steps[:len(steps)-1]
+	}
+	// Release anything else still held so playback never leaves keys stuck.
+	for vk := range r.held {
+		steps = append(steps, MacroStep{VkCode: vk, Down: false})
+	}
+	r.current.Steps = steps
+	r.macro = r.current
+	r.current = nil
+	r.recording = false
+}
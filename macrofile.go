@@ -0,0 +1,153 @@
+package keylogger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+/*
+	MacroFileVersion is the version written by SaveMacro.
+	LoadMacro accepts files up to this version.
+*/
+const MacroFileVersion = 1
+
+/*
+	On-disk representation of a macro. Delays are stored in milliseconds so
+	files stay readable and editable by hand.
+*/
+type macroFile struct {
+	Version     int             `json:"version"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Created     time.Time       `json:"created,omitempty"`
+	Steps       []macroFileStep `json:"steps"`
+}
+
+type macroFileStep struct {
+	VkCode  uint16  `json:"vk"`
+	Down    bool    `json:"down"`
+	DelayMs float64 `json:"delay_ms,omitempty"`
+}
+
+/*
+	SaveMacro writes the macro as versioned JSON.
+*/
+func SaveMacro(w io.Writer, m *Macro) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	f := macroFile{
+		Version:     MacroFileVersion,
+		Name:        m.Name,
+		Description: m.Description,
+		Created:     m.Created,
+		Steps:       make([]macroFileStep, len(m.Steps)),
+	}
+	for i, s := range m.Steps {
+		f.Steps[i] = macroFileStep{
+			VkCode:  s.VkCode,
+			Down:    s.Down,
+			DelayMs: float64(s.Delay) / float64(time.Millisecond),
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+/*
+	LoadMacro reads a macro written by SaveMacro and validates it.
+*/
+func LoadMacro(r io.Reader) (*Macro, error) {
+	var f macroFile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("macro: %v", err)
+	}
+	if f.Version < 1 || f.Version > MacroFileVersion {
+		return nil, fmt.Errorf("macro: unsupported file version %d", f.Version)
+	}
+	m := &Macro{
+		Name:        f.Name,
+		Description: f.Description,
+		Created:     f.Created,
+		Steps:       make([]MacroStep, len(f.Steps)),
+	}
+	for i, s := range f.Steps {
+		if s.DelayMs < 0 {
+			return nil, fmt.Errorf("macro: step %d: negative delay", i)
+		}
+		m.Steps[i] = MacroStep{
+			VkCode: s.VkCode,
+			Down:   s.Down,
+			Delay:  time.Duration(s.DelayMs * float64(time.Millisecond)),
+		}
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+/*
+	SaveMacroFile writes the macro to the named file.
+*/
+func SaveMacroFile(name string, m *Macro) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := SaveMacro(f, m); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+/*
+	LoadMacroFile reads a macro from the named file.
+*/
+func LoadMacroFile(name string) (*Macro, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadMacro(f)
+}
+
+/*
+	Validate checks that every step names a real virtual key, delays are not
+	negative, and every key pressed is released again, so that playing the
+	macro cannot leave keys stuck down.
+*/
+func (m *Macro) Validate() error {
+	if m == nil {
+		return errors.New("macro: nil macro")
+	}
+	held := make(map[uint16]bool)
+	for i, s := range m.Steps {
+		if s.VkCode == 0 || s.VkCode > 0xFE {
+			return fmt.Errorf("macro: step %d: invalid virtual key %#x", i, s.VkCode)
+		}
+		if s.Delay < 0 {
+			return fmt.Errorf("macro: step %d: negative delay", i)
+		}
+		if s.Down {
+			held[s.VkCode] = true
+		} else if !held[s.VkCode] {
+			return fmt.Errorf("macro: step %d: release of key %#x that is not pressed", i, s.VkCode)
+		} else {
+			delete(held, s.VkCode)
+		}
+	}
+	for vk := range held {
+		return fmt.Errorf("macro: key %#x is never released", vk)
+	}
+	return nil
+}
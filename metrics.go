@@ -0,0 +1,111 @@
+//go:build !localonly
+// +build !localonly
+
+package keylogger
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+/*
+	LocalOnly reports whether the package was built with the localonly tag,
+	which leaves out everything that listens on or talks to the network.
+*/
+const LocalOnly = false
+
+/*
+	MetricsHandler serves stats in the Prometheus format on /metrics and
+	as JSON on /stats.
+*/
+func MetricsHandler(stats func() Stats) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		stats().WritePrometheus(w)
+	})
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats())
+	})
+	return mux
+}
+
+/*
+	RuntimeStats is what DebugHandler serves on /debug/runtime: the state
+	of the Go runtime of a long-running capture and the occupancy of the
+	queue behind the hooks, as taken at Time.
+*/
+type RuntimeStats struct {
+	Time          time.Time     `json:"time"`
+	GoVersion     string        `json:"go_version"`
+	Uptime        time.Duration `json:"uptime_ns"` // since the run started
+	Goroutines    int           `json:"goroutines"`
+	Threads       int           `json:"threads"` // created by the runtime, not all of them running
+	HeapAlloc     uint64        `json:"heap_alloc_bytes"`
+	HeapObjects   uint64        `json:"heap_objects"`
+	Sys           uint64        `json:"sys_bytes"`
+	GCRuns        uint32        `json:"gc_runs"`
+	GCPauseTotal  time.Duration `json:"gc_pause_total_ns"`
+	LastGCPause   time.Duration `json:"last_gc_pause_ns"`
+	RingSize      int           `json:"ring_size"`
+	RingQueued    int           `json:"ring_queued"`
+	RingHighWater int           `json:"ring_high_water"`
+}
+
+/*
+	ReadRuntimeStats returns the RuntimeStats of now, with the queue and
+	uptime taken from s. Reading the memory statistics stops the world for
+	a moment.
+*/
+func ReadRuntimeStats(s Stats) RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	threads, _ := runtime.ThreadCreateProfile(nil)
+	r := RuntimeStats{
+		Time:          time.Now(),
+		GoVersion:     runtime.Version(),
+		Goroutines:    runtime.NumGoroutine(),
+		Threads:       threads,
+		HeapAlloc:     m.HeapAlloc,
+		HeapObjects:   m.HeapObjects,
+		Sys:           m.Sys,
+		GCRuns:        m.NumGC,
+		GCPauseTotal:  time.Duration(m.PauseTotalNs),
+		RingSize:      s.RingSize,
+		RingQueued:    s.RingQueued,
+		RingHighWater: s.RingHighWater,
+	}
+	if m.NumGC > 0 {
+		r.LastGCPause = time.Duration(m.PauseNs[(m.NumGC+255)%256])
+	}
+	if !s.Started.IsZero() {
+		r.Uptime = r.Time.Sub(s.Started)
+	}
+	return r
+}
+
+/*
+	DebugHandler serves the profiles of net/http/pprof under /debug/pprof/
+	and RuntimeStats as JSON on /debug/runtime, to profile a capture that
+	has been running for days where it runs. Goroutine dumps and CPU
+	profiles show what the program is doing, not what was typed, but they
+	are for whoever controls the capture: serve them behind a ScopeControl
+	token or on a loopback address only.
+*/
+func DebugHandler(stats func() Stats) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/runtime", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ReadRuntimeStats(stats()))
+	})
+	return mux
+}
@@ -0,0 +1,156 @@
+package keylogger
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+/*
+	MmapSink appends BinaryCodec records to a memory-mapped file. A Write is
+	a memory copy with no system call, which keeps up with raw mouse
+	movement where a write per batch starts to cost; the kernel writes the
+	pages back on its own, and every SyncInterval a Write also flushes them
+	to disk. The file grows in MmapChunk steps and is cut back to its records
+	on Close. After a crash the unused tail is zeros, which DecodeBinaryEvent
+	reports as ErrEndOfRecords.
+*/
+type MmapSink struct {
+	f        *os.File
+	m        mapping
+	off      int
+	interval time.Duration
+	synced   time.Time
+	buf      []byte
+}
+
+/*
+	MmapChunk is how much the file behind an MmapSink grows at a time.
+*/
+const MmapChunk = 16 << 20
+
+/*
+	DefaultMmapSyncInterval applies when OpenMmapSink is given no interval.
+*/
+const DefaultMmapSyncInterval = time.Second
+
+/*
+	OpenMmapSink opens or creates the file name and appends after the
+	records already in it. A negative syncInterval leaves flushing to the
+	kernel and Close.
+*/
+func OpenMmapSink(name string, syncInterval time.Duration) (*MmapSink, error) {
+	if syncInterval == 0 {
+		syncInterval = DefaultMmapSyncInterval
+	}
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s := &MmapSink{f: f, interval: syncInterval, synced: time.Now()}
+	if err := s.remap(int(st.Size()) + MmapChunk); err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.off = recordsEnd(s.m.data)
+	// Clear whatever a crash left of a half-copied record, so it cannot
+	// be mistaken for one once new records are written up to it.
+	tail := s.m.data[s.off:]
+	for i := range tail {
+		tail[i] = 0
+	}
+	return s, nil
+}
+
+func (s *MmapSink) Write(events []Event) error {
+	if s.f == nil {
+		return errors.New("mmap sink: closed")
+	}
+	s.buf = s.buf[:0]
+	for _, e := range events {
+		var err error
+		if s.buf, err = (BinaryCodec{}).AppendEvent(s.buf, e); err != nil {
+			return err
+		}
+	}
+	// Keep a zero byte after the last record to mark the end.
+	if need := s.off + len(s.buf) + 1; need > len(s.m.data) {
+		if err := s.remap(need + MmapChunk - need%MmapChunk); err != nil {
+			return err
+		}
+	}
+	s.off += copy(s.m.data[s.off:], s.buf)
+	if s.interval > 0 && time.Since(s.synced) >= s.interval {
+		return s.Sync()
+	}
+	return nil
+}
+
+/*
+	Sync writes the mapped records through to disk.
+*/
+func (s *MmapSink) Sync() error {
+	s.synced = time.Now()
+	return s.m.sync(s.f)
+}
+
+/*
+	Close syncs the records, unmaps the file and truncates it to the records.
+	The file is truncated and closed even if syncing or unmapping fails.
+*/
+func (s *MmapSink) Close() error {
+	if s.f == nil {
+		return errors.New("mmap sink: closed")
+	}
+	wipeBytes(s.buf[:cap(s.buf)])
+	err := s.Sync()
+	if uerr := s.m.unmap(); err == nil {
+		err = uerr
+	}
+	if terr := s.f.Truncate(int64(s.off)); err == nil {
+		err = terr
+	}
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	s.f = nil
+	return err
+}
+
+/*
+	Maps size bytes of the file, growing it as needed. The old mapping is
+	only given up once the new one is in place, so a failure leaves the sink
+	writing where it was.
+*/
+func (s *MmapSink) remap(size int) error {
+	m, err := mapFile(s.f, size)
+	if err != nil {
+		return err
+	}
+	old := s.m
+	s.m = m
+	if old.data != nil {
+		return old.unmap()
+	}
+	return nil
+}
+
+/*
+	Returns the offset after the last complete record in data.
+*/
+func recordsEnd(data []byte) int {
+	off := 0
+	for off < len(data) {
+		_, n, err := DecodeBinaryEvent(data[off:])
+		if err != nil && !errors.Is(err, ErrNewerSchema) {
+			break
+		}
+		off += n
+	}
+	return off
+}
@@ -0,0 +1,21 @@
+//go:build !windows && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !windows,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package keylogger
+
+import (
+	"errors"
+	"os"
+	"runtime"
+)
+
+type mapping struct {
+	data []byte
+}
+
+func mapFile(*os.File, int) (mapping, error) {
+	return mapping{}, errors.New("memory-mapped files are not supported on " + runtime.GOOS)
+}
+
+func (m *mapping) sync(*os.File) error { return nil }
+func (m *mapping) unmap() error        { m.data = nil; return nil }
@@ -0,0 +1,157 @@
+package keylogger
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var binaryEvents = []Event{
+	KeyEvent{VkCode: 'A', ScanCode: 0x1E, Down: true, Text: "a", Time: time.Unix(0, 1)},
+	MouseEvent{Action: MouseScroll, X: -5, Y: 1080, Delta: -120, Horizontal: true, Time: time.Unix(0, 2)},
+	FocusEvent{HWND: 0x10246, PID: 4242, Process: "notepad.exe", Title: "Untitled – Notepad", Time: time.Unix(0, 3)},
+	ProcessEvent{PID: 7, Name: "code.exe", Started: true, Time: time.Unix(0, 4)},
+	GamepadEvent{Pad: 1, Button: XINPUT_GAMEPAD_A, Down: true, State: GamepadState{Buttons: XINPUT_GAMEPAD_A, LeftX: -32768, RightTrigger: 255}, Time: time.Unix(0, 5)},
+	DiagnosticEvent{Kind: DiagHookReinstalled, Message: "keyboard hook", Time: time.Unix(0, 6)},
+	TextEvent{Start: time.Unix(0, 1), Process: "notepad.exe", Title: "Untitled – Notepad", Text: "héllo", Keys: 7, Uncertain: true, Time: time.Unix(0, 7)},
+	KeyEvent{VkCode: VK_PACKET, ScanCode: 'é', Flags: LLKHF_INJECTED, Down: true, OnScreen: true, Text: "é", Time: time.Unix(0, 8)},
+}
+
+/*
+	Records survive a reopen: the second sink appends after the first's
+	records and the file holds exactly both writes.
+*/
+func TestMmapSinkReopen(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "events.bin")
+	for i := 0; i < 2; i++ {
+		s, err := OpenMmapSink(name, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Write(binaryEvents); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := OpenMmapSink(name, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var got []Event
+	for data := s.m.data; ; {
+		e, n, err := DecodeBinaryEvent(data)
+		if err == ErrEndOfRecords {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+		data = data[n:]
+	}
+	want := append(append([]Event(nil), binaryEvents...), binaryEvents...)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("read back\n%v\nwant\n%v", got, want)
+	}
+}
+
+/*
+	A mapping that cannot grow leaves the old one in place: the records
+	written so far stay mapped, later writes still land, and Close cuts the
+	file back to them.
+*/
+func TestMmapSinkRemapFailure(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "events.bin")
+	s, err := OpenMmapSink(name, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(binaryEvents); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.remap(int(^uint(0) >> 1)); err == nil {
+		t.Fatal("remap to the largest int succeeded")
+	}
+	if s.m.data == nil {
+		t.Fatal("failed remap dropped the mapping")
+	}
+	if err := s.Write(binaryEvents); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Event
+	for len(data) > 0 {
+		e, n, err := DecodeBinaryEvent(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+		data = data[n:]
+	}
+	want := append(append([]Event(nil), binaryEvents...), binaryEvents...)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("read back\n%v\nwant\n%v", got, want)
+	}
+}
+
+/*
+	Batches of mouse movement with the occasional key, as written by a
+	Batcher in front of the sink.
+*/
+func benchmarkSink(b *testing.B, s Sink) {
+	batch := make([]Event, DefaultBatchSize)
+	now := time.Now()
+	for i := range batch {
+		if i%16 == 0 {
+			batch[i] = KeyEvent{VkCode: 'A', Down: true, Text: "a", Time: now}
+		} else {
+			batch[i] = MouseEvent{Action: MouseMove, X: int32(i), Y: int32(i), Time: now}
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.Write(batch); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	if err := s.Close(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkMmapSink(b *testing.B) {
+	s, err := OpenMmapSink(filepath.Join(b.TempDir(), "events.bin"), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkSink(b, s)
+}
+
+func BenchmarkFileSinkBinary(b *testing.B) {
+	s, err := NewFileSink(filepath.Join(b.TempDir(), "events.bin"), BinaryCodec{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkSink(b, s)
+}
+
+func BenchmarkFileSinkJSON(b *testing.B) {
+	s, err := NewFileSink(filepath.Join(b.TempDir(), "events.jsonl"), JSONCodec{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkSink(b, s)
+}
@@ -0,0 +1,35 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package keylogger
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+type mapping struct {
+	data []byte
+}
+
+func mapFile(f *os.File, size int) (mapping, error) {
+	if err := f.Truncate(int64(size)); err != nil {
+		return mapping{}, err
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return mapping{}, os.NewSyscallError("mmap", err)
+	}
+	return mapping{data: data}, nil
+}
+
+func (m *mapping) sync(*os.File) error {
+	return os.NewSyscallError("msync", unix.Msync(m.data, unix.MS_SYNC))
+}
+
+func (m *mapping) unmap() error {
+	err := unix.Munmap(m.data)
+	m.data = nil
+	return os.NewSyscallError("munmap", err)
+}
@@ -0,0 +1,53 @@
+package keylogger
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type mapping struct {
+	data []byte
+	h    windows.Handle
+	addr uintptr
+}
+
+/*
+	CreateFileMapping grows the file to size itself, which SetEndOfFile
+	cannot do while the old view is still mapped.
+*/
+func mapFile(f *os.File, size int) (mapping, error) {
+	h, err := windows.CreateFileMapping(windows.Handle(f.Fd()), nil, windows.PAGE_READWRITE, uint32(uint64(size)>>32), uint32(size), nil)
+	if err != nil {
+		return mapping{}, os.NewSyscallError("CreateFileMapping", err)
+	}
+	addr, err := windows.MapViewOfFile(h, windows.FILE_MAP_WRITE, 0, 0, uintptr(size))
+	if err != nil {
+		windows.CloseHandle(h)
+		return mapping{}, os.NewSyscallError("MapViewOfFile", err)
+	}
+	data := unsafe.Slice(*(**byte)(unsafe.Pointer(&addr)), size)
+	return mapping{data: data, h: h, addr: addr}, nil
+}
+
+/*
+	FlushViewOfFile only hands the pages to the file system, so the file is
+	flushed as well to get what msync(MS_SYNC) does.
+*/
+func (m *mapping) sync(f *os.File) error {
+	if err := windows.FlushViewOfFile(m.addr, uintptr(len(m.data))); err != nil {
+		return os.NewSyscallError("FlushViewOfFile", err)
+	}
+	return f.Sync()
+}
+
+func (m *mapping) unmap() error {
+	err := windows.UnmapViewOfFile(m.addr)
+	windows.CloseHandle(m.h)
+	*m = mapping{}
+	if err != nil {
+		return os.NewSyscallError("UnmapViewOfFile", err)
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package keylogger
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+	MouseButton identifies a mouse button.
+*/
+type MouseButton int
+
+const (
+	LeftButton MouseButton = iota + 1
+	RightButton
+	MiddleButton
+	XButton1
+	XButton2
+)
+
+var mouseButtonNames = map[MouseButton]string{
+	LeftButton:   "Left",
+	RightButton:  "Right",
+	MiddleButton: "Middle",
+	XButton1:     "X1",
+	XButton2:     "X2",
+}
+
+func (b MouseButton) String() string {
+	if n, ok := mouseButtonNames[b]; ok {
+		return n
+	}
+	return fmt.Sprintf("MouseButton(%d)", int(b))
+}
+
+/*
+	ParseMouseButton returns the button for a name accepted by String.
+*/
+func ParseMouseButton(s string) (MouseButton, error) {
+	for b, n := range mouseButtonNames {
+		if strings.EqualFold(n, s) {
+			return b, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown mouse button %q", s)
+}
+
+/*
+	MarshalText returns the button name, or nothing for no button.
+*/
+func (b MouseButton) MarshalText() ([]byte, error) {
+	if b == 0 {
+		return nil, nil
+	}
+	return []byte(b.String()), nil
+}
+
+func (b *MouseButton) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*b = 0
+		return nil
+	}
+	v, err := ParseMouseButton(string(text))
+	*b = v
+	return err
+}
+
+/*
+	MouseAction is the kind of a MouseEvent.
+*/
+type MouseAction int
+
+const (
+	MouseMove MouseAction = iota + 1
+	MouseDown
+	MouseUp
+	MouseScroll
+)
+
+var mouseActionNames = map[MouseAction]string{
+	MouseMove:   "move",
+	MouseDown:   "down",
+	MouseUp:     "up",
+	MouseScroll: "scroll",
+}
+
+func (a MouseAction) String() string {
+	if n, ok := mouseActionNames[a]; ok {
+		return n
+	}
+	return fmt.Sprintf("MouseAction(%d)", int(a))
+}
+
+func (a MouseAction) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *MouseAction) UnmarshalText(text []byte) error {
+	for v, n := range mouseActionNames {
+		if n == string(text) {
+			*a = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown mouse action %q", text)
+}
+
+/*
+	Flags reported in MSLLHOOKSTRUCT.Flags.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-msllhookstruct
+*/
+const (
+	LLMHF_INJECTED          = 0x01
+	LLMHF_LOWER_IL_INJECTED = 0x02
+)
+
+/*
+	MouseEvent is a single mouse event observed by the mouse hook.
+	X and Y are screen coordinates of the cursor. Button is set for
+	MouseDown and MouseUp; DoubleClick marks the second press of a
+	double-click, as defined by the user's double-click time and distance.
+	MouseScroll events carry the signed wheel Delta, in multiples of
+	WheelDelta for notched wheels, and whether the horizontal wheel turned.
+*/
+type MouseEvent struct {
+	Action      MouseAction `json:"action"`
+	Button      MouseButton `json:"button,omitempty"`
+	X           int32       `json:"x"`
+	Y           int32       `json:"y"`
+	DoubleClick bool        `json:"double_click,omitempty"`
+	Delta       int32       `json:"delta,omitempty"`
+	Horizontal  bool        `json:"horizontal,omitempty"`
+	Flags       uint32      `json:"flags,omitempty"`
+	ExtraInfo   uintptr     `json:"extra_info,omitempty"`
+	Time        time.Time   `json:"time"`
+}
+
+/*
+	Timestamp returns when the hook saw the event.
+*/
+func (e MouseEvent) Timestamp() time.Time {
+	return e.Time
+}
+
+/*
+	WheelDelta is the wheel rotation of one notch.
+*/
+const WheelDelta = 120
+
+/*
+	ScrollDirection is the direction of a MouseScroll event.
+*/
+type ScrollDirection int
+
+const (
+	ScrollUp ScrollDirection = iota + 1
+	ScrollDown
+	ScrollLeft
+	ScrollRight
+)
+
+func (d ScrollDirection) String() string {
+	switch d {
+	case ScrollUp:
+		return "up"
+	case ScrollDown:
+		return "down"
+	case ScrollLeft:
+		return "left"
+	case ScrollRight:
+		return "right"
+	}
+	return fmt.Sprintf("ScrollDirection(%d)", int(d))
+}
+
+/*
+	Direction returns where a MouseScroll event scrolls, or 0 for other events.
+	Turning the vertical wheel away from the user scrolls up.
+*/
+func (e MouseEvent) Direction() ScrollDirection {
+	switch {
+	case e.Action != MouseScroll || e.Delta == 0:
+		return 0
+	case e.Horizontal && e.Delta > 0:
+		return ScrollRight
+	case e.Horizontal:
+		return ScrollLeft
+	case e.Delta > 0:
+		return ScrollUp
+	}
+	return ScrollDown
+}
+
+/*
+	Notches returns the magnitude of a scroll in wheel notches. High-resolution
+	wheels and touchpads report fractions of a notch.
+*/
+func (e MouseEvent) Notches() float64 {
+	if e.Delta < 0 {
+		return float64(-e.Delta) / WheelDelta
+	}
+	return float64(e.Delta) / WheelDelta
+}
+
+/*
+	Injected reports whether the event was synthesized rather than produced by a mouse.
+*/
+func (e MouseEvent) Injected() bool {
+	return e.Flags&LLMHF_INJECTED != 0
+}
+
+/*
+	FromInjector reports whether the event was sent by an Injector of this package.
+*/
+func (e MouseEvent) FromInjector() bool {
+	return e.Injected() && e.ExtraInfo == InjectedSignature
+}
+
+/*
+	MouseSource is the device a mouse event came from.
+*/
+type MouseSource int
+
+const (
+	SourceMouse MouseSource = iota
+	SourcePen
+	SourceTouch
+)
+
+func (s MouseSource) String() string {
+	switch s {
+	case SourcePen:
+		return "pen"
+	case SourceTouch:
+		return "touch"
+	}
+	return "mouse"
+}
+
+/*
+	Signature Windows puts in dwExtraInfo of mouse input promoted from pen and
+	touch contacts; MI_WP_TOUCH distinguishes touch from pen.
+	https://docs.microsoft.com/en-us/windows/win32/tablet/system-events-and-mouse-messages
+*/
+const (
+	MI_WP_SIGNATURE = 0xFF515700
+	MI_WP_MASK      = 0xFFFFFF00
+	MI_WP_TOUCH     = 0x80
+)
+
+/*
+	Source reports whether the event was produced by a mouse, a pen or a touch
+	contact. Low-level hooks only see pen and touch input after Windows has
+	promoted it to mouse input, so pressure, tilt and multi-touch contacts
+	are not available; WM_POINTER messages only reach the window under the
+	contact and cannot be hooked system-wide.
+*/
+func (e MouseEvent) Source() MouseSource {
+	if uint64(e.ExtraInfo)&MI_WP_MASK != MI_WP_SIGNATURE {
+		return SourceMouse
+	}
+	if e.ExtraInfo&MI_WP_TOUCH != 0 {
+		return SourceTouch
+	}
+	return SourcePen
+}
+
+/*
+	Recognizes double-clicks. Low-level hooks only see individual presses;
+	Windows synthesizes WM_LBUTTONDBLCLK later, and only for windows that ask
+	for it, so the rule is applied here: a second press of the same button
+	within the double-click time and rectangle of the first.
+*/
+type doubleClicks struct {
+	interval      time.Duration
+	width, height int32
+
+	last   MouseEvent
+	paired bool
+}
+
+/*
+	Marks e as a double-click if it completes one.
+*/
+func (d *doubleClicks) check(e *MouseEvent) {
+	if e.Action != MouseDown {
+		return
+	}
+	l := d.last
+	if !d.paired && l.Action == MouseDown && l.Button == e.Button &&
+		e.Time.Sub(l.Time) <= d.interval &&
+		abs32(e.X-l.X) <= d.width/2 && abs32(e.Y-l.Y) <= d.height/2 {
+		e.DoubleClick = true
+	}
+	// A third press starts a new pair rather than completing another.
+	d.paired = e.DoubleClick
+	d.last = *e
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
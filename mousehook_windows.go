@@ -0,0 +1,112 @@
+package keylogger
+
+import (
+	"time"
+	"unsafe"
+)
+
+var (
+	getDoubleClickTime = user32.NewProc("GetDoubleClickTime")
+)
+
+/*
+	Contains information about a low-level mouse input event.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-msllhookstruct
+*/
+type MSLLHOOKSTRUCT struct {
+	Pt          POINT
+	MouseData   DWORD
+	Flags       DWORD
+	Time        DWORD
+	DwExtraInfo uintptr
+}
+
+/*
+	Mouse messages passed to a WH_MOUSE_LL hook.
+	https://docs.microsoft.com/en-us/windows/win32/inputdev/mouse-input-notifications
+*/
+const (
+	WM_MOUSEMOVE   = 0x0200
+	WM_LBUTTONDOWN = 0x0201
+	WM_LBUTTONUP   = 0x0202
+	WM_RBUTTONDOWN = 0x0204
+	WM_RBUTTONUP   = 0x0205
+	WM_MBUTTONDOWN = 0x0207
+	WM_MBUTTONUP   = 0x0208
+	WM_MOUSEWHEEL  = 0x020A
+	WM_XBUTTONDOWN = 0x020B
+	WM_XBUTTONUP   = 0x020C
+	WM_MOUSEHWHEEL = 0x020E
+
+	SM_CXDOUBLECLK = 36
+	SM_CYDOUBLECLK = 37
+)
+
+func newDoubleClicks() doubleClicks {
+	return doubleClicks{
+		interval: time.Duration(GetDoubleClickTime()) * time.Millisecond,
+		width:    int32(GetSystemMetrics(SM_CXDOUBLECLK)),
+		height:   int32(GetSystemMetrics(SM_CYDOUBLECLK)),
+	}
+}
+
+func (l *Logger) mouseProc(nCode int, wparam WPARAM, lparam LPARAM) LRESULT {
+	if nCode == HC_ACTION {
+		msllstruct := *(**MSLLHOOKSTRUCT)(unsafe.Pointer(&lparam))
+		if l.watch.sawMouse(msllstruct) {
+			return 1
+		}
+		if !l.onTarget() {
+			return l.api.CallNext(nCode, wparam, lparam)
+		}
+		e := MouseEvent{
+			X:         msllstruct.Pt.X,
+			Y:         msllstruct.Pt.Y,
+			Flags:     uint32(msllstruct.Flags),
+			ExtraInfo: msllstruct.DwExtraInfo,
+			Time:      time.Now(),
+		}
+		switch wparam {
+		case WM_MOUSEMOVE:
+			e.Action = MouseMove
+		case WM_LBUTTONDOWN, WM_RBUTTONDOWN, WM_MBUTTONDOWN, WM_XBUTTONDOWN:
+			e.Action = MouseDown
+		case WM_LBUTTONUP, WM_RBUTTONUP, WM_MBUTTONUP, WM_XBUTTONUP:
+			e.Action = MouseUp
+		case WM_MOUSEWHEEL, WM_MOUSEHWHEEL:
+			// HIWORD(mouseData) is the signed wheel delta.
+			e.Action = MouseScroll
+			e.Delta = int32(int16(msllstruct.MouseData >> 16))
+			e.Horizontal = wparam == WM_MOUSEHWHEEL
+		}
+		switch wparam {
+		case WM_LBUTTONDOWN, WM_LBUTTONUP:
+			e.Button = LeftButton
+		case WM_RBUTTONDOWN, WM_RBUTTONUP:
+			e.Button = RightButton
+		case WM_MBUTTONDOWN, WM_MBUTTONUP:
+			e.Button = MiddleButton
+		case WM_XBUTTONDOWN, WM_XBUTTONUP:
+			// HIWORD(mouseData) names the X button.
+			if msllstruct.MouseData>>16 == XBUTTON2 {
+				e.Button = XButton2
+			} else {
+				e.Button = XButton1
+			}
+		}
+		if e.Action != 0 {
+			l.pushCurrent(&rawEvent{kind: rawMouse, mouse: e})
+		}
+		l.hookTime.observe(time.Since(e.Time))
+	}
+	return l.api.CallNext(nCode, wparam, lparam)
+}
+
+/*
+	Retrieves the current double-click time for the mouse, in milliseconds.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getdoubleclicktime
+*/
+func GetDoubleClickTime() uint32 {
+	ret, _, _ := getDoubleClickTime.Call()
+	return uint32(ret)
+}
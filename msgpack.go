@@ -0,0 +1,601 @@
+package keylogger
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+/*
+	MsgpackCodec writes each event as a MessagePack map, with the keys and
+	values of the JSON object JSONCodec writes: "type", "v" holding
+	SchemaVersion, the field names of the event's JSON tags, mouse actions and buttons as names and
+	times as MessagePack timestamps. Records need no schema to be read by
+	any MessagePack library and are about a third smaller than JSON lines,
+	though several times larger than BinaryCodec's. A map is its own length, so records
+	follow each other without separators.
+*/
+type MsgpackCodec struct{}
+
+const msgpackTimestamp = -1
+
+func (MsgpackCodec) AppendEvent(dst []byte, e Event) ([]byte, error) {
+	w := msgpackWriter{buf: dst, at: len(dst)}
+	w.buf = append(w.buf, 0x80)
+	w.key("type")
+	w.string(EventType(e))
+	w.key("v")
+	w.uint(SchemaVersion)
+	switch e := e.(type) {
+	case KeyEvent:
+		w.key("vk")
+		w.uint(uint64(e.VkCode))
+		w.key("scan_code")
+		w.uint(uint64(e.ScanCode))
+		if e.Flags != 0 {
+			w.key("flags")
+			w.uint(uint64(e.Flags))
+		}
+		if e.ExtraInfo != 0 {
+			w.key("extra_info")
+			w.uint(uint64(e.ExtraInfo))
+		}
+		w.key("down")
+		w.bool(e.Down)
+		if e.Swallowed {
+			w.key("swallowed")
+			w.bool(true)
+		}
+		if e.OnScreen {
+			w.key("on_screen")
+			w.bool(true)
+		}
+		if e.Text != "" {
+			w.key("text")
+			w.string(e.Text)
+		}
+		w.key("time")
+		w.time(e.Time)
+	case MouseEvent:
+		w.key("action")
+		w.string(e.Action.String())
+		if e.Button != 0 {
+			w.key("button")
+			w.string(e.Button.String())
+		}
+		w.key("x")
+		w.int(int64(e.X))
+		w.key("y")
+		w.int(int64(e.Y))
+		if e.DoubleClick {
+			w.key("double_click")
+			w.bool(true)
+		}
+		if e.Delta != 0 {
+			w.key("delta")
+			w.int(int64(e.Delta))
+		}
+		if e.Horizontal {
+			w.key("horizontal")
+			w.bool(true)
+		}
+		if e.Flags != 0 {
+			w.key("flags")
+			w.uint(uint64(e.Flags))
+		}
+		if e.ExtraInfo != 0 {
+			w.key("extra_info")
+			w.uint(uint64(e.ExtraInfo))
+		}
+		w.key("time")
+		w.time(e.Time)
+	case FocusEvent:
+		w.key("hwnd")
+		w.uint(uint64(e.HWND))
+		w.key("pid")
+		w.uint(uint64(e.PID))
+		w.key("process")
+		w.string(e.Process)
+		w.key("title")
+		w.string(e.Title)
+		if e.Elevated {
+			w.key("elevated")
+			w.bool(true)
+		}
+		w.key("time")
+		w.time(e.Time)
+	case ProcessEvent:
+		w.key("pid")
+		w.uint(uint64(e.PID))
+		w.key("name")
+		w.string(e.Name)
+		w.key("started")
+		w.bool(e.Started)
+		w.key("time")
+		w.time(e.Time)
+	case GamepadEvent:
+		w.key("pad")
+		w.int(int64(e.Pad))
+		if e.Button != 0 {
+			w.key("button")
+			w.uint(uint64(e.Button))
+		}
+		if e.Down {
+			w.key("down")
+			w.bool(true)
+		}
+		w.key("state")
+		w.buf = append(w.buf, 0x87)
+		for _, f := range []struct {
+			name string
+			v    int64
+		}{
+			{"buttons", int64(e.State.Buttons)},
+			{"left_trigger", int64(e.State.LeftTrigger)},
+			{"right_trigger", int64(e.State.RightTrigger)},
+			{"left_x", int64(e.State.LeftX)},
+			{"left_y", int64(e.State.LeftY)},
+			{"right_x", int64(e.State.RightX)},
+			{"right_y", int64(e.State.RightY)},
+		} {
+			w.string(f.name)
+			w.int(f.v)
+		}
+		w.key("time")
+		w.time(e.Time)
+	case TextEvent:
+		w.key("start")
+		w.time(e.Start)
+		w.key("process")
+		w.string(e.Process)
+		w.key("title")
+		w.string(e.Title)
+		w.key("text")
+		w.string(e.Text)
+		w.key("keys")
+		w.int(int64(e.Keys))
+		if e.Uncertain {
+			w.key("uncertain")
+			w.bool(true)
+		}
+		w.key("time")
+		w.time(e.Time)
+	case DiagnosticEvent:
+		w.key("kind")
+		w.string(e.Kind)
+		w.key("message")
+		w.string(e.Message)
+		w.key("time")
+		w.time(e.Time)
+	default:
+		return dst, fmt.Errorf("cannot encode %s event", EventType(e))
+	}
+	// Every event has fewer than 16 keys, so the map header is one byte.
+	w.buf[w.at] = 0x80 | byte(w.keys)
+	return w.buf, nil
+}
+
+/*
+	DecodeMsgpackEvent parses the record at the start of data, written by
+	MsgpackCodec, and returns the event and the record's size, which it
+	also returns with ErrNewerSchema. Keys it does not know are skipped, so
+	records may carry more than MsgpackCodec writes.
+*/
+func DecodeMsgpackEvent(data []byte) (Event, int, error) {
+	r := msgpackReader{buf: data}
+	v := r.value(0)
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, 0, errors.New("msgpack record: not a map")
+	}
+	size := len(data) - len(r.buf)
+	f := msgpackFields(m)
+	version := uint64(1)
+	switch v := m["v"].(type) {
+	case nil:
+	case int64:
+		if v < 1 {
+			return nil, 0, fmt.Errorf("msgpack record: bad schema version %d", v)
+		}
+		version = uint64(v)
+	case uint64:
+		version = v
+	default:
+		return nil, 0, errors.New("msgpack record: bad schema version")
+	}
+	if version > SchemaVersion {
+		return nil, size, newerSchema(version)
+	}
+	var e Event
+	switch t, _ := m["type"].(string); t {
+	case "key":
+		e = KeyEvent{
+			VkCode:    uint16(f.uint("vk")),
+			ScanCode:  uint32(f.uint("scan_code")),
+			Flags:     uint32(f.uint("flags")),
+			ExtraInfo: uintptr(f.uint("extra_info")),
+			Down:      f.bool("down"),
+			Swallowed: f.bool("swallowed"),
+			OnScreen:  f.bool("on_screen"),
+			Text:      f.string("text"),
+			Time:      f.time("time"),
+		}
+	case "mouse":
+		ev := MouseEvent{
+			X:           int32(f.int("x")),
+			Y:           int32(f.int("y")),
+			DoubleClick: f.bool("double_click"),
+			Delta:       int32(f.int("delta")),
+			Horizontal:  f.bool("horizontal"),
+			Flags:       uint32(f.uint("flags")),
+			ExtraInfo:   uintptr(f.uint("extra_info")),
+			Time:        f.time("time"),
+		}
+		if err := ev.Action.UnmarshalText([]byte(f.string("action"))); err != nil || ev.Action == 0 {
+			return nil, 0, errors.New("msgpack record: mouse record without action")
+		}
+		if b, ok := m["button"].(string); ok {
+			if err := ev.Button.UnmarshalText([]byte(b)); err != nil {
+				return nil, 0, fmt.Errorf("msgpack record: %v", err)
+			}
+		}
+		e = ev
+	case "focus":
+		e = FocusEvent{
+			HWND:     uintptr(f.uint("hwnd")),
+			PID:      uint32(f.uint("pid")),
+			Process:  f.string("process"),
+			Title:    f.string("title"),
+			Elevated: f.bool("elevated"),
+			Time:     f.time("time"),
+		}
+	case "process":
+		e = ProcessEvent{
+			PID:     uint32(f.uint("pid")),
+			Name:    f.string("name"),
+			Started: f.bool("started"),
+			Time:    f.time("time"),
+		}
+	case "gamepad":
+		state, _ := m["state"].(map[string]interface{})
+		s := msgpackFields(state)
+		e = GamepadEvent{
+			Pad:    int(f.int("pad")),
+			Button: GamepadButton(f.uint("button")),
+			Down:   f.bool("down"),
+			State: GamepadState{
+				Buttons:      GamepadButton(s.uint("buttons")),
+				LeftTrigger:  uint8(s.uint("left_trigger")),
+				RightTrigger: uint8(s.uint("right_trigger")),
+				LeftX:        int16(s.int("left_x")),
+				LeftY:        int16(s.int("left_y")),
+				RightX:       int16(s.int("right_x")),
+				RightY:       int16(s.int("right_y")),
+			},
+			Time: f.time("time"),
+		}
+	case "text":
+		e = TextEvent{
+			Start:     f.time("start"),
+			Process:   f.string("process"),
+			Title:     f.string("title"),
+			Text:      f.string("text"),
+			Keys:      int(f.int("keys")),
+			Uncertain: f.bool("uncertain"),
+			Time:      f.time("time"),
+		}
+	case "diagnostic":
+		e = DiagnosticEvent{
+			Kind:    f.string("kind"),
+			Message: f.string("message"),
+			Time:    f.time("time"),
+		}
+	default:
+		return nil, 0, fmt.Errorf("msgpack record: unknown event type %q", t)
+	}
+	e, err := migrate(e, version)
+	if err != nil {
+		return nil, 0, err
+	}
+	return e, size, nil
+}
+
+/*
+	Writes MessagePack values, counting the keys of the map at at.
+*/
+type msgpackWriter struct {
+	buf  []byte
+	at   int
+	keys int
+}
+
+func (w *msgpackWriter) key(name string) {
+	w.keys++
+	w.string(name)
+}
+
+func (w *msgpackWriter) bool(b bool) {
+	if b {
+		w.buf = append(w.buf, 0xC3)
+	} else {
+		w.buf = append(w.buf, 0xC2)
+	}
+}
+
+func (w *msgpackWriter) uint(v uint64) {
+	switch {
+	case v < 0x80:
+		w.buf = append(w.buf, byte(v))
+	case v <= math.MaxUint8:
+		w.buf = append(w.buf, 0xCC, byte(v))
+	case v <= math.MaxUint16:
+		w.buf = appendBigEndian16(append(w.buf, 0xCD), uint16(v))
+	case v <= math.MaxUint32:
+		w.buf = appendBigEndian32(append(w.buf, 0xCE), uint32(v))
+	default:
+		w.buf = appendBigEndian64(append(w.buf, 0xCF), v)
+	}
+}
+
+func (w *msgpackWriter) int(v int64) {
+	switch {
+	case v >= 0:
+		w.uint(uint64(v))
+	case v >= -32:
+		w.buf = append(w.buf, byte(v))
+	case v >= math.MinInt8:
+		w.buf = append(w.buf, 0xD0, byte(v))
+	case v >= math.MinInt16:
+		w.buf = appendBigEndian16(append(w.buf, 0xD1), uint16(v))
+	case v >= math.MinInt32:
+		w.buf = appendBigEndian32(append(w.buf, 0xD2), uint32(v))
+	default:
+		w.buf = appendBigEndian64(append(w.buf, 0xD3), uint64(v))
+	}
+}
+
+func (w *msgpackWriter) string(s string) {
+	switch n := len(s); {
+	case n < 32:
+		w.buf = append(w.buf, 0xA0|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, 0xD9, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = appendBigEndian16(append(w.buf, 0xDA), uint16(n))
+	default:
+		w.buf = appendBigEndian32(append(w.buf, 0xDB), uint32(n))
+	}
+	w.buf = append(w.buf, s...)
+}
+
+/*
+	Writes t in the smallest timestamp format holding it. The zero time
+	is written as the instant it stands for, 1 January of year 1.
+*/
+func (w *msgpackWriter) time(t time.Time) {
+	sec, nsec := t.Unix(), uint32(t.Nanosecond())
+	switch {
+	case nsec == 0 && sec>>32 == 0:
+		w.buf = appendBigEndian32(append(w.buf, 0xD6, 0xFF), uint32(sec))
+	case sec>>34 == 0:
+		w.buf = appendBigEndian64(append(w.buf, 0xD7, 0xFF), uint64(nsec)<<34|uint64(sec))
+	default:
+		w.buf = appendBigEndian32(append(w.buf, 0xC7, 12, 0xFF), nsec)
+		w.buf = appendBigEndian64(w.buf, uint64(sec))
+	}
+}
+
+func appendBigEndian16(dst []byte, v uint16) []byte {
+	return append(dst, byte(v>>8), byte(v))
+}
+
+func appendBigEndian32(dst []byte, v uint32) []byte {
+	return append(dst, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendBigEndian64(dst []byte, v uint64) []byte {
+	return appendBigEndian32(appendBigEndian32(dst, uint32(v>>32)), uint32(v))
+}
+
+/*
+	Reads MessagePack values until the first error. Integers are read as
+	int64, or uint64 above math.MaxInt64, timestamps as time.Time and maps
+	as map[string]interface{}, skipping entries whose key is no string.
+*/
+type msgpackReader struct {
+	buf []byte
+	err error
+}
+
+const msgpackMaxDepth = 8
+
+var errMsgpackShort = errors.New("msgpack record: truncated")
+
+func (r *msgpackReader) take(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if uint64(len(r.buf)) < n {
+		r.err = errMsgpackShort
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *msgpackReader) size(n int) uint64 {
+	b := r.take(uint64(n))
+	switch {
+	case b == nil:
+		return 0
+	case n == 1:
+		return uint64(b[0])
+	case n == 2:
+		return uint64(binary.BigEndian.Uint16(b))
+	case n == 4:
+		return uint64(binary.BigEndian.Uint32(b))
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *msgpackReader) value(depth int) interface{} {
+	if depth > msgpackMaxDepth {
+		r.err = errors.New("msgpack record: nested too deeply")
+		return nil
+	}
+	b := r.take(1)
+	if b == nil {
+		return nil
+	}
+	switch c := b[0]; {
+	case c < 0x80:
+		return int64(c)
+	case c >= 0xE0:
+		return int64(int8(c))
+	case c&0xF0 == 0x80:
+		return r.mapValue(uint64(c&0x0F), depth)
+	case c&0xF0 == 0x90:
+		return r.array(uint64(c&0x0F), depth)
+	case c&0xE0 == 0xA0:
+		return string(r.take(uint64(c & 0x1F)))
+	}
+	switch c := b[0]; c {
+	case 0xC0:
+		return nil
+	case 0xC2, 0xC3:
+		return c == 0xC3
+	case 0xC4, 0xC5, 0xC6:
+		return r.take(r.size(1 << (c - 0xC4)))
+	case 0xC7, 0xC8, 0xC9:
+		n := r.size(1 << (c - 0xC7))
+		return r.ext(n)
+	case 0xCA:
+		return float64(math.Float32frombits(uint32(r.size(4))))
+	case 0xCB:
+		return math.Float64frombits(r.size(8))
+	case 0xCC, 0xCD, 0xCE, 0xCF:
+		v := r.size(1 << (c - 0xCC))
+		if v > math.MaxInt64 {
+			return v
+		}
+		return int64(v)
+	case 0xD0:
+		return int64(int8(r.size(1)))
+	case 0xD1:
+		return int64(int16(r.size(2)))
+	case 0xD2:
+		return int64(int32(r.size(4)))
+	case 0xD3:
+		return int64(r.size(8))
+	case 0xD4, 0xD5, 0xD6, 0xD7, 0xD8:
+		return r.ext(1 << (c - 0xD4))
+	case 0xD9, 0xDA, 0xDB:
+		return string(r.take(r.size(1 << (c - 0xD9))))
+	case 0xDC, 0xDD:
+		return r.array(r.size(2<<(c-0xDC)), depth)
+	case 0xDE, 0xDF:
+		return r.mapValue(r.size(2<<(c-0xDE)), depth)
+	}
+	r.err = fmt.Errorf("msgpack record: bad type byte 0x%02X", b[0])
+	return nil
+}
+
+func (r *msgpackReader) array(n uint64, depth int) interface{} {
+	// Every element takes at least a byte.
+	if n > uint64(len(r.buf)) {
+		r.err = errMsgpackShort
+		return nil
+	}
+	a := make([]interface{}, 0, n)
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		a = append(a, r.value(depth+1))
+	}
+	return a
+}
+
+func (r *msgpackReader) mapValue(n uint64, depth int) interface{} {
+	if n > uint64(len(r.buf))/2 {
+		r.err = errMsgpackShort
+		return nil
+	}
+	m := make(map[string]interface{}, n)
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		k := r.value(depth + 1)
+		v := r.value(depth + 1)
+		if s, ok := k.(string); ok {
+			m[s] = v
+		}
+	}
+	return m
+}
+
+/*
+	Reads the type and data of an extension of n bytes: a time for the
+	timestamp type, nil for others.
+*/
+func (r *msgpackReader) ext(n uint64) interface{} {
+	t := r.take(1)
+	data := r.take(n)
+	if r.err != nil || int8(t[0]) != msgpackTimestamp {
+		return nil
+	}
+	var sec int64
+	var nsec uint32
+	switch n {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		sec, nsec = int64(v&(1<<34-1)), uint32(v>>34)
+	case 12:
+		nsec, sec = binary.BigEndian.Uint32(data), int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		return nil
+	}
+	t0 := time.Unix(sec, int64(nsec))
+	if t0.IsZero() {
+		return time.Time{}
+	}
+	return t0
+}
+
+/*
+	The entries of a decoded map, read as the types of event fields; an
+	entry missing or of another type reads as zero.
+*/
+type msgpackFields map[string]interface{}
+
+func (f msgpackFields) uint(key string) uint64 {
+	switch v := f[key].(type) {
+	case int64:
+		return uint64(v)
+	case uint64:
+		return v
+	}
+	return 0
+}
+
+func (f msgpackFields) int(key string) int64 {
+	return int64(f.uint(key))
+}
+
+func (f msgpackFields) bool(key string) bool {
+	b, _ := f[key].(bool)
+	return b
+}
+
+func (f msgpackFields) string(key string) string {
+	s, _ := f[key].(string)
+	return s
+}
+
+func (f msgpackFields) time(key string) time.Time {
+	t, _ := f[key].(time.Time)
+	return t
+}
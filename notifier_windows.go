@@ -0,0 +1,245 @@
+package keylogger
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	shell32           = windows.NewLazySystemDLL("shell32.dll")
+	shellNotifyIconW  = shell32.NewProc("Shell_NotifyIconW")
+	loadIconW         = user32.NewProc("LoadIconW")
+	notifierClassOnce sync.Once
+	notifierClassErr  error
+)
+
+/*
+	Shell_NotifyIcon messages and flags.
+	https://docs.microsoft.com/en-us/windows/win32/api/shellapi/nf-shellapi-shell_notifyiconw
+*/
+const (
+	NIM_ADD              = 0
+	NIM_MODIFY           = 1
+	NIM_DELETE           = 2
+	NIM_SETVERSION       = 4
+	NIF_ICON             = 0x02
+	NIF_TIP              = 0x04
+	NIF_INFO             = 0x10
+	NIIF_INFO            = 0x01
+	NIIF_WARNING         = 0x02
+	NOTIFYICON_VERSION_4 = 4
+	IDI_INFORMATION      = 32516
+	HWND_MESSAGE         = ^uintptr(2) // (HWND)-3
+)
+
+/*
+	The window class and icon of notifiers, and how many UTF-16 units of
+	the title, text and tip Windows keeps.
+*/
+const (
+	notifierClass    = "KeyloggerNotifier"
+	notifierIconID   = 1
+	notifierMaxTitle = 63
+	notifierMaxText  = 255
+	notifierMaxTip   = 127
+)
+
+/*
+	NOTIFYICONDATAW
+	https://docs.microsoft.com/en-us/windows/win32/api/shellapi/ns-shellapi-notifyicondataw
+*/
+type notifyIconData struct {
+	size        uint32
+	hwnd        HWND
+	id          uint32
+	flags       uint32
+	callback    uint32
+	icon        windows.Handle
+	tip         [128]uint16
+	state       uint32
+	stateMask   uint32
+	info        [256]uint16
+	version     uint32 // also uTimeout
+	infoTitle   [64]uint16
+	infoFlags   uint32
+	guid        windows.GUID
+	balloonIcon windows.Handle
+}
+
+/*
+	Notifier shows Windows notifications, which Windows 10 and later
+	present as toasts, from an icon in the notification area that stays
+	there while the Notifier is shown: whoever sits at the machine can see
+	that the keylogger is running. Notify and Close may be called from any
+	goroutine; the icon's window runs on a thread of its own.
+*/
+type Notifier struct {
+	Tip string // shown when hovering the icon
+
+	mu   sync.Mutex
+	hwnd HWND
+	done chan struct{}
+}
+
+/*
+	NewNotifier returns a Notifier whose icon shows tip, not yet shown.
+*/
+func NewNotifier(tip string) *Notifier {
+	return &Notifier{Tip: tip}
+}
+
+/*
+	Show adds the icon and returns once it is there.
+*/
+func (n *Notifier) Show() error {
+	if n.done != nil {
+		return errors.New("notifier: already shown")
+	}
+	n.done = make(chan struct{})
+	errc := make(chan error, 1)
+	go n.run(errc)
+	return <-errc
+}
+
+/*
+	Notify shows a notification; warning gives it the warning icon. Long
+	titles and texts are cut to what Windows shows.
+*/
+func (n *Notifier) Notify(title, text string, warning bool) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.hwnd == 0 {
+		return errors.New("notifier: not shown")
+	}
+	d := n.data(NIF_INFO)
+	copyUTF16(d.infoTitle[:], title, notifierMaxTitle)
+	copyUTF16(d.info[:], text, notifierMaxText)
+	d.infoFlags = NIIF_INFO
+	if warning {
+		d.infoFlags = NIIF_WARNING
+	}
+	if ret, _, err := shellNotifyIconW.Call(NIM_MODIFY, uintptr(unsafe.Pointer(&d))); ret == 0 {
+		return fmt.Errorf("Shell_NotifyIcon: %v", err)
+	}
+	return nil
+}
+
+/*
+	Close removes the icon and its window.
+*/
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	hwnd := n.hwnd
+	if hwnd != 0 {
+		d := n.data(0)
+		shellNotifyIconW.Call(NIM_DELETE, uintptr(unsafe.Pointer(&d)))
+		n.hwnd = 0
+	}
+	n.mu.Unlock()
+	if hwnd == 0 {
+		return
+	}
+	postMessageW.Call(uintptr(hwnd), WM_CLOSE, 0, 0)
+	<-n.done
+}
+
+func (n *Notifier) data(flags uint32) notifyIconData {
+	d := notifyIconData{hwnd: n.hwnd, id: notifierIconID, flags: flags}
+	d.size = uint32(unsafe.Sizeof(d))
+	return d
+}
+
+func (n *Notifier) run(errc chan<- error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer close(n.done)
+	if err := n.create(); err != nil {
+		errc <- err
+		return
+	}
+	errc <- nil
+	var msg MSG
+	for GetMessage(&msg, 0, 0, 0) > 0 {
+		dispatchMessageW.Call(uintptr(unsafe.Pointer(&msg)))
+	}
+}
+
+/*
+	Creates a message-only window to own the icon and adds the icon.
+*/
+func (n *Notifier) create() error {
+	var instance windows.Handle
+	if err := windows.GetModuleHandleEx(0, nil, &instance); err != nil {
+		return err
+	}
+	class, _ := windows.UTF16PtrFromString(notifierClass)
+	notifierClassOnce.Do(func() {
+		wc := wndClassEx{
+			wndProc:   windows.NewCallback(notifierProc),
+			instance:  instance,
+			className: class,
+		}
+		wc.size = uint32(unsafe.Sizeof(wc))
+		if ret, _, err := registerClassExW.Call(uintptr(unsafe.Pointer(&wc))); ret == 0 {
+			notifierClassErr = fmt.Errorf("RegisterClassEx: %v", err)
+		}
+	})
+	if notifierClassErr != nil {
+		return notifierClassErr
+	}
+	ret, _, err := createWindowExW.Call(0, uintptr(unsafe.Pointer(class)), 0, 0, 0, 0, 0, 0, HWND_MESSAGE, 0, uintptr(instance), 0)
+	if ret == 0 {
+		return fmt.Errorf("CreateWindowEx: %v", err)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.hwnd = HWND(ret)
+	icon, _, _ := loadIconW.Call(0, IDI_INFORMATION)
+	d := n.data(NIF_ICON | NIF_TIP)
+	d.icon = windows.Handle(icon)
+	copyUTF16(d.tip[:], n.Tip, notifierMaxTip)
+	if ret, _, err := shellNotifyIconW.Call(NIM_ADD, uintptr(unsafe.Pointer(&d))); ret == 0 {
+		destroyWindow.Call(uintptr(n.hwnd))
+		n.hwnd = 0
+		return fmt.Errorf("Shell_NotifyIcon: %v", err)
+	}
+	d.version = NOTIFYICON_VERSION_4
+	shellNotifyIconW.Call(NIM_SETVERSION, uintptr(unsafe.Pointer(&d)))
+	return nil
+}
+
+func notifierProc(hwnd HWND, msg uint32, wparam WPARAM, lparam LPARAM) LRESULT {
+	switch msg {
+	case WM_CLOSE:
+		destroyWindow.Call(uintptr(hwnd))
+		return 0
+	case WM_DESTROY:
+		postQuitMessage.Call(0)
+		return 0
+	}
+	ret, _, _ := defWindowProcW.Call(uintptr(hwnd), uintptr(msg), uintptr(wparam), uintptr(lparam))
+	return LRESULT(ret)
+}
+
+/*
+	Copies at most max UTF-16 units of s into dst, which is left
+	NUL-terminated.
+*/
+func copyUTF16(dst []uint16, s string, max int) {
+	u, err := windows.UTF16FromString(s)
+	if err != nil {
+		// s holds a NUL.
+		u = []uint16{0}
+	}
+	u = u[:len(u)-1]
+	if len(u) > max {
+		u = u[:max]
+	}
+	copy(dst, u)
+	dst[len(u)] = 0
+}
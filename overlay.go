@@ -0,0 +1,152 @@
+package keylogger
+
+import (
+	"fmt"
+	"time"
+	"unicode"
+)
+
+/*
+	Default limits of a KeyDisplay.
+*/
+const (
+	DefaultDisplayLines   = 5
+	DefaultDisplayTimeout = 3 * time.Second
+	maxDisplayLineRunes   = 40
+)
+
+/*
+	KeyDisplay turns key presses into the lines of an on-screen overlay, as
+	screencasts show them: typed text runs together on one line, shortcuts
+	and keys that type nothing get a line of their own ("Ctrl+Shift+T",
+	"Enter"), and repeats of them are counted ("Backspace ×3"). Modifiers
+	are only shown with a key. It keeps the newest MaxLines lines
+	(DefaultDisplayLines if zero) and drops those not updated for Timeout
+	(DefaultDisplayTimeout if zero). Names names the keys of shortcuts,
+	KeyName if nil; KeyNamer returns one for other languages.
+*/
+type KeyDisplay struct {
+	MaxLines int
+	Timeout  time.Duration
+	Names    func(vk uint16) string
+
+	mods  ModifierState
+	lines []displayLine
+}
+
+type displayLine struct {
+	text   []rune
+	chord  string // empty for typed text
+	count  int
+	update time.Time
+}
+
+/*
+	Feed processes one key event and reports whether the lines changed.
+	Swallowed keys are shown as well: they were pressed.
+*/
+func (d *KeyDisplay) Feed(e KeyEvent) bool {
+	mods := d.mods.Update(e)
+	if !e.Down || modifierOf(e.VkCode) != 0 {
+		return false
+	}
+	text := []rune(e.Text)
+	if mods&(ModCtrl|ModAlt|ModWin) == 0 && len(text) > 0 && printable(text) {
+		if last := d.last(); last != nil && last.chord == "" && e.Time.Sub(last.update) < d.timeout() {
+			last.text = append(last.text, text...)
+			if n := len(last.text); n > maxDisplayLineRunes {
+				last.text = append(last.text[:0], last.text[n-maxDisplayLineRunes:]...)
+			}
+			last.update = e.Time
+			return true
+		}
+		d.add(displayLine{text: text, update: e.Time})
+		return true
+	}
+	if mods&(ModCtrl|ModAlt|ModWin) == 0 {
+		// Shift only counts for keys that type nothing, like Shift+Tab.
+		mods &= ModShift
+	}
+	names := d.Names
+	if names == nil {
+		names = KeyName
+	}
+	chord := Hotkey{Mods: mods, VkCode: e.VkCode}.format(names)
+	if last := d.last(); last != nil && last.chord == chord && e.Time.Sub(last.update) < d.timeout() {
+		last.count++
+		last.text = []rune(fmt.Sprintf("%s ×%d", chord, last.count))
+		last.update = e.Time
+		return true
+	}
+	d.add(displayLine{text: []rune(chord), chord: chord, count: 1, update: e.Time})
+	return true
+}
+
+/*
+	Lines returns the lines to show at now, the oldest first, and drops the
+	expired ones.
+*/
+func (d *KeyDisplay) Lines(now time.Time) []string {
+	keep := d.lines[:0]
+	for _, l := range d.lines {
+		if now.Sub(l.update) < d.timeout() {
+			keep = append(keep, l)
+		}
+	}
+	for i := len(keep); i < len(d.lines); i++ {
+		d.lines[i] = displayLine{}
+	}
+	d.lines = keep
+	lines := make([]string, len(d.lines))
+	for i, l := range d.lines {
+		lines[i] = string(l.text)
+	}
+	return lines
+}
+
+/*
+	Wipe clears the lines, which hold what was typed.
+*/
+func (d *KeyDisplay) Wipe() {
+	for i := range d.lines {
+		for j := range d.lines[i].text {
+			d.lines[i].text[j] = 0
+		}
+		d.lines[i] = displayLine{}
+	}
+	d.lines = d.lines[:0]
+}
+
+func (d *KeyDisplay) last() *displayLine {
+	if len(d.lines) == 0 {
+		return nil
+	}
+	return &d.lines[len(d.lines)-1]
+}
+
+func (d *KeyDisplay) add(l displayLine) {
+	max := d.MaxLines
+	if max <= 0 {
+		max = DefaultDisplayLines
+	}
+	d.lines = append(d.lines, l)
+	if n := len(d.lines); n > max {
+		d.lines = append(d.lines[:0], d.lines[n-max:]...)
+	}
+}
+
+func (d *KeyDisplay) timeout() time.Duration {
+	if d.Timeout <= 0 {
+		return DefaultDisplayTimeout
+	}
+	return d.Timeout
+}
+
+func printable(text []rune) bool {
+	for _, r := range text {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
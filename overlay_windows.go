@@ -0,0 +1,425 @@
+package keylogger
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	registerClassExW           = user32.NewProc("RegisterClassExW")
+	createWindowExW            = user32.NewProc("CreateWindowExW")
+	defWindowProcW             = user32.NewProc("DefWindowProcW")
+	destroyWindow              = user32.NewProc("DestroyWindow")
+	postQuitMessage            = user32.NewProc("PostQuitMessage")
+	postMessageW               = user32.NewProc("PostMessageW")
+	dispatchMessageW           = user32.NewProc("DispatchMessageW")
+	showWindow                 = user32.NewProc("ShowWindow")
+	setLayeredWindowAttributes = user32.NewProc("SetLayeredWindowAttributes")
+	setTimer                   = user32.NewProc("SetTimer")
+	invalidateRect             = user32.NewProc("InvalidateRect")
+	beginPaint                 = user32.NewProc("BeginPaint")
+	endPaint                   = user32.NewProc("EndPaint")
+	fillRect                   = user32.NewProc("FillRect")
+	drawTextW                  = user32.NewProc("DrawTextW")
+	getClientRect              = user32.NewProc("GetClientRect")
+
+	gdi32            = windows.NewLazySystemDLL("gdi32.dll")
+	createFontW      = gdi32.NewProc("CreateFontW")
+	createSolidBrush = gdi32.NewProc("CreateSolidBrush")
+	selectObject     = gdi32.NewProc("SelectObject")
+	setTextColor     = gdi32.NewProc("SetTextColor")
+	setBkMode        = gdi32.NewProc("SetBkMode")
+	deleteObject     = gdi32.NewProc("DeleteObject")
+)
+
+/*
+	Window styles, messages and text drawing flags of the overlay window.
+	https://docs.microsoft.com/en-us/windows/win32/winmsg/extended-window-styles
+*/
+const (
+	WS_POPUP          = 0x80000000
+	WS_EX_TOPMOST     = 0x00000008
+	WS_EX_TRANSPARENT = 0x00000020
+	WS_EX_TOOLWINDOW  = 0x00000080
+	WS_EX_LAYERED     = 0x00080000
+	WS_EX_NOACTIVATE  = 0x08000000
+	LWA_ALPHA         = 0x2
+	SW_HIDE           = 0
+	SW_SHOWNOACTIVATE = 4
+	SM_CXSCREEN       = 0
+	SM_CYSCREEN       = 1
+	WM_DESTROY        = 0x0002
+	WM_PAINT          = 0x000F
+	WM_CLOSE          = 0x0010
+	WM_TIMER          = 0x0113
+	DT_CENTER         = 0x01
+	DT_RIGHT          = 0x02
+	DT_VCENTER        = 0x04
+	DT_SINGLELINE     = 0x20
+	DT_NOPREFIX       = 0x800
+	DT_END_ELLIPSIS   = 0x8000
+)
+
+/*
+	The window class, message and timer of overlays.
+*/
+const (
+	overlayClass      = "KeyloggerOverlay"
+	wmOverlayUpdate   = WM_APP + 1
+	overlayTimer      = 1
+	overlayTick       = 200 * time.Millisecond
+	overlayMargin     = 40
+	overlayLineHeight = 1.4 // times the font size
+)
+
+/*
+	The RECT structure defines a rectangle by the coordinates of its
+	upper-left and lower-right corners.
+	https://docs.microsoft.com/en-us/windows/win32/api/windef/ns-windef-rect
+*/
+type RECT struct {
+	Left, Top, Right, Bottom int32
+}
+
+/*
+	WNDCLASSEXW and PAINTSTRUCT.
+*/
+type wndClassEx struct {
+	size       uint32
+	style      uint32
+	wndProc    uintptr
+	clsExtra   int32
+	wndExtra   int32
+	instance   windows.Handle
+	icon       windows.Handle
+	cursor     windows.Handle
+	background windows.Handle
+	menuName   *uint16
+	className  *uint16
+	iconSm     windows.Handle
+}
+
+type paintStruct struct {
+	hdc       windows.Handle
+	erase     int32
+	paint     RECT
+	restore   int32
+	incUpdate int32
+	reserved  [32]byte
+}
+
+/*
+	OverlayTheme is the look of an Overlay. Colors are COLORREFs,
+	0x00BBGGRR; Opacity runs from 0, invisible, to 255, opaque.
+*/
+type OverlayTheme struct {
+	Background uint32
+	Text       uint32
+	Opacity    byte
+	Font       string
+	FontSize   int // in pixels
+}
+
+/*
+	OverlayThemes are the themes an Overlay comes with.
+*/
+var OverlayThemes = map[string]OverlayTheme{
+	"dark":     {Background: 0x202020, Text: 0xFFFFFF, Opacity: 200, Font: "Segoe UI", FontSize: 28},
+	"light":    {Background: 0xF4F4F4, Text: 0x202020, Opacity: 220, Font: "Segoe UI", FontSize: 28},
+	"contrast": {Background: 0x000000, Text: 0x00FFFF, Opacity: 255, Font: "Segoe UI Semibold", FontSize: 32},
+}
+
+/*
+	OverlayPositions are the corners and edges an Overlay can be placed at.
+*/
+var OverlayPositions = []string{"bottom-right", "bottom-left", "bottom-center", "top-right", "top-left", "top-center"}
+
+/*
+	Overlay shows the keys pressed in a window on top of all others, for
+	screencasts and presentations. The window is layered and click-through:
+	it never takes the focus or the mouse, and it hides itself when the
+	lines of Display have expired. Position is one of OverlayPositions on
+	the primary monitor. Feed and Close may be called from any goroutine;
+	the window runs on a thread of its own.
+*/
+type Overlay struct {
+	Position string
+	Theme    OverlayTheme
+	Display  KeyDisplay
+
+	mu      sync.Mutex // guards Display and lines
+	lines   []string
+	hwnd    HWND
+	font    uintptr
+	brush   uintptr
+	done    chan struct{}
+	visible bool
+}
+
+/*
+	NewOverlay returns an Overlay at the bottom right in the dark theme, not
+	yet shown.
+*/
+func NewOverlay() *Overlay {
+	return &Overlay{Position: "bottom-right", Theme: OverlayThemes["dark"]}
+}
+
+var (
+	overlayClassOnce sync.Once
+	overlayClassErr  error
+	overlaysMu       sync.Mutex
+	overlays         = make(map[HWND]*Overlay)
+)
+
+/*
+	Show creates the window and returns once it exists.
+*/
+func (o *Overlay) Show() error {
+	if o.done != nil {
+		return errors.New("overlay: already shown")
+	}
+	valid := false
+	for _, p := range OverlayPositions {
+		valid = valid || p == o.Position
+	}
+	if !valid {
+		return fmt.Errorf("overlay: unknown position %q", o.Position)
+	}
+	o.done = make(chan struct{})
+	errc := make(chan error, 1)
+	go o.run(errc)
+	return <-errc
+}
+
+/*
+	Feed shows a key event.
+*/
+func (o *Overlay) Feed(e KeyEvent) {
+	o.mu.Lock()
+	changed := o.Display.Feed(e)
+	if changed {
+		o.lines = o.Display.Lines(e.Time)
+	}
+	hwnd := o.hwnd
+	o.mu.Unlock()
+	if changed && hwnd != 0 {
+		postMessageW.Call(uintptr(hwnd), wmOverlayUpdate, 0, 0)
+	}
+}
+
+/*
+	Close removes the window and clears what it showed.
+*/
+func (o *Overlay) Close() {
+	o.mu.Lock()
+	hwnd := o.hwnd
+	o.mu.Unlock()
+	if hwnd == 0 {
+		return
+	}
+	postMessageW.Call(uintptr(hwnd), WM_CLOSE, 0, 0)
+	<-o.done
+	o.mu.Lock()
+	o.Display.Wipe()
+	for i := range o.lines {
+		o.lines[i] = ""
+	}
+	o.lines = nil
+	o.mu.Unlock()
+}
+
+func (o *Overlay) run(errc chan<- error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer close(o.done)
+	hwnd, err := o.create()
+	if err != nil {
+		errc <- err
+		return
+	}
+	errc <- nil
+	var msg MSG
+	for GetMessage(&msg, 0, 0, 0) > 0 {
+		dispatchMessageW.Call(uintptr(unsafe.Pointer(&msg)))
+	}
+	overlaysMu.Lock()
+	delete(overlays, hwnd)
+	overlaysMu.Unlock()
+	deleteObject.Call(o.font)
+	deleteObject.Call(o.brush)
+}
+
+func (o *Overlay) create() (HWND, error) {
+	var instance windows.Handle
+	if err := windows.GetModuleHandleEx(0, nil, &instance); err != nil {
+		return 0, err
+	}
+	class, _ := windows.UTF16PtrFromString(overlayClass)
+	overlayClassOnce.Do(func() {
+		wc := wndClassEx{
+			wndProc:   windows.NewCallback(overlayProc),
+			instance:  instance,
+			className: class,
+		}
+		wc.size = uint32(unsafe.Sizeof(wc))
+		if ret, _, err := registerClassExW.Call(uintptr(unsafe.Pointer(&wc))); ret == 0 {
+			overlayClassErr = fmt.Errorf("RegisterClassEx: %v", err)
+		}
+	})
+	if overlayClassErr != nil {
+		return 0, overlayClassErr
+	}
+
+	t := o.Theme
+	face, err := windows.UTF16PtrFromString(t.Font)
+	if err != nil {
+		return 0, err
+	}
+	const fwBold, clearTypeQuality = 700, 5
+	o.font, _, _ = createFontW.Call(uintptr(-int32(t.FontSize)), 0, 0, 0, fwBold, 0, 0, 0, 0, 0, 0, clearTypeQuality, 0, uintptr(unsafe.Pointer(face)))
+	o.brush, _, _ = createSolidBrush.Call(uintptr(t.Background))
+
+	lines := o.Display.MaxLines
+	if lines <= 0 {
+		lines = DefaultDisplayLines
+	}
+	width := GetSystemMetrics(SM_CXSCREEN) / 3
+	height := int(float64(lines*t.FontSize)*overlayLineHeight) + t.FontSize/2
+	x, y := o.origin(width, height)
+	ret, _, err := createWindowExW.Call(
+		WS_EX_LAYERED|WS_EX_TRANSPARENT|WS_EX_TOPMOST|WS_EX_TOOLWINDOW|WS_EX_NOACTIVATE,
+		uintptr(unsafe.Pointer(class)), 0, WS_POPUP,
+		uintptr(x), uintptr(y), uintptr(width), uintptr(height),
+		0, 0, uintptr(instance), 0)
+	if ret == 0 {
+		deleteObject.Call(o.font)
+		deleteObject.Call(o.brush)
+		return 0, fmt.Errorf("CreateWindowEx: %v", err)
+	}
+	hwnd := HWND(ret)
+	setLayeredWindowAttributes.Call(uintptr(hwnd), 0, uintptr(t.Opacity), LWA_ALPHA)
+	setTimer.Call(uintptr(hwnd), overlayTimer, uintptr(overlayTick/time.Millisecond), 0)
+	overlaysMu.Lock()
+	overlays[hwnd] = o
+	overlaysMu.Unlock()
+	o.mu.Lock()
+	o.hwnd = hwnd
+	o.mu.Unlock()
+	return hwnd, nil
+}
+
+/*
+	Returns the top left corner of the window for Position.
+*/
+func (o *Overlay) origin(width, height int) (int, int) {
+	screenWidth, screenHeight := GetSystemMetrics(SM_CXSCREEN), GetSystemMetrics(SM_CYSCREEN)
+	x, y := overlayMargin, overlayMargin
+	switch o.Position {
+	case "bottom-right", "top-right":
+		x = screenWidth - width - overlayMargin
+	case "bottom-center", "top-center":
+		x = (screenWidth - width) / 2
+	}
+	if strings.HasPrefix(o.Position, "bottom") {
+		// Above the taskbar.
+		y = screenHeight - height - 2*overlayMargin
+	}
+	return x, y
+}
+
+func overlayProc(hwnd HWND, msg uint32, wparam WPARAM, lparam LPARAM) LRESULT {
+	overlaysMu.Lock()
+	o := overlays[hwnd]
+	overlaysMu.Unlock()
+	if o != nil {
+		switch msg {
+		case wmOverlayUpdate, WM_TIMER:
+			o.update(hwnd, msg == WM_TIMER)
+			return 0
+		case WM_PAINT:
+			o.paint(hwnd)
+			return 0
+		case WM_CLOSE:
+			destroyWindow.Call(uintptr(hwnd))
+			return 0
+		case WM_DESTROY:
+			postQuitMessage.Call(0)
+			return 0
+		}
+	}
+	ret, _, _ := defWindowProcW.Call(uintptr(hwnd), uintptr(msg), uintptr(wparam), uintptr(lparam))
+	return LRESULT(ret)
+}
+
+/*
+	Shows the window with the current lines, or hides it once they have
+	expired; the timer expires them.
+*/
+func (o *Overlay) update(hwnd HWND, expire bool) {
+	o.mu.Lock()
+	before := len(o.lines)
+	if expire {
+		o.lines = o.Display.Lines(time.Now())
+	}
+	empty, changed := len(o.lines) == 0, !expire || len(o.lines) != before
+	o.mu.Unlock()
+	switch {
+	case empty && o.visible:
+		showWindow.Call(uintptr(hwnd), SW_HIDE)
+		o.visible = false
+	case !empty && !o.visible:
+		showWindow.Call(uintptr(hwnd), SW_SHOWNOACTIVATE)
+		o.visible = true
+	}
+	if changed {
+		invalidateRect.Call(uintptr(hwnd), 0, 1)
+	}
+}
+
+func (o *Overlay) paint(hwnd HWND) {
+	var ps paintStruct
+	hdc, _, _ := beginPaint.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&ps)))
+	defer endPaint.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&ps)))
+	var client RECT
+	getClientRect.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&client)))
+	fillRect.Call(hdc, uintptr(unsafe.Pointer(&client)), o.brush)
+	old, _, _ := selectObject.Call(hdc, o.font)
+	defer selectObject.Call(hdc, old)
+	setTextColor.Call(hdc, uintptr(o.Theme.Text))
+	const transparent = 1 // TRANSPARENT
+	setBkMode.Call(hdc, transparent)
+
+	o.mu.Lock()
+	lines := append([]string(nil), o.lines...)
+	o.mu.Unlock()
+	format := uintptr(DT_SINGLELINE | DT_VCENTER | DT_NOPREFIX | DT_END_ELLIPSIS)
+	switch o.Position {
+	case "bottom-right", "top-right":
+		format |= DT_RIGHT
+	case "bottom-center", "top-center":
+		format |= DT_CENTER
+	}
+	pad := int32(o.Theme.FontSize / 2)
+	lineHeight := int32(float64(o.Theme.FontSize) * overlayLineHeight)
+	// The newest line is nearest the edge of the screen the overlay is at.
+	top := client.Top + pad/2
+	if strings.HasPrefix(o.Position, "bottom") {
+		top = client.Bottom - pad/2 - int32(len(lines))*lineHeight
+	}
+	for i, line := range lines {
+		text, err := windows.UTF16FromString(line)
+		if err != nil {
+			continue
+		}
+		r := RECT{Left: client.Left + pad, Right: client.Right - pad, Top: top + int32(i)*lineHeight}
+		r.Bottom = r.Top + lineHeight
+		drawTextW.Call(hdc, uintptr(unsafe.Pointer(&text[0])), uintptr(len(text)-1), uintptr(unsafe.Pointer(&r)), format)
+	}
+}
@@ -0,0 +1,29 @@
+package keylogger
+
+/*
+	Why the hooks are paused: bits of Logger.pauses, each with what the
+	pause and resume markers say.
+*/
+const (
+	pauseCalled uint32 = 1 << iota
+	pauseLocked
+	pauseSensitive
+	pauseDisconnected
+)
+
+var pauseReasons = map[uint32][2]string{
+	pauseCalled:       {"capture paused", "capture resumed"},
+	pauseLocked:       {"workstation locked", "workstation unlocked"},
+	pauseSensitive:    {"sensitive application", "no sensitive application"},
+	pauseDisconnected: {"session disconnected", "session reconnected"},
+}
+
+/*
+	Records in a that the hooks were paused or resumed for reason.
+*/
+func recordPause(a *AuditLog, reason uint32, on bool) error {
+	if on {
+		return a.Record(AuditPause, pauseReasons[reason][0])
+	}
+	return a.Record(AuditResume, pauseReasons[reason][1])
+}
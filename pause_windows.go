@@ -0,0 +1,100 @@
+package keylogger
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+	Pause removes the keyboard and mouse hooks until Resume, so no input
+	is captured and Windows stops calling into the Logger for every key and
+	mouse move. The event stream carries a DiagPaused and a DiagResumed
+	marker. A pause outlasts Stop and Start, but a lock does not: Start
+	installs the hooks PauseWhenLocked removed. The pause is recorded in
+	Audit; if that fails, the hooks are removed all the same and the error
+	is returned.
+*/
+func (l *Logger) Pause() error {
+	return l.pause(pauseCalled, true)
+}
+
+/*
+	Resume installs the hooks again after Pause, unless they are paused for
+	another reason, such as PauseWhenLocked. Like Start, it does not resume
+	the capture if that cannot be recorded in Audit.
+*/
+func (l *Logger) Resume() error {
+	return l.pause(pauseCalled, false)
+}
+
+/*
+	Paused reports whether the hooks are paused, for any reason.
+*/
+func (l *Logger) Paused() bool {
+	l.pauseMu.Lock()
+	defer l.pauseMu.Unlock()
+	return l.pauses != 0
+}
+
+/*
+	Sets or clears reason, recording the change in Audit, and, when the
+	hooks go from running to paused or back, tells the hook thread. The
+	message is posted under pauseMu, so the hook thread sees the changes in
+	order, and run installs the hooks of a new thread under it too. A
+	reason that cannot be recorded is set all the same, but not cleared.
+*/
+func (l *Logger) pause(reason uint32, on bool) error {
+	l.pauseMu.Lock()
+	defer l.pauseMu.Unlock()
+	before := l.pauses
+	var err error
+	if (before&reason != 0) != on {
+		if err = recordPause(l.Audit, reason, on); err != nil && !on {
+			return err
+		}
+	}
+	if on {
+		l.pauses |= reason
+	} else {
+		l.pauses &^= reason
+	}
+	t := l.current()
+	if t == nil {
+		return err
+	}
+	switch {
+	case before == 0 && l.pauses != 0:
+		l.api.PostMessage(t.id, wmPauseHooks, WPARAM(reason), 0)
+	case before != 0 && l.pauses == 0:
+		l.api.PostMessage(t.id, wmResumeHooks, WPARAM(reason), 0)
+	}
+	return err
+}
+
+/*
+	The marker of a pause or resume that could not be recorded in Audit.
+*/
+func auditFailed(err error, t time.Time) DiagnosticEvent {
+	return DiagnosticEvent{Kind: DiagAuditFailed, Message: fmt.Sprintf("audit log: %v", err), Time: t}
+}
+
+/*
+	Runs on the hook thread: removes or installs again the low-level hooks
+	of t and queues the marker.
+*/
+func (l *Logger) pauseHooks(t *hookThread, reason uint32, paused bool) {
+	d := DiagnosticEvent{Kind: DiagPaused, Message: pauseReasons[reason][0]}
+	if paused {
+		l.unhook(t)
+	} else {
+		d.Kind, d.Message = DiagResumed, pauseReasons[reason][1]
+		l.threadMu.Lock()
+		err := l.installHooks(t)
+		l.threadMu.Unlock()
+		if err != nil {
+			d.Kind, d.Message = DiagHookLost, fmt.Sprintf("%s, but the hooks could not be installed again: %v", d.Message, err)
+		}
+	}
+	d.Time = time.Now()
+	l.pushCurrent(&rawEvent{kind: rawDiagnostic, diag: d})
+}
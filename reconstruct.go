@@ -0,0 +1,330 @@
+package keylogger
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+/*
+	Typing stops making one TextEvent after this long a pause.
+*/
+const textBurstPause = 5 * time.Second
+
+/*
+	TextEvent is the text one burst of typing left in a window: the keys
+	from Start to Time with Backspace, Delete, the arrow keys, Home, End,
+	Shift selection, Ctrl+A and Ctrl+X applied. Keys is how many key
+	presses made it. Uncertain is set when the burst did something whose
+	effect cannot be told from the keys alone, such as pasting, undoing or
+	moving the caret out of the text typed in the burst, so Text may not
+	be what the field holds.
+*/
+type TextEvent struct {
+	Start     time.Time `json:"start"`
+	Process   string    `json:"process"`
+	Title     string    `json:"title"`
+	Text      string    `json:"text"`
+	Keys      int       `json:"keys"`
+	Uncertain bool      `json:"uncertain,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+/*
+	Timestamp returns when the burst ended.
+*/
+func (e TextEvent) Timestamp() time.Time {
+	return e.Time
+}
+
+/*
+	TextReconstructor turns key events into TextEvents, following the
+	foreground window from FocusEvents. A burst ends with a pause, Enter,
+	Tab, a mouse click, which moves the caret to where the keys cannot
+	tell, or another window. The caret is taken to start at the end of
+	the burst's text and to stay within it. Swallowed and redacted keys
+	are left out, and injected ones unless typed on an on-screen keyboard.
+*/
+type TextReconstructor struct {
+	mods      ModifierState
+	process   string
+	title     string
+	text      []rune
+	caret     int
+	anchor    int  // the other end of the selection, -1 without one
+	all       bool // Ctrl+A selected all of the field
+	keys      int
+	uncertain bool
+	start     time.Time
+	last      time.Time
+}
+
+/*
+	Feed processes one event and returns the TextEvent of the burst it
+	ended, if any.
+*/
+func (r *TextReconstructor) Feed(ev Event) (TextEvent, bool) {
+	switch e := ev.(type) {
+	case FocusEvent:
+		t, ok := r.cut()
+		r.process, r.title = e.Process, e.Title
+		return t, ok
+	case MouseEvent:
+		if e.Action == MouseDown {
+			return r.cut()
+		}
+	case KeyEvent:
+		return r.key(e)
+	}
+	return TextEvent{}, false
+}
+
+/*
+	Expire returns the TextEvent of a burst that has paused since before
+	now.
+*/
+func (r *TextReconstructor) Expire(now time.Time) (TextEvent, bool) {
+	if r.keys > 0 && now.Sub(r.last) >= textBurstPause {
+		return r.cut()
+	}
+	return TextEvent{}, false
+}
+
+/*
+	Flush ends the burst being typed and returns its TextEvent.
+*/
+func (r *TextReconstructor) Flush() (TextEvent, bool) {
+	return r.cut()
+}
+
+func (r *TextReconstructor) key(e KeyEvent) (TextEvent, bool) {
+	if e.Source() == KeySourceInjected || e.Swallowed || e.VkCode == 0 {
+		return TextEvent{}, false
+	}
+	mods := r.mods.Update(e)
+	// Alt without Ctrl and Win pick menus and shortcuts.
+	if !e.Down || modifierOf(e.VkCode) != 0 || mods&ModWin != 0 || mods&(ModCtrl|ModAlt) == ModAlt {
+		return TextEvent{}, false
+	}
+	var t TextEvent
+	var ended bool
+	if r.keys > 0 && e.Time.Sub(r.last) >= textBurstPause {
+		t, ended = r.cut()
+	}
+	if r.keys == 0 {
+		r.start, r.anchor = e.Time, -1
+	}
+	r.keys++
+	r.last = e.Time
+	// AltGr is Ctrl+Alt, and types characters.
+	ctrl := mods&ModCtrl != 0 && mods&ModAlt == 0
+	shift := mods&ModShift != 0
+	switch e.VkCode {
+	case VK_RETURN, VK_TAB:
+		r.keys--
+		if r.keys == 0 {
+			return t, ended
+		}
+		return r.cut()
+	case VK_BACK:
+		if !r.deleteSelection() {
+			from := r.caret - 1
+			if ctrl {
+				from = r.wordBefore()
+			}
+			r.delete(from, r.caret)
+		}
+	case VK_DELETE:
+		if !r.deleteSelection() {
+			to := r.caret + 1
+			if ctrl {
+				to = r.wordAfter()
+			}
+			r.delete(r.caret, to)
+		}
+	case VK_LEFT:
+		if from, _, ok := r.selection(); ok && !shift {
+			r.move(from, false)
+		} else if ctrl {
+			r.move(r.wordBefore(), shift)
+		} else {
+			r.move(r.caret-1, shift)
+		}
+	case VK_RIGHT:
+		if _, to, ok := r.selection(); ok && !shift {
+			r.move(to, false)
+		} else if ctrl {
+			r.move(r.wordAfter(), shift)
+		} else {
+			r.move(r.caret+1, shift)
+		}
+	case VK_HOME:
+		r.move(0, shift)
+	case VK_END:
+		r.move(len(r.text), shift)
+	case VK_UP, VK_DOWN, VK_PRIOR, VK_NEXT:
+		r.uncertain = true
+	default:
+		if ctrl {
+			r.shortcut(e.VkCode)
+		} else if e.Text != "" {
+			r.insert(e.Text)
+		}
+	}
+	return t, ended
+}
+
+func (r *TextReconstructor) shortcut(vk uint16) {
+	switch vk {
+	case 'A':
+		r.anchor, r.caret, r.all = 0, len(r.text), true
+	case 'X':
+		r.deleteSelection()
+	case 'V':
+		r.deleteSelection()
+		r.uncertain = true
+	case 'Z', 'Y':
+		r.uncertain = true
+	}
+}
+
+/*
+	Returns the selection, ordered, if there is one.
+*/
+func (r *TextReconstructor) selection() (from, to int, ok bool) {
+	if r.all {
+		return 0, len(r.text), true
+	}
+	if r.anchor < 0 || r.anchor == r.caret {
+		return 0, 0, false
+	}
+	if r.anchor < r.caret {
+		return r.anchor, r.caret, true
+	}
+	return r.caret, r.anchor, true
+}
+
+/*
+	Moves the caret to i, extending the selection if extend is set. Moving
+	out of the burst's text, or out of all of the field selected by
+	Ctrl+A, makes it uncertain.
+*/
+func (r *TextReconstructor) move(i int, extend bool) {
+	if r.all {
+		r.all, r.uncertain = false, true
+	}
+	if i < 0 || i > len(r.text) {
+		r.uncertain = true
+		i = clampInt(i, 0, len(r.text))
+	}
+	if !extend {
+		r.anchor = -1
+	} else if r.anchor < 0 {
+		r.anchor = r.caret
+	}
+	r.caret = i
+}
+
+func (r *TextReconstructor) insert(s string) {
+	r.deleteSelection()
+	var add []rune
+	for _, c := range s {
+		if unicode.IsPrint(c) {
+			add = append(add, c)
+		}
+	}
+	r.text = append(r.text[:r.caret], append(add, r.text[r.caret:]...)...)
+	r.caret += len(add)
+}
+
+/*
+	Deletes the selection. Deleting all of the field leaves only text the
+	keys type, so what made the burst uncertain is gone with it.
+*/
+func (r *TextReconstructor) deleteSelection() bool {
+	from, to, ok := r.selection()
+	if ok {
+		r.delete(from, to)
+	}
+	if r.all {
+		r.all, r.uncertain = false, false
+	}
+	r.anchor = -1
+	return ok
+}
+
+/*
+	Deletes the text from from to to; beyond the burst's text there is
+	text of the field the keys did not type.
+*/
+func (r *TextReconstructor) delete(from, to int) {
+	if from < 0 || to > len(r.text) {
+		r.uncertain = true
+		from, to = clampInt(from, 0, len(r.text)), clampInt(to, 0, len(r.text))
+	}
+	n := copy(r.text[from:], r.text[to:])
+	wipeRunes(r.text[from+n:])
+	r.text = r.text[:from+n]
+	r.caret = from
+}
+
+/*
+	Where Ctrl+Left and Ctrl+Backspace go: the start of the word before the
+	caret, or past the start of the text if the caret is in the first word.
+*/
+func (r *TextReconstructor) wordBefore() int {
+	i := r.caret
+	for i > 0 && !isWordRune(r.text[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(r.text[i-1]) {
+		i--
+	}
+	if i == 0 {
+		return -1
+	}
+	return i
+}
+
+/*
+	Where Ctrl+Right and Ctrl+Delete go: past the word after the caret and
+	the spaces that follow it.
+*/
+func (r *TextReconstructor) wordAfter() int {
+	i := r.caret
+	for i < len(r.text) && isWordRune(r.text[i]) {
+		i++
+	}
+	for i < len(r.text) && !isWordRune(r.text[i]) {
+		i++
+	}
+	if i == len(r.text) {
+		return i + 1
+	}
+	return i
+}
+
+func isWordRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func clampInt(i, min, max int) int {
+	if i < min {
+		return min
+	}
+	if i > max {
+		return max
+	}
+	return i
+}
+
+/*
+	Ends the burst, returning its TextEvent unless it left no text.
+*/
+func (r *TextReconstructor) cut() (TextEvent, bool) {
+	t := TextEvent{Start: r.start, Process: r.process, Title: r.title, Text: string(r.text), Keys: r.keys, Uncertain: r.uncertain, Time: r.last}
+	ok := r.keys > 0 && strings.TrimSpace(t.Text) != ""
+	wipeRunes(r.text)
+	r.text, r.caret, r.anchor, r.all, r.keys, r.uncertain = r.text[:0], 0, -1, false, 0, false
+	return t, ok
+}
@@ -0,0 +1,94 @@
+package keylogger
+
+import (
+	"testing"
+	"time"
+)
+
+/*
+	Feeds a TextReconstructor key presses, one per millisecond: a string is
+	typed character by character, a uint16 is a virtual key pressed, a
+	KeyEvent is fed as it is and Modifiers are held for the key that follows.
+*/
+func reconstruct(keys ...interface{}) []TextEvent {
+	r := &TextReconstructor{}
+	var out []TextEvent
+	now := time.Unix(0, 0)
+	feed := func(e KeyEvent) {
+		now = now.Add(time.Millisecond)
+		e.Time = now
+		if t, ok := r.Feed(e); ok {
+			out = append(out, t)
+		}
+	}
+	var held []uint16
+	for _, k := range keys {
+		switch k := k.(type) {
+		case string:
+			for _, c := range k {
+				feed(KeyEvent{VkCode: 'X', Down: true, Text: string(c)})
+			}
+		case uint16:
+			feed(KeyEvent{VkCode: k, Down: true})
+			feed(KeyEvent{VkCode: k})
+			for _, vk := range held {
+				feed(KeyEvent{VkCode: vk})
+			}
+			held = held[:0]
+		case Modifiers:
+			for _, m := range []struct {
+				mod Modifiers
+				vk  uint16
+			}{{ModCtrl, VK_LCONTROL}, {ModShift, VK_LSHIFT}} {
+				if k&m.mod != 0 {
+					feed(KeyEvent{VkCode: m.vk, Down: true})
+					held = append(held, m.vk)
+				}
+			}
+		case KeyEvent:
+			feed(k)
+		case time.Duration:
+			now = now.Add(k)
+		}
+	}
+	if t, ok := r.Flush(); ok {
+		out = append(out, t)
+	}
+	return out
+}
+
+func TestTextReconstructor(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		keys      []interface{}
+		text      []string
+		uncertain bool
+	}{
+		{"backspace", []interface{}{"helo", uint16(VK_BACK), "lo"}, []string{"hello"}, false},
+		{"arrows", []interface{}{"hllo", uint16(VK_HOME), uint16(VK_RIGHT), "e", uint16(VK_END), "!"}, []string{"hello!"}, false},
+		{"delete", []interface{}{"hexllo", uint16(VK_HOME), uint16(VK_RIGHT), uint16(VK_RIGHT), uint16(VK_DELETE)}, []string{"hello"}, false},
+		{"selection", []interface{}{"hello world", ModShift, uint16(VK_LEFT), ModShift, uint16(VK_LEFT), "ld!"}, []string{"hello world!"}, false},
+		{"word", []interface{}{"hello wrold", ModCtrl, uint16(VK_BACK), "world"}, []string{"hello world"}, false},
+		{"select all", []interface{}{uint16(VK_UP), "old", ModCtrl, uint16('A'), "new"}, []string{"new"}, false},
+		{"paste", []interface{}{"see ", ModCtrl, uint16('V')}, []string{"see "}, true},
+		{"outside", []interface{}{"abc", uint16(VK_HOME), uint16(VK_BACK)}, []string{"abc"}, true},
+		{"enter", []interface{}{"one", uint16(VK_RETURN), "two"}, []string{"one", "two"}, false},
+		{"pause", []interface{}{"one", textBurstPause, "two"}, []string{"one", "two"}, false},
+		{"erased", []interface{}{"ab", uint16(VK_BACK), uint16(VK_BACK)}, nil, false},
+		{"on-screen", []interface{}{"ab",
+			KeyEvent{VkCode: VK_PACKET, Flags: LLKHF_INJECTED, OnScreen: true, Down: true, Text: "c"},
+			KeyEvent{VkCode: VK_PACKET, Flags: LLKHF_INJECTED, Down: true, Text: "x"},
+		}, []string{"abc"}, false},
+	} {
+		got := reconstruct(c.keys...)
+		if len(got) != len(c.text) {
+			t.Errorf("%s: got %d events %+v, want %q", c.name, len(got), got, c.text)
+			continue
+		}
+		for i, e := range got {
+			if e.Text != c.text[i] || e.Uncertain != c.uncertain {
+				t.Errorf("%s: got %q (uncertain %v), want %q (uncertain %v)", c.name, e.Text, e.Uncertain, c.text[i], c.uncertain)
+			}
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package keylogger
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+	Built-in redactions, by the names NewRedactor and the -redact flag
+	accept in place of a regular expression.
+*/
+var Redactions = map[string]string{
+	"credit-card": `\b(?:\d[ -]?){12,18}\d\b`,
+	"ssn":         `\b\d{3}-\d{2}-\d{4}\b`,
+	"email":       `[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`,
+}
+
+/*
+	Default limits of a Redactor.
+*/
+const (
+	DefaultRedactWindow  = 64
+	DefaultRedactMaxHold = 5 * time.Second
+)
+
+/*
+	Redactor masks typed text that matches any of its patterns before the
+	events reach a sink; see Logger.Redact. It follows the text the
+	key events type, with Backspace taking back the last character, and
+	holds events back until a match can no longer begin in them: until
+	Window more characters have been typed or they are MaxHold old. Events
+	of other kinds are held with them, so the order is kept.
+
+	A TextEvent has the matches in its Text masked as a whole.
+
+	A redacted key-down has its Text replaced by Mask per character and its
+	VkCode and ScanCode cleared; its key-up is cleared as well, so the
+	virtual keys do not give the characters away. A match typed more slowly
+	than MaxHold, or longer than Window, is only masked in part.
+*/
+type Redactor struct {
+	Mask    string
+	Window  int
+	MaxHold time.Duration
+
+	patterns []*regexp.Regexp
+	held     []heldEvent
+	maskUp   map[uint16]bool
+}
+
+type heldEvent struct {
+	e      Event
+	text   string // the characters typed, before masking
+	back   bool   // a Backspace key-down
+	masked bool
+}
+
+/*
+	NewRedactor compiles patterns, each either the name of one of
+	Redactions or a regular expression.
+*/
+func NewRedactor(patterns []string) (*Redactor, error) {
+	r := &Redactor{Mask: "*", Window: DefaultRedactWindow, MaxHold: DefaultRedactMaxHold, maskUp: make(map[uint16]bool)}
+	for _, p := range patterns {
+		expr := p
+		if builtin, ok := Redactions[p]; ok {
+			expr = builtin
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("redact %q: %v", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+/*
+	Push adds e to the held events and passes those that are done to emit,
+	in order.
+*/
+func (r *Redactor) Push(e Event, emit func(Event)) {
+	h := heldEvent{e: e}
+	if t, ok := e.(TextEvent); ok {
+		h.e = r.maskText(t)
+	}
+	if k, ok := e.(KeyEvent); ok {
+		if !k.Down && r.maskUp[k.VkCode] {
+			delete(r.maskUp, k.VkCode)
+			h.e, h.masked = r.masked(k), true
+		} else if k.Down && !k.Swallowed {
+			h.text, h.back = k.Text, k.VkCode == VK_BACK
+		}
+	}
+	r.held = append(r.held, h)
+	if h.text != "" || h.back {
+		r.redact()
+	}
+	r.release(emit, time.Time{})
+}
+
+/*
+	Expire passes events held longer than MaxHold at now to emit.
+*/
+func (r *Redactor) Expire(now time.Time, emit func(Event)) {
+	r.release(emit, now.Add(-r.MaxHold))
+}
+
+/*
+	Flush passes all held events to emit.
+*/
+func (r *Redactor) Flush(emit func(Event)) {
+	for i, h := range r.held {
+		emit(h.e)
+		r.held[i] = heldEvent{}
+	}
+	r.held = r.held[:0]
+}
+
+/*
+	Rebuilds the typed text of the held events and masks the events whose
+	characters are part of a match.
+*/
+func (r *Redactor) redact() {
+	var text strings.Builder
+	var owner []int // held event of each byte of text
+	for i, h := range r.held {
+		if h.back {
+			if n := text.Len(); n > 0 {
+				s := text.String()
+				_, size := utf8.DecodeLastRuneInString(s)
+				text.Reset()
+				text.WriteString(s[:n-size])
+				owner = owner[:n-size]
+			}
+			continue
+		}
+		text.WriteString(h.text)
+		for j := 0; j < len(h.text); j++ {
+			owner = append(owner, i)
+		}
+	}
+	s := text.String()
+	for _, re := range r.patterns {
+		for _, m := range re.FindAllStringIndex(s, -1) {
+			for _, i := range owner[m[0]:m[1]] {
+				r.mask(i)
+			}
+		}
+	}
+}
+
+func (r *Redactor) mask(i int) {
+	h := &r.held[i]
+	if h.masked {
+		return
+	}
+	k := h.e.(KeyEvent)
+	h.e, h.masked = r.masked(k), true
+	for j := i + 1; j < len(r.held); j++ {
+		if u, ok := r.held[j].e.(KeyEvent); ok && !u.Down && u.VkCode == k.VkCode {
+			r.held[j].e, r.held[j].masked = r.masked(u), true
+			return
+		}
+	}
+	r.maskUp[k.VkCode] = true
+}
+
+func (r *Redactor) maskText(t TextEvent) TextEvent {
+	for _, re := range r.patterns {
+		t.Text = re.ReplaceAllStringFunc(t.Text, func(m string) string {
+			return strings.Repeat(r.Mask, utf8.RuneCountInString(m))
+		})
+	}
+	return t
+}
+
+func (r *Redactor) masked(k KeyEvent) KeyEvent {
+	if k.Text != "" {
+		k.Text = strings.Repeat(r.Mask, utf8.RuneCountInString(k.Text))
+	}
+	k.VkCode, k.ScanCode = 0, 0
+	return k
+}
+
+/*
+	Passes on held events from the front while they cannot be part of a
+	later match: events that type nothing ahead of all typed characters,
+	characters more than Window behind the end, and events from before
+	cutoff.
+*/
+func (r *Redactor) release(emit func(Event), cutoff time.Time) {
+	chars := 0
+	for _, h := range r.held {
+		chars += utf8.RuneCountInString(h.text)
+	}
+	n := 0
+	for _, h := range r.held {
+		typed := h.text != "" || h.back
+		if typed && chars <= r.Window && !h.e.Timestamp().Before(cutoff) {
+			break
+		}
+		chars -= utf8.RuneCountInString(h.text)
+		emit(h.e)
+		n++
+	}
+	m := copy(r.held, r.held[n:])
+	for i := m; i < len(r.held); i++ {
+		r.held[i] = heldEvent{}
+	}
+	r.held = r.held[:m]
+}
@@ -0,0 +1,101 @@
+package keylogger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+	Types s, a '\b' being Backspace: each key goes down, and with overlap
+	set the previous one comes up only after it.
+*/
+func typeKeys(s string, overlap bool) []Event {
+	var events []Event
+	var prev *KeyEvent
+	start := time.Unix(0, 0)
+	for i, c := range s {
+		down := KeyEvent{VkCode: uint16(c), Down: true, Text: string(c), Time: start.Add(time.Duration(i) * time.Millisecond)}
+		if c == '\b' {
+			down.VkCode, down.Text = VK_BACK, ""
+		}
+		up := down
+		up.Down, up.Text = false, ""
+		if !overlap {
+			events = append(events, down, up)
+			continue
+		}
+		events = append(events, down)
+		if prev != nil {
+			events = append(events, *prev)
+		}
+		prev = &up
+	}
+	if prev != nil {
+		events = append(events, *prev)
+	}
+	return events
+}
+
+func TestRedactor(t *testing.T) {
+	tests := []struct {
+		name    string
+		typed   string
+		overlap bool
+		want    string // the Text of the key-downs
+		cleared int    // events with their virtual key cleared
+	}{
+		{"no match", "hello 12-34", false, "hello 12-34", 0},
+		{"match", "ssn 123-45-6789.", false, "ssn ***********.", 22},
+		{"across key-up and key-down", "123-45-6789", true, "***********", 22},
+		{"edited with backspace", "123-45-678x\b9", false, "**********x*", 22},
+		{"masked once typed", "123-45-6789\b\b0", false, "***********0", 22},
+	}
+	for _, tt := range tests {
+		r, err := NewRedactor([]string{"ssn"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events := typeKeys(tt.typed, tt.overlap)
+		var out []Event
+		emit := func(e Event) { out = append(out, e) }
+		for _, e := range events {
+			r.Push(e, emit)
+		}
+		r.Flush(emit)
+		if len(out) != len(events) {
+			t.Errorf("%s: %d events out, want %d", tt.name, len(out), len(events))
+			continue
+		}
+		var text strings.Builder
+		cleared := 0
+		for i, e := range out {
+			k := e.(KeyEvent)
+			if k.Down != events[i].(KeyEvent).Down {
+				t.Errorf("%s: event %d out of order", tt.name, i)
+			}
+			text.WriteString(k.Text)
+			if k.VkCode == 0 {
+				cleared++
+			}
+		}
+		if text.String() != tt.want || cleared != tt.cleared {
+			t.Errorf("%s: typed %q with %d keys cleared, want %q with %d", tt.name, text.String(), cleared, tt.want, tt.cleared)
+		}
+	}
+}
+
+func TestRedactorText(t *testing.T) {
+	r, err := NewRedactor([]string{"email", `secret\d+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got TextEvent
+	r.Push(TextEvent{Text: "mail a.b@example.com the secret42"}, func(e Event) { got = e.(TextEvent) })
+	if want := "mail *************** the ********"; got.Text != want {
+		t.Errorf("Text = %q, want %q", got.Text, want)
+	}
+	if _, err := NewRedactor([]string{"("}); err == nil {
+		t.Error("NewRedactor accepted an invalid pattern")
+	}
+}
@@ -0,0 +1,99 @@
+package keylogger
+
+import "fmt"
+
+/*
+	RemapSignature marks input re-injected by a Remapper. Unlike input sent by
+	a plain Injector, remapped keys stand in for physical key presses, so the
+	rest of the package treats them as real typing.
+*/
+const RemapSignature = 0x4B4C524D // "KLRM"
+
+/*
+	Remapper replaces keys system-wide: it swallows the original event in the
+	hook and injects the substitute in its place.
+*/
+type Remapper struct {
+	table map[uint16]uint16
+	inj   KeyInjector
+}
+
+/*
+	NewRemapper returns a Remapper for a from -> to table. The generic
+	modifier keys (Ctrl, Shift, Alt) match both their left and right keys
+	as a source and are sent as the left key as a target.
+*/
+func NewRemapper(table map[uint16]uint16, inj KeyInjector) *Remapper {
+	r := &Remapper{table: make(map[uint16]uint16), inj: inj}
+	for from, to := range table {
+		to = leftModifier(to)
+		for _, vk := range sidedKeys(from) {
+			r.table[vk] = to
+		}
+	}
+	return r
+}
+
+/*
+	Filter is a KeyFilter that swallows remapped keys and sends their
+	substitutes. Only physical input is remapped, so substitutes and replayed
+	macros are left alone.
+*/
+func (r *Remapper) Filter(e KeyEvent) bool {
+	if e.Injected() {
+		return false
+	}
+	to, ok := r.table[e.VkCode]
+	if !ok {
+		return false
+	}
+	if e.Down {
+		r.inj.Press(to)
+	} else {
+		r.inj.Release(to)
+	}
+	return true
+}
+
+/*
+	ParseRemap converts a configuration table of key names into virtual keys.
+*/
+func ParseRemap(names map[string]string) (map[uint16]uint16, error) {
+	table := make(map[uint16]uint16, len(names))
+	for from, to := range names {
+		f, err := ParseKey(from)
+		if err != nil {
+			return nil, fmt.Errorf("remap %s: %v", from, err)
+		}
+		t, err := ParseKey(to)
+		if err != nil {
+			return nil, fmt.Errorf("remap %s: %v", from, err)
+		}
+		table[f] = t
+	}
+	return table, nil
+}
+
+func sidedKeys(vk uint16) []uint16 {
+	switch vk {
+	case VK_CONTROL:
+		return []uint16{VK_LCONTROL, VK_RCONTROL}
+	case VK_SHIFT:
+		return []uint16{VK_LSHIFT, VK_RSHIFT}
+	case VK_MENU:
+		return []uint16{VK_LMENU, VK_RMENU}
+	}
+	return []uint16{vk}
+}
+
+func leftModifier(vk uint16) uint16 {
+	switch vk {
+	case VK_CONTROL:
+		return VK_LCONTROL
+	case VK_SHIFT:
+		return VK_LSHIFT
+	case VK_MENU:
+		return VK_LMENU
+	}
+	return vk
+}
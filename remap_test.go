@@ -0,0 +1,100 @@
+package keylogger
+
+import (
+	"reflect"
+	"testing"
+)
+
+/*
+	Records the keys a Remapper injects, as "+name" for a press and
+	"-name" for a release.
+*/
+type keyInjector struct {
+	keys []string
+}
+
+func (i *keyInjector) Press(vks ...uint16) error {
+	for _, vk := range vks {
+		i.keys = append(i.keys, "+"+KeyName(vk))
+	}
+	return nil
+}
+
+func (i *keyInjector) Release(vks ...uint16) error {
+	for _, vk := range vks {
+		i.keys = append(i.keys, "-"+KeyName(vk))
+	}
+	return nil
+}
+
+func TestRemapper(t *testing.T) {
+	for _, c := range []struct {
+		remap    map[string]string
+		keys     []KeyEvent
+		want     []string
+		swallows []bool
+	}{
+		{
+			remap:    map[string]string{"CapsLock": "Ctrl"},
+			keys:     []KeyEvent{{VkCode: VK_CAPITAL, Down: true}, {VkCode: VK_CAPITAL}},
+			want:     []string{"+LCtrl", "-LCtrl"},
+			swallows: []bool{true, true},
+		},
+		{
+			remap:    map[string]string{"Ctrl": "CapsLock"},
+			keys:     []KeyEvent{{VkCode: VK_LCONTROL, Down: true}, {VkCode: VK_RCONTROL, Down: true}, {VkCode: VK_LSHIFT, Down: true}},
+			want:     []string{"+CapsLock", "+CapsLock"},
+			swallows: []bool{true, true, false},
+		},
+		{
+			remap:    map[string]string{"Y": "Z", "Z": "Y"},
+			keys:     []KeyEvent{{VkCode: 'Y', Down: true}, {VkCode: 'Z', Down: true}, {VkCode: 'A', Down: true}},
+			want:     []string{"+Z", "+Y"},
+			swallows: []bool{true, true, false},
+		},
+		{
+			// Substitutes are injected, so chains do not go on.
+			remap:    map[string]string{"A": "B", "B": "C"},
+			keys:     []KeyEvent{{VkCode: 'A', Down: true}, {VkCode: 'B', Flags: LLKHF_INJECTED, ExtraInfo: RemapSignature, Down: true}},
+			want:     []string{"+B"},
+			swallows: []bool{true, false},
+		},
+		{
+			remap:    map[string]string{"A": "B"},
+			keys:     []KeyEvent{{VkCode: 'A', Flags: LLKHF_INJECTED, ExtraInfo: InjectedSignature, Down: true}},
+			swallows: []bool{false},
+		},
+	} {
+		table, err := ParseRemap(c.remap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var inj keyInjector
+		r := NewRemapper(table, &inj)
+		var swallows []bool
+		for _, e := range c.keys {
+			swallows = append(swallows, r.Filter(e))
+		}
+		if !reflect.DeepEqual(inj.keys, c.want) {
+			t.Errorf("%v injects %q, want %q", c.remap, inj.keys, c.want)
+		}
+		if !reflect.DeepEqual(swallows, c.swallows) {
+			t.Errorf("%v swallows %v, want %v", c.remap, swallows, c.swallows)
+		}
+	}
+}
+
+/*
+	Swapping keys is allowed, as substitutes are not remapped again;
+	unknown keys are not.
+*/
+func TestRemapValidate(t *testing.T) {
+	if err := (&Config{Remap: map[string]string{"Y": "Z", "Z": "Y"}}).Validate(); err != nil {
+		t.Errorf("swap rejected: %v", err)
+	}
+	for _, remap := range []map[string]string{{"Hyper": "A"}, {"A": "Hyper"}} {
+		if err := (&Config{Remap: remap}).Validate(); err == nil {
+			t.Errorf("%v accepted", remap)
+		}
+	}
+}
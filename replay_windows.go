@@ -0,0 +1,94 @@
+package keylogger
+
+import (
+	"io"
+	"unicode/utf16"
+)
+
+/*
+	Replay runs a trace written through Logger.Trace into the pipeline in
+	place of captured input: the filters, translation, mouse sampling,
+	double-click detection, text reconstruction, Redact, Pseudonymize and
+	the sinks see the recorded events as they saw them live, and the
+	results are delivered on Events. No hooks are installed, so bugs in the
+	pipeline can be reproduced without touching real input; filters that
+	inject input themselves, such as a Remapper's, still do. Like Stop, Replay closes
+	Events once the sinks are closed, so consume Events on another
+	goroutine. Replay fails if the Logger is running.
+*/
+func (l *Logger) Replay(trace io.Reader) error {
+	l.runMu.Lock()
+	defer l.runMu.Unlock()
+	if l.running {
+		return errLoggerRunning
+	}
+	r, err := newTraceReader(trace)
+	if err != nil {
+		return err
+	}
+	layout := &replayLayout{caps: r.header.CapsLock}
+	l.awaitLastRun()
+	l.begin()
+	l.translator = newTranslator(layout)
+	if l.Layout != nil {
+		l.Layout.CapsLock = l.translator.caps
+	}
+	l.clicks = r.header.doubleClicks()
+	// The trace tells which keys came from an on-screen keyboard.
+	l.onScreen = nil
+	l.startTrace()
+	l.startText()
+	var raw rawEvent
+	for {
+		e, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			l.endTrace()
+			l.out.finish()
+			l.end()
+			return err
+		}
+		switch e := e.(type) {
+		case KeyEvent:
+			layout.text = e.Text
+			e.Text, e.Swallowed = "", l.filter(e)
+			raw = rawEvent{kind: rawKey, key: e}
+			l.deliver(&raw)
+		case MouseEvent:
+			e.DoubleClick = false
+			raw = rawEvent{kind: rawMouse, mouse: e}
+			l.deliver(&raw)
+		default:
+			l.record(e)
+			l.out.emit(e)
+			l.reconstruct(e)
+		}
+	}
+	l.endText()
+	l.endTrace()
+	l.out.finish()
+	l.end()
+	return l.out.err
+}
+
+/*
+	Stands in for the keyboard layout on replay: every key types the text
+	recorded with it.
+*/
+type replayLayout struct {
+	caps bool
+	text string
+}
+
+func (r *replayLayout) ToUnicode(vk, scanCode uint32, state *[256]byte, buf []uint16) int32 {
+	return int32(copy(buf, utf16.Encode([]rune(r.text))))
+}
+
+func (r *replayLayout) KeyState(vk int) int16 {
+	if vk == VK_CAPITAL && r.caps {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,314 @@
+package keylogger
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+	PurgeLog removes the events that happened before t from a log file
+	written with codec, JSONCodec, BinaryCodec or MsgpackCodec, and
+	returns how many it removed. The remaining records are written to a
+	new file that replaces the old one, so nothing may have the log open
+	for writing; a running capture purges its own log through a
+	RetentionSink. JSON lines that cannot be read, such as one cut off by
+	a crash, are kept.
+*/
+func PurgeLog(name string, codec Codec, t time.Time) (int, error) {
+	return rewriteLog(name, codec, false, func(e Event) bool {
+		return e.Timestamp().Before(t)
+	})
+}
+
+/*
+	Calls fn with each record of a log file written with codec and the
+	event in it, nil for a JSON line that cannot be read and for records
+	of a newer schema.
+*/
+func scanLog(data []byte, codec Codec, fn func(e Event, record []byte)) error {
+	switch codec.(type) {
+	case JSONCodec, nil:
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		sc.Buffer(nil, len(data)+1)
+		for sc.Scan() {
+			e, _ := DecodeJSONEvent(sc.Bytes())
+			fn(e, sc.Bytes())
+		}
+	default:
+		decode := recordDecoder(codec)
+		if decode == nil {
+			return fmt.Errorf("cannot read %T records", codec)
+		}
+		for off := 0; off < len(data); {
+			e, n, err := decode(data[off:])
+			if errors.Is(err, ErrNewerSchema) {
+				fn(nil, data[off:off+n])
+				off += n
+				continue
+			}
+			if err != nil {
+				// The zeros after the records of an MmapSink that was
+				// not closed, or a record cut off by a crash.
+				break
+			}
+			fn(e, data[off:off+n])
+			off += n
+		}
+	}
+	return nil
+}
+
+/*
+	Returns the decoder of the records of codec if they are not lines, nil
+	for JSONCodec and codecs that cannot be read.
+*/
+func recordDecoder(codec Codec) func([]byte) (Event, int, error) {
+	switch codec.(type) {
+	case BinaryCodec:
+		return DecodeBinaryEvent
+	case MsgpackCodec:
+		return DecodeMsgpackEvent
+	}
+	return nil
+}
+
+/*
+	ReadLogs calls fn with each event of the log files written with codec,
+	read in the order given. Lines that cannot be read are skipped.
+*/
+func ReadLogs(logs []string, codec Codec, fn func(Event)) error {
+	for _, name := range logs {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		err = scanLog(data, codec, func(e Event, record []byte) {
+			if e != nil {
+				fn(e)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+/*
+	Removes the events drop returns true for from a log file and returns
+	how many it removed. With wipe, the old contents are overwritten with
+	zeros before the new file takes the log's name.
+*/
+func rewriteLog(name string, codec Codec, wipe bool, drop func(Event) bool) (int, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	var kept []byte
+	removed := 0
+	err = scanLog(data, codec, func(e Event, record []byte) {
+		if e != nil && drop(e) {
+			removed++
+			return
+		}
+		kept = append(kept, record...)
+		if recordDecoder(codec) == nil {
+			kept = append(kept, '\n')
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", name, err)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, replaceFile(name, kept, wipe)
+}
+
+/*
+	Writes data to a new file next to name and renames it over name, so a
+	crash leaves either the old or the new contents. With wipe, the old
+	contents are overwritten first; a crash then leaves the new contents
+	under the temporary name.
+*/
+func replaceFile(name string, data []byte, wipe bool) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".purge*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if serr := f.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && wipe {
+		// From here on the temporary file holds the only copy of the
+		// records that are kept.
+		if err = wipeFile(name); err == nil {
+			err = os.Rename(f.Name(), name)
+		}
+		return err
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+/*
+	Overwrites a file with zeros and syncs it. Journaling file systems and
+	SSDs may keep copies elsewhere, so this is no guarantee that the old
+	contents cannot be recovered from the disk.
+*/
+func wipeFile(name string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err == nil {
+		zeros := make([]byte, 64<<10)
+		for left := st.Size(); left > 0 && err == nil; left -= int64(len(zeros)) {
+			if left < int64(len(zeros)) {
+				zeros = zeros[:left]
+			}
+			_, err = f.Write(zeros)
+		}
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+/*
+	DefaultPurgeInterval applies when NewRetentionSink is given no interval.
+*/
+const DefaultPurgeInterval = time.Hour
+
+/*
+	RetentionSink writes to a log file and, every interval, removes the
+	events older than maxAge from it with PurgeLog. To do so it closes the
+	file, purges it and opens it again, holding back Writes in between.
+	Errors from purges are returned by the next Write.
+*/
+type RetentionSink struct {
+	name   string
+	codec  Codec
+	maxAge time.Duration
+	open   func(name string) (Sink, error)
+
+	mu   sync.Mutex
+	sink Sink
+	err  error
+	stop chan struct{}
+	done chan struct{}
+}
+
+/*
+	NewRetentionSink opens the log file name with open, purges it once and
+	then every interval. codec is the format open writes the file in.
+*/
+func NewRetentionSink(name string, codec Codec, maxAge, interval time.Duration, open func(name string) (Sink, error)) (*RetentionSink, error) {
+	if maxAge <= 0 {
+		return nil, errors.New("retention: the maximum age must be positive")
+	}
+	if interval <= 0 {
+		interval = DefaultPurgeInterval
+	}
+	if _, err := PurgeLog(name, codec, time.Now().Add(-maxAge)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sink, err := open(name)
+	if err != nil {
+		return nil, err
+	}
+	r := &RetentionSink{
+		name:   name,
+		codec:  codec,
+		maxAge: maxAge,
+		open:   open,
+		sink:   sink,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go r.purgeLoop(interval)
+	return r, nil
+}
+
+func (r *RetentionSink) Write(events []Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.err; err != nil {
+		r.err = nil
+		return err
+	}
+	if r.sink == nil {
+		return errors.New("retention: log could not be reopened")
+	}
+	return r.sink.Write(events)
+}
+
+/*
+	Close stops purging and closes the log.
+*/
+func (r *RetentionSink) Close() error {
+	close(r.stop)
+	<-r.done
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sink == nil {
+		return r.err
+	}
+	err := r.sink.Close()
+	r.sink = nil
+	return err
+}
+
+func (r *RetentionSink) purgeLoop(interval time.Duration) {
+	defer close(r.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case now := <-t.C:
+			r.mu.Lock()
+			if err := r.purge(now.Add(-r.maxAge)); err != nil && r.err == nil {
+				r.err = err
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (r *RetentionSink) purge(before time.Time) error {
+	if r.sink != nil {
+		if err := r.sink.Close(); err != nil {
+			return err
+		}
+		r.sink = nil
+	}
+	_, err := PurgeLog(r.name, r.codec, before)
+	sink, oerr := r.open(r.name)
+	if oerr != nil {
+		return oerr
+	}
+	r.sink = sink
+	return err
+}
@@ -0,0 +1,136 @@
+package keylogger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeLog(t *testing.T, name string, codec Codec, events []Event) {
+	t.Helper()
+	s, err := NewFileSink(name, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(events); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+	The virtual keys of the key events in a log, in order.
+*/
+func logKeys(t *testing.T, name string, codec Codec) string {
+	t.Helper()
+	var keys []byte
+	err := ReadLogs([]string{name}, codec, func(e Event) {
+		if k, ok := e.(KeyEvent); ok {
+			keys = append(keys, byte(k.VkCode))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(keys)
+}
+
+/*
+	Key-downs of keys, each a minute older than the next, the last one at
+	end.
+*/
+func agedKeys(keys string, end time.Time) []Event {
+	var events []Event
+	for i := range keys {
+		at := end.Add(-time.Duration(len(keys)-1-i) * time.Minute)
+		events = append(events, KeyEvent{VkCode: uint16(keys[i]), Down: true, Time: at.UTC()})
+	}
+	return events
+}
+
+func TestPurgeLog(t *testing.T) {
+	end := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, codec := range []Codec{JSONCodec{}, BinaryCodec{}, MsgpackCodec{}} {
+		name := filepath.Join(t.TempDir(), "events.log")
+		writeLog(t, name, codec, agedKeys("ABCDE", end))
+		n, err := PurgeLog(name, codec, end.Add(-2*time.Minute))
+		if err != nil {
+			t.Fatalf("%T: %v", codec, err)
+		}
+		if got := logKeys(t, name, codec); n != 2 || got != "CDE" {
+			t.Errorf("%T: purged %d, left %q, want 2 and CDE", codec, n, got)
+		}
+		if n, err := PurgeLog(name, codec, end.Add(-time.Hour)); n != 0 || err != nil {
+			t.Errorf("%T: purging again = %d, %v, want nothing", codec, n, err)
+		}
+	}
+}
+
+/*
+	A log whose name leaves no room for the temporary file makes the
+	rewrite fail after the log was read; it must be left as it was.
+*/
+func TestPurgeLogFailureKeepsLog(t *testing.T) {
+	name := filepath.Join(t.TempDir(), strings.Repeat("l", 250))
+	end := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	writeLog(t, name, JSONCodec{}, agedKeys("ABCDE", end))
+	before, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PurgeLog(name, JSONCodec{}, end); err == nil {
+		t.Fatal("PurgeLog did not fail")
+	}
+	after, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("the failed purge changed the log")
+	}
+}
+
+func TestRetentionSink(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "events.log")
+	now := time.Now()
+	writeLog(t, name, JSONCodec{}, agedKeys("AB", now.Add(-2*time.Hour)))
+	open := func(name string) (Sink, error) { return NewFileSink(name, JSONCodec{}) }
+	r, err := NewRetentionSink(name, JSONCodec{}, time.Hour, 10*time.Millisecond, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := logKeys(t, name, JSONCodec{}); got != "" {
+		t.Errorf("after opening the log holds %q, want the old events purged", got)
+	}
+	// C ages out while the sink runs; D is in the window.
+	if err := r.Write([]Event{KeyEvent{VkCode: 'C', Down: true, Time: now.Add(-time.Hour + 10*time.Millisecond)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Write([]Event{KeyEvent{VkCode: 'D', Down: true, Time: now}}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := logKeys(t, name, JSONCodec{}); got != "D" {
+		t.Errorf("log holds %q, want D", got)
+	}
+}
+
+func TestRetentionSinkFailureKeepsLog(t *testing.T) {
+	name := filepath.Join(t.TempDir(), strings.Repeat("l", 250))
+	writeLog(t, name, JSONCodec{}, agedKeys("AB", time.Now().Add(-2*time.Hour)))
+	open := func(name string) (Sink, error) { return NewFileSink(name, JSONCodec{}) }
+	if _, err := NewRetentionSink(name, JSONCodec{}, time.Hour, time.Hour, open); err == nil {
+		t.Fatal("NewRetentionSink did not fail")
+	}
+	if got := logKeys(t, name, JSONCodec{}); got != "AB" {
+		t.Errorf("log holds %q after the failed purge, want AB", got)
+	}
+}
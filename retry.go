@@ -0,0 +1,125 @@
+package keylogger
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+/*
+	RetryPolicy says how often and how patiently a failed sink write is
+	repeated. The delay before attempt n+1 is InitialDelay * Multiplier^(n-1),
+	capped at MaxDelay and varied by up to ±Jitter (a fraction) so that
+	sinks failing together do not retry together. Events still failing
+	after MaxAttempts are appended as JSON lines to DeadLetter if set.
+*/
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	Jitter       float64
+	DeadLetter   string
+}
+
+/*
+	DefaultRetryPolicy tries for about half a minute before giving up.
+*/
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  8,
+	InitialDelay: 250 * time.Millisecond,
+	MaxDelay:     10 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+}
+
+/*
+	Delay returns how long to wait after the given failed attempt, counted
+	from 1.
+*/
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := float64(p.InitialDelay)
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < float64(p.MaxDelay)); i++ {
+		d *= p.Multiplier
+	}
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
+}
+
+/*
+	Do calls fn until it succeeds or has failed MaxAttempts times, waiting
+	Delay between the attempts, and returns the last error. Everything that
+	repeats requests to a remote end goes through it, so they all back off
+	alike.
+*/
+func (p RetryPolicy) Do(fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts {
+			return err
+		}
+		time.Sleep(p.Delay(attempt))
+	}
+}
+
+/*
+	RetrySink repeats failed writes to its sink according to a RetryPolicy.
+	Sinks that write over the network or to a database should be wrapped
+	in one, so every remote sink fails the same way. Write blocks while it
+	retries, holding up the sinks after it; events queue up meanwhile as
+	Logger.Backpressure allows.
+*/
+type RetrySink struct {
+	sink   Sink
+	policy RetryPolicy
+	dead   *FileSink
+}
+
+/*
+	NewRetrySink wraps s. A zero MaxAttempts means DefaultRetryPolicy.
+*/
+func NewRetrySink(s Sink, p RetryPolicy) (*RetrySink, error) {
+	if p.MaxAttempts <= 0 {
+		dead := p.DeadLetter
+		p = DefaultRetryPolicy
+		p.DeadLetter = dead
+	}
+	r := &RetrySink{sink: s, policy: p}
+	if p.DeadLetter != "" {
+		f, err := NewFileSink(p.DeadLetter, JSONCodec{})
+		if err != nil {
+			return nil, err
+		}
+		r.dead = f
+	}
+	return r, nil
+}
+
+func (r *RetrySink) Write(events []Event) error {
+	err := r.policy.Do(func() error { return r.sink.Write(events) })
+	if err == nil {
+		return nil
+	}
+	if r.dead == nil {
+		return fmt.Errorf("sink failed %d times: %w", r.policy.MaxAttempts, err)
+	}
+	if derr := r.dead.Write(events); derr != nil {
+		return fmt.Errorf("sink failed %d times: %w; dead letter file: %v", r.policy.MaxAttempts, err, derr)
+	}
+	return fmt.Errorf("sink failed %d times, %d events written to %s: %w", r.policy.MaxAttempts, len(events), r.policy.DeadLetter, err)
+}
+
+func (r *RetrySink) Close() error {
+	err := r.sink.Close()
+	if r.dead != nil {
+		if derr := r.dead.Close(); err == nil {
+			err = derr
+		}
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package keylogger
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2}
+	for attempt, want := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		60: time.Second,
+	} {
+		if d := p.Delay(attempt); d != want {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, d, want)
+		}
+	}
+	p.Jitter = 0.25
+	for i := 0; i < 1000; i++ {
+		if d := p.Delay(2); d < 150*time.Millisecond || d > 250*time.Millisecond {
+			t.Fatalf("Delay(2) = %v with jitter 0.25, want within 150ms and 250ms", d)
+		}
+		if d := p.Delay(30); d < 750*time.Millisecond || d > 1250*time.Millisecond {
+			t.Fatalf("Delay(30) = %v with jitter 0.25, want within 750ms and 1.25s", d)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, Multiplier: 1}
+	calls := 0
+	err := p.Do(func() error {
+		calls++
+		if calls < 2 {
+			return errors.New("flaky")
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("Do = %v after %d calls, want success after 2", err, calls)
+	}
+	calls = 0
+	if err := p.Do(func() error { calls++; return errors.New("down") }); err == nil || calls != 3 {
+		t.Errorf("Do = %v after %d calls, want the error after 3", err, calls)
+	}
+}
+
+/*
+	Events the sink keeps failing end up in the dead letter file, and the
+	error says so.
+*/
+func TestRetrySinkDeadLetter(t *testing.T) {
+	dead := filepath.Join(t.TempDir(), "dead.jsonl")
+	down := &memorySink{err: errors.New("unreachable")}
+	s, err := NewRetrySink(down, RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, Multiplier: 1, DeadLetter: dead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []Event{KeyEvent{VkCode: 'A', Down: true, Text: "a", Time: time.Unix(1, 0).UTC()}}
+	if err := s.Write(events); !errors.Is(err, down.err) {
+		t.Errorf("Write = %v, want it to wrap the sink's error", err)
+	}
+	if down.writes != 3 {
+		t.Errorf("sink written %d times, want 3", down.writes)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var got []Event
+	if err := ReadLogs([]string{dead}, JSONCodec{}, func(e Event) { got = append(got, e) }); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, events) {
+		t.Errorf("dead letter file holds %v, want %v", got, events)
+	}
+}
@@ -0,0 +1,194 @@
+package keylogger
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	DefaultRolloverCombos are the combinations a RolloverTest asks for
+	unless told otherwise: movement with sprint and jump, the home row
+	of both hands and the left hand across the top row, which cheap
+	keyboard matrices often cannot report together.
+*/
+var DefaultRolloverCombos = []string{
+	"W+A+LShift+Space",
+	"W+D+LShift+Space",
+	"A+S+D+F",
+	"J+K+L+;",
+	"A+S+D+F+J+K+L+;",
+	"Q+W+E+R+T",
+	"LCtrl+LShift+Z+X",
+}
+
+/*
+	ParseRolloverCombo parses a combination of key names joined by "+",
+	as in DefaultRolloverCombos.
+*/
+func ParseRolloverCombo(s string) ([]uint16, error) {
+	var keys []uint16
+	for _, name := range strings.Split(s, "+") {
+		vk, err := ParseKey(name)
+		if err != nil {
+			return nil, fmt.Errorf("rollover: %q: %v", s, err)
+		}
+		keys = append(keys, vk)
+	}
+	return keys, nil
+}
+
+/*
+	RolloverTest checks how many keys a keyboard reports at once and
+	whether it ghosts. It asks for one combination of keys after the
+	other; an attempt starts with the first key of the combination and
+	ends once every key is released. It passes if all keys of the
+	combination were held together at some point. Keys of a failed
+	attempt that never went down while the most of the others were held
+	are missing; keys outside the combination that went down during an
+	attempt are phantoms, which is how ghosting shows, unless they were
+	pressed by mistake. Without combinations the test only follows the
+	keys held.
+
+	Injected keys and repeats are ignored. Feed is not safe for
+	concurrent use.
+*/
+type RolloverTest struct {
+	Combos  [][]uint16
+	Results []RolloverResult
+	MaxHeld int // most keys held at once
+
+	held    []uint16 // in the order they went down
+	active  bool     // an attempt at the current combination runs
+	passed  bool
+	best    []uint16 // keys of the combination held at the best moment
+	phantom []uint16
+}
+
+/*
+	RolloverResult is the outcome of the attempt at one combination.
+*/
+type RolloverResult struct {
+	Keys    []uint16
+	Passed  bool
+	Missing []uint16
+	Phantom []uint16
+}
+
+/*
+	NewRolloverTest returns a test asking for combos in turn.
+*/
+func NewRolloverTest(combos [][]uint16) *RolloverTest {
+	return &RolloverTest{Combos: combos}
+}
+
+/*
+	Feed processes a key event and reports whether the keys held or the
+	combination asked for changed.
+*/
+func (t *RolloverTest) Feed(e KeyEvent) bool {
+	if e.Injected() {
+		return false
+	}
+	i := indexKey(t.held, e.VkCode)
+	if e.Down {
+		if i >= 0 {
+			return false
+		}
+		t.held = append(t.held, e.VkCode)
+		if len(t.held) > t.MaxHeld {
+			t.MaxHeld = len(t.held)
+		}
+		t.attempt(e.VkCode)
+		return true
+	}
+	if i < 0 {
+		return false
+	}
+	t.held = append(t.held[:i], t.held[i+1:]...)
+	if len(t.held) == 0 && t.active {
+		t.finish()
+	}
+	return true
+}
+
+func (t *RolloverTest) attempt(vk uint16) {
+	combo := t.Current()
+	if combo == nil {
+		return
+	}
+	if indexKey(combo, vk) < 0 {
+		if t.active && indexKey(t.phantom, vk) < 0 {
+			t.phantom = append(t.phantom, vk)
+		}
+		return
+	}
+	t.active = true
+	var held []uint16
+	for _, k := range t.held {
+		if indexKey(combo, k) >= 0 {
+			held = append(held, k)
+		}
+	}
+	if len(held) > len(t.best) {
+		t.best = held
+	}
+	t.passed = t.passed || len(held) == len(combo)
+}
+
+func (t *RolloverTest) finish() {
+	combo := t.Current()
+	r := RolloverResult{Keys: combo, Passed: t.passed, Phantom: t.phantom}
+	if !t.passed {
+		for _, k := range combo {
+			if indexKey(t.best, k) < 0 {
+				r.Missing = append(r.Missing, k)
+			}
+		}
+	}
+	t.Results = append(t.Results, r)
+	t.active, t.passed, t.best, t.phantom = false, false, nil, nil
+}
+
+/*
+	Current returns the combination asked for, nil once all are done.
+*/
+func (t *RolloverTest) Current() []uint16 {
+	if len(t.Results) < len(t.Combos) {
+		return t.Combos[len(t.Results)]
+	}
+	return nil
+}
+
+/*
+	Done reports whether every combination has had its attempt.
+*/
+func (t *RolloverTest) Done() bool {
+	return len(t.Combos) > 0 && t.Current() == nil
+}
+
+/*
+	Held returns the keys held now, in the order they went down.
+*/
+func (t *RolloverTest) Held() []uint16 {
+	return append([]uint16(nil), t.held...)
+}
+
+func indexKey(keys []uint16, vk uint16) int {
+	for i, k := range keys {
+		if k == vk {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+	KeyNames returns the names of keys joined by "+".
+*/
+func KeyNames(keys []uint16) string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = KeyName(k)
+	}
+	return strings.Join(names, "+")
+}
@@ -0,0 +1,39 @@
+package keylogger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRolloverTest(t *testing.T) {
+	test := NewRolloverTest([][]uint16{{'A', 'S', 'D'}, {'Q', 'W', 'E'}})
+	press := func(vks ...uint16) {
+		for _, vk := range vks {
+			test.Feed(KeyEvent{VkCode: vk, Down: true})
+		}
+	}
+	release := func(vks ...uint16) {
+		for _, vk := range vks {
+			test.Feed(KeyEvent{VkCode: vk})
+		}
+	}
+
+	press('A', 'S', 'A', 'D') // the repeat of A is ignored
+	if got := test.Held(); !reflect.DeepEqual(got, []uint16{'A', 'S', 'D'}) {
+		t.Errorf("Held = %v", got)
+	}
+	release('S', 'A', 'D')
+	// The keyboard reports W and a ghost R, never E.
+	press('Q', 'W', 'R')
+	release('Q', 'W', 'R')
+	want := []RolloverResult{
+		{Keys: []uint16{'A', 'S', 'D'}, Passed: true},
+		{Keys: []uint16{'Q', 'W', 'E'}, Missing: []uint16{'E'}, Phantom: []uint16{'R'}},
+	}
+	if !reflect.DeepEqual(test.Results, want) {
+		t.Errorf("Results = %+v, want %+v", test.Results, want)
+	}
+	if !test.Done() || test.MaxHeld != 3 {
+		t.Errorf("Done = %v, MaxHeld = %d", test.Done(), test.MaxHeld)
+	}
+}
@@ -0,0 +1,88 @@
+package keylogger
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+/*
+	MoveSampler thins out mouse movement, which Windows reports for every
+	pixel and can easily exceed a thousand events per second. A move passes
+	only if at least 1/MaxRate seconds have elapsed and the cursor travelled
+	at least MinDistance pixels since the last move that passed. Zero values
+	disable the respective limit. Other mouse events always pass.
+
+	Distance statistics are computed from every move, sampled or not.
+*/
+type MoveSampler struct {
+	MaxRate     float64
+	MinDistance float64
+
+	mu       sync.Mutex
+	started  bool
+	last     MouseEvent
+	pos      MouseEvent
+	seen     bool
+	distance float64
+	moves    uint64
+	passed   uint64
+	since    time.Time
+}
+
+/*
+	MovementStats summarizes mouse movement since the sampler was created.
+*/
+type MovementStats struct {
+	Distance float64 // pixels travelled
+	Moves    uint64  // raw move events
+	Sampled  uint64  // move events that passed the sampler
+	Since    time.Time
+}
+
+/*
+	Sample updates the statistics and reports whether e should be kept.
+*/
+func (s *MoveSampler) Sample(e MouseEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.since.IsZero() {
+		s.since = e.Time
+	}
+	if s.seen {
+		s.distance += math.Hypot(float64(e.X-s.pos.X), float64(e.Y-s.pos.Y))
+	}
+	s.pos, s.seen = e, true
+	if e.Action != MouseMove {
+		return true
+	}
+	s.moves++
+
+	if s.started {
+		if s.MaxRate > 0 && e.Time.Sub(s.last.Time) < time.Duration(float64(time.Second)/s.MaxRate) {
+			return false
+		}
+		if s.MinDistance > 0 && math.Hypot(float64(e.X-s.last.X), float64(e.Y-s.last.Y)) < s.MinDistance {
+			return false
+		}
+	}
+	s.started = true
+	s.last = e
+	s.passed++
+	return true
+}
+
+/*
+	Stats returns the movement statistics so far.
+*/
+func (s *MoveSampler) Stats() MovementStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return MovementStats{
+		Distance: s.distance,
+		Moves:    s.moves,
+		Sampled:  s.passed,
+		Since:    s.since,
+	}
+}
@@ -0,0 +1,55 @@
+package keylogger
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+	SchemaVersion is the version of the event records JSONCodec,
+	BinaryCodec and MsgpackCodec write, which every record carries.
+	Records without one were written before versions were recorded and
+	are version 1.
+*/
+const SchemaVersion = 2
+
+/*
+	ErrNewerSchema is returned, wrapped, for a record of a version later
+	than SchemaVersion, written by a newer keylogger. Readers skip such
+	records as they skip those cut off by a crash; retention and erasure
+	keep them.
+*/
+var ErrNewerSchema = errors.New("record of a newer schema version")
+
+/*
+	Migrations bring events decoded from older records up to date: the
+	one for version n turns an event as version n meant it into an event
+	of version n+1. A version changing what a field means adds its
+	migration here, and the decoders go on reading the fields of older
+	records. Version 1 needs none: fields it lacks, such as
+	FocusEvent.Elevated in early binary records, read as false.
+*/
+var migrations = map[int]func(Event) Event{}
+
+/*
+	Upgrades e, decoded from a record of the given version, to
+	SchemaVersion.
+*/
+func migrate(e Event, version uint64) (Event, error) {
+	if version < 1 {
+		return nil, fmt.Errorf("bad schema version %d", version)
+	}
+	if version > SchemaVersion {
+		return nil, newerSchema(version)
+	}
+	for v := int(version); v < SchemaVersion; v++ {
+		if m := migrations[v]; m != nil {
+			e = m(e)
+		}
+	}
+	return e, nil
+}
+
+func newerSchema(version uint64) error {
+	return fmt.Errorf("%w: version %d, this keylogger reads up to %d", ErrNewerSchema, version, SchemaVersion)
+}
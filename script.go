@@ -0,0 +1,290 @@
+package keylogger
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+/*
+	ScriptInjector is what scripts can drive: keyboard and mouse injection.
+*/
+type ScriptInjector interface {
+	KeyInjector
+	TextInjector
+	MoveTo(x, y int) error
+	Click(b MouseButton) error
+}
+
+/*
+	Script runs a Lua automation script against the captured event stream.
+
+	Scripts register handlers with these functions:
+
+		on_hotkey("Ctrl+Shift+T", function() ... end)  -- hotkey is swallowed
+		on_key(function(ev) ... end)  -- ev.key, ev.vk, ev.down, ev.text, ev.injected, ev.source
+
+	and act through:
+
+		type("text")  tap("Ctrl+C")  press("Shift")  release("Shift")
+		move(x, y)  click("Left")  sleep(ms)  app()  log(...)
+
+	Handlers run one at a time on the script's own goroutine, never on the
+	hook thread, so a slow or sleeping script cannot stall system input.
+	In LocalOnly builds, os.execute and io.popen are not available.
+*/
+type Script struct {
+	L       *lua.LState
+	inj     ScriptInjector
+	app     func() string
+	logf    func(format string, args ...interface{})
+	hotkeys []scriptHotkey
+	onKey   []*lua.LFunction
+	loaded  bool
+
+	filterMods ModifierState
+	filtered   map[uint16]bool
+	mods       ModifierState
+	events     chan KeyEvent
+	dropped    uint64
+}
+
+type scriptHotkey struct {
+	hotkey Hotkey
+	fn     *lua.LFunction
+}
+
+/*
+	LoadScript runs the script file once to register its handlers. app
+	returns the foreground process name, logf receives log() output.
+*/
+func LoadScript(name string, inj ScriptInjector, app func() string, logf func(string, ...interface{})) (*Script, error) {
+	s := &Script{
+		L:        lua.NewState(),
+		inj:      inj,
+		app:      app,
+		logf:     logf,
+		filtered: make(map[uint16]bool),
+		events:   make(chan KeyEvent, 256),
+	}
+	if LocalOnly {
+		// A started process could send captured text anywhere.
+		s.L.SetField(s.L.GetGlobal("os"), "execute", lua.LNil)
+		s.L.SetField(s.L.GetGlobal("io"), "popen", lua.LNil)
+	}
+	for fname, fn := range map[string]lua.LGFunction{
+		"on_hotkey": s.luaOnHotkey,
+		"on_key":    s.luaOnKey,
+		"type":      s.luaType,
+		"tap":       s.luaTap,
+		"press":     s.luaPress,
+		"release":   s.luaRelease,
+		"move":      s.luaMove,
+		"click":     s.luaClick,
+		"sleep":     s.luaSleep,
+		"app":       s.luaApp,
+		"log":       s.luaLog,
+	} {
+		s.L.SetGlobal(fname, s.L.NewFunction(fn))
+	}
+	if err := s.L.DoFile(name); err != nil {
+		s.L.Close()
+		return nil, fmt.Errorf("script %s: %v", name, err)
+	}
+	s.loaded = true
+	return s, nil
+}
+
+/*
+	Filter is a KeyFilter that swallows the hotkeys registered by the script,
+	including their key releases. It does not call into Lua.
+*/
+func (s *Script) Filter(e KeyEvent) bool {
+	if e.FromInjector() {
+		return false
+	}
+	mods := s.filterMods.Mods()
+	s.filterMods.Update(e)
+	if !e.Down {
+		if s.filtered[e.VkCode] {
+			delete(s.filtered, e.VkCode)
+			return true
+		}
+		return false
+	}
+	for _, h := range s.hotkeys {
+		if h.hotkey.Matches(e, mods) {
+			s.filtered[e.VkCode] = true
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Feed queues an event for the script. Events are dropped while the script
+	is busy and its queue is full; Dropped counts them.
+*/
+func (s *Script) Feed(e KeyEvent) {
+	select {
+	case s.events <- e:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+/*
+	Dropped returns the number of events the script was too busy to receive.
+*/
+func (s *Script) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+/*
+	Run calls the script's handlers for queued events until Close is called.
+	Handler errors are passed to logf and do not stop the script.
+*/
+func (s *Script) Run() {
+	for e := range s.events {
+		if err := s.handle(e); err != nil {
+			s.logf("script: %v", err)
+		}
+	}
+	s.L.Close()
+}
+
+/*
+	Close stops Run once the queued events are handled.
+*/
+func (s *Script) Close() {
+	close(s.events)
+}
+
+func (s *Script) handle(e KeyEvent) error {
+	if e.FromInjector() {
+		return nil
+	}
+	mods := s.mods.Mods()
+	s.mods.Update(e)
+	for _, h := range s.hotkeys {
+		if h.hotkey.Matches(e, mods) {
+			if err := s.L.CallByParam(lua.P{Fn: h.fn, Protect: true}); err != nil {
+				return err
+			}
+		}
+	}
+	if len(s.onKey) == 0 {
+		return nil
+	}
+	ev := s.L.NewTable()
+	ev.RawSetString("key", lua.LString(KeyName(e.VkCode)))
+	ev.RawSetString("vk", lua.LNumber(e.VkCode))
+	ev.RawSetString("down", lua.LBool(e.Down))
+	ev.RawSetString("text", lua.LString(e.Text))
+	ev.RawSetString("injected", lua.LBool(e.Injected()))
+	ev.RawSetString("source", lua.LString(e.Source().String()))
+	ev.RawSetString("swallowed", lua.LBool(e.Swallowed))
+	for _, fn := range s.onKey {
+		if err := s.L.CallByParam(lua.P{Fn: fn, Protect: true}, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Script) luaOnHotkey(L *lua.LState) int {
+	if s.loaded {
+		// The hook thread reads the hotkey list without locking.
+		L.RaiseError("on_hotkey must be called when the script is loaded")
+	}
+	h, err := ParseHotkey(L.CheckString(1))
+	if err != nil {
+		L.ArgError(1, err.Error())
+	}
+	s.hotkeys = append(s.hotkeys, scriptHotkey{hotkey: h, fn: L.CheckFunction(2)})
+	return 0
+}
+
+func (s *Script) luaOnKey(L *lua.LState) int {
+	s.onKey = append(s.onKey, L.CheckFunction(1))
+	return 0
+}
+
+func (s *Script) luaType(L *lua.LState) int {
+	s.check(L, s.inj.Type(L.CheckString(1)))
+	return 0
+}
+
+func (s *Script) luaTap(L *lua.LState) int {
+	h, err := ParseHotkey(L.CheckString(1))
+	if err != nil {
+		L.ArgError(1, err.Error())
+	}
+	s.check(L, s.inj.Tap(append(h.modifierKeys(), h.VkCode)...))
+	return 0
+}
+
+func (s *Script) luaPress(L *lua.LState) int {
+	vk := s.checkKey(L, 1)
+	s.check(L, s.inj.Press(vk))
+	return 0
+}
+
+func (s *Script) luaRelease(L *lua.LState) int {
+	vk := s.checkKey(L, 1)
+	s.check(L, s.inj.Release(vk))
+	return 0
+}
+
+func (s *Script) luaMove(L *lua.LState) int {
+	s.check(L, s.inj.MoveTo(L.CheckInt(1), L.CheckInt(2)))
+	return 0
+}
+
+func (s *Script) luaClick(L *lua.LState) int {
+	b := LeftButton
+	if L.GetTop() >= 1 {
+		var err error
+		if b, err = ParseMouseButton(L.CheckString(1)); err != nil {
+			L.ArgError(1, err.Error())
+		}
+	}
+	s.check(L, s.inj.Click(b))
+	return 0
+}
+
+func (s *Script) luaSleep(L *lua.LState) int {
+	time.Sleep(time.Duration(L.CheckInt(1)) * time.Millisecond)
+	return 0
+}
+
+func (s *Script) luaApp(L *lua.LState) int {
+	L.Push(lua.LString(s.app()))
+	return 1
+}
+
+func (s *Script) luaLog(L *lua.LState) int {
+	args := make([]string, L.GetTop())
+	for i := range args {
+		args[i] = L.Get(i + 1).String()
+	}
+	s.logf("script: %s", strings.Join(args, " "))
+	return 0
+}
+
+func (s *Script) checkKey(L *lua.LState, n int) uint16 {
+	vk, err := ParseKey(L.CheckString(n))
+	if err != nil {
+		L.ArgError(n, err.Error())
+	}
+	return vk
+}
+
+func (s *Script) check(L *lua.LState, err error) {
+	if err != nil {
+		L.RaiseError("%v", err)
+	}
+}
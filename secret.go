@@ -0,0 +1,94 @@
+package keylogger
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"strings"
+)
+
+/*
+	Secret is a configuration value that may be protected with DPAPI:
+	"dpapi:" followed by base64, as printed by `keylogger config protect`.
+	Other values are used as they are.
+*/
+type Secret string
+
+const secretPrefix = "dpapi:"
+
+/*
+	SecretScope says who can reveal a protected secret.
+*/
+type SecretScope int
+
+const (
+	// SecretUser secrets can only be revealed by the user who protected them.
+	SecretUser SecretScope = iota
+	// SecretMachine secrets can be revealed by any user of the machine.
+	SecretMachine
+)
+
+/*
+	Protected reports whether s is protected with DPAPI.
+*/
+func (s Secret) Protected() bool {
+	return strings.HasPrefix(string(s), secretPrefix)
+}
+
+/*
+	Reveal returns the plaintext of s.
+*/
+func (s Secret) Reveal() ([]byte, error) {
+	if !s.Protected() {
+		return []byte(s), nil
+	}
+	blob, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(string(s), secretPrefix))
+	if err != nil {
+		return nil, err
+	}
+	return unprotectSecret(blob)
+}
+
+/*
+	ProtectSecret encrypts plain with DPAPI for scope. It fails on systems
+	other than Windows.
+*/
+func ProtectSecret(plain []byte, scope SecretScope) (Secret, error) {
+	blob, err := protectSecret(plain, scope)
+	if err != nil {
+		return "", err
+	}
+	return Secret(secretPrefix + base64.StdEncoding.EncodeToString(blob)), nil
+}
+
+/*
+	Reads the key in the file name, which may be a protected Secret, or
+	creates the file with a new random key of size bytes, protected for the
+	current user where DPAPI is available.
+*/
+func loadKey(name string, size int) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err == nil {
+		return Secret(data).Reveal()
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	data = key
+	if s, err := ProtectSecret(key, SecretUser); err == nil {
+		data = []byte(s)
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return key, f.Close()
+}
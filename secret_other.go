@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package keylogger
+
+import "errors"
+
+var errNoDPAPI = errors.New("DPAPI secrets are only supported on Windows")
+
+func protectSecret([]byte, SecretScope) ([]byte, error) {
+	return nil, errNoDPAPI
+}
+
+func unprotectSecret([]byte) ([]byte, error) {
+	return nil, errNoDPAPI
+}
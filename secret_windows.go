@@ -0,0 +1,60 @@
+package keylogger
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+/*
+	Encrypts data so that only the same user, or with
+	CRYPTPROTECT_LOCAL_MACHINE any user on the same computer, can decrypt it.
+	https://docs.microsoft.com/en-us/windows/win32/api/dpapi/nf-dpapi-cryptprotectdata
+*/
+func protectSecret(plain []byte, scope SecretScope) ([]byte, error) {
+	flags := uint32(windows.CRYPTPROTECT_UI_FORBIDDEN)
+	if scope == SecretMachine {
+		flags |= windows.CRYPTPROTECT_LOCAL_MACHINE
+	}
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(dataBlob(plain), nil, nil, 0, nil, flags, &out); err != nil {
+		return nil, err
+	}
+	return takeDataBlob(&out), nil
+}
+
+/*
+	Decrypts data encrypted by CryptProtectData.
+	https://docs.microsoft.com/en-us/windows/win32/api/dpapi/nf-dpapi-cryptunprotectdata
+*/
+func unprotectSecret(blob []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(dataBlob(blob), nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeDataBlob(&out), nil
+}
+
+func dataBlob(b []byte) *windows.DataBlob {
+	if len(b) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(b)), Data: &b[0]}
+}
+
+/*
+	Copies a blob allocated by the system and frees it, zeroing the
+	system's copy of what may be plaintext first.
+*/
+func takeDataBlob(b *windows.DataBlob) []byte {
+	if b.Data == nil {
+		return nil
+	}
+	data := unsafe.Slice(b.Data, b.Size)
+	out := append([]byte(nil), data...)
+	for i := range data {
+		data[i] = 0
+	}
+	windows.LocalFree(windows.Handle(unsafe.Pointer(b.Data)))
+	return out
+}
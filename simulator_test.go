@@ -0,0 +1,93 @@
+package keylogger
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+	Start, Stop, Events and Stats called from several goroutines at once;
+	run with -race. Every run delivers on a channel that Stop closes.
+*/
+func TestSimulatorConcurrentLifecycle(t *testing.T) {
+	s := NewSimulator()
+	s.WPM = -1
+	s.StopTimeout = time.Second
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				events := s.Events()
+				for open := true; open; {
+					select {
+					case _, open = <-events:
+					case <-done:
+						return
+					}
+				}
+				s.Stats()
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		var starts sync.WaitGroup
+		started := 0
+		var mu sync.Mutex
+		for j := 0; j < 3; j++ {
+			starts.Add(1)
+			go func() {
+				defer starts.Done()
+				if s.Start() == nil {
+					mu.Lock()
+					started++
+					mu.Unlock()
+				}
+			}()
+		}
+		starts.Wait()
+		if started != 1 {
+			t.Fatalf("run %d: %d concurrent starts succeeded, want 1", i, started)
+		}
+		time.Sleep(time.Millisecond)
+		var stops sync.WaitGroup
+		for j := 0; j < 3; j++ {
+			stops.Add(1)
+			go func() {
+				defer stops.Done()
+				s.Stop()
+			}()
+		}
+		stops.Wait()
+	}
+	close(done)
+	wg.Wait()
+	if d := s.Stats().Dropped.Total(); d != 0 {
+		t.Errorf("%d events dropped", d)
+	}
+}
+
+/*
+	Typed events through the whole delivery pipeline to Events, as fast as
+	it takes them.
+*/
+func BenchmarkSimulatorPipeline(b *testing.B) {
+	s := NewSimulator()
+	s.WPM = -1
+	events := s.Events()
+	b.ReportAllocs()
+	start := time.Now()
+	if err := s.Start(); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		<-events
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+	s.Stop()
+	b.ReportMetric(float64(b.N)/elapsed.Seconds(), "events/s")
+}
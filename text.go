@@ -0,0 +1,55 @@
+package keylogger
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+/*
+	Converts translated key output to strings. Strings of single characters
+	are cached, so ordinary typing does not allocate; only multi-character
+	output such as an unused dead key followed by a letter does.
+*/
+type textCache struct {
+	runes [0x800]string
+}
+
+/*
+	Returns the printable characters, tab and newline in buf, with carriage
+	returns turned into newlines.
+*/
+func (c *textCache) text(buf []uint16) string {
+	if len(buf) == 1 {
+		if r, ok := keyTextRune(rune(buf[0])); ok {
+			return c.rune(r)
+		}
+		return ""
+	}
+	var b strings.Builder
+	for _, r := range utf16.Decode(buf) {
+		if r, ok := keyTextRune(r); ok {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (c *textCache) rune(r rune) string {
+	if r < 0 || int(r) >= len(c.runes) {
+		return string(r)
+	}
+	if c.runes[r] == "" {
+		c.runes[r] = string(r)
+	}
+	return c.runes[r]
+}
+
+func keyTextRune(r rune) (rune, bool) {
+	switch {
+	case r == '\r':
+		return '\n', true
+	case r == '\t' || r >= 0x20 && r != 0x7F:
+		return r, true
+	}
+	return 0, false
+}
@@ -0,0 +1,308 @@
+package keylogger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+	Typing stops making one TextSegment after this long a pause.
+*/
+const textSegmentPause = 30 * time.Second
+
+/*
+	TextSegment is text typed into one window without a long pause, with
+	Backspace applied and Enter as a newline.
+*/
+type TextSegment struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	App   string    `json:"app"`
+	Title string    `json:"title"`
+	Text  string    `json:"text"`
+}
+
+/*
+	TextSegmenter turns key events into TextSegments, following the
+	foreground window from FocusEvents. Shortcuts and input injected by
+	programs other than on-screen keyboards are left out, as is the text
+	of redacted keys.
+*/
+type TextSegmenter struct {
+	mods     ModifierState
+	app      string
+	title    string
+	text     []rune
+	start    time.Time
+	end      time.Time
+	segments []TextSegment
+}
+
+/*
+	Feed processes one event.
+*/
+func (s *TextSegmenter) Feed(ev Event) {
+	switch e := ev.(type) {
+	case FocusEvent:
+		s.cut()
+		s.app, s.title = e.Process, e.Title
+	case KeyEvent:
+		if e.Source() == KeySourceInjected || e.VkCode == 0 {
+			return
+		}
+		mods := s.mods.Update(e)
+		if !e.Down || mods&(ModCtrl|ModWin) != 0 && mods&ModAlt == 0 {
+			return
+		}
+		if len(s.text) > 0 && e.Time.Sub(s.end) >= textSegmentPause {
+			s.cut()
+		}
+		if e.VkCode == VK_BACK {
+			if len(s.text) > 0 {
+				s.text = s.text[:len(s.text)-1]
+				s.end = e.Time
+			}
+			return
+		}
+		for _, r := range e.Text {
+			if r != '\n' && !unicode.IsPrint(r) {
+				continue
+			}
+			if len(s.text) == 0 {
+				s.start = e.Time
+			}
+			s.text = append(s.text, r)
+			s.end = e.Time
+		}
+	}
+}
+
+/*
+	Segments returns the segments so far, ending the one being typed.
+*/
+func (s *TextSegmenter) Segments() []TextSegment {
+	s.cut()
+	return s.segments
+}
+
+func (s *TextSegmenter) cut() {
+	if text := strings.TrimSpace(string(s.text)); text != "" {
+		s.segments = append(s.segments, TextSegment{Start: s.start, End: s.end, App: s.app, Title: s.title, Text: text})
+	}
+	wipeRunes(s.text)
+	s.text = s.text[:0]
+}
+
+func wipeRunes(r []rune) {
+	for i := range r {
+		r[i] = 0
+	}
+}
+
+/*
+	TextIndex finds the TextSegments holding words. Words are runs of
+	letters and digits, compared in lower case.
+*/
+type TextIndex struct {
+	Segments []TextSegment
+	words    map[string][]int // segment indexes, ascending
+}
+
+/*
+	TextMatch is a segment found by TextIndex.Search, with an excerpt of
+	its text around the first of the words.
+*/
+type TextMatch struct {
+	TextSegment
+	Excerpt string
+}
+
+/*
+	NewTextIndex indexes segments.
+*/
+func NewTextIndex(segments []TextSegment) *TextIndex {
+	x := &TextIndex{words: make(map[string][]int)}
+	for _, s := range segments {
+		x.Add(s)
+	}
+	return x
+}
+
+/*
+	Add indexes one more segment.
+*/
+func (x *TextIndex) Add(s TextSegment) {
+	i := len(x.Segments)
+	x.Segments = append(x.Segments, s)
+	seen := map[string]bool{}
+	for _, w := range textWords(s.Text) {
+		if !seen[w] {
+			seen[w] = true
+			x.words[w] = append(x.words[w], i)
+		}
+	}
+}
+
+/*
+	Search returns the segments holding every word of query, those with
+	the query as a phrase first, then the newest first. A word ending in
+	* matches the words it starts.
+*/
+func (x *TextIndex) Search(query string) []TextMatch {
+	words := strings.Fields(strings.ToLower(query))
+	var found []int
+	for n, w := range words {
+		var hits []int
+		if strings.HasSuffix(w, "*") {
+			hits = x.prefix(strings.TrimSuffix(w, "*"))
+		} else if ws := textWords(w); len(ws) > 0 {
+			hits = x.words[ws[0]]
+			for _, more := range ws[1:] {
+				hits = intersect(hits, x.words[more])
+			}
+		}
+		if n == 0 {
+			found = hits
+		} else {
+			found = intersect(found, hits)
+		}
+	}
+	phrase := strings.TrimRight(strings.ToLower(query), "*")
+	matches := make([]TextMatch, 0, len(found))
+	for _, i := range found {
+		s := x.Segments[i]
+		matches = append(matches, TextMatch{TextSegment: s, Excerpt: excerpt(s.Text, words[0])})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		pi := strings.Contains(strings.ToLower(matches[i].Text), phrase)
+		pj := strings.Contains(strings.ToLower(matches[j].Text), phrase)
+		if pi != pj {
+			return pi
+		}
+		return matches[i].Start.After(matches[j].Start)
+	})
+	return matches
+}
+
+/*
+	The segments holding a word that starts with p.
+*/
+func (x *TextIndex) prefix(p string) []int {
+	set := map[int]bool{}
+	for w, hits := range x.words {
+		if strings.HasPrefix(w, p) {
+			for _, i := range hits {
+				set[i] = true
+			}
+		}
+	}
+	hits := make([]int, 0, len(set))
+	for i := range set {
+		hits = append(hits, i)
+	}
+	sort.Ints(hits)
+	return hits
+}
+
+func intersect(a, b []int) []int {
+	var out []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+func textWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+/*
+	Returns the text around the first place word appears, on one line.
+*/
+func excerpt(text, word string) string {
+	const around = 40
+	text = strings.ReplaceAll(text, "\n", " ⏎ ")
+	runes := []rune(text)
+	lower := strings.ToLower(text)
+	i := 0
+	if at := strings.Index(lower, strings.TrimSuffix(word, "*")); at > 0 {
+		i = utf8.RuneCountInString(lower[:at])
+	}
+	from, to := i-around, i+around
+	if from < 0 {
+		from = 0
+	}
+	if to > len(runes) {
+		to = len(runes)
+	}
+	s := string(runes[from:to])
+	if from > 0 {
+		s = "…" + s
+	}
+	if to < len(runes) {
+		s += "…"
+	}
+	return s
+}
+
+/*
+	SaveTextIndex writes the segments of x to the file name as JSON lines,
+	replacing it. The file holds typed text: it is as private as the logs
+	it was made of.
+*/
+func SaveTextIndex(name string, x *TextIndex) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, s := range x.Segments {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	wipeBytes(buf.Bytes())
+	return os.Rename(tmp, name)
+}
+
+/*
+	LoadTextIndex reads a file written by SaveTextIndex.
+*/
+func LoadTextIndex(name string) (*TextIndex, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBytes(data)
+	x := NewTextIndex(nil)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	for line := 1; sc.Scan(); line++ {
+		var s TextSegment
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+		x.Add(s)
+	}
+	return x, sc.Err()
+}
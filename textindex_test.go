@@ -0,0 +1,125 @@
+package keylogger
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+/*
+	Returns the keys typing s at start, a millisecond apart.
+*/
+func typedAt(s string, start time.Time) []Event {
+	events := typeKeys(s, false)
+	for i, e := range events {
+		k := e.(KeyEvent)
+		k.Time = start.Add(k.Time.Sub(time.Unix(0, 0)))
+		events[i] = k
+	}
+	return events
+}
+
+func TestTextSegmenter(t *testing.T) {
+	start := time.Unix(1000, 0).UTC()
+	var s TextSegmenter
+	feed := func(events ...Event) {
+		for _, e := range events {
+			s.Feed(e)
+		}
+	}
+	feed(FocusEvent{Process: "notepad.exe", Title: "notes.txt", Time: start})
+	feed(typedAt("hlo\b\bello world", start)...)
+	// Ctrl+S is a shortcut, not text.
+	feed(KeyEvent{VkCode: VK_LCONTROL, Down: true, Time: start}, KeyEvent{VkCode: 'S', Down: true, Text: "s", Time: start}, KeyEvent{VkCode: VK_LCONTROL, Time: start})
+	feed(KeyEvent{VkCode: 'X', Flags: LLKHF_INJECTED, ExtraInfo: InjectedSignature, Down: true, Text: "x", Time: start})
+	later := start.Add(time.Minute)
+	feed(typedAt("second\nline", later)...)
+	feed(FocusEvent{Process: "code.exe", Title: "main.go", Time: later})
+	feed(typedAt("func", later.Add(time.Second))...)
+
+	want := []TextSegment{
+		{Start: start, End: start.Add(14 * time.Millisecond), App: "notepad.exe", Title: "notes.txt", Text: "hello world"},
+		{Start: later, End: later.Add(10 * time.Millisecond), App: "notepad.exe", Title: "notes.txt", Text: "second\nline"},
+		{Start: later.Add(time.Second), End: later.Add(time.Second + 3*time.Millisecond), App: "code.exe", Title: "main.go", Text: "func"},
+	}
+	if got := s.Segments(); !reflect.DeepEqual(got, want) {
+		t.Errorf("segments\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+/*
+	Segments of two sessions, as `keylogger index` builds them from the
+	logs of two days.
+*/
+var textSessions = [][]TextSegment{
+	{
+		{Start: time.Unix(100, 0).UTC(), App: "outlook.exe", Text: "The quarterly report is attached."},
+		{Start: time.Unix(200, 0).UTC(), App: "code.exe", Text: "func report() error"},
+	},
+	{
+		{Start: time.Unix(86500, 0).UTC(), App: "slack.exe", Text: "Report looks good, quarterly numbers are up\nthanks"},
+		{Start: time.Unix(86600, 0).UTC(), App: "notepad.exe", Text: "groceries: milk, eggs"},
+	},
+}
+
+func matchedApps(matches []TextMatch) []string {
+	apps := []string{}
+	for _, m := range matches {
+		apps = append(apps, m.App)
+	}
+	return apps
+}
+
+func TestTextIndexSearch(t *testing.T) {
+	x := NewTextIndex(textSessions[0])
+	for _, s := range textSessions[1] {
+		x.Add(s)
+	}
+	for _, c := range []struct {
+		query string
+		want  []string
+	}{
+		{"report", []string{"slack.exe", "code.exe", "outlook.exe"}},
+		{"REPORT", []string{"slack.exe", "code.exe", "outlook.exe"}},
+		{"quarterly report", []string{"outlook.exe", "slack.exe"}},
+		{"report quarterly", []string{"slack.exe", "outlook.exe"}},
+		{"quart*", []string{"slack.exe", "outlook.exe"}},
+		{"rep* milk", []string{}},
+		{"milk,eggs", []string{"notepad.exe"}},
+		{"repo", []string{}},
+		{"", []string{}},
+	} {
+		if got := matchedApps(x.Search(c.query)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q finds %v, want %v", c.query, got, c.want)
+		}
+	}
+	m := x.Search("thanks")
+	if len(m) != 1 || m[0].Excerpt != "… looks good, quarterly numbers are up ⏎ thanks" {
+		t.Errorf("thanks finds %+v", m)
+	}
+}
+
+/*
+	A saved index loads with its segments, and segments of a later session
+	added to it are found along with them.
+*/
+func TestTextIndexSaveLoad(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "index.jsonl")
+	if err := SaveTextIndex(name, NewTextIndex(textSessions[0])); err != nil {
+		t.Fatal(err)
+	}
+	x, err := LoadTextIndex(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(x.Segments, textSessions[0]) {
+		t.Errorf("loaded\n%+v\nwant\n%+v", x.Segments, textSessions[0])
+	}
+	for _, s := range textSessions[1] {
+		x.Add(s)
+	}
+	if got, want := matchedApps(x.Search("report")), []string{"slack.exe", "code.exe", "outlook.exe"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("report finds %v across sessions, want %v", got, want)
+	}
+}
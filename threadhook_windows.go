@@ -0,0 +1,116 @@
+package keylogger
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32             = windows.NewLazySystemDLL("kernel32.dll")
+	getProcessIdOfThread = kernel32.NewProc("GetProcessIdOfThread")
+)
+
+const (
+	/*
+		The 'WH_KEYBOARD' hook monitors the keystroke messages one thread is about to get.
+		https://docs.microsoft.com/en-us/windows/win32/winmsg/about-hooks#wh_keyboard
+	*/
+	WH_KEYBOARD = 2
+
+	/*
+		HC_NOREMOVE : An application peeked at a keystroke message without removing it
+		from the queue; it will be seen again.
+	*/
+	HC_NOREMOVE = 3
+)
+
+/*
+	wmThreadKey hands a key seen by the thread keyboard hook to the hook
+	thread: WParam carries the virtual key, with threadKeySwallowed set if a
+	filter swallowed it, and LParam the keystroke flags of the hook.
+*/
+const (
+	wmThreadKey        = WM_APP + 5
+	threadKeySwallowed = 1 << 16
+)
+
+/*
+	Makes sure thread belongs to this process. A thread hook in another
+	process would need a DLL injected into it, which the Logger does not do.
+*/
+func checkThread(thread uint32) error {
+	h, err := windows.OpenThread(windows.THREAD_QUERY_LIMITED_INFORMATION, false, thread)
+	if err != nil {
+		return fmt.Errorf("thread %d: %w", thread, err)
+	}
+	defer windows.CloseHandle(h)
+	pid, _, err := getProcessIdOfThread.Call(uintptr(h))
+	if pid == 0 {
+		return fmt.Errorf("thread %d: GetProcessIdOfThread: %w", thread, err)
+	}
+	if int(pid) != os.Getpid() {
+		return fmt.Errorf("thread %d belongs to process %d, not this one", thread, pid)
+	}
+	return nil
+}
+
+func (l *Logger) installThreadKeyboard(t *hookThread) error {
+	if l.threadKeyCB == nil {
+		l.threadKeyCB = l.threadKeyboardProc
+	}
+	t.keyboard = l.api.SetHook(WH_KEYBOARD, l.threadKeyCB, l.Thread)
+	if t.keyboard == 0 {
+		return fmt.Errorf("SetWindowsHookEx(WH_KEYBOARD) for thread %d failed", l.Thread)
+	}
+	return nil
+}
+
+/*
+	Runs on Thread, before it gets a keystroke message. The filters decide
+	there whether the thread gets the key; the event itself is queued by
+	the hook thread, which is the only one to push to the ring.
+*/
+func (l *Logger) threadKeyboardProc(nCode int, wparam WPARAM, lparam LPARAM) LRESULT {
+	if nCode != HC_ACTION || !l.onTarget() {
+		return l.api.CallNext(nCode, wparam, lparam)
+	}
+	start := time.Now()
+	e := threadKeyEvent(wparam, lparam)
+	if l.filter(e) {
+		wparam |= threadKeySwallowed
+	}
+	l.api.PostMessage(l.current().id, wmThreadKey, wparam, lparam)
+	l.hookTime.observe(time.Since(start))
+	if wparam&threadKeySwallowed != 0 {
+		return 1
+	}
+	return l.api.CallNext(nCode, wparam, lparam)
+}
+
+/*
+	Decodes the arguments of a WH_KEYBOARD hook: the virtual key, and the
+	scan code and flags packed as in WM_KEYDOWN, which are turned into the
+	LLKHF flags a low-level hook reports.
+*/
+func threadKeyEvent(wparam WPARAM, lparam LPARAM) KeyEvent {
+	e := KeyEvent{
+		VkCode:    uint16(wparam),
+		ScanCode:  uint32(lparam>>16) & 0xff,
+		Down:      lparam&(1<<31) == 0,
+		Swallowed: wparam&threadKeySwallowed != 0,
+		Time:      time.Now(),
+	}
+	if lparam&(1<<24) != 0 {
+		e.Flags |= LLKHF_EXTENDED
+	}
+	if lparam&(1<<29) != 0 {
+		e.Flags |= LLKHF_ALTDOWN
+	}
+	if !e.Down {
+		e.Flags |= LLKHF_UP
+	}
+	return e
+}
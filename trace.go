@@ -0,0 +1,132 @@
+package keylogger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+/*
+	A trace records the events as the hooks produced them, before the
+	Logger enriches them, so that they can be run through the pipeline
+	again with Logger.Replay: filters, translation, mouse sampling,
+	double-click detection, redaction and the sinks.
+
+	The first line is a header holding the state translation and
+	double-click detection start from; every other line is a JSONCodec
+	record. Key events carry the text they typed when they were recorded,
+	which stands in for the keyboard layout on replay; keys a filter
+	swallowed were not translated, so they type nothing on replay either.
+	Focus, process, gamepad and diagnostic events are recorded as they were
+	delivered.
+*/
+type traceHeader struct {
+	Trace             int   `json:"trace"`
+	CapsLock          bool  `json:"caps_lock,omitempty"`
+	DoubleClickMillis int64 `json:"double_click_ms"`
+	DoubleClickWidth  int32 `json:"double_click_width"`
+	DoubleClickHeight int32 `json:"double_click_height"`
+}
+
+const traceVersion = 1
+
+func (h traceHeader) doubleClicks() doubleClicks {
+	return doubleClicks{
+		interval: time.Duration(h.DoubleClickMillis) * time.Millisecond,
+		width:    h.DoubleClickWidth,
+		height:   h.DoubleClickHeight,
+	}
+}
+
+/*
+	Writes a trace. It is only used from the Logger's worker; the first
+	error stops it and is kept.
+*/
+type traceWriter struct {
+	w   *bufio.Writer
+	buf []byte
+	err error
+}
+
+func newTraceWriter(w io.Writer, caps bool, clicks doubleClicks) *traceWriter {
+	t := &traceWriter{w: bufio.NewWriter(w)}
+	h := traceHeader{
+		Trace:             traceVersion,
+		CapsLock:          caps,
+		DoubleClickMillis: int64(clicks.interval / time.Millisecond),
+		DoubleClickWidth:  clicks.width,
+		DoubleClickHeight: clicks.height,
+	}
+	data, err := json.Marshal(h)
+	if err == nil {
+		_, err = t.w.Write(append(data, '\n'))
+	}
+	t.err = err
+	return t
+}
+
+/*
+	Records e and reports whether this write failed, so the failure is
+	reported once.
+*/
+func (t *traceWriter) write(e Event) bool {
+	if t == nil || t.err != nil {
+		return false
+	}
+	if t.buf, t.err = (JSONCodec{}).AppendEvent(t.buf[:0], e); t.err == nil {
+		_, t.err = t.w.Write(t.buf)
+	}
+	return t.err != nil
+}
+
+func (t *traceWriter) flush() error {
+	if t.err == nil {
+		t.err = t.w.Flush()
+	}
+	return t.err
+}
+
+type traceReader struct {
+	sc     *bufio.Scanner
+	header traceHeader
+	line   int
+}
+
+func newTraceReader(r io.Reader) (*traceReader, error) {
+	t := &traceReader{sc: bufio.NewScanner(r), line: 1}
+	t.sc.Buffer(nil, 1<<20)
+	if !t.sc.Scan() {
+		if err := t.sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("trace: empty")
+	}
+	if err := json.Unmarshal(t.sc.Bytes(), &t.header); err != nil || t.header.Trace == 0 {
+		return nil, errors.New("trace: no header")
+	}
+	if t.header.Trace != traceVersion {
+		return nil, fmt.Errorf("trace: version %d is not supported", t.header.Trace)
+	}
+	return t, nil
+}
+
+/*
+	Returns the next recorded event, or io.EOF after the last.
+*/
+func (t *traceReader) next() (Event, error) {
+	if !t.sc.Scan() {
+		if err := t.sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	t.line++
+	e, err := DecodeJSONEvent(t.sc.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("trace line %d: %v", t.line, err)
+	}
+	return e, nil
+}
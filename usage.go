@@ -0,0 +1,209 @@
+package keylogger
+
+import (
+	"sort"
+	"time"
+)
+
+/*
+	UsageReport sums up input by day, application and key, and lists the
+	focus sessions. It holds counts only, nothing of what was typed.
+*/
+type UsageReport struct {
+	Days     []DayUsage
+	Apps     []AppUsage
+	Keys     []KeyUsage
+	Sessions []FocusSession
+}
+
+/*
+	DayUsage is the input of one local day. Active minutes are those with
+	any key or mouse button press.
+*/
+type DayUsage struct {
+	Date          time.Time
+	Keys          int
+	Clicks        int
+	ActiveMinutes int
+	First, Last   time.Time
+}
+
+/*
+	AppUsage is the input while an application was in the foreground, as
+	told by the FocusEvents in the log.
+*/
+type AppUsage struct {
+	App           string
+	Keys          int
+	Clicks        int
+	ActiveMinutes int
+}
+
+/*
+	KeyUsage is how often a key was pressed, repeats left out.
+*/
+type KeyUsage struct {
+	Key     string
+	Presses int
+}
+
+type usageDay struct {
+	DayUsage
+	minutes map[int64]bool
+}
+
+type usageApp struct {
+	AppUsage
+	minutes map[int64]bool
+}
+
+/*
+	UsageCounter gathers a UsageReport from events in the order they
+	happened. Injected input is not counted, except keys typed on an
+	on-screen keyboard.
+*/
+type UsageCounter struct {
+	held map[uint16]bool
+	app  string
+	days map[time.Time]*usageDay
+	apps map[string]*usageApp
+	keys map[uint16]int
+}
+
+/*
+	NewUsageCounter returns a counter that has not counted anything.
+*/
+func NewUsageCounter() *UsageCounter {
+	return &UsageCounter{
+		held: make(map[uint16]bool),
+		days: make(map[time.Time]*usageDay),
+		apps: make(map[string]*usageApp),
+		keys: make(map[uint16]int),
+	}
+}
+
+/*
+	Feed counts e.
+*/
+func (c *UsageCounter) Feed(e Event) {
+	switch e := e.(type) {
+	case FocusEvent:
+		c.app = e.Process
+	case KeyEvent:
+		if e.Source() == KeySourceInjected {
+			return
+		}
+		if !e.Down {
+			delete(c.held, e.VkCode)
+			return
+		}
+		if c.held[e.VkCode] {
+			return
+		}
+		c.held[e.VkCode] = true
+		c.keys[e.VkCode]++
+		day, app := c.count(e.Time)
+		day.Keys++
+		app.Keys++
+	case MouseEvent:
+		if e.Injected() || e.Action != MouseDown {
+			return
+		}
+		day, app := c.count(e.Time)
+		day.Clicks++
+		app.Clicks++
+	}
+}
+
+func (c *UsageCounter) count(t time.Time) (*usageDay, *usageApp) {
+	t = t.Local()
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	day := c.days[date]
+	if day == nil {
+		day = &usageDay{DayUsage: DayUsage{Date: date, First: t}, minutes: make(map[int64]bool)}
+		c.days[date] = day
+	}
+	if t.Before(day.First) {
+		day.First = t
+	}
+	if t.After(day.Last) {
+		day.Last = t
+	}
+	minute := t.Unix() / 60
+	if !day.minutes[minute] {
+		day.minutes[minute] = true
+		day.ActiveMinutes++
+	}
+	app := c.apps[c.app]
+	if app == nil {
+		app = &usageApp{AppUsage: AppUsage{App: c.app}, minutes: make(map[int64]bool)}
+		c.apps[c.app] = app
+	}
+	if !app.minutes[minute] {
+		app.minutes[minute] = true
+		app.ActiveMinutes++
+	}
+	return day, app
+}
+
+/*
+	Report returns the counts: days in order, applications by active
+	minutes and keys by presses, the most first.
+*/
+func (c *UsageCounter) Report() UsageReport {
+	var r UsageReport
+	for _, d := range c.days {
+		r.Days = append(r.Days, d.DayUsage)
+	}
+	sort.Slice(r.Days, func(i, j int) bool { return r.Days[i].Date.Before(r.Days[j].Date) })
+	for _, a := range c.apps {
+		r.Apps = append(r.Apps, a.AppUsage)
+	}
+	sort.Slice(r.Apps, func(i, j int) bool {
+		if r.Apps[i].ActiveMinutes != r.Apps[j].ActiveMinutes {
+			return r.Apps[i].ActiveMinutes > r.Apps[j].ActiveMinutes
+		}
+		return r.Apps[i].App < r.Apps[j].App
+	})
+	for vk, n := range c.keys {
+		r.Keys = append(r.Keys, KeyUsage{Key: KeyName(vk), Presses: n})
+	}
+	sort.Slice(r.Keys, func(i, j int) bool {
+		if r.Keys[i].Presses != r.Keys[j].Presses {
+			return r.Keys[i].Presses > r.Keys[j].Presses
+		}
+		return r.Keys[i].Key < r.Keys[j].Key
+	})
+	return r
+}
+
+/*
+	AnalyzeUsageLogs returns the UsageReport of the events in logs written
+	with codec that happened from from until to, or until now if to is
+	zero, with the focus sessions found by the rules focus.
+*/
+func AnalyzeUsageLogs(logs []string, codec Codec, from, to time.Time, focus FocusRules) (UsageReport, error) {
+	if err := focus.Validate(); err != nil {
+		return UsageReport{}, err
+	}
+	c := NewUsageCounter()
+	d := NewFocusSessionDetector(focus)
+	err := ReadLogs(logs, codec, func(e Event) {
+		// Focus changes before the window still say which application is in front.
+		if inWindow(e.Timestamp(), from, to) || isFocus(e) {
+			c.Feed(e)
+			d.Feed(e)
+		}
+	})
+	if err != nil {
+		return UsageReport{}, err
+	}
+	r := c.Report()
+	r.Sessions = d.Sessions()
+	return r, nil
+}
+
+func isFocus(e Event) bool {
+	_, ok := e.(FocusEvent)
+	return ok
+}
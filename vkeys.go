@@ -0,0 +1,10 @@
+package keylogger
+
+/*
+	Reports whether the key is one of the "extended" keys, which SendInput
+	must be told about via KEYEVENTF_EXTENDEDKEY.
+	https://docs.microsoft.com/en-us/windows/win32/inputdev/about-keyboard-input#extended-key-flag
+*/
+func isExtendedKey(vk uint16) bool {
+	return KeyScanCode(vk)>>8 == 0xE0
+}
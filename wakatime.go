@@ -0,0 +1,211 @@
+//go:build !localonly
+// +build !localonly
+
+package keylogger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	DefaultWakaTimeURL is the API of wakatime.com. Servers implementing the
+	same API, such as Wakapi, have URLs of their own.
+*/
+const DefaultWakaTimeURL = "https://api.wakatime.com/api/v1"
+
+/*
+	While the same file is being worked on, a heartbeat is sent at most
+	this often, as the editor plugins of WakaTime do.
+*/
+const wakaTimeHeartbeatInterval = 2 * time.Minute
+
+type wakaTimeHeartbeat struct {
+	Entity   string  `json:"entity"`
+	Type     string  `json:"type"`
+	Category string  `json:"category"`
+	Time     float64 `json:"time"`
+	Project  string  `json:"project,omitempty"`
+	IsWrite  bool    `json:"is_write"`
+
+	editor string
+}
+
+/*
+	WakaTime is a Sink that tracks coding time system-wide: while a window
+	of an editor or IDE recognized by ParseEditorTitle is in the
+	foreground and there is key or mouse input, it sends heartbeats naming
+	the file and project of the title to a WakaTime-compatible API. Nothing
+	typed is sent; the input only tells that someone is at work. It needs
+	the FocusEvents of Logger.CaptureFocus.
+
+	Heartbeats are sent from a goroutine of its own, retried according to
+	DefaultRetryPolicy. Once a heartbeat fails, Writes return its error
+	until one gets through again, as for ActivityWatch; heartbeats that
+	cannot be queued are counted in Dropped.
+
+	FocusEvents only tell when another window comes to the foreground,
+	while editors change their titles when another file is opened. If
+	Foreground is set, it is called on input, at most once a second, to
+	read the process and title of the foreground window.
+*/
+type WakaTime struct {
+	dropped uint64 // first for 64-bit alignment on 386
+
+	Foreground func() (process, title string)
+
+	url    string
+	auth   string
+	client *http.Client
+
+	mu       sync.Mutex
+	file     EditorFile
+	inEditor bool
+	lastPoll time.Time
+	lastFile string
+	lastSent time.Time
+	err      error
+
+	queue chan wakaTimeHeartbeat
+	done  chan struct{}
+}
+
+/*
+	NewWakaTime returns a sink sending heartbeats to the API at apiURL,
+	DefaultWakaTimeURL if empty, with apiKey. As the key goes with every
+	request, apiURL has to be https unless the server is on this machine.
+*/
+func NewWakaTime(apiURL, apiKey string) (*WakaTime, error) {
+	if apiURL == "" {
+		apiURL = DefaultWakaTimeURL
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && loopbackHost(u.Hostname())) {
+		return nil, fmt.Errorf("wakatime: %s is neither https nor on this machine", apiURL)
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("wakatime: no API key")
+	}
+	w := &WakaTime{
+		url:    strings.TrimSuffix(apiURL, "/") + "/users/current/heartbeats",
+		auth:   "Basic " + base64.StdEncoding.EncodeToString([]byte(apiKey)),
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan wakaTimeHeartbeat, 64),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+/*
+	Dropped returns the number of heartbeats left out because the queue
+	was full.
+*/
+func (w *WakaTime) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+func (w *WakaTime) Write(events []Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, e := range events {
+		switch e := e.(type) {
+		case FocusEvent:
+			w.file, w.inEditor = ParseEditorTitle(e.Process, e.Title)
+		case KeyEvent:
+			if e.Source() != KeySourceInjected && e.Down {
+				w.active(e.Time)
+			}
+		case MouseEvent:
+			if !e.Injected() && e.Action != MouseMove {
+				w.active(e.Time)
+			}
+		}
+	}
+	return w.err
+}
+
+/*
+	Close sends the queued heartbeats and stops.
+*/
+func (w *WakaTime) Close() error {
+	close(w.queue)
+	<-w.done
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+/*
+	Queues a heartbeat for input at t if the file changed or the last
+	heartbeat is old enough. Called with mu held.
+*/
+func (w *WakaTime) active(t time.Time) {
+	if w.Foreground != nil && t.Sub(w.lastPoll) >= time.Second {
+		w.lastPoll = t
+		w.file, w.inEditor = ParseEditorTitle(w.Foreground())
+	}
+	if !w.inEditor || w.file.File == w.lastFile && t.Sub(w.lastSent) < wakaTimeHeartbeatInterval {
+		return
+	}
+	w.lastFile, w.lastSent = w.file.File, t
+	h := wakaTimeHeartbeat{
+		Entity:   w.file.File,
+		Type:     "file",
+		Category: "coding",
+		Time:     float64(t.UnixNano()) / 1e9,
+		Project:  w.file.Project,
+		editor:   w.file.Editor,
+	}
+	select {
+	case w.queue <- h:
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+	}
+}
+
+func (w *WakaTime) run() {
+	defer close(w.done)
+	for h := range w.queue {
+		h := h
+		err := DefaultRetryPolicy.Do(func() error { return w.send(h) })
+		w.mu.Lock()
+		w.err = err
+		w.mu.Unlock()
+	}
+}
+
+func (w *WakaTime) send(h wakaTimeHeartbeat) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", w.auth)
+	// The servers tell editors apart by the user agent, as the plugins set it.
+	req.Header.Set("User-Agent", "wakatime/v1.0.0 (windows) keylogger "+h.editor+"/0.0.0 keylogger-wakatime/1.0.0")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("wakatime: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("wakatime: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package keylogger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+/*
+	WAL is a write-ahead log in front of a slow or unreliable sink. Events
+	are appended to the log file and synced to disk before they are passed
+	on, and the log is only cleared once the sink has accepted them. Events
+	the sink rejects stay in the log and are retried with the next Write;
+	events still in the log when the process dies are replayed by OpenWAL.
+
+	Delivery is at least once: a crash between the sink accepting a batch
+	and the log being cleared replays that batch. When a Batcher is used,
+	wrap the WAL in it, so batches rather than single events are synced.
+
+	Records are length-prefixed and checksummed JSONCodec lines, so a
+	record torn by a power loss is detected and dropped on replay.
+*/
+type WAL struct {
+	name    string
+	f       *os.File
+	next    Sink
+	pending []Event
+	buf     []byte
+}
+
+/*
+	OpenWAL opens or creates the log file name and replays any events left
+	in it into next.
+*/
+func OpenWAL(name string, next Sink) (*WAL, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w := &WAL{name: name, f: f, next: next}
+	if w.pending, err = readWAL(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// Rewrite the log without a torn tail, positioned for appending.
+	if err := w.rewrite(); err != nil {
+		w.f.Close()
+		return nil, err
+	}
+	if len(w.pending) > 0 {
+		if err := w.forward(); err != nil {
+			return w, err
+		}
+	}
+	return w, nil
+}
+
+/*
+	Pending returns the number of events the sink has not accepted yet.
+*/
+func (w *WAL) Pending() int {
+	return len(w.pending)
+}
+
+func (w *WAL) Write(events []Event) error {
+	w.buf = w.buf[:0]
+	for _, e := range events {
+		var err error
+		if w.buf, err = appendWALRecord(w.buf, e); err != nil {
+			return err
+		}
+	}
+	if _, err := w.f.Write(w.buf); err != nil {
+		return err
+	}
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	w.pending = append(w.pending, events...)
+	return w.forward()
+}
+
+/*
+	Close closes the log and the sink. Events the sink has not accepted stay
+	in the log for the next OpenWAL.
+*/
+func (w *WAL) Close() error {
+	wipeBytes(w.buf[:cap(w.buf)])
+	err := w.f.Close()
+	if cerr := w.next.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (w *WAL) forward() error {
+	if err := w.next.Write(w.pending); err != nil {
+		return err
+	}
+	w.pending = w.pending[:0]
+	return w.rewrite()
+}
+
+/*
+	Replaces the log contents with the pending events. An empty log is
+	truncated in place, as nothing is lost if that is cut short; otherwise
+	the records are written to a new file that replaces the log, so a crash
+	leaves either the old or the new records.
+*/
+func (w *WAL) rewrite() error {
+	if len(w.pending) == 0 {
+		if err := w.f.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return w.f.Sync()
+	}
+	w.buf = w.buf[:0]
+	for _, e := range w.pending {
+		var err error
+		if w.buf, err = appendWALRecord(w.buf, e); err != nil {
+			return err
+		}
+	}
+	// Windows does not rename over an open file.
+	w.f.Close()
+	err := replaceFile(w.name, w.buf, false)
+	f, oerr := os.OpenFile(w.name, os.O_RDWR|os.O_APPEND, 0600)
+	if oerr != nil {
+		return oerr
+	}
+	w.f = f
+	return err
+}
+
+/*
+	A record is the payload length and its CRC-32 (IEEE), both little
+	endian, followed by the payload.
+*/
+func appendWALRecord(dst []byte, e Event) ([]byte, error) {
+	payload, err := JSONCodec{}.AppendEvent(nil, e)
+	if err != nil {
+		return dst, err
+	}
+	var head [8]byte
+	binary.LittleEndian.PutUint32(head[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(head[4:], crc32.ChecksumIEEE(payload))
+	return append(append(dst, head[:]...), payload...), nil
+}
+
+/*
+	No event encodes to anything near this size; a larger length is damage.
+*/
+const maxWALRecord = 1 << 20
+
+/*
+	Reads records up to the end of the file or the first damaged record.
+*/
+func readWAL(f io.ReadSeeker) ([]Event, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+	var events []Event
+	for {
+		var head [8]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return events, nil
+			}
+			return nil, err
+		}
+		n := binary.LittleEndian.Uint32(head[0:])
+		if n > maxWALRecord {
+			return events, nil
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return events, nil
+			}
+			return nil, err
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(head[4:]) {
+			return events, nil
+		}
+		e, err := DecodeJSONEvent(payload)
+		if err != nil {
+			return events, nil
+		}
+		events = append(events, e)
+	}
+}
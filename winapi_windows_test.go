@@ -0,0 +1,193 @@
+package keylogger
+
+import (
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+/*
+	fakeAPI stands in for Windows in tests. Input queued with key and mouse
+	runs through the installed hook procedures on the hook thread, from
+	inside GetMessage, as it does on a desktop; what each hook returned
+	arrives on results. A message posted to a thread is only taken off the
+	queue by that thread, so that a hook thread and its replacement each get
+	their own. ToUnicode knows the letters, digits and space of a US layout.
+*/
+type fakeAPI struct {
+	queue    chan fakeItem
+	results  chan LRESULT
+	capsLock bool
+
+	mu     sync.Mutex
+	hooks  map[HHOOK]fakeHook
+	last   HHOOK
+	passed []fakeCall
+	kbd    *KBDLLHOOKSTRUCT
+	ms     *MSLLHOOKSTRUCT
+}
+
+type fakeHook struct {
+	id     int
+	proc   HOOKPROC
+	thread uint32
+}
+
+type fakeItem struct {
+	thread uint32
+	msg    MSG
+	input  func()
+}
+
+type fakeCall struct {
+	nCode  int
+	wparam WPARAM
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{
+		queue:   make(chan fakeItem, 256),
+		results: make(chan LRESULT, 256),
+		hooks:   make(map[HHOOK]fakeHook),
+	}
+}
+
+func (f *fakeAPI) SetHook(id int, proc HOOKPROC, thread uint32) HHOOK {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.last++
+	f.hooks[f.last] = fakeHook{id: id, proc: proc, thread: thread}
+	return f.last
+}
+
+func (f *fakeAPI) Unhook(h HHOOK) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.hooks[h]
+	delete(f.hooks, h)
+	return ok
+}
+
+func (f *fakeAPI) GetMessage(msg *MSG) int {
+	for item := range f.queue {
+		if item.input != nil {
+			item.input()
+			continue
+		}
+		if item.thread != windows.GetCurrentThreadId() {
+			go func(item fakeItem) { f.queue <- item }(item)
+			continue
+		}
+		*msg = item.msg
+		if msg.Message == WM_QUIT {
+			return 0
+		}
+		return 1
+	}
+	return 0
+}
+
+func (f *fakeAPI) PostMessage(thread uint32, msg uint32, wparam WPARAM, lparam LPARAM) bool {
+	f.queue <- fakeItem{thread: thread, msg: MSG{Message: msg, WParam: uintptr(wparam), LParam: uintptr(lparam)}}
+	return true
+}
+
+func (f *fakeAPI) CallNext(nCode int, wparam WPARAM, lparam LPARAM) LRESULT {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.passed = append(f.passed, fakeCall{nCode: nCode, wparam: wparam})
+	return 0
+}
+
+func (f *fakeAPI) ToUnicode(vk, scanCode uint32, state *[256]byte, buf []uint16) int32 {
+	shift := state[VK_SHIFT]&0x80 != 0
+	switch {
+	case state[VK_CONTROL]&0x80 != 0 || state[VK_MENU]&0x80 != 0:
+		return 0
+	case vk >= 'A' && vk <= 'Z':
+		if shift == (state[VK_CAPITAL]&1 != 0) {
+			vk += 'a' - 'A'
+		}
+	case vk >= '0' && vk <= '9' && !shift, vk == VK_SPACE:
+	default:
+		return 0
+	}
+	buf[0] = uint16(vk)
+	return 1
+}
+
+func (f *fakeAPI) KeyState(vk int) int16 {
+	if vk == VK_CAPITAL && f.capsLock {
+		return 1
+	}
+	return 0
+}
+
+/*
+	Queues a key press or release, as a WH_KEYBOARD_LL hook sees it.
+*/
+func (f *fakeAPI) key(vk uint16, down bool) {
+	wparam := WPARAM(WM_KEYUP)
+	if down {
+		wparam = WM_KEYDOWN
+	}
+	f.rawKey(wparam, KBDLLHOOKSTRUCT{VkCode: DWORD(vk)})
+}
+
+/*
+	Queues a keyboard message with the hook struct s.
+*/
+func (f *fakeAPI) rawKey(wparam WPARAM, s KBDLLHOOKSTRUCT) {
+	f.input(func() LRESULT {
+		// Kept in f, on the heap, where the hook's pointer stays valid.
+		f.kbd = &s
+		return f.send(WH_KEYBOARD_LL, HC_ACTION, wparam, LPARAM(unsafe.Pointer(f.kbd)))
+	})
+}
+
+/*
+	Queues a mouse message, as a WH_MOUSE_LL hook sees it.
+*/
+func (f *fakeAPI) mouse(wparam WPARAM, x, y int32, data DWORD) {
+	f.input(func() LRESULT {
+		f.ms = &MSLLHOOKSTRUCT{Pt: POINT{X: x, Y: y}, MouseData: data}
+		return f.send(WH_MOUSE_LL, HC_ACTION, wparam, LPARAM(unsafe.Pointer(f.ms)))
+	})
+}
+
+func (f *fakeAPI) input(fn func() LRESULT) {
+	f.queue <- fakeItem{input: func() { f.results <- fn() }}
+}
+
+/*
+	Calls the newest hook of type id, which Windows calls first, or passes
+	the input on if there is none.
+*/
+func (f *fakeAPI) send(id int, nCode int, wparam WPARAM, lparam LPARAM) LRESULT {
+	f.mu.Lock()
+	var proc HOOKPROC
+	var newest HHOOK
+	for h, hook := range f.hooks {
+		if hook.id == id && h > newest {
+			proc, newest = hook.proc, h
+		}
+	}
+	f.mu.Unlock()
+	if proc == nil {
+		return f.CallNext(nCode, wparam, lparam)
+	}
+	return proc(nCode, wparam, lparam)
+}
+
+func (f *fakeAPI) installed() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.hooks)
+}
+
+func (f *fakeAPI) passedOn() []fakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeCall(nil), f.passed...)
+}
@@ -0,0 +1,137 @@
+package keylogger
+
+import (
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	getForegroundWindow = user32.NewProc("GetForegroundWindow")
+	getWindowTextW      = user32.NewProc("GetWindowTextW")
+	setWinEventHook     = user32.NewProc("SetWinEventHook")
+	unhookWinEvent      = user32.NewProc("UnhookWinEvent")
+)
+
+/*
+	Retrieves a handle to the foreground window (the window with which the user is currently working).
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getforegroundwindow
+*/
+func GetForegroundWindow() HWND {
+	ret, _, _ := getForegroundWindow.Call()
+	return HWND(ret)
+}
+
+/*
+	Returns the process ID and executable base name (e.g. "notepad.exe") of
+	the process owning the foreground window. The name is empty if the
+	process cannot be queried, e.g. because it runs elevated.
+*/
+func ForegroundProcess() (pid uint32, name string) {
+	hwnd := GetForegroundWindow()
+	if hwnd == 0 {
+		return 0, ""
+	}
+	windows.GetWindowThreadProcessId(windows.HWND(hwnd), &pid)
+	return pid, processName(pid)
+}
+
+func processName(pid uint32) string {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return ""
+	}
+	defer windows.CloseHandle(h)
+
+	buf := make([]uint16, windows.MAX_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(h, 0, &buf[0], &size); err != nil {
+		return ""
+	}
+	return filepath.Base(windows.UTF16ToString(buf[:size]))
+}
+
+/*
+	Constants for SetWinEventHook.
+	https://docs.microsoft.com/en-us/windows/win32/winauto/event-constants
+*/
+const (
+	EVENT_SYSTEM_FOREGROUND = 0x0003
+	WINEVENT_OUTOFCONTEXT   = 0x0000
+)
+
+/*
+	An application-defined callback function that the system calls in response to events generated by an accessible object.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nc-winuser-wineventproc
+*/
+type WINEVENTPROC func(hWinEventHook HANDLE, event DWORD, hwnd HWND, idObject int32, idChild int32, idEventThread DWORD, dwmsEventTime DWORD) uintptr
+
+func (l *Logger) focusProc(hWinEventHook HANDLE, event DWORD, hwnd HWND, idObject int32, idChild int32, idEventThread DWORD, dwmsEventTime DWORD) uintptr {
+	if event == EVENT_SYSTEM_FOREGROUND && hwnd != 0 && l.onCurrentThread() {
+		l.targetFocus(hwnd)
+		if l.CaptureFocus {
+			l.pushCurrent(&rawEvent{kind: rawFocus, focus: FocusEvent{HWND: uintptr(hwnd), Time: time.Now()}})
+		}
+	}
+	return 0
+}
+
+/*
+	Looks up the process and title of a window that became the foreground
+	window at time t.
+*/
+func newFocusEvent(hwnd HWND, t time.Time) FocusEvent {
+	e := FocusEvent{HWND: uintptr(hwnd), Time: t}
+	if hwnd != 0 {
+		windows.GetWindowThreadProcessId(windows.HWND(hwnd), &e.PID)
+		e.Process = processName(e.PID)
+		e.Title = GetWindowText(hwnd)
+		e.Elevated = ProcessElevated(e.PID)
+	}
+	return e
+}
+
+/*
+	Sets an event hook function for a range of events.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-setwineventhook
+*/
+func SetWinEventHook(eventMin DWORD, eventMax DWORD, hmodWinEventProc HINSTANCE, pfnWinEventProc WINEVENTPROC, idProcess DWORD, idThread DWORD, dwFlags DWORD) HANDLE {
+	ret, _, _ := setWinEventHook.Call(
+		uintptr(eventMin),
+		uintptr(eventMax),
+		uintptr(hmodWinEventProc),
+		syscall.NewCallback(pfnWinEventProc),
+		uintptr(idProcess),
+		uintptr(idThread),
+		uintptr(dwFlags),
+	)
+	return HANDLE(ret)
+}
+
+/*
+	Removes an event hook function created by a previous call to SetWinEventHook.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-unhookwinevent
+*/
+func UnhookWinEvent(hWinEventHook HANDLE) bool {
+	ret, _, _ := unhookWinEvent.Call(
+		uintptr(hWinEventHook),
+	)
+	return ret != 0
+}
+
+/*
+	Copies the text of the specified window's title bar (if it has one) into a buffer.
+	https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getwindowtextw
+*/
+func GetWindowText(hwnd HWND) string {
+	buf := make([]uint16, 512)
+	ret, _, _ := getWindowTextW.Call(
+		uintptr(hwnd),
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(len(buf)),
+	)
+	return windows.UTF16ToString(buf[:ret])
+}
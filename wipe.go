@@ -0,0 +1,30 @@
+package keylogger
+
+/*
+	Wiping captured input from memory, on a best-effort basis.
+
+	When the worker stops, it clears the slots of the queue behind the
+	hooks and the translator's key state, and the Redactor clears the
+	events it held back. Sinks clear their encoding buffers on Close;
+	Pseudonymizer.Wipe and Hotstrings.Wipe clear the key and the typed text
+	once they are no longer needed, and a Pseudonymizer's key is cleared
+	before the garbage collector frees it. Go strings cannot be
+	overwritten, so the Text of events already handed out stays in memory
+	until the garbage collector reuses it; what is wiped are the buffers
+	the package holds on to and reuses.
+*/
+
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+/*
+	Clears every slot. Only called once the producer is gone.
+*/
+func (r *eventRing) wipe() {
+	for i := range r.buf {
+		r.buf[i] = rawEvent{}
+	}
+}
@@ -0,0 +1,237 @@
+package keylogger
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	WriteUsageWorkbook writes r as an Excel workbook (.xlsx) with a sheet
+	per day, application, key and focus session, each with a bold, frozen
+	header row.
+*/
+func WriteUsageWorkbook(w io.Writer, r UsageReport) error {
+	days := xlsxSheet{
+		name:   "Days",
+		widths: []float64{12, 10, 10, 15, 10, 10},
+		rows:   [][]interface{}{{"Date", "Keys", "Clicks", "Active minutes", "First", "Last"}},
+	}
+	for _, d := range r.Days {
+		days.rows = append(days.rows, []interface{}{xlsxDate(d.Date), d.Keys, d.Clicks, d.ActiveMinutes, xlsxClock(d.First), xlsxClock(d.Last)})
+	}
+	keys := 0
+	for _, k := range r.Keys {
+		keys += k.Presses
+	}
+	apps := xlsxSheet{
+		name:   "Applications",
+		widths: []float64{30, 10, 10, 15},
+		rows:   [][]interface{}{{"Application", "Keys", "Clicks", "Active minutes"}},
+	}
+	for _, a := range r.Apps {
+		name := a.App
+		if name == "" {
+			name = "(unknown)"
+		}
+		apps.rows = append(apps.rows, []interface{}{name, a.Keys, a.Clicks, a.ActiveMinutes})
+	}
+	top := xlsxSheet{
+		name:   "Keys",
+		widths: []float64{16, 10, 10},
+		rows:   [][]interface{}{{"Key", "Presses", "Share"}},
+	}
+	for _, k := range r.Keys {
+		top.rows = append(top.rows, []interface{}{k.Key, k.Presses, xlsxPercent(float64(k.Presses) / float64(keys))})
+	}
+	sessions := xlsxSheet{
+		name:   "Focus sessions",
+		widths: []float64{12, 10, 10, 10, 30, 10, 10},
+		rows:   [][]interface{}{{"Date", "Start", "End", "Minutes", "Application", "Keys", "Switches"}},
+	}
+	for _, s := range r.Sessions {
+		name := s.App
+		if name == "" {
+			name = "(unknown)"
+		}
+		minutes := int(s.Duration().Round(time.Minute) / time.Minute)
+		sessions.rows = append(sessions.rows, []interface{}{xlsxDate(s.Start), xlsxClock(s.Start), xlsxClock(s.End), minutes, name, s.Keys, s.Switches})
+	}
+	return writeXLSX(w, []xlsxSheet{days, apps, top, sessions})
+}
+
+/*
+	A worksheet: column widths in characters and rows of string, int,
+	xlsxDate, xlsxClock or xlsxPercent cells, the first row the header.
+*/
+type xlsxSheet struct {
+	name   string
+	widths []float64
+	rows   [][]interface{}
+}
+
+type (
+	xlsxDate    time.Time
+	xlsxClock   time.Time
+	xlsxPercent float64
+)
+
+/*
+	Cell styles, indexes into cellXfs of xlsxStyles.
+*/
+const (
+	xlsxStyleHeader  = 1
+	xlsxStyleDate    = 2
+	xlsxStyleClock   = 3
+	xlsxStylePercent = 4
+)
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/><numFmt numFmtId="165" formatCode="hh:mm"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="5">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="10" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+</cellXfs>
+</styleSheet>
+`
+
+/*
+	Writes a workbook of sheets: the parts of the Office Open XML package
+	Excel and LibreOffice need, and no more.
+*/
+func writeXLSX(w io.Writer, sheets []xlsxSheet) error {
+	z := zip.NewWriter(w)
+	part := func(name, content string) error {
+		f, err := z.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, content)
+		return err
+	}
+	const header = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+	types := header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`
+	workbook := header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`
+	rels := header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`
+	for i, s := range sheets {
+		types += fmt.Sprintf(`<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		workbook += fmt.Sprintf(`<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(s.name), i+1, i+1)
+		rels += fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	types += `</Types>`
+	workbook += `</sheets></workbook>`
+	rels += fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+	rels += `</Relationships>`
+	root := header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	for _, p := range []struct{ name, content string }{
+		{"[Content_Types].xml", types},
+		{"_rels/.rels", root},
+		{"xl/workbook.xml", workbook},
+		{"xl/_rels/workbook.xml.rels", rels},
+		{"xl/styles.xml", xlsxStyles},
+	} {
+		if err := part(p.name, p.content); err != nil {
+			return err
+		}
+	}
+	for i, s := range sheets {
+		f, err := z.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+		if err := s.write(f); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+func (s *xlsxSheet) write(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	if len(s.widths) > 0 {
+		b.WriteString(`<cols>`)
+		for i, width := range s.widths {
+			fmt.Fprintf(b, `<col min="%d" max="%d" width="%g" customWidth="1"/>`, i+1, i+1, width)
+		}
+		b.WriteString(`</cols>`)
+	}
+	b.WriteString(`<sheetData>`)
+	for i, row := range s.rows {
+		fmt.Fprintf(b, `<row r="%d">`, i+1)
+		for j, cell := range row {
+			ref := xlsxColumn(j) + strconv.Itoa(i+1)
+			style := 0
+			if i == 0 {
+				style = xlsxStyleHeader
+			}
+			switch v := cell.(type) {
+			case string:
+				fmt.Fprintf(b, `<c r="%s" s="%d" t="inlineStr"><is><t>%s</t></is></c>`, ref, style, xmlEscape(v))
+			case int:
+				fmt.Fprintf(b, `<c r="%s" s="%d"><v>%d</v></c>`, ref, style, v)
+			case xlsxDate:
+				fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleDate, xlsxSerial(time.Time(v)))
+			case xlsxClock:
+				fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleClock, xlsxSerial(time.Time(v)))
+			case xlsxPercent:
+				fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStylePercent, strconv.FormatFloat(float64(v), 'g', -1, 64))
+			default:
+				return fmt.Errorf("xlsx: cell of type %T", cell)
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.Flush()
+}
+
+/*
+	Returns the name of the column at index i: A to Z, then AA.
+*/
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+/*
+	Returns the spreadsheet date of t: days since 1899-12-30 in local time,
+	the time of day as the fraction.
+*/
+func xlsxSerial(t time.Time) string {
+	t = t.Local()
+	_, offset := t.Zone()
+	days := float64(t.Unix()+int64(offset))/86400 + 25569
+	return strconv.FormatFloat(days, 'f', 6, 64)
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,166 @@
+package keylogger
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type xlsxTestCell struct {
+	Ref   string `xml:"r,attr"`
+	Style int    `xml:"s,attr"`
+	Type  string `xml:"t,attr"`
+	Text  string `xml:"is>t"`
+	Value string `xml:"v"`
+}
+
+type xlsxTestSheet struct {
+	Rows []struct {
+		R     int            `xml:"r,attr"`
+		Cells []xlsxTestCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+/*
+	A written workbook opens as a zip of the package parts, with the
+	sheets named in the workbook, strings escaped and times as spreadsheet
+	date serials.
+*/
+func TestUsageWorkbook(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	r := UsageReport{
+		Days: []DayUsage{{Date: day, Keys: 120, Clicks: 8, ActiveMinutes: 42, First: day.Add(9*time.Hour + 30*time.Minute), Last: day.Add(18 * time.Hour)}},
+		Apps: []AppUsage{{App: `<Tom & "Jerry">.exe`, Keys: 90, Clicks: 2, ActiveMinutes: 30}, {Keys: 30}},
+		Keys: []KeyUsage{{Key: "&", Presses: 3}, {Key: "A", Presses: 1}},
+		Sessions: []FocusSession{
+			{App: "code.exe", Start: day.Add(10 * time.Hour), End: day.Add(10*time.Hour + 45*time.Minute + 20*time.Second), Keys: 800, Switches: 1},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteUsageWorkbook(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]string{}
+	var names []string
+	for _, f := range z.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(data)
+		names = append(names, f.Name)
+	}
+	wantNames := []string{
+		"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml",
+		"xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml", "xl/worksheets/sheet3.xml", "xl/worksheets/sheet4.xml",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("parts %v, want %v", names, wantNames)
+	}
+	for name, content := range parts {
+		if err := xml.Unmarshal([]byte(content), new(struct{})); err != nil {
+			t.Errorf("%s is not XML: %v", name, err)
+		}
+	}
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal([]byte(parts["xl/workbook.xml"]), &workbook); err != nil {
+		t.Fatal(err)
+	}
+	var sheetNames []string
+	for _, s := range workbook.Sheets {
+		sheetNames = append(sheetNames, s.Name)
+	}
+	if want := []string{"Days", "Applications", "Keys", "Focus sessions"}; !reflect.DeepEqual(sheetNames, want) {
+		t.Errorf("sheets %v, want %v", sheetNames, want)
+	}
+
+	sheet := func(n int) [][]xlsxTestCell {
+		var s xlsxTestSheet
+		if err := xml.Unmarshal([]byte(parts["xl/worksheets/sheet"+string(rune('0'+n))+".xml"]), &s); err != nil {
+			t.Fatal(err)
+		}
+		var rows [][]xlsxTestCell
+		for i, row := range s.Rows {
+			if row.R != i+1 {
+				t.Errorf("sheet %d: row %d numbered %d", n, i+1, row.R)
+			}
+			rows = append(rows, row.Cells)
+		}
+		return rows
+	}
+
+	days := sheet(1)
+	if len(days) != 2 {
+		t.Fatalf("%d rows of days", len(days))
+	}
+	if h := days[0][0]; h.Ref != "A1" || h.Style != xlsxStyleHeader || h.Type != "inlineStr" || h.Text != "Date" {
+		t.Errorf("header cell %+v", h)
+	}
+	wantDay := []xlsxTestCell{
+		{Ref: "A2", Style: xlsxStyleDate, Value: "45352.000000"},
+		{Ref: "B2", Value: "120"},
+		{Ref: "C2", Value: "8"},
+		{Ref: "D2", Value: "42"},
+		{Ref: "E2", Style: xlsxStyleClock, Value: "45352.395833"},
+		{Ref: "F2", Style: xlsxStyleClock, Value: "45352.750000"},
+	}
+	if !reflect.DeepEqual(days[1], wantDay) {
+		t.Errorf("day row\n%+v\nwant\n%+v", days[1], wantDay)
+	}
+
+	apps := sheet(2)
+	if c := apps[1][0]; c.Type != "inlineStr" || c.Text != `<Tom & "Jerry">.exe` {
+		t.Errorf("application cell %+v", c)
+	}
+	if !strings.Contains(parts["xl/worksheets/sheet2.xml"], "&lt;Tom &amp; &#34;Jerry&#34;&gt;.exe") {
+		t.Error("application name not escaped")
+	}
+	if c := apps[2][0]; c.Text != "(unknown)" {
+		t.Errorf("application without a name written as %+v", c)
+	}
+
+	keys := sheet(3)
+	if c := keys[1]; c[0].Text != "&" || c[2].Style != xlsxStylePercent || c[2].Value != "0.75" {
+		t.Errorf("key row %+v", c)
+	}
+
+	sessions := sheet(4)
+	wantSession := []xlsxTestCell{
+		{Ref: "A2", Style: xlsxStyleDate, Value: "45352.416667"},
+		{Ref: "B2", Style: xlsxStyleClock, Value: "45352.416667"},
+		{Ref: "C2", Style: xlsxStyleClock, Value: "45352.448148"},
+		{Ref: "D2", Value: "45"},
+		{Ref: "E2", Type: "inlineStr", Text: "code.exe"},
+		{Ref: "F2", Value: "800"},
+		{Ref: "G2", Value: "1"},
+	}
+	if !reflect.DeepEqual(sessions[1], wantSession) {
+		t.Errorf("session row\n%+v\nwant\n%+v", sessions[1], wantSession)
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumn(i); got != want {
+			t.Errorf("column %d is %s, want %s", i, got, want)
+		}
+	}
+}